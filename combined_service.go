@@ -1,15 +1,21 @@
 package main
 
 import (
+	"github.com/krelinga/video-manager/internal/services/artwork"
 	"github.com/krelinga/video-manager/internal/services/catalog"
+	"github.com/krelinga/video-manager/internal/services/export"
 	"github.com/krelinga/video-manager/internal/services/inbox"
 	"github.com/krelinga/video-manager/internal/services/media"
+	"github.com/krelinga/video-manager/internal/services/task"
 	"github.com/krelinga/video-manager/internal/services/tmdb"
 )
 
 type CombinedService struct {
+	*artwork.ArtworkService
 	*catalog.CatalogService
+	*export.ExportService
 	*inbox.InboxService
 	*media.MediaService
 	*tmdb.TMDbService
+	*task.TaskService
 }
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 )
 
 var (
@@ -22,6 +23,29 @@ const (
 	EnvPostgresPassword = "VIDEO_MANAGER_POSTGRES_PASSWORD"
 	EnvRootDir          = "VIDEO_MANAGER_ROOT_DIR"
 	EnvWorkerGoroutines = "VIDEO_MANAGER_WORKER_GOROUTINES"
+	EnvTmdbApiKey       = "VIDEO_MANAGER_TMDB_API_KEY"
+	EnvTmdbBaseUrl      = "VIDEO_MANAGER_TMDB_BASE_URL"
+	EnvShutdownTimeout  = "VIDEO_MANAGER_SHUTDOWN_TIMEOUT"
+	EnvLogFormat        = "VIDEO_MANAGER_LOG_FORMAT"
+
+	EnvInboxWatch             = "VIDEO_MANAGER_INBOX_WATCH"
+	EnvInboxWatchQuietPeriod  = "VIDEO_MANAGER_INBOX_WATCH_QUIET_PERIOD"
+	EnvInboxWatchPollInterval = "VIDEO_MANAGER_INBOX_WATCH_POLL_INTERVAL"
+
+	EnvExportDir      = "VIDEO_MANAGER_EXPORT_DIR"
+	EnvExportLinkMode = "VIDEO_MANAGER_EXPORT_LINK_MODE"
+)
+
+// Supported values for Config.LogFormat.
+const (
+	LogFormatText = "text"
+	LogFormatJson = "json"
+)
+
+// Supported values for Export.LinkMode.
+const (
+	LinkModeSymlink  = "symlink"
+	LinkModeHardlink = "hardlink"
 )
 
 type Config struct {
@@ -29,6 +53,14 @@ type Config struct {
 	HttpPort         int
 	Postgres         *Postgres
 	WorkerGoroutines int
+	Tmdb             *Tmdb
+	// ShutdownTimeout bounds how long the server waits for in-flight requests
+	// and tasks to finish after receiving SIGTERM or SIGINT.
+	ShutdownTimeout time.Duration
+	// LogFormat is either LogFormatText or LogFormatJson.
+	LogFormat  string
+	InboxWatch *InboxWatch
+	Export     *Export
 }
 
 type Postgres struct {
@@ -49,6 +81,36 @@ func (p *Postgres) URL() string {
 	)
 }
 
+// Tmdb holds the settings needed to talk to The Movie Database API.
+// ApiKey may be empty, in which case TMDb lookups are unavailable.
+type Tmdb struct {
+	ApiKey  string
+	BaseUrl string
+}
+
+// InboxWatch controls the watcher that creates media for DVDs as they are
+// added to the inbox.
+type InboxWatch struct {
+	Enabled bool
+	// QuietPeriod is how long a DVD directory must go without changes before
+	// it is considered complete.
+	QuietPeriod time.Duration
+	// PollInterval is how often the inbox is scanned when inotify is not
+	// available.  It should be shorter than QuietPeriod.
+	PollInterval time.Duration
+}
+
+// Export controls where the library is exported for media players such as
+// Kodi and Jellyfin.
+type Export struct {
+	// Dir is the absolute path of the exported library.  If empty, the
+	// library is not exported.
+	Dir string
+	// LinkMode is either LinkModeSymlink or LinkModeHardlink.  Hard links need
+	// Dir to be on the same filesystem as the media.
+	LinkMode string
+}
+
 func New() *Config {
 	return &Config{
 		Paths: Paths{
@@ -63,6 +125,21 @@ func New() *Config {
 			Password: getRequiredVar(EnvPostgresPassword),
 			DBName:   getRequiredVar(EnvPostgresDBName),
 		},
+		Tmdb: &Tmdb{
+			ApiKey:  getVarWithDefault(EnvTmdbApiKey, ""),
+			BaseUrl: getVarWithDefault(EnvTmdbBaseUrl, "https://api.themoviedb.org/3"),
+		},
+		ShutdownTimeout: parseDuration(getVarWithDefault(EnvShutdownTimeout, "30s")),
+		LogFormat:       parseLogFormat(getVarWithDefault(EnvLogFormat, LogFormatText)),
+		InboxWatch: &InboxWatch{
+			Enabled:      parseBool(getVarWithDefault(EnvInboxWatch, "false")),
+			QuietPeriod:  parseDuration(getVarWithDefault(EnvInboxWatchQuietPeriod, "1m")),
+			PollInterval: parseDuration(getVarWithDefault(EnvInboxWatchPollInterval, "10s")),
+		},
+		Export: &Export{
+			Dir:      getVarWithDefault(EnvExportDir, ""),
+			LinkMode: parseLinkMode(getVarWithDefault(EnvExportLinkMode, LinkModeSymlink)),
+		},
 	}
 }
 
@@ -91,6 +168,42 @@ func parseInt(s string) int {
 	return i
 }
 
+func parseBool(s string) bool {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		panic(fmt.Errorf("%w: could not parse %q as bool", ErrMalformedEnvVar, s))
+	}
+
+	return b
+}
+
+func parseDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		panic(fmt.Errorf("%w: could not parse %q as duration", ErrMalformedEnvVar, s))
+	}
+
+	return d
+}
+
+func parseLogFormat(s string) string {
+	switch s {
+	case LogFormatText, LogFormatJson:
+		return s
+	default:
+		panic(fmt.Errorf("%w: unknown log format %q", ErrMalformedEnvVar, s))
+	}
+}
+
+func parseLinkMode(s string) string {
+	switch s {
+	case LinkModeSymlink, LinkModeHardlink:
+		return s
+	default:
+		panic(fmt.Errorf("%w: unknown link mode %q", ErrMalformedEnvVar, s))
+	}
+}
+
 type PathKind bool
 
 const (
@@ -102,13 +215,24 @@ type Paths struct {
 	RootDir string
 }
 
+// Returns the relative paths of all directories that must exist for the
+// service to work.
+func (p Paths) Dirs() []string {
+	return []string{
+		p.InboxDvd(PathKindRelative),
+		p.MediaDvd(PathKindRelative),
+		p.InboxBluray(PathKindRelative),
+		p.MediaBluray(PathKindRelative),
+		p.InboxFile(PathKindRelative),
+		p.MediaFile(PathKindRelative),
+		p.Artwork(PathKindRelative),
+	}
+}
+
 // Makes sure that all necessary directories exist.
 func (p Paths) Bootstrap() error {
-	necessary := []string{
-		p.InboxDvd(PathKindAbsolute),
-		p.MediaDvd(PathKindAbsolute),
-	}
-	for _, dir := range necessary {
+	for _, rel := range p.Dirs() {
+		dir := p.Absolute(rel)
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
@@ -147,3 +271,85 @@ func (p Paths) MediaDvd(pk PathKind) string {
 func (p Paths) MediaDvdId(pk PathKind, mediaId uint32) string {
 	return p.makePath(pk, "media", "dvd", fmt.Sprintf("%d", mediaId))
 }
+
+// Returns the path to the directory that a DVD is copied into before it is
+// moved to MediaDvdId.  This is only used when the inbox and media directories
+// are on different filesystems.
+func (p Paths) MediaDvdIdStaging(pk PathKind, mediaId uint32) string {
+	return p.makePath(pk, "media", "dvd", fmt.Sprintf("%d.partial", mediaId))
+}
+
+// Returns the path to the inbox Blu-ray directory.
+// Entries under this directory represent Blu-rays that have not been imported yet.
+func (p Paths) InboxBluray(pk PathKind) string {
+	return p.makePath(pk, "inbox", "bluray")
+}
+
+// Returns the path to the directory that contains a specific inbox Blu-ray.
+func (p Paths) InboxBlurayName(pk PathKind, name string) string {
+	return p.makePath(pk, "inbox", "bluray", name)
+}
+
+// Returns the path to the media directory that contains all imported Blu-rays.
+func (p Paths) MediaBluray(pk PathKind) string {
+	return p.makePath(pk, "media", "bluray")
+}
+
+// Returns the path to the directory that contains a specific imported Blu-ray.
+func (p Paths) MediaBlurayId(pk PathKind, mediaId uint32) string {
+	return p.makePath(pk, "media", "bluray", fmt.Sprintf("%d", mediaId))
+}
+
+// Returns the path to the directory that a Blu-ray is copied into before it is
+// moved to MediaBlurayId.  This is only used when the inbox and media
+// directories are on different filesystems.
+func (p Paths) MediaBlurayIdStaging(pk PathKind, mediaId uint32) string {
+	return p.makePath(pk, "media", "bluray", fmt.Sprintf("%d.partial", mediaId))
+}
+
+// Returns the path to the inbox file directory.
+// Entries under this directory are single video files, such as MKV or MP4
+// files, that have not been imported yet.
+func (p Paths) InboxFile(pk PathKind) string {
+	return p.makePath(pk, "inbox", "file")
+}
+
+// Returns the path to a specific inbox video file.
+func (p Paths) InboxFileName(pk PathKind, name string) string {
+	return p.makePath(pk, "inbox", "file", name)
+}
+
+// Returns the path to the media directory that contains all imported video files.
+func (p Paths) MediaFile(pk PathKind) string {
+	return p.makePath(pk, "media", "file")
+}
+
+// Returns the path to the directory that contains a specific imported video
+// file, under its original name.
+func (p Paths) MediaFileId(pk PathKind, mediaId uint32) string {
+	return p.makePath(pk, "media", "file", fmt.Sprintf("%d", mediaId))
+}
+
+// Returns the path that a video file is copied to before it is moved into
+// MediaFileId.  This is only used when the inbox and media directories are on
+// different filesystems.
+func (p Paths) MediaFileIdStaging(pk PathKind, mediaId uint32) string {
+	return p.makePath(pk, "media", "file", fmt.Sprintf("%d.partial", mediaId))
+}
+
+// Returns the path to the directory that contains all downloaded artwork.
+func (p Paths) Artwork(pk PathKind) string {
+	return p.makePath(pk, "artwork")
+}
+
+// Returns the path to the directory that contains one image of a card, such
+// as its poster, along with the thumbnails made from it.
+func (p Paths) ArtworkId(pk PathKind, cardId uint32, kind string) string {
+	return p.makePath(pk, "artwork", fmt.Sprintf("%d", cardId), kind)
+}
+
+// Returns the path to the directory that an image and its thumbnails are
+// written to before they replace ArtworkId.
+func (p Paths) ArtworkIdStaging(pk PathKind, cardId uint32, kind string) string {
+	return p.makePath(pk, "artwork", fmt.Sprintf("%d", cardId), kind+".partial")
+}
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/krelinga/go-libs/deep"
 	"github.com/krelinga/go-libs/exam"
@@ -46,6 +47,119 @@ func TestNew(t *testing.T) {
 		})
 	})
 
+	e.Run("tmdb defaults", func(e exam.E) {
+		cfg := config.New()
+		expectedTmdb := &config.Tmdb{
+			ApiKey:  "",
+			BaseUrl: "https://api.themoviedb.org/3",
+		}
+		exam.Equal(e, env, expectedTmdb, cfg.Tmdb)
+	})
+
+	e.Run("override tmdb settings", func(e exam.E) {
+		exam.SetEnv(e, config.EnvTmdbApiKey, "secret")
+		exam.SetEnv(e, config.EnvTmdbBaseUrl, "http://localhost:1234/3")
+		cfg := config.New()
+		expectedTmdb := &config.Tmdb{
+			ApiKey:  "secret",
+			BaseUrl: "http://localhost:1234/3",
+		}
+		exam.Equal(e, env, expectedTmdb, cfg.Tmdb)
+	})
+
+	e.Run("shutdown timeout default", func(e exam.E) {
+		cfg := config.New()
+		exam.Equal(e, env, 30*time.Second, cfg.ShutdownTimeout)
+	})
+
+	e.Run("override shutdown timeout", func(e exam.E) {
+		exam.SetEnv(e, config.EnvShutdownTimeout, "2m")
+		cfg := config.New()
+		exam.Equal(e, env, 2*time.Minute, cfg.ShutdownTimeout)
+	})
+
+	e.Run("malformed shutdown timeout", func(e exam.E) {
+		exam.SetEnv(e, config.EnvShutdownTimeout, "soon")
+		exam.PanicWith(e, env, match.As[error](match.ErrorIs(config.ErrMalformedEnvVar)), func() {
+			config.New()
+		})
+	})
+
+	e.Run("log format default", func(e exam.E) {
+		cfg := config.New()
+		exam.Equal(e, env, config.LogFormatText, cfg.LogFormat)
+	})
+
+	e.Run("override log format", func(e exam.E) {
+		exam.SetEnv(e, config.EnvLogFormat, config.LogFormatJson)
+		cfg := config.New()
+		exam.Equal(e, env, config.LogFormatJson, cfg.LogFormat)
+	})
+
+	e.Run("malformed log format", func(e exam.E) {
+		exam.SetEnv(e, config.EnvLogFormat, "xml")
+		exam.PanicWith(e, env, match.As[error](match.ErrorIs(config.ErrMalformedEnvVar)), func() {
+			config.New()
+		})
+	})
+
+	e.Run("inbox watch defaults", func(e exam.E) {
+		cfg := config.New()
+		expectedWatch := &config.InboxWatch{
+			Enabled:      false,
+			QuietPeriod:  time.Minute,
+			PollInterval: 10 * time.Second,
+		}
+		exam.Equal(e, env, expectedWatch, cfg.InboxWatch)
+	})
+
+	e.Run("override inbox watch settings", func(e exam.E) {
+		exam.SetEnv(e, config.EnvInboxWatch, "true")
+		exam.SetEnv(e, config.EnvInboxWatchQuietPeriod, "5m")
+		exam.SetEnv(e, config.EnvInboxWatchPollInterval, "30s")
+		cfg := config.New()
+		expectedWatch := &config.InboxWatch{
+			Enabled:      true,
+			QuietPeriod:  5 * time.Minute,
+			PollInterval: 30 * time.Second,
+		}
+		exam.Equal(e, env, expectedWatch, cfg.InboxWatch)
+	})
+
+	e.Run("malformed inbox watch", func(e exam.E) {
+		exam.SetEnv(e, config.EnvInboxWatch, "sometimes")
+		exam.PanicWith(e, env, match.As[error](match.ErrorIs(config.ErrMalformedEnvVar)), func() {
+			config.New()
+		})
+	})
+
+	e.Run("export defaults", func(e exam.E) {
+		cfg := config.New()
+		expectedExport := &config.Export{
+			Dir:      "",
+			LinkMode: config.LinkModeSymlink,
+		}
+		exam.Equal(e, env, expectedExport, cfg.Export)
+	})
+
+	e.Run("override export settings", func(e exam.E) {
+		exam.SetEnv(e, config.EnvExportDir, "/library")
+		exam.SetEnv(e, config.EnvExportLinkMode, config.LinkModeHardlink)
+		cfg := config.New()
+		expectedExport := &config.Export{
+			Dir:      "/library",
+			LinkMode: config.LinkModeHardlink,
+		}
+		exam.Equal(e, env, expectedExport, cfg.Export)
+	})
+
+	e.Run("malformed export link mode", func(e exam.E) {
+		exam.SetEnv(e, config.EnvExportLinkMode, "copy")
+		exam.PanicWith(e, env, match.As[error](match.ErrorIs(config.ErrMalformedEnvVar)), func() {
+			config.New()
+		})
+	})
+
 	e.Run("required vars missing", func(e exam.E) {
 		tests := []string{
 			config.EnvPostgresHost,
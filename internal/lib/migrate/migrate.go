@@ -1,12 +1,16 @@
 package migrate
 
 import (
+	"context"
 	"embed"
 	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
+	"strings"
 
 	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -19,8 +23,11 @@ var migrationsFS embed.FS
 
 var Err = errors.New("migration error")
 
-type logger struct {
-	*log.Logger
+// logger adapts slog to the migrate.Logger interface.
+type logger struct{}
+
+func (l logger) Printf(format string, v ...any) {
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
 }
 
 func (l logger) Verbose() bool {
@@ -36,7 +43,7 @@ func setup(cfg *config.Postgres) (*migrate.Migrate, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%w: failed to create migrate instance: %w", Err, err)
 	}
-	m.Log = logger{Logger: log.Default()}
+	m.Log = logger{}
 	return m, nil
 }
 
@@ -46,13 +53,13 @@ func Up(cfg *config.Postgres) error {
 		return err
 	}
 	defer m.Close()
-	log.Println("Starting database UP migrations...")
+	slog.Info("Starting database UP migrations")
 	err = m.Up()
 	if err != nil && err != migrate.ErrNoChange {
 		return fmt.Errorf("%w: migration failed: %w", Err, err)
 	}
 
-	log.Println("Database UP migrations completed successfully.")
+	slog.Info("Database UP migrations completed successfully")
 	return nil
 }
 
@@ -62,12 +69,53 @@ func Down(cfg *config.Postgres) error {
 		return err
 	}
 	defer m.Close()
-	log.Println("Starting database DOWN migrations...")
+	slog.Info("Starting database DOWN migrations")
 	err = m.Down()
 	if err != nil && err != migrate.ErrNoChange {
 		return fmt.Errorf("%w: migration failed: %w", Err, err)
 	}
 
-	log.Println("Database DOWN migrations completed successfully.")
+	slog.Info("Database DOWN migrations completed successfully")
 	return nil
 }
+
+// Latest returns the version of the newest embedded migration.
+func Latest() (uint, error) {
+	d, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to create iofs source: %w", Err, err)
+	}
+	defer d.Close()
+
+	version, err := d.First()
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to read first migration: %w", Err, err)
+	}
+	for {
+		next, err := d.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("%w: failed to read migration after %d: %w", Err, version, err)
+		}
+		version = next
+	}
+}
+
+// Version returns the migration version currently applied to the database, and
+// whether a migration failed partway through and left the schema dirty.
+// Returns a version of zero if no migrations have been applied.
+func Version(ctx context.Context, db vmdb.Runner) (uint, bool, error) {
+	type row struct {
+		Version uint
+		Dirty   bool
+	}
+	const sql = "SELECT version, dirty FROM schema_migrations LIMIT 1"
+	r, err := vmdb.QueryOne[row](ctx, db, vmdb.Constant(sql))
+	if errors.Is(err, vmdb.ErrNotFound) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("%w: failed to read schema version: %w", Err, err)
+	}
+	return r.Version, r.Dirty, nil
+}
@@ -0,0 +1,34 @@
+package migrate_test
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/video-manager/internal/lib/migrate"
+)
+
+func TestLatest(t *testing.T) {
+	entries, err := os.ReadDir("migrations")
+	if err != nil {
+		t.Fatalf("failed to read migrations: %v", err)
+	}
+	var want uint
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.ParseUint(prefix, 10, 32)
+		if err != nil {
+			t.Fatalf("bad migration file name %q: %v", entry.Name(), err)
+		}
+		want = max(want, uint(version))
+	}
+
+	got, err := migrate.Latest()
+	if err != nil {
+		t.Fatalf("Latest() error: %v", err)
+	}
+	if got != want {
+		t.Fatalf("Latest() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tasks_pending_run_after;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS run_after;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,9 @@
+-- Track how many times a task has failed with a retryable error.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0 CHECK (attempts >= 0);
+
+-- Tasks are not claimed before run_after; used to delay retries.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS run_after TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- Index for finding the next pending task that is due.
+CREATE INDEX IF NOT EXISTS idx_tasks_pending_run_after ON tasks (run_after)
+WHERE status = 'pending';
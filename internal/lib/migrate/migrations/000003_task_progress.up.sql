@@ -0,0 +1,2 @@
+-- Progress published by a running task, e.g. bytes copied so far.
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS progress JSONB;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_tasks_file_ingestion_media_id;
+DROP INDEX IF EXISTS idx_tasks_bluray_ingestion_media_id;
+
+DROP TRIGGER IF EXISTS trg_delete_file_media ON media_files;
+DROP FUNCTION IF EXISTS delete_file_media();
+DROP TRIGGER IF EXISTS trg_delete_bluray_media ON media_blurays;
+DROP FUNCTION IF EXISTS delete_bluray_media();
+
+DROP TABLE IF EXISTS media_files;
+DROP TABLE IF EXISTS media_blurays;
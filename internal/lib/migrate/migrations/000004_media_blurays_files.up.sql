@@ -0,0 +1,55 @@
+-- Blu-rays, stored as a BDMV folder or an ISO image.
+CREATE TABLE IF NOT EXISTS media_blurays (
+    media_id INTEGER PRIMARY KEY,
+    path TEXT NOT NULL CHECK (path <> ''),
+    CONSTRAINT fk_media_blurays_media_id
+        FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+-- Single video files, such as MKV or MP4 files.
+CREATE TABLE IF NOT EXISTS media_files (
+    media_id INTEGER PRIMARY KEY,
+    path TEXT NOT NULL CHECK (path <> ''),
+    CONSTRAINT fk_media_files_media_id
+        FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+-- Like media_dvds, deleting the details deletes the media record.
+CREATE OR REPLACE FUNCTION delete_bluray_media()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM media WHERE id = OLD.media_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_delete_bluray_media ON media_blurays;
+
+CREATE TRIGGER trg_delete_bluray_media
+AFTER DELETE ON media_blurays
+FOR EACH ROW
+EXECUTE FUNCTION delete_bluray_media();
+
+CREATE OR REPLACE FUNCTION delete_file_media()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM media WHERE id = OLD.media_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_delete_file_media ON media_files;
+
+CREATE TRIGGER trg_delete_file_media
+AFTER DELETE ON media_files
+FOR EACH ROW
+EXECUTE FUNCTION delete_file_media();
+
+-- Add indexes for looking up bluray_ingestion and file_ingestion tasks by media_id
+CREATE INDEX IF NOT EXISTS idx_tasks_bluray_ingestion_media_id
+ON tasks (task_type, ((state->>'media_id')::integer))
+WHERE task_type = 'bluray_ingestion';
+
+CREATE INDEX IF NOT EXISTS idx_tasks_file_ingestion_media_id
+ON tasks (task_type, ((state->>'media_id')::integer))
+WHERE task_type = 'file_ingestion';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS media_dvd_title_subtitles;
+DROP TABLE IF EXISTS media_dvd_title_audio;
+DROP TABLE IF EXISTS media_dvd_titles;
@@ -0,0 +1,38 @@
+-- The titles of a DVD, as read from its IFO files.
+CREATE TABLE IF NOT EXISTS media_dvd_titles (
+    media_id INTEGER NOT NULL,
+    title_number INTEGER NOT NULL,
+    title_set INTEGER NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    chapters INTEGER NOT NULL,
+    angles INTEGER NOT NULL,
+    PRIMARY KEY (media_id, title_number),
+    CONSTRAINT fk_media_dvd_titles_media_id
+        FOREIGN KEY (media_id) REFERENCES media_dvds(media_id) ON DELETE CASCADE
+);
+
+-- The audio streams of each DVD title, in stream order.
+CREATE TABLE IF NOT EXISTS media_dvd_title_audio (
+    media_id INTEGER NOT NULL,
+    title_number INTEGER NOT NULL,
+    stream_index INTEGER NOT NULL,
+    -- Empty if the disc doesn't say.
+    language TEXT NOT NULL,
+    codec TEXT NOT NULL,
+    channels INTEGER NOT NULL,
+    PRIMARY KEY (media_id, title_number, stream_index),
+    CONSTRAINT fk_media_dvd_title_audio_title
+        FOREIGN KEY (media_id, title_number) REFERENCES media_dvd_titles(media_id, title_number) ON DELETE CASCADE
+);
+
+-- The subtitle streams of each DVD title, in stream order.
+CREATE TABLE IF NOT EXISTS media_dvd_title_subtitles (
+    media_id INTEGER NOT NULL,
+    title_number INTEGER NOT NULL,
+    stream_index INTEGER NOT NULL,
+    -- Empty if the disc doesn't say.
+    language TEXT NOT NULL,
+    PRIMARY KEY (media_id, title_number, stream_index),
+    CONSTRAINT fk_media_dvd_title_subtitles_title
+        FOREIGN KEY (media_id, title_number) REFERENCES media_dvd_titles(media_id, title_number) ON DELETE CASCADE
+);
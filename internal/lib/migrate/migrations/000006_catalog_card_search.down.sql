@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_catalog_cards_note_trgm;
+DROP INDEX IF EXISTS idx_catalog_cards_name_trgm;
+DROP INDEX IF EXISTS idx_catalog_cards_search;
+ALTER TABLE catalog_cards DROP COLUMN IF EXISTS search;
+DROP EXTENSION IF EXISTS pg_trgm;
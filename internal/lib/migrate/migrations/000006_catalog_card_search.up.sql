@@ -0,0 +1,14 @@
+-- Trigram matching makes card search tolerant of typos.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The words of each card's name and note, for full-text search.  Words in the
+-- name count for more than words in the note.
+ALTER TABLE catalog_cards ADD COLUMN IF NOT EXISTS search tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', name), 'A') ||
+        setweight(to_tsvector('english', COALESCE(note, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_catalog_cards_search ON catalog_cards USING GIN (search);
+CREATE INDEX IF NOT EXISTS idx_catalog_cards_name_trgm ON catalog_cards USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_catalog_cards_note_trgm ON catalog_cards USING GIN (note gin_trgm_ops);
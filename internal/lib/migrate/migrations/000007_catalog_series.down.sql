@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS trg_delete_episode_card ON catalog_episodes;
+DROP FUNCTION IF EXISTS delete_episode_card();
+DROP TABLE IF EXISTS catalog_episodes;
+
+DROP TRIGGER IF EXISTS trg_delete_season_card ON catalog_seasons;
+DROP FUNCTION IF EXISTS delete_season_card();
+DROP TABLE IF EXISTS catalog_seasons;
+
+DROP TRIGGER IF EXISTS trg_delete_series_card ON catalog_series;
+DROP FUNCTION IF EXISTS delete_series_card();
+DROP TABLE IF EXISTS catalog_series;
@@ -0,0 +1,86 @@
+-- Create catalog_series table
+-- series are a kind of catalog_card, and so they share a primary key.
+CREATE TABLE IF NOT EXISTS catalog_series (
+    card_id INTEGER PRIMARY KEY,
+    first_air_year INTEGER,
+    tmdb_id INTEGER,
+    CONSTRAINT fk_catalog_series_card_id
+        FOREIGN KEY (card_id) REFERENCES catalog_cards(id) ON DELETE CASCADE
+);
+
+-- A trigger to ensure that the catalog_card for a series is deleted when the corresponding catalog_series is deleted.
+CREATE OR REPLACE FUNCTION delete_series_card()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM catalog_cards WHERE id = OLD.card_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_delete_series_card ON catalog_series;
+
+CREATE TRIGGER trg_delete_series_card
+AFTER DELETE ON catalog_series
+FOR EACH ROW
+EXECUTE FUNCTION delete_series_card();
+
+-- Create catalog_seasons table
+-- seasons are a kind of catalog_card, and so they share a primary key.
+-- The series_card_id field references the parent series's card_id, and must be set.
+-- Season 0 holds the specials of a series.
+CREATE TABLE IF NOT EXISTS catalog_seasons (
+    card_id INTEGER PRIMARY KEY,
+    series_card_id INTEGER NOT NULL,
+    season_number INTEGER NOT NULL CHECK (season_number >= 0),
+    CONSTRAINT fk_catalog_seasons_card_id
+        FOREIGN KEY (card_id) REFERENCES catalog_cards(id) ON DELETE CASCADE,
+    CONSTRAINT fk_catalog_seasons_series_card_id
+        FOREIGN KEY (series_card_id) REFERENCES catalog_series(card_id) ON DELETE CASCADE,
+    CONSTRAINT unique_catalog_seasons_number UNIQUE (series_card_id, season_number)
+);
+
+-- A trigger to ensure that the catalog_card for a season is deleted when the corresponding catalog_season is deleted.
+CREATE OR REPLACE FUNCTION delete_season_card()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM catalog_cards WHERE id = OLD.card_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_delete_season_card ON catalog_seasons;
+
+CREATE TRIGGER trg_delete_season_card
+AFTER DELETE ON catalog_seasons
+FOR EACH ROW
+EXECUTE FUNCTION delete_season_card();
+
+-- Create catalog_episodes table
+-- episodes are a kind of catalog_card, and so they share a primary key.
+-- The season_card_id field references the parent season's card_id, and must be set.
+CREATE TABLE IF NOT EXISTS catalog_episodes (
+    card_id INTEGER PRIMARY KEY,
+    season_card_id INTEGER NOT NULL,
+    episode_number INTEGER NOT NULL CHECK (episode_number > 0),
+    CONSTRAINT fk_catalog_episodes_card_id
+        FOREIGN KEY (card_id) REFERENCES catalog_cards(id) ON DELETE CASCADE,
+    CONSTRAINT fk_catalog_episodes_season_card_id
+        FOREIGN KEY (season_card_id) REFERENCES catalog_seasons(card_id) ON DELETE CASCADE,
+    CONSTRAINT unique_catalog_episodes_number UNIQUE (season_card_id, episode_number)
+);
+
+-- A trigger to ensure that the catalog_card for an episode is deleted when the corresponding catalog_episode is deleted.
+CREATE OR REPLACE FUNCTION delete_episode_card()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM catalog_cards WHERE id = OLD.card_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_delete_episode_card ON catalog_episodes;
+
+CREATE TRIGGER trg_delete_episode_card
+AFTER DELETE ON catalog_episodes
+FOR EACH ROW
+EXECUTE FUNCTION delete_episode_card();
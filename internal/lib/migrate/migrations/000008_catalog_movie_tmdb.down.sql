@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS catalog_movie_genres;
+
+ALTER TABLE catalog_movies
+    DROP COLUMN IF EXISTS release_year_from_tmdb,
+    DROP COLUMN IF EXISTS tmdb_refreshed_at,
+    DROP COLUMN IF EXISTS backdrop_path,
+    DROP COLUMN IF EXISTS poster_path,
+    DROP COLUMN IF EXISTS runtime_minutes,
+    DROP COLUMN IF EXISTS overview,
+    DROP COLUMN IF EXISTS title;
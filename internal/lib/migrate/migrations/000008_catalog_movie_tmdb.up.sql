@@ -0,0 +1,25 @@
+-- Details of a movie fetched from TMDb by its tmdb_id.  They are NULL until
+-- the movie has been refreshed, and whenever TMDb doesn't know them.
+ALTER TABLE catalog_movies
+    ADD COLUMN IF NOT EXISTS title TEXT,
+    ADD COLUMN IF NOT EXISTS overview TEXT,
+    ADD COLUMN IF NOT EXISTS runtime_minutes INTEGER,
+    ADD COLUMN IF NOT EXISTS poster_path TEXT,
+    ADD COLUMN IF NOT EXISTS backdrop_path TEXT,
+    ADD COLUMN IF NOT EXISTS tmdb_refreshed_at TIMESTAMPTZ;
+
+-- Whether release_year was filled in from TMDb rather than given by the user.
+-- Only such years are replaced when the movie is refreshed.
+ALTER TABLE catalog_movies
+    ADD COLUMN IF NOT EXISTS release_year_from_tmdb BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The TMDb genres of a movie, in the order that TMDb lists them.
+CREATE TABLE IF NOT EXISTS catalog_movie_genres (
+    card_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    tmdb_genre_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    PRIMARY KEY (card_id, position),
+    CONSTRAINT fk_catalog_movie_genres_card_id
+        FOREIGN KEY (card_id) REFERENCES catalog_movies(card_id) ON DELETE CASCADE
+);
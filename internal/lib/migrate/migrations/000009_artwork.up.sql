@@ -0,0 +1,18 @@
+-- Create artwork table
+-- Each row is an image of a card that has been downloaded to the artwork
+-- directory, along with its thumbnails.  A card has at most one image of each
+-- kind.
+CREATE TABLE IF NOT EXISTS artwork (
+    card_id INTEGER NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('poster', 'fanart')),
+    source_url TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    -- The SHA-256 of the original image, in hex.
+    sha256 TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    downloaded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (card_id, kind),
+    CONSTRAINT fk_artwork_card_id
+        FOREIGN KEY (card_id) REFERENCES catalog_cards(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+-- Create library_exports table
+-- Each row is a movie folder that the last library export wrote to the export
+-- directory.  The next export only rewrites folders whose fingerprint changed,
+-- and removes the folders of cards that are no longer exported.  There is no
+-- foreign key on card_id, since the row must outlive its card until the
+-- folder is removed.
+CREATE TABLE IF NOT EXISTS library_exports (
+    card_id INTEGER PRIMARY KEY,
+    dir_name TEXT NOT NULL CHECK (dir_name <> ''),
+    -- The SHA-256 of everything written to the folder, in hex.
+    fingerprint TEXT NOT NULL,
+    exported_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,64 @@
+package vmbody_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmbody"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc    exam.Loc
+		name   string
+		method string
+		wantOk bool
+	}{
+		{
+			loc:    exam.Here(),
+			name:   "post",
+			method: http.MethodPost,
+			wantOk: true,
+		},
+		{
+			loc:    exam.Here(),
+			name:   "patch",
+			method: http.MethodPatch,
+			wantOk: true,
+		},
+		{
+			loc:    exam.Here(),
+			name:   "get",
+			method: http.MethodGet,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			const body = `{"name": "n"}`
+			var gotKept []byte
+			var gotOk bool
+			var gotRead string
+			handler := vmbody.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The handler still sees the whole body.
+				read, err := io.ReadAll(r.Body)
+				exam.Nil(e, env, err).Log(err).Must()
+				gotRead = string(read)
+				gotKept, gotOk = vmbody.FromContext(r.Context())
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/", strings.NewReader(body)))
+			exam.Equal(e, env, gotRead, body).Log(tt.loc)
+			exam.Equal(e, env, gotOk, tt.wantOk).Log(tt.loc)
+			if tt.wantOk {
+				exam.Equal(e, env, string(gotKept), body).Log(tt.loc)
+			}
+		})
+	}
+}
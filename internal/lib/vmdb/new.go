@@ -39,6 +39,17 @@ func (p *pgxPoolDbRunner) Begin(ctx context.Context, options ...TxOption) (TxRun
 	return pgxTxRunner{tx: tx}, nil
 }
 
+func (p *pgxPoolDbRunner) Ping(ctx context.Context) error {
+	asPool := (*pgxpool.Pool)(p)
+	return handleError(asPool.Ping(ctx), vmerr.InternalError)
+}
+
+// Stat returns statistics about the underlying connection pool.
+func (p *pgxPoolDbRunner) Stat() *pgxpool.Stat {
+	asPool := (*pgxpool.Pool)(p)
+	return asPool.Stat()
+}
+
 func (p *pgxPoolDbRunner) Close() {
 	asPool := (*pgxpool.Pool)(p)
 	asPool.Close()
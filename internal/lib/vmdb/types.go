@@ -34,5 +34,7 @@ type TxRunner interface {
 type DbRunner interface {
 	Runner
 	Begin(ctx context.Context, options ...TxOption) (TxRunner, error)
+	// Ping checks that a connection to the database can be acquired and used.
+	Ping(ctx context.Context) error
 	Close()
 }
@@ -0,0 +1,41 @@
+package vmdisc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DetectBluray returns the format of the Blu-ray in dir, which holds either a
+// BDMV folder with an index.bdmv file, or an ISO image.  If dir is not a
+// Blu-ray, the error says why.
+func DetectBluray(dir string) (Format, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	bdmv := ""
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir() && strings.EqualFold(entry.Name(), "BDMV"):
+			bdmv = filepath.Join(dir, entry.Name())
+		case entry.Type().IsRegular() && hasExt(entry.Name(), ".iso"):
+			return FormatIso, nil
+		}
+	}
+	if bdmv == "" {
+		return "", errors.New("found neither a BDMV folder nor an ISO image")
+	}
+
+	entries, err = os.ReadDir(bdmv)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.EqualFold(entry.Name(), "index.bdmv") {
+			return FormatBdmv, nil
+		}
+	}
+	return "", errors.New("BDMV folder has no index.bdmv file")
+}
@@ -0,0 +1,64 @@
+package vmdisc_test
+
+import (
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+)
+
+func TestDetectBluray(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc     exam.Loc
+		name    string
+		files   []string
+		dirs    []string
+		want    vmdisc.Format
+		wantErr match.Matcher
+	}{
+		{
+			loc:     exam.Here(),
+			name:    "BDMV folder",
+			files:   []string{"BDMV/index.bdmv", "BDMV/STREAM/00000.m2ts"},
+			want:    vmdisc.FormatBdmv,
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "ISO image",
+			files:   []string{"movie.iso"},
+			want:    vmdisc.FormatIso,
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "empty",
+			wantErr: match.Not(match.Nil()),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "no index.bdmv",
+			files:   []string{"BDMV/STREAM/00000.m2ts"},
+			wantErr: match.Not(match.Nil()),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "DVD",
+			files:   []string{"VIDEO_TS/VIDEO_TS.IFO", "VIDEO_TS/VTS_01_1.VOB"},
+			wantErr: match.Not(match.Nil()),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			dir := makeTree(e, tt.dirs, tt.files)
+			got, err := vmdisc.DetectBluray(dir)
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+}
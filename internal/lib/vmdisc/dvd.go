@@ -0,0 +1,68 @@
+// Package vmdisc recognizes the on-disk layouts of ripped discs.
+package vmdisc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the layout of a ripped disc.
+type Format string
+
+const (
+	// FormatVideoTs is a DVD copied file by file, with a VIDEO_TS folder.
+	FormatVideoTs Format = "video_ts"
+	// FormatIso is a disc image.
+	FormatIso Format = "iso"
+	// FormatBdmv is a Blu-ray copied file by file, with a BDMV folder.
+	FormatBdmv Format = "bdmv"
+)
+
+// DetectDvd returns the format of the DVD in dir, which holds either a
+// VIDEO_TS folder with IFO and VOB files, or an ISO image.  If dir is not a
+// DVD, the error says why.  Names are matched case-insensitively, since not
+// every ripping tool preserves case.
+func DetectDvd(dir string) (Format, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	videoTs := ""
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir() && strings.EqualFold(entry.Name(), "VIDEO_TS"):
+			videoTs = filepath.Join(dir, entry.Name())
+		case entry.Type().IsRegular() && hasExt(entry.Name(), ".iso"):
+			return FormatIso, nil
+		}
+	}
+	if videoTs == "" {
+		return "", errors.New("found neither a VIDEO_TS folder nor an ISO image")
+	}
+
+	entries, err = os.ReadDir(videoTs)
+	if err != nil {
+		return "", err
+	}
+	var hasIfo, hasVob bool
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		hasIfo = hasIfo || hasExt(entry.Name(), ".ifo")
+		hasVob = hasVob || hasExt(entry.Name(), ".vob")
+	}
+	switch {
+	case !hasIfo:
+		return "", errors.New("VIDEO_TS folder has no IFO files")
+	case !hasVob:
+		return "", errors.New("VIDEO_TS folder has no VOB files")
+	}
+	return FormatVideoTs, nil
+}
+
+func hasExt(name, ext string) bool {
+	return strings.EqualFold(filepath.Ext(name), ext)
+}
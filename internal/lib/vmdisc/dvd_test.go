@@ -0,0 +1,106 @@
+package vmdisc_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+)
+
+func TestDetectDvd(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc     exam.Loc
+		name    string
+		files   []string
+		dirs    []string
+		want    vmdisc.Format
+		wantErr match.Matcher
+	}{
+		{
+			loc:     exam.Here(),
+			name:    "VIDEO_TS folder",
+			files:   []string{"VIDEO_TS/VIDEO_TS.IFO", "VIDEO_TS/VTS_01_1.VOB"},
+			want:    vmdisc.FormatVideoTs,
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "lower case names",
+			files:   []string{"video_ts/video_ts.ifo", "video_ts/vts_01_1.vob"},
+			want:    vmdisc.FormatVideoTs,
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "ISO image",
+			files:   []string{"movie.ISO"},
+			want:    vmdisc.FormatIso,
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "empty",
+			wantErr: match.Not(match.Nil()),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "empty VIDEO_TS folder",
+			dirs:    []string{"VIDEO_TS"},
+			wantErr: match.Not(match.Nil()),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "no VOB files",
+			files:   []string{"VIDEO_TS/VIDEO_TS.IFO"},
+			wantErr: match.Not(match.Nil()),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "no IFO files",
+			files:   []string{"VIDEO_TS/VTS_01_1.VOB"},
+			wantErr: match.Not(match.Nil()),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "ISO directory",
+			dirs:    []string{"movie.iso"},
+			wantErr: match.Not(match.Nil()),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			dir := makeTree(e, tt.dirs, tt.files)
+			got, err := vmdisc.DetectDvd(dir)
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+}
+
+// makeTree creates the given directories and files in a new temporary
+// directory, and returns its path.
+func makeTree(e exam.E, dirs, files []string) string {
+	dir := e.TempDir()
+	for _, d := range dirs {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			e.Fatalf("could not create directory: %v", err)
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			e.Fatalf("could not create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			e.Fatalf("could not write file: %v", err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,16 @@
+package vmdisc
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// VideoFileExts are the extensions of the video files that are accepted as
+// media on their own, in lower case.
+var VideoFileExts = []string{".avi", ".m2ts", ".m4v", ".mkv", ".mov", ".mp4", ".mpg", ".ts", ".webm", ".wmv"}
+
+// IsVideoFile reports whether name has one of VideoFileExts, in any case.
+func IsVideoFile(name string) bool {
+	return slices.Contains(VideoFileExts, strings.ToLower(filepath.Ext(name)))
+}
@@ -0,0 +1,29 @@
+package vmdisc_test
+
+import (
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+)
+
+func TestIsVideoFile(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := map[string]bool{
+		"movie.mkv":      true,
+		"movie.MP4":      true,
+		"show.s01e01.ts": true,
+		"movie.iso":      false,
+		"movie.srt":      false,
+		"movie":          false,
+		"mkv":            false,
+	}
+	for name, want := range tests {
+		e.Run(name, func(e exam.E) {
+			exam.Equal(e, env, vmdisc.IsVideoFile(name), want)
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package vmdisc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidIfo is wrapped by the errors that ParseDvd returns for IFO files
+// that are malformed, as opposed to files that could not be read.
+var ErrInvalidIfo = errors.New("invalid IFO file")
+
+// DvdInfo describes the contents of a DVD, as read from its IFO files.
+type DvdInfo struct {
+	Titles []DvdTitle
+}
+
+// DvdTitle is one title of a DVD, such as the main feature or an extra.
+type DvdTitle struct {
+	// Number is the 1-based number of the title on the disc.
+	Number int
+	// TitleSet is the number of the VTS_xx_0.IFO file that describes the title.
+	TitleSet int
+	Duration time.Duration
+	Chapters int
+	Angles   int
+	// Audio and SubtitleLanguages are shared by all titles of a title set.
+	Audio             []AudioStream
+	SubtitleLanguages []string
+}
+
+// AudioStream is one audio track of a DVD title.
+type AudioStream struct {
+	// Language is a two-letter ISO 639-1 code, or empty if not given.
+	Language string
+	// Codec is one of "ac3", "mpeg1", "mpeg2", "lpcm", "dts" or "unknown".
+	Codec    string
+	Channels int
+}
+
+const ifoSectorSize = 2048
+
+// ParseDvd reads VIDEO_TS.IFO and the VTS_xx_0.IFO files of the DVD in dir,
+// which must hold a VIDEO_TS folder.  Names are matched case-insensitively.
+func ParseDvd(dir string) (*DvdInfo, error) {
+	videoTs, err := findFold(dir, "VIDEO_TS")
+	if err != nil {
+		return nil, err
+	}
+	vmg, err := readIfo(videoTs, "VIDEO_TS.IFO", "DVDVIDEO-VMG")
+	if err != nil {
+		return nil, err
+	}
+	titles, err := parseTitleTable(vmg)
+	if err != nil {
+		return nil, fmt.Errorf("VIDEO_TS.IFO: %w", err)
+	}
+
+	titleSets := make(map[int]*titleSet)
+	info := &DvdInfo{}
+	for _, title := range titles {
+		ts, ok := titleSets[title.TitleSet]
+		if !ok {
+			name := fmt.Sprintf("VTS_%02d_0.IFO", title.TitleSet)
+			vts, err := readIfo(videoTs, name, "DVDVIDEO-VTS")
+			if err != nil {
+				return nil, err
+			}
+			ts, err = parseTitleSet(vts)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			titleSets[title.TitleSet] = ts
+		}
+		title.Duration, err = ts.duration(title.titleSetTitle)
+		if err != nil {
+			return nil, fmt.Errorf("title %d: %w", title.Number, err)
+		}
+		title.Audio = ts.audio
+		title.SubtitleLanguages = ts.subtitleLanguages
+		info.Titles = append(info.Titles, title.DvdTitle)
+	}
+	return info, nil
+}
+
+// findFold returns the path of the entry of dir whose name matches name,
+// ignoring case.
+func findFold(dir, name string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), name) {
+			return filepath.Join(dir, entry.Name()), nil
+		}
+	}
+	return "", fmt.Errorf("%s: %w", filepath.Join(dir, name), fs.ErrNotExist)
+}
+
+// readIfo reads the IFO file with the given name from videoTs, and checks
+// that it starts with magic.
+func readIfo(videoTs, name, magic string) (ifo, error) {
+	path, err := findFold(videoTs, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(string(data), magic) {
+		return nil, fmt.Errorf("%s: %w: does not start with %s", name, ErrInvalidIfo, magic)
+	}
+	return ifo(data), nil
+}
+
+// ifo is the content of an IFO file.  Its accessors read big-endian values,
+// and report reads past the end of the file as ErrInvalidIfo.
+type ifo []byte
+
+func (b ifo) check(off, n int) error {
+	if off < 0 || off+n > len(b) {
+		return fmt.Errorf("%w: offset %#x is past the end of the file", ErrInvalidIfo, off)
+	}
+	return nil
+}
+
+func (b ifo) u8(off int) (int, error) {
+	if err := b.check(off, 1); err != nil {
+		return 0, err
+	}
+	return int(b[off]), nil
+}
+
+func (b ifo) u16(off int) (int, error) {
+	if err := b.check(off, 2); err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(b[off:])), nil
+}
+
+func (b ifo) u32(off int) (int, error) {
+	if err := b.check(off, 4); err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(b[off:])), nil
+}
+
+func (b ifo) bytes(off, n int) ([]byte, error) {
+	if err := b.check(off, n); err != nil {
+		return nil, err
+	}
+	return b[off : off+n], nil
+}
+
+// sector returns the offset of the table whose sector number is at off.
+func (b ifo) sector(off int) (int, error) {
+	sector, err := b.u32(off)
+	if err != nil {
+		return 0, err
+	}
+	if sector == 0 {
+		return 0, fmt.Errorf("%w: missing table at %#x", ErrInvalidIfo, off)
+	}
+	return sector * ifoSectorSize, nil
+}
+
+// vmgTitle is a title as listed in VIDEO_TS.IFO.
+type vmgTitle struct {
+	DvdTitle
+	// titleSetTitle is the 1-based number of the title within its title set.
+	titleSetTitle int
+}
+
+// parseTitleTable reads the title search pointer table (TT_SRPT) of
+// VIDEO_TS.IFO.
+func parseTitleTable(vmg ifo) ([]vmgTitle, error) {
+	table, err := vmg.sector(0xC4)
+	if err != nil {
+		return nil, err
+	}
+	count, err := vmg.u16(table)
+	if err != nil {
+		return nil, err
+	}
+	titles := make([]vmgTitle, count)
+	for i := range titles {
+		entry, err := vmg.bytes(table+8+i*12, 12)
+		if err != nil {
+			return nil, err
+		}
+		titles[i] = vmgTitle{
+			DvdTitle: DvdTitle{
+				Number:   i + 1,
+				Angles:   int(entry[1]),
+				Chapters: int(binary.BigEndian.Uint16(entry[2:])),
+				TitleSet: int(entry[6]),
+			},
+			titleSetTitle: int(entry[7]),
+		}
+		if titles[i].TitleSet == 0 || titles[i].titleSetTitle == 0 {
+			return nil, fmt.Errorf("%w: title %d has no title set", ErrInvalidIfo, i+1)
+		}
+	}
+	return titles, nil
+}
+
+// titleSet is what a VTS_xx_0.IFO file says about its titles.
+type titleSet struct {
+	vts               ifo
+	audio             []AudioStream
+	subtitleLanguages []string
+}
+
+func parseTitleSet(vts ifo) (*titleSet, error) {
+	ts := &titleSet{vts: vts}
+
+	audioCount, err := vts.u16(0x202)
+	if err != nil {
+		return nil, err
+	}
+	for i := range min(audioCount, 8) {
+		attr, err := vts.bytes(0x204+i*8, 8)
+		if err != nil {
+			return nil, err
+		}
+		stream := AudioStream{
+			Codec:    audioCodec(attr[0] >> 5),
+			Channels: int(attr[1]&0x07) + 1,
+		}
+		if (attr[0]>>2)&0x03 == 1 {
+			stream.Language = string(attr[2:4])
+		}
+		ts.audio = append(ts.audio, stream)
+	}
+
+	subtitleCount, err := vts.u16(0x254)
+	if err != nil {
+		return nil, err
+	}
+	for i := range min(subtitleCount, 32) {
+		attr, err := vts.bytes(0x256+i*6, 6)
+		if err != nil {
+			return nil, err
+		}
+		language := ""
+		if attr[0]&0x03 == 1 {
+			language = string(attr[2:4])
+		}
+		ts.subtitleLanguages = append(ts.subtitleLanguages, language)
+	}
+	return ts, nil
+}
+
+func audioCodec(mode byte) string {
+	switch mode {
+	case 0:
+		return "ac3"
+	case 2:
+		return "mpeg1"
+	case 3:
+		return "mpeg2"
+	case 4:
+		return "lpcm"
+	case 6:
+		return "dts"
+	default:
+		return "unknown"
+	}
+}
+
+// duration returns the playback time of the program chain that the given title
+// of the title set starts with.
+func (ts *titleSet) duration(title int) (time.Duration, error) {
+	vts := ts.vts
+	// The part-of-title table (VTS_PTT_SRPT) says which program chain each
+	// title starts with.
+	ptt, err := vts.sector(0xC8)
+	if err != nil {
+		return 0, err
+	}
+	pttOffset, err := vts.u32(ptt + 8 + (title-1)*4)
+	if err != nil {
+		return 0, err
+	}
+	pgcNumber, err := vts.u16(ptt + pttOffset)
+	if err != nil {
+		return 0, err
+	}
+	if pgcNumber == 0 {
+		return 0, fmt.Errorf("%w: title starts with program chain 0", ErrInvalidIfo)
+	}
+
+	// The program chain information table (VTS_PGCI) holds the program chains.
+	pgci, err := vts.sector(0xCC)
+	if err != nil {
+		return 0, err
+	}
+	pgcOffset, err := vts.u32(pgci + 8 + (pgcNumber-1)*8 + 4)
+	if err != nil {
+		return 0, err
+	}
+	playbackTime, err := vts.bytes(pgci+pgcOffset+4, 4)
+	if err != nil {
+		return 0, err
+	}
+	return decodePlaybackTime(playbackTime)
+}
+
+// decodePlaybackTime decodes a BCD playback time: hours, minutes, seconds and
+// frames, with the frame rate in the top two bits of the last byte.
+func decodePlaybackTime(b []byte) (time.Duration, error) {
+	var fields [4]int
+	for i, v := range []byte{b[0], b[1], b[2], b[3] & 0x3F} {
+		hi, lo := int(v>>4), int(v&0x0F)
+		if hi > 9 || lo > 9 {
+			return 0, fmt.Errorf("%w: playback time %x is not BCD", ErrInvalidIfo, b)
+		}
+		fields[i] = hi*10 + lo
+	}
+	d := time.Duration(fields[0])*time.Hour + time.Duration(fields[1])*time.Minute + time.Duration(fields[2])*time.Second
+	switch b[3] >> 6 {
+	case 1:
+		d += time.Duration(fields[3]) * time.Second / 25
+	case 3:
+		d += time.Duration(fields[3]) * time.Second * 1001 / 30000
+	}
+	return d, nil
+}
@@ -0,0 +1,171 @@
+package vmdisc_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+)
+
+func TestParseDvd(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	mainAudio := []vmdisc.AudioStream{
+		{Language: "en", Codec: "ac3", Channels: 6},
+		{Language: "fr", Codec: "dts", Channels: 6},
+		{Codec: "lpcm", Channels: 2},
+	}
+	titles := []vmdisc.DvdTitle{
+		{
+			Number:            1,
+			TitleSet:          1,
+			Duration:          time.Hour + 45*time.Minute + 30*time.Second + 480*time.Millisecond,
+			Chapters:          28,
+			Angles:            1,
+			Audio:             mainAudio,
+			SubtitleLanguages: []string{"en", "de", ""},
+		},
+		{
+			Number:   2,
+			TitleSet: 2,
+			Duration: 2*time.Minute + 5*time.Second,
+			Chapters: 1,
+			Angles:   1,
+			Audio:    []vmdisc.AudioStream{{Language: "en", Codec: "mpeg2", Channels: 2}},
+		},
+		{
+			Number:            3,
+			TitleSet:          1,
+			Duration:          12 * time.Minute,
+			Chapters:          4,
+			Angles:            2,
+			Audio:             mainAudio,
+			SubtitleLanguages: []string{"en", "de", ""},
+		},
+	}
+
+	// corrupt overwrites the bytes of the given file at off.
+	corrupt := func(e exam.E, dir, name string, off int, b ...byte) {
+		path := filepath.Join(dir, "VIDEO_TS", name)
+		data, err := os.ReadFile(path)
+		exam.Nil(e, env, err).Log(err).Must()
+		copy(data[off:], b)
+		err = os.WriteFile(path, data, 0644)
+		exam.Nil(e, env, err).Log(err).Must()
+	}
+
+	tests := []struct {
+		loc   exam.Loc
+		name  string
+		setup func(e exam.E, dir string)
+		want  *vmdisc.DvdInfo
+		// wantErr is checked with errors.Is, if set.
+		wantErr error
+	}{
+		{
+			loc:  exam.Here(),
+			name: "titles across title sets",
+			want: &vmdisc.DvdInfo{Titles: titles},
+		},
+		{
+			loc:  exam.Here(),
+			name: "lower case names",
+			setup: func(e exam.E, dir string) {
+				for _, name := range []string{"VIDEO_TS.IFO", "VTS_01_0.IFO", "VTS_02_0.IFO"} {
+					err := os.Rename(filepath.Join(dir, "VIDEO_TS", name), filepath.Join(dir, "VIDEO_TS", strings.ToLower(name)))
+					exam.Nil(e, env, err).Log(err).Must()
+				}
+				err := os.Rename(filepath.Join(dir, "VIDEO_TS"), filepath.Join(dir, "video_ts"))
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+			want: &vmdisc.DvdInfo{Titles: titles},
+		},
+		{
+			loc:  exam.Here(),
+			name: "NTSC frame rate",
+			setup: func(e exam.E, dir string) {
+				// The playback time of the only program chain of title set 2,
+				// with 15 frames at 29.97 fps.
+				corrupt(e, dir, "VTS_02_0.IFO", 4096+0x100+4, 0x00, 0x02, 0x05, 0xC0|0x15)
+			},
+			want: &vmdisc.DvdInfo{Titles: []vmdisc.DvdTitle{
+				titles[0],
+				func() vmdisc.DvdTitle {
+					t := titles[1]
+					t.Duration += 15 * time.Second * 1001 / 30000
+					return t
+				}(),
+				titles[2],
+			}},
+		},
+		{
+			loc:  exam.Here(),
+			name: "wrong magic",
+			setup: func(e exam.E, dir string) {
+				corrupt(e, dir, "VIDEO_TS.IFO", 0, []byte("DVDVIDEO-VTS")...)
+			},
+			wantErr: vmdisc.ErrInvalidIfo,
+		},
+		{
+			loc:  exam.Here(),
+			name: "table past end of file",
+			setup: func(e exam.E, dir string) {
+				corrupt(e, dir, "VTS_01_0.IFO", 0xCC, 0, 0, 0, 9)
+			},
+			wantErr: vmdisc.ErrInvalidIfo,
+		},
+		{
+			loc:  exam.Here(),
+			name: "playback time not BCD",
+			setup: func(e exam.E, dir string) {
+				corrupt(e, dir, "VTS_02_0.IFO", 4096+0x100+4, 0x0A)
+			},
+			wantErr: vmdisc.ErrInvalidIfo,
+		},
+		{
+			loc:  exam.Here(),
+			name: "missing title set",
+			setup: func(e exam.E, dir string) {
+				err := os.Remove(filepath.Join(dir, "VIDEO_TS", "VTS_02_0.IFO"))
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+			wantErr: fs.ErrNotExist,
+		},
+		{
+			loc:  exam.Here(),
+			name: "no VIDEO_TS folder",
+			setup: func(e exam.E, dir string) {
+				err := os.RemoveAll(filepath.Join(dir, "VIDEO_TS"))
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+			wantErr: fs.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			dir := e.TempDir()
+			vmtest.WriteDvd(e, dir, titles)
+			if tt.setup != nil {
+				tt.setup(e, dir)
+			}
+			got, err := vmdisc.ParseDvd(dir)
+			if tt.wantErr != nil {
+				exam.Equal(e, env, errors.Is(err, tt.wantErr), true).Log(err).Log(tt.loc)
+				exam.Match(e, env, got, match.Nil()).Log(tt.loc)
+				return
+			}
+			exam.Nil(e, env, err).Log(err).Log(tt.loc).Must()
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+}
@@ -18,6 +18,24 @@ const (
 	ProblemDbSerialization Problem = "/errors/db-serialization"
 )
 
+// Title returns a short, human-readable summary of the problem type.
+func (p Problem) Title() string {
+	switch p {
+	case ProblemNotFound:
+		return "Not Found"
+	case ProblemBadRequest:
+		return "Bad Request"
+	case ProblemInternalError:
+		return "Internal Server Error"
+	case ProblemAlreadyExists:
+		return "Already Exists"
+	case ProblemDbSerialization:
+		return "Database Serialization Failure"
+	default:
+		return "Unknown Problem"
+	}
+}
+
 type HttpError struct {
 	Problem    Problem
 	StatusCode int
@@ -4,32 +4,79 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
 )
 
+// ContentTypeProblem is the media type of an RFC 7807 problem document.
+const ContentTypeProblem = "application/problem+json"
+
+// Middleware writes err as a problem document.  Errors that are not an
+// HttpError are reported as internal errors, and all server errors are logged.
+// Server errors may describe internals, so clients only get a generic detail
+// that points at the log by request ID.
+// It is meant to be used as the ResponseErrorHandlerFunc of a vmapi strict
+// handler.
 func Middleware(w http.ResponseWriter, r *http.Request, err error) {
 	var httpErr *HttpError
 	if !errors.As(err, &httpErr) {
 		httpErr = &HttpError{
+			Problem:    ProblemInternalError,
 			StatusCode: 500,
 			Wrapped:    fmt.Errorf("unhandled internal server error: %w", err),
 		}
 	}
+	if httpErr.StatusCode >= 500 {
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "error", httpErr)
+	}
+	writeProblem(w, httpErr)
+}
+
+// RequestMiddleware writes err as a problem document.  Errors that are not an
+// HttpError are reported as bad requests, since they come from failing to parse
+// the request.  It is meant to be used as the RequestErrorHandlerFunc of a vmapi
+// strict handler, and as the ErrorHandlerFunc of the vmapi router.
+func RequestMiddleware(w http.ResponseWriter, r *http.Request, err error) {
+	var httpErr *HttpError
+	if !errors.As(err, &httpErr) {
+		httpErr = &HttpError{
+			Problem:    ProblemBadRequest,
+			StatusCode: 400,
+			Wrapped:    err,
+		}
+	}
+	writeProblem(w, httpErr)
+}
+
+// problemDetail returns the detail of the problem document for httpErr.
+func problemDetail(w http.ResponseWriter, httpErr *HttpError) string {
+	if httpErr.StatusCode < 500 {
+		return httpErr.Error()
+	}
+	if requestId := w.Header().Get(vmlog.HeaderRequestId); requestId != "" {
+		return fmt.Sprintf("the server failed to handle request %s; see its logs for details", requestId)
+	}
+	return "the server failed to handle the request; see its logs for details"
+}
+
+func writeProblem(w http.ResponseWriter, httpErr *HttpError) {
+	detail := problemDetail(w, httpErr)
 
 	// Much of this was copied & pasted from http.Error().
 	h := w.Header()
 	h.Del("Content-Length")
-	h.Set("Content-Type", "text/json; charset=utf-8")
+	h.Set("Content-Type", ContentTypeProblem)
 	h.Set("X-Content-Type-Options", "nosniff")
 	w.WriteHeader(httpErr.StatusCode)
 
 	errJson := vmapi.ErrorResponse{
-		Type: "/errors/todo",
-		Title: "TODO: set title",
+		Type:   string(httpErr.Problem),
+		Title:  httpErr.Problem.Title(),
 		Status: httpErr.StatusCode,
-		Detail: httpErr.Error(),
+		Detail: detail,
 	}
 	if err := json.NewEncoder(w).Encode(errJson); err != nil {
 		http.Error(w, "Failed to encode error response", http.StatusInternalServerError)
@@ -0,0 +1,162 @@
+package vmerr_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	cause := errors.New("something went wrong")
+
+	tests := []struct {
+		name       string
+		loc        exam.Loc
+		middleware func(http.ResponseWriter, *http.Request, error)
+		err        error
+		want       vmapi.ErrorResponse
+	}{
+		{
+			name:       "not found",
+			loc:        exam.Here(),
+			middleware: vmerr.Middleware,
+			err:        vmerr.NotFound(cause),
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/not-found",
+				Title:  "Not Found",
+				Status: 404,
+				Detail: "something went wrong",
+			},
+		},
+		{
+			name:       "bad request",
+			loc:        exam.Here(),
+			middleware: vmerr.Middleware,
+			err:        vmerr.BadRequest(cause),
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/bad-request",
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: "something went wrong",
+			},
+		},
+		{
+			name:       "internal error",
+			loc:        exam.Here(),
+			middleware: vmerr.Middleware,
+			err:        vmerr.InternalError(cause),
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/internal-error",
+				Title:  "Internal Server Error",
+				Status: 500,
+				Detail: "the server failed to handle the request; see its logs for details",
+			},
+		},
+		{
+			name:       "already exists",
+			loc:        exam.Here(),
+			middleware: vmerr.Middleware,
+			err:        vmerr.AlreadyExists(cause),
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/already-exists",
+				Title:  "Already Exists",
+				Status: 409,
+				Detail: "something went wrong",
+			},
+		},
+		{
+			name:       "db serialization",
+			loc:        exam.Here(),
+			middleware: vmerr.Middleware,
+			err:        vmerr.DbSerialization(cause),
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/db-serialization",
+				Title:  "Database Serialization Failure",
+				Status: 409,
+				Detail: "something went wrong",
+			},
+		},
+		{
+			name:       "wrapped http error",
+			loc:        exam.Here(),
+			middleware: vmerr.Middleware,
+			err:        errors.Join(errors.New("context"), vmerr.NotFound(cause)),
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/not-found",
+				Title:  "Not Found",
+				Status: 404,
+				Detail: "something went wrong",
+			},
+		},
+		{
+			name:       "unhandled response error",
+			loc:        exam.Here(),
+			middleware: vmerr.Middleware,
+			err:        cause,
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/internal-error",
+				Title:  "Internal Server Error",
+				Status: 500,
+				Detail: "the server failed to handle the request; see its logs for details",
+			},
+		},
+		{
+			name:       "unhandled request error",
+			loc:        exam.Here(),
+			middleware: vmerr.RequestMiddleware,
+			err:        cause,
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/bad-request",
+				Title:  "Bad Request",
+				Status: 400,
+				Detail: "something went wrong",
+			},
+		},
+		{
+			name:       "request error with problem",
+			loc:        exam.Here(),
+			middleware: vmerr.RequestMiddleware,
+			err:        vmerr.NotFound(cause),
+			want: vmapi.ErrorResponse{
+				Type:   "/errors/not-found",
+				Title:  "Not Found",
+				Status: 404,
+				Detail: "something went wrong",
+			},
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			rec := httptest.NewRecorder()
+			tt.middleware(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err)
+
+			exam.Equal(e, env, rec.Code, tt.want.Status).Log(tt.loc)
+			exam.Equal(e, env, rec.Header().Get("Content-Type"), vmerr.ContentTypeProblem).Log(tt.loc)
+			var got vmapi.ErrorResponse
+			err := json.Unmarshal(rec.Body.Bytes(), &got)
+			exam.Nil(e, env, err).Log(err).Log(tt.loc).Must()
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+
+	e.Run("internal error with request id", func(e exam.E) {
+		rec := httptest.NewRecorder()
+		rec.Header().Set(vmlog.HeaderRequestId, "abc123")
+		vmerr.Middleware(rec, httptest.NewRequest(http.MethodGet, "/", nil), vmerr.InternalError(cause))
+
+		var got vmapi.ErrorResponse
+		err := json.Unmarshal(rec.Body.Bytes(), &got)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, got.Detail, "the server failed to handle request abc123; see its logs for details")
+	})
+}
@@ -0,0 +1,29 @@
+package vmlog
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// HeaderRequestId is the header used to correlate a request across services.
+const HeaderRequestId = "X-Request-ID"
+
+// maxRequestIdLen bounds the length of request IDs accepted from clients.
+const maxRequestIdLen = 128
+
+// Middleware tags the context of every request with a request ID, taken from
+// the X-Request-ID header if the client sent one, and generated otherwise.
+// The ID is echoed back in the response headers.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(HeaderRequestId)
+		if requestId == "" || len(requestId) > maxRequestIdLen {
+			requestId = uuid.New().String()
+		}
+		w.Header().Set(HeaderRequestId, requestId)
+		ctx := With(r.Context(), slog.String(KeyRequestId, requestId))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
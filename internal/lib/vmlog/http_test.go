@@ -0,0 +1,56 @@
+package vmlog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
+)
+
+func TestMiddleware(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	// serve runs a request through the middleware, and returns the request ID
+	// seen by the handler as well as the response.
+	serve := func(header string) (string, *httptest.ResponseRecorder) {
+		var seen string
+		handler := vmlog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, attr := range vmlog.Attrs(r.Context()) {
+				if attr.Key == vmlog.KeyRequestId {
+					seen = attr.Value.String()
+				}
+			}
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(vmlog.HeaderRequestId, header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return seen, rec
+	}
+
+	e.Run("uses client id", func(e exam.E) {
+		seen, rec := serve("client-id")
+		exam.Equal(e, env, seen, "client-id")
+		exam.Equal(e, env, rec.Header().Get(vmlog.HeaderRequestId), "client-id")
+	})
+
+	e.Run("generates id", func(e exam.E) {
+		seen, rec := serve("")
+		exam.Equal(e, env, seen != "", true)
+		exam.Equal(e, env, rec.Header().Get(vmlog.HeaderRequestId), seen)
+	})
+
+	e.Run("replaces overlong id", func(e exam.E) {
+		long := strings.Repeat("x", 1000)
+		seen, rec := serve(long)
+		exam.Equal(e, env, seen != long && seen != "", true)
+		exam.Equal(e, env, rec.Header().Get(vmlog.HeaderRequestId), seen)
+	})
+}
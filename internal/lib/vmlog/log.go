@@ -0,0 +1,76 @@
+package vmlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+)
+
+var ErrUnknownFormat = errors.New("unknown log format")
+
+// Attribute keys threaded through context.
+const (
+	KeyRequestId = "request_id"
+	KeyTaskId    = "task_id"
+	KeyTaskType  = "task_type"
+	KeyWorkerId  = "worker_id"
+)
+
+type attrsKey struct{}
+
+// With returns a copy of ctx that carries attrs.  Every line logged with that
+// context through a logger from New includes them.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, attrsKey{}, combined)
+}
+
+// Attrs returns the attributes that With has attached to ctx.
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// New returns a logger that writes to w in the given format, which is one of
+// config.LogFormatText or config.LogFormatJson.  The logger adds the
+// attributes carried by the context of each log call.
+func New(w io.Writer, format string) (*slog.Logger, error) {
+	var handler slog.Handler
+	switch format {
+	case config.LogFormatText:
+		handler = slog.NewTextHandler(w, nil)
+	case config.LogFormatJson:
+		handler = slog.NewJSONHandler(w, nil)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	return slog.New(contextHandler{Handler: handler}), nil
+}
+
+// contextHandler adds the attributes carried by the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := Attrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,79 @@
+package vmlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
+)
+
+func TestNew(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	e.Run("json includes context attrs", func(e exam.E) {
+		var buf bytes.Buffer
+		logger, err := vmlog.New(&buf, config.LogFormatJson)
+		exam.Nil(e, env, err).Log(err).Must()
+
+		ctx := vmlog.With(context.Background(), slog.String(vmlog.KeyRequestId, "abc"))
+		ctx = vmlog.With(ctx, slog.Int(vmlog.KeyTaskId, 7))
+		logger.InfoContext(ctx, "hello", "extra", "value")
+
+		var got map[string]any
+		err = json.Unmarshal(buf.Bytes(), &got)
+		exam.Nil(e, env, err).Log(err).Log(buf.String()).Must()
+		exam.Equal(e, env, got["msg"], any("hello"))
+		exam.Equal(e, env, got["extra"], any("value"))
+		exam.Equal(e, env, got[vmlog.KeyRequestId], any("abc"))
+		exam.Equal(e, env, got[vmlog.KeyTaskId], any(float64(7)))
+	})
+
+	e.Run("text includes context attrs", func(e exam.E) {
+		var buf bytes.Buffer
+		logger, err := vmlog.New(&buf, config.LogFormatText)
+		exam.Nil(e, env, err).Log(err).Must()
+
+		ctx := vmlog.With(context.Background(), slog.String(vmlog.KeyWorkerId, "w1"))
+		logger.With("component", "test").InfoContext(ctx, "hello")
+
+		line := buf.String()
+		exam.Equal(e, env, strings.Contains(line, "worker_id=w1"), true).Log(line)
+		exam.Equal(e, env, strings.Contains(line, "component=test"), true).Log(line)
+	})
+
+	e.Run("no context attrs", func(e exam.E) {
+		var buf bytes.Buffer
+		logger, err := vmlog.New(&buf, config.LogFormatJson)
+		exam.Nil(e, env, err).Log(err).Must()
+
+		logger.Info("hello")
+
+		var got map[string]any
+		err = json.Unmarshal(buf.Bytes(), &got)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Match(e, env, got, match.Len(match.Equal(3)))
+	})
+
+	e.Run("unknown format", func(e exam.E) {
+		_, err := vmlog.New(&bytes.Buffer{}, "xml")
+		exam.Match(e, env, err, match.ErrorIs(vmlog.ErrUnknownFormat))
+	})
+}
+
+func TestWith_DoesNotModifyParent(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	parent := vmlog.With(context.Background(), slog.String("a", "1"))
+	_ = vmlog.With(parent, slog.String("b", "2"))
+	exam.Match(e, env, vmlog.Attrs(parent), match.Len(match.Equal(1)))
+}
@@ -0,0 +1,79 @@
+package vmmetrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolStater is implemented by DbRunners that are backed by a pgx connection
+// pool.
+type poolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// poolCollector exports the statistics of a vmdb connection pool.
+type poolCollector struct {
+	db poolStater
+
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	newConnsCount        *prometheus.Desc
+	acquiredConns        *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+}
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(Namespace, "db_pool", name), help, nil, nil)
+}
+
+// NewPoolCollector returns a collector for the statistics of db's connection
+// pool.  If db isn't backed by a pool, the collector exports nothing.
+func NewPoolCollector(db vmdb.DbRunner) prometheus.Collector {
+	stater, _ := db.(poolStater)
+	return &poolCollector{
+		db:                   stater,
+		acquireCount:         poolDesc("acquires_total", "Number of successful connection acquires."),
+		acquireDuration:      poolDesc("acquire_duration_seconds_total", "Total time spent acquiring connections."),
+		canceledAcquireCount: poolDesc("canceled_acquires_total", "Number of connection acquires cancelled by a context."),
+		emptyAcquireCount:    poolDesc("empty_acquires_total", "Number of acquires that had to wait for a connection."),
+		newConnsCount:        poolDesc("new_connections_total", "Number of connections opened."),
+		acquiredConns:        poolDesc("acquired_connections", "Number of connections currently in use."),
+		constructingConns:    poolDesc("constructing_connections", "Number of connections currently being opened."),
+		idleConns:            poolDesc("idle_connections", "Number of idle connections."),
+		totalConns:           poolDesc("connections", "Total number of connections in the pool."),
+		maxConns:             poolDesc("max_connections", "Maximum size of the pool."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.db == nil {
+		return
+	}
+	stat := c.db.Stat()
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter(c.acquireCount, float64(stat.AcquireCount()))
+	counter(c.acquireDuration, stat.AcquireDuration().Seconds())
+	counter(c.canceledAcquireCount, float64(stat.CanceledAcquireCount()))
+	counter(c.emptyAcquireCount, float64(stat.EmptyAcquireCount()))
+	counter(c.newConnsCount, float64(stat.NewConnsCount()))
+	gauge(c.acquiredConns, float64(stat.AcquiredConns()))
+	gauge(c.constructingConns, float64(stat.ConstructingConns()))
+	gauge(c.idleConns, float64(stat.IdleConns()))
+	gauge(c.totalConns, float64(stat.TotalConns()))
+	gauge(c.maxConns, float64(stat.MaxConns()))
+}
@@ -0,0 +1,28 @@
+package vmmetrics_test
+
+import (
+	"testing"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmmetrics"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPoolCollector(t *testing.T) {
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+
+	collector := vmmetrics.NewPoolCollector(pg.DbRunner(e))
+	problems, err := testutil.CollectAndLint(collector)
+	if err != nil {
+		t.Fatalf("failed to lint metrics: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Fatalf("lint problems: %v", problems)
+	}
+	if got := testutil.CollectAndCount(collector); got != 10 {
+		t.Fatalf("collected %d metrics, want 10", got)
+	}
+}
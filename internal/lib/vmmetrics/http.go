@@ -0,0 +1,55 @@
+package vmmetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of API requests handled, by operation and status code.",
+	}, []string{"operation", "code"})
+
+	requestDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time spent handling API requests, by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+// StrictMiddleware records the count and latency of every vmapi operation.
+// Successful responses are counted with a code of "2xx", since the exact status
+// is not known until the response is written.
+func StrictMiddleware(f vmapi.StrictHandlerFunc, operation string) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		start := time.Now()
+		response, err := f(ctx, w, r, request)
+		requestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+		requestsTotal.WithLabelValues(operation, statusCode(err)).Inc()
+		return response, err
+	}
+}
+
+func statusCode(err error) string {
+	if err == nil {
+		return "2xx"
+	}
+	var httpErr *vmerr.HttpError
+	if errors.As(err, &httpErr) {
+		return strconv.Itoa(httpErr.StatusCode)
+	}
+	return strconv.Itoa(http.StatusInternalServerError)
+}
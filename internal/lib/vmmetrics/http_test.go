@@ -0,0 +1,63 @@
+package vmmetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStrictMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+	}{
+		{name: "success", wantCode: "2xx"},
+		{name: "not found", err: vmerr.NotFound(errors.New("missing")), wantCode: "404"},
+		{name: "unhandled error", err: errors.New("boom"), wantCode: "500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation := "Test" + strings.ReplaceAll(tt.name, " ", "")
+			handler := StrictMiddleware(func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+				return nil, tt.err
+			}, operation)
+
+			_, err := handler(context.Background(), httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil)
+			if err != tt.err {
+				t.Fatalf("middleware returned error %v, want %v", err, tt.err)
+			}
+			if got := testutil.ToFloat64(requestsTotal.WithLabelValues(operation, tt.wantCode)); got != 1 {
+				t.Fatalf("requests_total{operation=%q,code=%q} = %v, want 1", operation, tt.wantCode, got)
+			}
+			if got := testutil.CollectAndCount(requestDuration, "video_manager_http_request_duration_seconds"); got == 0 {
+				t.Fatal("no request duration was recorded")
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	requestsTotal.WithLabelValues("TestHandler", "2xx").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`video_manager_http_requests_total{code="2xx",operation="TestHandler"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output is missing %q", want)
+		}
+	}
+}
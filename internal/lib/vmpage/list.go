@@ -19,6 +19,9 @@ type ListQuery struct {
 	Default   uint32
 	Max       uint32
 	PageToken *string
+	// Params holds any additional named parameters referenced by Sql, such as
+	// filters.  It must not contain "limit", "lastSeenId" or "lastSeenKey".
+	Params map[string]any
 }
 
 func (lq *ListQuery) limit() uint32 {
@@ -36,24 +39,35 @@ func (lq *ListQuery) limit() uint32 {
 }
 
 func (lq *ListQuery) statement() (vmdb.Statement, error) {
-	if !strings.Contains(lq.Sql, "@limit") {
-		panic(fmt.Errorf("%w: sql missing @limit", ErrPanicBadListQuery))
-	}
-	if !strings.Contains(lq.Sql, "@lastSeenId") {
-		panic(fmt.Errorf("%w: sql missing @lastSeenId", ErrPanicBadListQuery))
-	}
-
 	lastSeenId, err := toLastSeenId(lq.PageToken)
 	if err != nil {
 		return nil, err
 	}
+	return lq.named(map[string]any{"lastSeenId": lastSeenId}), nil
+}
 
+// named returns Sql with the given page parameters, the limit and Params.
+func (lq *ListQuery) named(page map[string]any) vmdb.Statement {
+	if !strings.Contains(lq.Sql, "@limit") {
+		panic(fmt.Errorf("%w: sql missing @limit", ErrPanicBadListQuery))
+	}
 	params := map[string]any{
 		// Go one above the stated limit to see if there is a next page.
-		"limit":      lq.limit() + 1,
-		"lastSeenId": lastSeenId,
+		"limit": lq.limit() + 1,
+	}
+	for name, value := range page {
+		if !strings.Contains(lq.Sql, "@"+name) {
+			panic(fmt.Errorf("%w: sql missing @%s", ErrPanicBadListQuery, name))
+		}
+		params[name] = value
 	}
-	return vmdb.Named(lq.Sql, params), nil
+	for name, value := range lq.Params {
+		if _, reserved := params[name]; reserved {
+			panic(fmt.Errorf("%w: Params may not set %q", ErrPanicBadListQuery, name))
+		}
+		params[name] = value
+	}
+	return vmdb.Named(lq.Sql, params)
 }
 
 func ListPtr[T any](ctx context.Context, runner vmdb.Runner, query *ListQuery, cb ListCallback[*T]) (*string, error) {
@@ -61,24 +75,32 @@ func ListPtr[T any](ctx context.Context, runner vmdb.Runner, query *ListQuery, c
 	if err != nil {
 		return nil, err
 	}
+	var lastSeenId uint32
+	more, err := listPtr(ctx, runner, query, stmt, func(record *T) {
+		lastSeenId = cb(record)
+	})
+	if err != nil || !more {
+		return nil, err
+	}
+	nextPageToken := fromLastSeenId(lastSeenId)
+	return &nextPageToken, nil
+}
+
+// listPtr passes up to query.limit() records to cb, and reports whether there
+// are more.
+func listPtr[T any](ctx context.Context, runner vmdb.Runner, query *ListQuery, stmt vmdb.Statement, cb func(*T)) (bool, error) {
 	var count uint32
 	limit := query.limit()
-	var lastSeenId uint32
-	err = vmdb.QueryPtr(ctx, runner, stmt, func(record *T) bool {
+	err := vmdb.QueryPtr(ctx, runner, stmt, func(record *T) bool {
 		count++
 		if count <= limit {
-			lastSeenId = cb(record)
+			cb(record)
 			return true
 		}
 		return false
 	})
 	if err != nil {
-		return nil, err
-	}
-	if count > limit {
-		// We have a next page.
-		nextPageToken := fromLastSeenId(lastSeenId)
-		return &nextPageToken, nil
+		return false, err
 	}
-	return nil, nil
+	return count > limit, nil
 }
@@ -0,0 +1,32 @@
+package vmpage
+
+// OffsetFetcher returns up to limit items starting at position offset, along
+// with the total number of items available.
+type OffsetFetcher[T any] func(offset, limit uint32) (items []T, total uint32, err error)
+
+// ListFromOffset pages through a result set that can only be addressed by
+// position, such as search results ranked by an external service.
+func ListFromOffset[T any](limit *Limit, pageToken *string, fetch OffsetFetcher[T]) ([]T, *string, error) {
+	limitValue := limit.Limit()
+	offset, err := toOffset(pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if limitValue == 0 {
+		return []T{}, nil, nil
+	}
+	items, total, err := fetch(offset, limitValue)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint32(len(items)) > limitValue {
+		items = items[:limitValue]
+	}
+	var nextPageToken *string
+	next := offset + uint32(len(items))
+	if len(items) > 0 && next < total {
+		token := fromOffset(next)
+		nextPageToken = &token
+	}
+	return items, nextPageToken, nil
+}
@@ -0,0 +1,48 @@
+package vmpage
+
+import (
+	"context"
+
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+)
+
+// SortedListCallback is like ListCallback, but also returns the sort key of
+// the record.
+type SortedListCallback[T any] func(T) (seenKey int64, seenId uint32)
+
+// ListSortedPtr is like ListPtr, for queries that are sorted by an integer key
+// other than the id, with the id breaking ties.  The Sql of query must order
+// by the key and then the id, both ascending, and only return records whose
+// (key, id) is greater than (@lastSeenKey, @lastSeenId), or every record if
+// @lastSeenKey is NULL.  For example:
+//
+//	WHERE (@lastSeenKey::bigint IS NULL OR (key, id) > (@lastSeenKey::bigint, @lastSeenId))
+//	ORDER BY key, id
+//
+// sort names the sort order.  Page tokens are only accepted by a query with
+// the same sort order as the one that returned them.
+func ListSortedPtr[T any](ctx context.Context, runner vmdb.Runner, query *ListQuery, sort string, cb SortedListCallback[*T]) (*string, error) {
+	lastSeen, err := toLastSeenKey(query.PageToken, sort)
+	if err != nil {
+		return nil, err
+	}
+	var lastSeenKey *int64
+	var lastSeenId uint32
+	if lastSeen != nil {
+		lastSeenKey, lastSeenId = &lastSeen.LastSeenKey, lastSeen.LastSeenId
+	}
+	stmt := query.named(map[string]any{
+		"lastSeenKey": lastSeenKey,
+		"lastSeenId":  lastSeenId,
+	})
+
+	next := lastSeenKeyPage{Sort: sort}
+	more, err := listPtr(ctx, runner, query, stmt, func(record *T) {
+		next.LastSeenKey, next.LastSeenId = cb(record)
+	})
+	if err != nil || !more {
+		return nil, err
+	}
+	nextPageToken := fromLastSeenKey(next)
+	return &nextPageToken, nil
+}
@@ -53,13 +53,13 @@ func toLastSeenId(pageStr *string) (uint32, error) {
 const lastSeenStringMagicNumber uint32 = 217668344
 
 type lastSeenStringPage struct {
-	MagicNumber   uint32 `json:"magic_number"`
+	MagicNumber    uint32 `json:"magic_number"`
 	LastSeenString string `json:"last_seen_string"`
 }
 
 func fromLastSeenString(lastSeenString string) string {
 	page := &lastSeenStringPage{
-		MagicNumber:   lastSeenStringMagicNumber,
+		MagicNumber:    lastSeenStringMagicNumber,
 		LastSeenString: lastSeenString,
 	}
 	pageBytes, err := json.Marshal(page)
@@ -85,4 +85,82 @@ func toLastSeenString(pageStr *string) (string, error) {
 		return "", vmerr.BadRequest(fmt.Errorf("%w: invalid magic number", ErrBadPageToken))
 	}
 	return page.LastSeenString, nil
-}
\ No newline at end of file
+}
+
+const offsetMagicNumber uint32 = 1482931670
+
+type offsetPage struct {
+	MagicNumber uint32 `json:"magic_number"`
+	Offset      uint32 `json:"offset"`
+}
+
+func fromOffset(offset uint32) string {
+	page := &offsetPage{
+		MagicNumber: offsetMagicNumber,
+		Offset:      offset,
+	}
+	pageBytes, err := json.Marshal(page)
+	if err != nil {
+		panic(fmt.Errorf("%w: %w", ErrPanicTokenMarshall, err))
+	}
+	return base64.StdEncoding.EncodeToString(pageBytes)
+}
+
+func toOffset(pageStr *string) (uint32, error) {
+	if pageStr == nil {
+		return 0, nil
+	}
+	pageBytes, err := base64.StdEncoding.DecodeString(*pageStr)
+	if err != nil {
+		return 0, vmerr.BadRequest(fmt.Errorf("%w: could not decode base64 data: %w", ErrBadPageToken, err))
+	}
+	var page offsetPage
+	if err := json.Unmarshal(pageBytes, &page); err != nil {
+		return 0, vmerr.BadRequest(fmt.Errorf("%w: could not decode json data: %w", ErrBadPageToken, err))
+	}
+	if page.MagicNumber != offsetMagicNumber {
+		return 0, vmerr.BadRequest(fmt.Errorf("%w: invalid magic number", ErrBadPageToken))
+	}
+	return page.Offset, nil
+}
+
+const lastSeenKeyMagicNumber uint32 = 2946513527
+
+type lastSeenKeyPage struct {
+	MagicNumber uint32 `json:"magic_number"`
+	Sort        string `json:"sort"`
+	LastSeenKey int64  `json:"last_seen_key"`
+	LastSeenId  uint32 `json:"last_seen_id"`
+}
+
+func fromLastSeenKey(page lastSeenKeyPage) string {
+	page.MagicNumber = lastSeenKeyMagicNumber
+	pageBytes, err := json.Marshal(page)
+	if err != nil {
+		panic(fmt.Errorf("%w: %w", ErrPanicTokenMarshall, err))
+	}
+	return base64.StdEncoding.EncodeToString(pageBytes)
+}
+
+// toLastSeenKey decodes a page token from fromLastSeenKey, and checks that it
+// was made for the given sort order.  It returns nil if pageStr is nil.
+func toLastSeenKey(pageStr *string, sort string) (*lastSeenKeyPage, error) {
+	if pageStr == nil {
+		return nil, nil
+	}
+	pageBytes, err := base64.StdEncoding.DecodeString(*pageStr)
+	if err != nil {
+		return nil, vmerr.BadRequest(fmt.Errorf("%w: could not decode base64 data: %w", ErrBadPageToken, err))
+	}
+	var page lastSeenKeyPage
+	if err := json.Unmarshal(pageBytes, &page); err != nil {
+		return nil, vmerr.BadRequest(fmt.Errorf("%w: could not decode json data: %w", ErrBadPageToken, err))
+	}
+	if page.MagicNumber != lastSeenKeyMagicNumber {
+		return nil, vmerr.BadRequest(fmt.Errorf("%w: invalid magic number", ErrBadPageToken))
+	}
+	if page.Sort != sort {
+		return nil, vmerr.BadRequest(fmt.Errorf("%w: token is for sort order %q, not %q", ErrBadPageToken, page.Sort, sort))
+	}
+	return &page, nil
+}
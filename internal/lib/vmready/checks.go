@@ -0,0 +1,81 @@
+package vmready
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/krelinga/video-manager/internal/lib/migrate"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// Database checks that a pooled connection to Postgres can be used.
+func Database(db vmdb.DbRunner) Check {
+	return func(ctx context.Context) (string, error) {
+		if err := db.Ping(ctx); err != nil {
+			return "", fmt.Errorf("ping failed: %w", err)
+		}
+		return "", nil
+	}
+}
+
+// Writable checks that dir exists and that files can be created in it.
+func Writable(dir string) Check {
+	return func(ctx context.Context) (string, error) {
+		f, err := os.CreateTemp(dir, ".vmready-*")
+		if err != nil {
+			return "", fmt.Errorf("could not create file in %s: %w", dir, err)
+		}
+		name := f.Name()
+		closeErr := f.Close()
+		removeErr := os.Remove(name)
+		if err := errors.Join(closeErr, removeErr); err != nil {
+			return "", fmt.Errorf("could not clean up %s: %w", name, err)
+		}
+		return dir, nil
+	}
+}
+
+// MigrationVersion checks that every embedded migration has been applied, and
+// that the last one did not fail partway through.
+func MigrationVersion(db vmdb.Runner) Check {
+	return func(ctx context.Context) (string, error) {
+		latest, err := migrate.Latest()
+		if err != nil {
+			return "", err
+		}
+		version, dirty, err := migrate.Version(ctx, db)
+		if err != nil {
+			return "", err
+		}
+		detail := fmt.Sprintf("version %d", version)
+		switch {
+		case dirty:
+			return detail, fmt.Errorf("schema version %d is dirty", version)
+		case version != latest:
+			return detail, fmt.Errorf("schema version is %d, want %d", version, latest)
+		}
+		return detail, nil
+	}
+}
+
+// TaskHandlers checks that the listener and scanner goroutines started by
+// Registry.StartHandlers are alive and connected.
+func TaskHandlers(registry *vmtask.Registry) Check {
+	return func(ctx context.Context) (string, error) {
+		h := registry.Health()
+		switch {
+		case !h.Started:
+			return "", errors.New("task handlers not started")
+		case !h.ScannerRunning:
+			return "", errors.New("scanner stopped")
+		case !h.ListenerRunning:
+			return "", errors.New("listener stopped")
+		case !h.ListenerConnected:
+			return "", fmt.Errorf("listener disconnected: %w", h.ListenerError)
+		}
+		return "", nil
+	}
+}
@@ -0,0 +1,108 @@
+package vmready
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds how long all checks together may take.
+const DefaultTimeout = 5 * time.Second
+
+// Check reports whether one dependency is ready.  On success it returns a short
+// human-readable detail, such as a version number, which may be empty.
+type Check func(ctx context.Context) (detail string, err error)
+
+// CheckResult is the outcome of a single Check.
+type CheckResult struct {
+	Ok     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the body served by Handler.
+type Report struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Handler serves a Report of all Checks.  It responds 200 if every check
+// passed, and 503 otherwise.
+type Handler struct {
+	Checks map[string]Check
+	// Timeout bounds how long all checks together may take.  If zero,
+	// DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// Run runs all checks concurrently and collects their results.  Checks that
+// have not finished when the timeout expires are reported as failed.
+func (h *Handler) Run(ctx context.Context) Report {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type namedResult struct {
+		name   string
+		result CheckResult
+	}
+	// Buffered so that slow checks can finish after we stop waiting for them.
+	results := make(chan namedResult, len(h.Checks))
+	for name, check := range h.Checks {
+		go func() {
+			detail, err := check(ctx)
+			result := CheckResult{
+				Ok:     err == nil,
+				Detail: detail,
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results <- namedResult{name: name, result: result}
+		}()
+	}
+
+	report := Report{
+		Ready:  true,
+		Checks: make(map[string]CheckResult, len(h.Checks)),
+	}
+	for range h.Checks {
+		select {
+		case r := <-results:
+			report.Checks[r.name] = r.result
+		case <-ctx.Done():
+		}
+	}
+	for name := range h.Checks {
+		if _, ok := report.Checks[name]; !ok {
+			report.Checks[name] = CheckResult{Error: fmt.Sprintf("timed out after %v", timeout)}
+		}
+	}
+	for _, result := range report.Checks {
+		if !result.Ok {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.Run(r.Context())
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.ErrorContext(r.Context(), "vmready: failed to encode report", "error", err)
+	}
+}
@@ -0,0 +1,146 @@
+package vmready_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/vmready"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+)
+
+func ok(detail string) vmready.Check {
+	return func(ctx context.Context) (string, error) {
+		return detail, nil
+	}
+}
+
+func failing(msg string) vmready.Check {
+	return func(ctx context.Context) (string, error) {
+		return "", errors.New(msg)
+	}
+}
+
+func serve(e exam.E, h *vmready.Handler) (int, vmready.Report) {
+	e.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	var report vmready.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		e.Fatalf("failed to decode report %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, report
+}
+
+func TestHandler(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	e.Run("all ready", func(e exam.E) {
+		code, report := serve(e, &vmready.Handler{
+			Checks: map[string]vmready.Check{
+				"a": ok("version 1"),
+				"b": ok(""),
+			},
+		})
+		exam.Equal(e, env, code, http.StatusOK)
+		exam.Equal(e, env, report, vmready.Report{
+			Ready: true,
+			Checks: map[string]vmready.CheckResult{
+				"a": {Ok: true, Detail: "version 1"},
+				"b": {Ok: true},
+			},
+		})
+	})
+
+	e.Run("one failing", func(e exam.E) {
+		code, report := serve(e, &vmready.Handler{
+			Checks: map[string]vmready.Check{
+				"a": ok(""),
+				"b": failing("broken"),
+			},
+		})
+		exam.Equal(e, env, code, http.StatusServiceUnavailable)
+		exam.Equal(e, env, report, vmready.Report{
+			Ready: false,
+			Checks: map[string]vmready.CheckResult{
+				"a": {Ok: true},
+				"b": {Error: "broken"},
+			},
+		})
+	})
+
+	e.Run("slow check times out", func(e exam.E) {
+		block := make(chan struct{})
+		defer close(block)
+		code, report := serve(e, &vmready.Handler{
+			Checks: map[string]vmready.Check{
+				"a": ok(""),
+				"slow": func(ctx context.Context) (string, error) {
+					<-block
+					return "", nil
+				},
+			},
+			Timeout: 10 * time.Millisecond,
+		})
+		exam.Equal(e, env, code, http.StatusServiceUnavailable)
+		exam.Equal(e, env, report.Checks["a"], vmready.CheckResult{Ok: true})
+		exam.Equal(e, env, report.Checks["slow"].Ok, false)
+		exam.Match(e, env, report.Checks["slow"].Error, match.Len(match.GreaterThan(0)))
+	})
+}
+
+func TestWritable(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	e.Run("writable dir", func(e exam.E) {
+		dir := e.TempDir()
+		_, err := vmready.Writable(dir)(ctx)
+		exam.Nil(e, env, err).Log(err)
+		entries, err := filepath.Glob(filepath.Join(dir, "*"))
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Match(e, env, entries, match.Len(match.Equal(0))).Log("temp file was not removed")
+	})
+
+	e.Run("missing dir", func(e exam.E) {
+		_, err := vmready.Writable(filepath.Join(e.TempDir(), "missing"))(ctx)
+		exam.Match(e, env, err, match.Not(match.Nil()))
+	})
+}
+
+func TestTaskHandlers_NotStarted(t *testing.T) {
+	_, err := vmready.TaskHandlers(&vmtask.Registry{})(context.Background())
+	if err == nil {
+		t.Fatal("TaskHandlers check passed for a registry that was never started")
+	}
+}
+
+func TestDatabaseChecks(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	e.Run("database", func(e exam.E) {
+		_, err := vmready.Database(db)(ctx)
+		exam.Nil(e, env, err).Log(err)
+	})
+
+	e.Run("migrations", func(e exam.E) {
+		_, err := vmready.MigrationVersion(db)(ctx)
+		exam.Nil(e, env, err).Log(err)
+	})
+}
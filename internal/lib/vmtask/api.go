@@ -85,7 +85,7 @@ func ResumeWithState(ctx context.Context, db vmdb.Runner, taskId int, newState [
 // Get retrieves a task by ID.
 func Get(ctx context.Context, db vmdb.Runner, taskId int) (*Task, error) {
 	const sql = `
-		SELECT id, task_type, state, status, worker_id, lease_expires_at, error, parent_id, created_at, updated_at
+		SELECT id, task_type, state, status, worker_id, lease_expires_at, error, parent_id, created_at, updated_at, attempts, run_after, progress
 		FROM tasks
 		WHERE id = $1
 	`
@@ -125,7 +125,7 @@ func CreateChild(ctx context.Context, db vmdb.Runner, parentId int, taskType str
 // GetChildTasks retrieves all child tasks for a given parent task.
 func GetChildTasks(ctx context.Context, db vmdb.Runner, parentId int) ([]Task, error) {
 	const sql = `
-		SELECT id, task_type, state, status, worker_id, lease_expires_at, error, parent_id, created_at, updated_at
+		SELECT id, task_type, state, status, worker_id, lease_expires_at, error, parent_id, created_at, updated_at, attempts, run_after, progress
 		FROM tasks
 		WHERE parent_id = $1
 		ORDER BY created_at
@@ -179,3 +179,28 @@ func cancelRecursive(ctx context.Context, db vmdb.Runner, taskId int) error {
 
 	return nil
 }
+
+// Requeue moves a failed task back to pending so it will be processed again.
+// The error is cleared and the retry budget is reset, so the task gets a fresh
+// set of attempts under its retry policy.
+// Returns true if the task was requeued, false if it wasn't in failed state.
+func Requeue(ctx context.Context, db vmdb.Runner, taskId int) (bool, error) {
+	const sql = `
+		UPDATE tasks
+		SET status = 'pending', error = NULL, attempts = 0, run_after = NOW()
+		WHERE id = $1 AND status = 'failed'
+	`
+	count, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, taskId))
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue task: %w", err)
+	}
+
+	if count > 0 {
+		// Notify workers that there's work to do.
+		if err := notify(ctx, db); err != nil {
+			return false, fmt.Errorf("failed to notify task channel: %w", err)
+		}
+	}
+
+	return count > 0, nil
+}
@@ -3,6 +3,7 @@ package vmtask_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/krelinga/go-libs/exam"
 	"github.com/krelinga/video-manager/internal/lib/vmdb"
@@ -289,3 +290,66 @@ func TestGet_ParentId(t *testing.T) {
 		}
 	})
 }
+
+func TestRequeue(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	t.Run("requeues a failed task", func(t *testing.T) {
+		taskId, err := vmtask.Create(ctx, db, "test-type", nil)
+		if err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+		const failSQL = `
+			UPDATE tasks
+			SET status = 'failed', error = 'boom', attempts = 3, run_after = NOW() + INTERVAL '1 hour'
+			WHERE id = $1
+		`
+		if _, err := vmdb.Exec(ctx, db, vmdb.Positional(failSQL, taskId)); err != nil {
+			t.Fatalf("failed to fail task: %v", err)
+		}
+
+		requeued, err := vmtask.Requeue(ctx, db, taskId)
+		if err != nil {
+			t.Fatalf("failed to requeue task: %v", err)
+		}
+		if !requeued {
+			t.Fatal("Requeue returned false, want true")
+		}
+
+		task, err := vmtask.Get(ctx, db, taskId)
+		if err != nil {
+			t.Fatalf("failed to get task: %v", err)
+		}
+		if task.Status != vmtask.StatusPending {
+			t.Fatalf("task.Status = %q, want %q", task.Status, vmtask.StatusPending)
+		}
+		if task.Error != nil {
+			t.Fatalf("task.Error = %q, want nil", *task.Error)
+		}
+		if task.Attempts != 0 {
+			t.Fatalf("task.Attempts = %d, want 0", task.Attempts)
+		}
+		if task.RunAfter.After(time.Now()) {
+			t.Fatalf("task.RunAfter = %v, want it to be due now", task.RunAfter)
+		}
+	})
+
+	t.Run("does not requeue a task that has not failed", func(t *testing.T) {
+		taskId, err := vmtask.Create(ctx, db, "test-type", nil)
+		if err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+
+		requeued, err := vmtask.Requeue(ctx, db, taskId)
+		if err != nil {
+			t.Fatalf("failed to requeue task: %v", err)
+		}
+		if requeued {
+			t.Fatal("Requeue returned true for a pending task, want false")
+		}
+	})
+}
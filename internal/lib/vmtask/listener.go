@@ -0,0 +1,172 @@
+package vmtask
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/krelinga/video-manager/internal/lib/config"
+)
+
+// closeTimeout bounds how long closing a LISTEN connection may take.
+const closeTimeout = 5 * time.Second
+
+// Health reports on the background goroutines started by StartHandlers.
+type Health struct {
+	// Started is true once StartHandlers has succeeded.
+	Started bool
+	// ScannerRunning is true until the scanner goroutine exits.
+	ScannerRunning bool
+	// ListenerRunning is true until the listener goroutine exits.
+	ListenerRunning bool
+	// ListenerConnected is true while the LISTEN connection to Postgres is up.
+	ListenerConnected bool
+	// ListenerError is why the LISTEN connection is down, if it is.
+	ListenerError error
+}
+
+// Ok reports whether the task handlers are started and working.
+func (h Health) Ok() bool {
+	return h.Started && h.ScannerRunning && h.ListenerRunning && h.ListenerConnected
+}
+
+// healthState is the mutable state behind Health, shared between goroutines.
+type healthState struct {
+	mu     sync.Mutex
+	health Health
+}
+
+func (h *healthState) get() Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.health
+}
+
+func (h *healthState) setScannerStopped() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.health.ScannerRunning = false
+}
+
+func (h *healthState) setListenerStopped() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.health.ListenerRunning = false
+	h.health.ListenerConnected = false
+}
+
+func (h *healthState) setListener(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.health.ListenerConnected = err == nil
+	h.health.ListenerError = err
+}
+
+// listener holds a LISTEN connection open and forwards notifications to the
+// scanner.  If the connection breaks it reconnects with backoff, and then wakes
+// the scanner since notifications may have been missed in the meantime.
+type listener struct {
+	pgConfig config.Postgres
+	health   *healthState
+
+	// events wakes the scanner.
+	events chan<- event
+	// done signals that the listener has stopped.
+	done chan struct{}
+}
+
+// connect opens a new connection and starts listening on the tasks channel.
+func (l *listener) connect(ctx context.Context) (*pgx.Conn, error) {
+	pg, err := pgx.Connect(ctx, l.pgConfig.URL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
+	}
+	if _, err := pg.Exec(ctx, fmt.Sprintf("LISTEN %q;", channelTasks)); err != nil {
+		closeConn(pg)
+		return nil, fmt.Errorf("failed to LISTEN on channel %q: %w", channelTasks, err)
+	}
+	return pg, nil
+}
+
+// run is the main listener loop.  It takes ownership of pg, which must already
+// be listening on the tasks channel.
+func (l *listener) run(ctx context.Context, pg *pgx.Conn) {
+	defer close(l.done)
+	defer l.health.setListenerStopped()
+
+	for {
+		err := l.forward(ctx, pg)
+		closeConn(pg)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.ErrorContext(ctx, "vmtask: lost LISTEN connection", "error", err)
+		l.health.setListener(err)
+
+		if pg = l.reconnect(ctx); pg == nil {
+			return
+		}
+		l.health.setListener(nil)
+		slog.InfoContext(ctx, "vmtask: re-established LISTEN connection")
+
+		// Notifications sent while we were disconnected are lost, so rescan.
+		select {
+		case l.events <- event{}:
+		case <-ctx.Done():
+			closeConn(pg)
+			return
+		}
+	}
+}
+
+// forward dispatches notifications to the scanner until the connection fails
+// or ctx is cancelled.
+func (l *listener) forward(ctx context.Context, pg *pgx.Conn) error {
+	for {
+		notification, err := pg.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if notification.Channel != channelTasks {
+			continue
+		}
+		select {
+		case l.events <- event{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// reconnect retries connect with backoff until it succeeds.
+// Returns nil if ctx is cancelled first.
+func (l *listener) reconnect(ctx context.Context) *pgx.Conn {
+	backoff := initialBackoff
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+
+		pg, err := l.connect(ctx)
+		if err == nil {
+			return pg
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		backoff = min(time.Duration(float64(backoff)*backoffFactor), maxBackoff)
+		slog.ErrorContext(ctx, "vmtask: failed to reconnect LISTEN connection", "error", err, "backoff", backoff)
+		l.health.setListener(err)
+	}
+}
+
+func closeConn(pg *pgx.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	pg.Close(ctx)
+}
@@ -0,0 +1,95 @@
+package vmtask
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+)
+
+func TestRegistry_Health_NotStarted(t *testing.T) {
+	registry := &Registry{}
+	if got := registry.Health(); got != (Health{}) {
+		t.Fatalf("Health() = %+v, want zero value", got)
+	}
+}
+
+func TestListener_Reconnects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	events := make(chan event)
+	health := &healthState{
+		health: Health{
+			Started:           true,
+			ScannerRunning:    true,
+			ListenerRunning:   true,
+			ListenerConnected: true,
+		},
+	}
+	l := &listener{
+		pgConfig: *pg.Config(),
+		health:   health,
+		events:   events,
+		done:     make(chan struct{}),
+	}
+	conn, err := l.connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect listener: %v", err)
+	}
+	go l.run(ctx, conn)
+
+	waitForEvent := func(what string) {
+		t.Helper()
+		select {
+		case <-events:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	// Notifications are forwarded while connected.
+	if err := notify(ctx, db); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+	waitForEvent("notification")
+
+	// Simulate a Postgres restart by killing the LISTEN connection.
+	const killSQL = `
+		SELECT pg_terminate_backend(pid) FROM pg_stat_activity
+		WHERE pid <> pg_backend_pid() AND query LIKE 'LISTEN%'
+	`
+	if _, err := vmdb.Exec(ctx, db, vmdb.Constant(killSQL)); err != nil {
+		t.Fatalf("failed to terminate listener connection: %v", err)
+	}
+
+	// After reconnecting, the listener forces a rescan.
+	waitForEvent("rescan after reconnect")
+	if got := health.get(); !got.Ok() || got.ListenerError != nil {
+		t.Fatalf("health after reconnect = %+v, want ok", got)
+	}
+
+	// And notifications flow again.
+	if err := notify(ctx, db); err != nil {
+		t.Fatalf("failed to notify: %v", err)
+	}
+	waitForEvent("notification after reconnect")
+
+	cancel()
+	select {
+	case <-l.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not stop after context cancellation")
+	}
+	if got := health.get(); got.ListenerRunning || got.Ok() {
+		t.Fatalf("health after stopping = %+v, want listener not running", got)
+	}
+}
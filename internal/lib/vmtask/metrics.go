@@ -0,0 +1,94 @@
+package vmtask
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// countTimeout bounds how long counting tasks may take during a scrape.
+const countTimeout = 5 * time.Second
+
+var (
+	claimLatency = promauto.With(vmmetrics.Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: vmmetrics.Namespace,
+		Subsystem: "task",
+		Name:      "claim_latency_seconds",
+		Help:      "Time from a task becoming claimable until a worker claimed it.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"task_type"})
+
+	handlerDuration = promauto.With(vmmetrics.Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: vmmetrics.Namespace,
+		Subsystem: "task",
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent in Handler.Handle, by the status it returned.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"task_type", "status"})
+
+	handlerFailures = promauto.With(vmmetrics.Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: vmmetrics.Namespace,
+		Subsystem: "task",
+		Name:      "handler_failures_total",
+		Help:      "Number of times Handler.Handle returned StatusFailed.",
+	}, []string{"task_type", "retryable"})
+)
+
+// observeResult records the metrics for a single call to Handler.Handle.
+func observeResult(taskType string, result Result, duration time.Duration) {
+	handlerDuration.WithLabelValues(taskType, string(result.NewStatus)).Observe(duration.Seconds())
+	if result.NewStatus == StatusFailed {
+		handlerFailures.WithLabelValues(taskType, fmt.Sprint(result.Retryable)).Inc()
+	}
+}
+
+// countCollector exports the number of tasks in each task_type and status.
+type countCollector struct {
+	db   vmdb.Runner
+	desc *prometheus.Desc
+}
+
+// NewCountCollector returns a collector that counts the rows of the tasks table
+// by task_type and status each time it is scraped.
+func NewCountCollector(db vmdb.Runner) prometheus.Collector {
+	return &countCollector{
+		db: db,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(vmmetrics.Namespace, "task", "tasks"),
+			"Number of tasks, by task_type and status.",
+			[]string{"task_type", "status"}, nil,
+		),
+	}
+}
+
+func (c *countCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *countCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+	defer cancel()
+
+	const sql = `
+		SELECT task_type, status, COUNT(*)
+		FROM tasks
+		GROUP BY task_type, status
+	`
+	type row struct {
+		TaskType string
+		Status   string
+		Count    int64
+	}
+	err := vmdb.Query(ctx, c.db, vmdb.Constant(sql), func(r row) bool {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(r.Count), r.TaskType, r.Status)
+		return true
+	})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, fmt.Errorf("failed to count tasks: %w", err))
+	}
+}
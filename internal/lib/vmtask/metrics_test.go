@@ -0,0 +1,50 @@
+package vmtask
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveResult(t *testing.T) {
+	observeResult("metrics-type", Completed(), time.Second)
+	observeResult("metrics-type", Failed("boom"), time.Second)
+	observeResult("metrics-type", Retry("flaky"), time.Second)
+	observeResult("metrics-type", Retry("flaky"), time.Second)
+
+	if got := testutil.ToFloat64(handlerFailures.WithLabelValues("metrics-type", "false")); got != 1 {
+		t.Errorf("permanent failures = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(handlerFailures.WithLabelValues("metrics-type", "true")); got != 2 {
+		t.Errorf("retryable failures = %v, want 2", got)
+	}
+}
+
+func TestCountCollector(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	for _, taskType := range []string{"type-a", "type-a", "type-b"} {
+		if _, err := Create(ctx, db, taskType, nil); err != nil {
+			t.Fatalf("failed to create task: %v", err)
+		}
+	}
+
+	const want = `
+# HELP video_manager_task_tasks Number of tasks, by task_type and status.
+# TYPE video_manager_task_tasks gauge
+video_manager_task_tasks{status="pending",task_type="type-a"} 2
+video_manager_task_tasks{status="pending",task_type="type-b"} 1
+`
+	if err := testutil.CollectAndCompare(NewCountCollector(db), strings.NewReader(want)); err != nil {
+		t.Fatalf("unexpected metrics: %v", err)
+	}
+}
@@ -4,14 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
 	"github.com/krelinga/video-manager/internal/lib/config"
 	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
 )
 
 // channelTasks is the notification channel for task events.
@@ -53,6 +53,8 @@ type scanner struct {
 	available <-chan *worker
 	// events receives notifications from Postgres.
 	events <-chan event
+	// finished receives a signal each time a worker finishes a task.
+	finished <-chan struct{}
 	// done signals that the scanner has stopped.
 	done chan struct{}
 }
@@ -63,6 +65,8 @@ func (s *scanner) run(ctx context.Context) {
 
 	backoff := initialBackoff
 	needScan := true // Start with an initial scan.
+	// dueTimer fires when the earliest delayed task becomes due; nil if there is none.
+	var dueTimer <-chan time.Time
 
 	for {
 		if needScan {
@@ -78,7 +82,7 @@ func (s *scanner) run(ctx context.Context) {
 			// Try to claim and assign a task.
 			assigned, err := s.scanAndAssign(ctx, w)
 			if err != nil {
-				log.Printf("vmtask: scanner error: %v (backing off for %v)", err, backoff)
+				slog.ErrorContext(ctx, "vmtask: scanner error", "error", err, "backoff", backoff)
 				// Return worker to pool on error.
 				go func() {
 					select {
@@ -108,6 +112,12 @@ func (s *scanner) run(ctx context.Context) {
 					}
 				}()
 				needScan = false
+
+				// Pending tasks that are not due yet won't generate a NOTIFY when
+				// they become due, so wake ourselves up in time to claim them.
+				if timer, err := s.dueTimer(ctx); err == nil {
+					dueTimer = timer
+				}
 			}
 			// If assigned, continue scanning (there may be more tasks).
 		} else {
@@ -117,6 +127,14 @@ func (s *scanner) run(ctx context.Context) {
 				return
 			case <-s.events:
 				needScan = true
+			case <-dueTimer:
+				dueTimer = nil
+				needScan = true
+			case <-s.finished:
+				// A busy worker may have scheduled a retry since we last looked.
+				if timer, err := s.dueTimer(ctx); err == nil {
+					dueTimer = timer
+				}
 			}
 		}
 	}
@@ -135,26 +153,35 @@ func (s *scanner) scanAndAssign(ctx context.Context, w *worker) (bool, error) {
 	// Claim a task: either pending, or running with expired lease.
 	leaseExpires := time.Now().Add(LeaseDuration)
 
+	// claimable_at is when the task became eligible to be claimed, which is
+	// captured before the UPDATE changes updated_at.
 	const claimSQL = `
-		UPDATE tasks
+		UPDATE tasks t
 		SET status = 'running',
 		    worker_id = @workerId,
 		    lease_expires_at = @leaseExpires
-		WHERE id = (
-			SELECT id FROM tasks
-			WHERE ((status = 'pending')
+		FROM (
+			SELECT id,
+			       CASE WHEN status = 'running' THEN lease_expires_at
+			            ELSE GREATEST(run_after, updated_at)
+			       END AS claimable_at
+			FROM tasks
+			WHERE ((status = 'pending' AND run_after <= NOW())
 			   OR (status = 'running' AND lease_expires_at < NOW()))
 			  AND task_type = ANY(@taskTypes)
 			ORDER BY created_at
 			FOR UPDATE SKIP LOCKED
 			LIMIT 1
-		)
-		RETURNING id, task_type, state
+		) c
+		WHERE t.id = c.id
+		RETURNING t.id, t.task_type, t.state, t.attempts, c.claimable_at
 	`
 	type claimRow struct {
-		Id       int
-		TaskType string
-		State    []byte
+		Id          int
+		TaskType    string
+		State       []byte
+		Attempts    int
+		ClaimableAt time.Time
 	}
 	row, err := vmdb.QueryOne[claimRow](ctx, tx, vmdb.Named(claimSQL, map[string]any{
 		"workerId":     string(w.workerId),
@@ -173,15 +200,16 @@ func (s *scanner) scanAndAssign(ctx context.Context, w *worker) (bool, error) {
 	if err := tx.Commit(ctx); err != nil {
 		return false, fmt.Errorf("failed to commit claim: %w", err)
 	}
+	claimLatency.WithLabelValues(row.TaskType).Observe(max(time.Since(row.ClaimableAt), 0).Seconds())
 
 	// Look up the handler for this task type.
 	handler, exists := s.registry.Get(row.TaskType)
 	if !exists {
 		// No handler registered - this shouldn't happen since we filter by taskTypes,
 		// but handle it gracefully by failing the task.
-		log.Printf("vmtask: no handler registered for task type %q (task %d)", row.TaskType, row.Id)
+		slog.ErrorContext(ctx, "vmtask: no handler registered for task type", vmlog.KeyTaskId, row.Id, vmlog.KeyTaskType, row.TaskType)
 		if err := s.failTaskDirect(ctx, row.Id, fmt.Sprintf("no handler registered for task type %q", row.TaskType)); err != nil {
-			log.Printf("vmtask: failed to mark unhandled task %d as failed: %v", row.Id, err)
+			slog.ErrorContext(ctx, "vmtask: failed to mark unhandled task as failed", vmlog.KeyTaskId, row.Id, "error", err)
 		}
 		// Return worker to pool.
 		go func() {
@@ -199,16 +227,56 @@ func (s *scanner) scanAndAssign(ctx context.Context, w *worker) (bool, error) {
 		taskId:   row.Id,
 		taskType: row.TaskType,
 		state:    row.State,
+		attempts: row.Attempts,
 		handler:  handler,
+		retry:    s.registry.retryPolicy(row.TaskType),
 	}:
 		// Task assigned.
 	case <-ctx.Done():
+		// The worker will never start this task, so don't leave it leased.
+		if err := releaseClaim(ctx, s.db, row.Id, w.workerId); err != nil {
+			slog.ErrorContext(ctx, "vmtask: failed to release task", vmlog.KeyTaskId, row.Id, "error", err)
+		}
 		return false, ctx.Err()
 	}
 
 	return true, nil
 }
 
+// nextRunAfter returns when the earliest pending task becomes due.
+// Returns nil if there are no pending tasks.  The result may be in the past if
+// a due task is locked by another scanner.
+func (s *scanner) nextRunAfter(ctx context.Context) (*time.Time, error) {
+	const sql = `
+		SELECT MIN(run_after) FROM tasks
+		WHERE status = 'pending'
+		  AND task_type = ANY(@taskTypes)
+	`
+	next, err := vmdb.QueryOne[*time.Time](ctx, s.db, vmdb.Named(sql, map[string]any{
+		"taskTypes": s.taskTypes,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query next run_after: %w", err)
+	}
+	return next, nil
+}
+
+// dueTimer returns a channel that fires when the earliest pending task becomes
+// due, or nil if there are no pending tasks.
+func (s *scanner) dueTimer(ctx context.Context) (<-chan time.Time, error) {
+	nextDue, err := s.nextRunAfter(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "vmtask: scanner failed to find next due task", "error", err)
+		return nil, err
+	}
+	if nextDue == nil {
+		return nil, nil
+	}
+	// Never rescan more often than initialBackoff, in case the due task is
+	// locked by another scanner and we keep skipping it.
+	return time.After(max(time.Until(*nextDue), initialBackoff)), nil
+}
+
 // failTaskDirect marks a task as failed without a transaction.
 func (s *scanner) failTaskDirect(ctx context.Context, taskId int, errMsg string) error {
 	const sql = `
@@ -232,25 +300,36 @@ func (r *Registry) StartHandlers(ctx context.Context, pgConfig config.Postgres,
 		workerGoroutines = 1
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
-	pg, err := pgx.Connect(ctx, pgConfig.URL())
+	// Connect the listener before starting anything, so a bad config fails fast.
+	events := make(chan event)
+	health := &healthState{
+		health: Health{
+			Started:           true,
+			ScannerRunning:    true,
+			ListenerRunning:   true,
+			ListenerConnected: true,
+		},
+	}
+	l := &listener{
+		pgConfig: pgConfig,
+		health:   health,
+		events:   events,
+		done:     make(chan struct{}),
+	}
+	pg, err := l.connect(ctx)
 	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to connect to Postgres: %w", err)
+		return err
 	}
-	context.AfterFunc(ctx, func() {
-		pg.Close(ctx)
-	})
 
 	// Get the task types this registry handles.
 	taskTypes := r.Types()
 	if len(taskTypes) == 0 {
-		log.Printf("vmtask: no handlers registered, workers will not claim any tasks")
+		slog.WarnContext(ctx, "vmtask: no handlers registered, workers will not claim any tasks")
 	}
 
 	// Create channels.
 	available := make(chan *worker, workerGoroutines)
-	events := make(chan event)
+	finished := make(chan struct{}, 1)
 
 	// Track all goroutines for Wait().
 	var wg sync.WaitGroup
@@ -263,6 +342,7 @@ func (r *Registry) StartHandlers(ctx context.Context, pgConfig config.Postgres,
 			workerId:  newWorkerId(),
 			work:      make(chan taskAssignment),
 			available: available,
+			finished:  finished,
 			done:      make(chan struct{}),
 		}
 		wg.Add(1)
@@ -279,43 +359,37 @@ func (r *Registry) StartHandlers(ctx context.Context, pgConfig config.Postgres,
 		taskTypes: taskTypes,
 		available: available,
 		events:    events,
+		finished:  finished,
 		done:      make(chan struct{}),
 	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer health.setScannerStopped()
 		s.run(ctx)
 	}()
 
-	// Listen on the tasks channel.
-	if _, err := pg.Exec(ctx, fmt.Sprintf("LISTEN %q;", channelTasks)); err != nil {
-		cancel()
-		return fmt.Errorf("failed to LISTEN on channel %q: %w", channelTasks, err)
+	// Create and start the lease reaper.
+	rp := &reaper{
+		db:        db,
+		taskTypes: taskTypes,
+		interval:  ReapInterval,
+		events:    events,
+		done:      make(chan struct{}),
 	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rp.run(ctx)
+	}()
 
-	// Dispatch notifications to the scanner.
+	// Dispatch notifications to the scanner, reconnecting as needed.
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer cancel()
-		for {
-			if ctx.Err() != nil {
-				return
-			}
-			notification, err := pg.WaitForNotification(ctx)
-			if err != nil {
-				log.Printf("vmtask: error while waiting for notification: %v", err)
-				return
-			}
-			if notification.Channel == channelTasks {
-				select {
-				case events <- event{}:
-				case <-ctx.Done():
-					return
-				}
-			}
-		}
+		l.run(ctx, pg)
 	}()
 
+	r.setHealth(health)
 	return nil
 }
@@ -0,0 +1,69 @@
+package vmtask
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+)
+
+// Progress describes how far a running task has got.  It is stored on the task
+// row as soon as it is reported, so it is visible before the task finishes.
+type Progress struct {
+	// Phase names what the task is doing, e.g. "copy".
+	Phase string `json:"phase"`
+	// Done and Total count units of work, e.g. bytes.  Total may be zero if it
+	// is not known.
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+type progressKey struct{}
+
+// progressReporter writes progress for the task that a worker is processing.
+type progressReporter struct {
+	db       vmdb.Runner
+	taskId   int
+	workerId WorkerId
+}
+
+// withProgressReporter returns a copy of ctx that lets the handler report
+// progress for taskId.  db must not be the transaction the handler runs in,
+// since that is not committed until the handler returns.
+func withProgressReporter(ctx context.Context, db vmdb.Runner, taskId int, workerId WorkerId) context.Context {
+	return context.WithValue(ctx, progressKey{}, &progressReporter{
+		db:       db,
+		taskId:   taskId,
+		workerId: workerId,
+	})
+}
+
+// ReportProgress records the progress of the task being handled with ctx.  It
+// is committed right away, independently of the transaction that the handler
+// runs in.  Outside of a worker, e.g. when a handler is called directly in a
+// test, it does nothing.
+func ReportProgress(ctx context.Context, progress Progress) error {
+	reporter, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok {
+		return nil
+	}
+	progressBytes, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to marshal progress: %w", err)
+	}
+
+	const sql = `
+		UPDATE tasks
+		SET progress = $3::jsonb
+		WHERE id = $1 AND worker_id = $2 AND status = 'running'
+	`
+	count, err := vmdb.Exec(ctx, reporter.db, vmdb.Positional(sql, reporter.taskId, string(reporter.workerId), string(progressBytes)))
+	if err != nil {
+		return fmt.Errorf("failed to report progress: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("task %d no longer owned by this worker", reporter.taskId)
+	}
+	return nil
+}
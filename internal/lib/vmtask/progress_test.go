@@ -0,0 +1,52 @@
+package vmtask
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+)
+
+func TestReportProgress_NoWorker(t *testing.T) {
+	if err := ReportProgress(context.Background(), Progress{Phase: "copy"}); err != nil {
+		t.Fatalf("ReportProgress() outside of a worker = %v, want nil", err)
+	}
+}
+
+func TestReportProgress(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(ctx, db, "test-type", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	const claimSQL = `UPDATE tasks SET status = 'running', worker_id = $2, lease_expires_at = $3 WHERE id = $1`
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(claimSQL, taskId, "worker-1", time.Now().Add(LeaseDuration))); err != nil {
+		t.Fatalf("failed to claim task: %v", err)
+	}
+
+	want := Progress{Phase: "copy", Done: 10, Total: 100}
+	if err := ReportProgress(withProgressReporter(ctx, db, taskId, "worker-1"), want); err != nil {
+		t.Fatalf("ReportProgress() = %v", err)
+	}
+
+	task, err := Get(ctx, db, taskId)
+	if err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+	if task.Progress == nil || *task.Progress != want {
+		t.Fatalf("task.Progress = %+v, want %+v", task.Progress, want)
+	}
+
+	// Another worker can't report progress for the task.
+	if err := ReportProgress(withProgressReporter(ctx, db, taskId, "worker-2"), want); err == nil {
+		t.Fatal("ReportProgress() from another worker = nil, want error")
+	}
+}
@@ -0,0 +1,96 @@
+package vmtask
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
+)
+
+// ReapInterval is how often the reaper looks for tasks with expired leases.
+const ReapInterval = 1 * time.Minute
+
+// reaper periodically reclaims tasks whose worker stopped renewing the lease,
+// and wakes the scanner so they are picked up without waiting for a NOTIFY.
+type reaper struct {
+	db        vmdb.DbRunner
+	taskTypes []string
+	interval  time.Duration
+
+	// events wakes the scanner after tasks have been reclaimed.
+	events chan<- event
+	// done signals that the reaper has stopped.
+	done chan struct{}
+}
+
+// run is the main reaper loop.
+func (r *reaper) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		count, err := r.reap(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "vmtask: reaper error", "error", err)
+			continue
+		}
+		if count == 0 {
+			continue
+		}
+
+		// Wake the scanner so the reclaimed tasks are claimed promptly.
+		select {
+		case r.events <- event{}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reap moves every running task with an expired lease back to pending.
+// Returns the number of tasks reclaimed.
+func (r *reaper) reap(ctx context.Context) (int, error) {
+	// The CTE captures worker_id before the UPDATE clears it, so we can log who lost the task.
+	const sql = `
+		WITH expired AS (
+			SELECT id, worker_id FROM tasks
+			WHERE status = 'running'
+			  AND lease_expires_at < NOW()
+			  AND task_type = ANY(@taskTypes)
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE tasks t
+		SET status = 'pending', worker_id = NULL, lease_expires_at = NULL
+		FROM expired e
+		WHERE t.id = e.id
+		RETURNING t.id, t.task_type, e.worker_id
+	`
+	type reapedRow struct {
+		Id       int
+		TaskType string
+		WorkerId string
+	}
+	var count int
+	err := vmdb.Query(ctx, r.db, vmdb.Named(sql, map[string]any{
+		"taskTypes": r.taskTypes,
+	}), func(row reapedRow) bool {
+		count++
+		slog.WarnContext(ctx, "vmtask: reclaimed task after its lease expired", vmlog.KeyTaskId, row.Id, vmlog.KeyTaskType, row.TaskType, vmlog.KeyWorkerId, row.WorkerId)
+		return true
+	})
+	if err != nil {
+		return count, fmt.Errorf("failed to reclaim expired leases: %w", err)
+	}
+	return count, nil
+}
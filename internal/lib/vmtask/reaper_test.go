@@ -0,0 +1,143 @@
+package vmtask
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+)
+
+// expireLease puts a task into the running state with a lease that has already expired,
+// as if the worker holding it had died.
+func expireLease(t *testing.T, ctx context.Context, db vmdb.Runner, taskId int, workerId string) {
+	t.Helper()
+	const sql = `
+		UPDATE tasks
+		SET status = 'running',
+		    worker_id = $2,
+		    lease_expires_at = NOW() - INTERVAL '1 minute'
+		WHERE id = $1
+	`
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, taskId, workerId)); err != nil {
+		t.Fatalf("failed to expire lease for task %d: %v", taskId, err)
+	}
+}
+
+func TestReaper_ReclaimsExpiredLeases(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	// A task whose worker died.
+	expiredId, err := Create(ctx, db, "type-a", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	expireLease(t, ctx, db, expiredId, "dead-worker")
+
+	// A task whose worker is still alive.
+	liveId, err := Create(ctx, db, "type-a", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	const claimSQL = `
+		UPDATE tasks
+		SET status = 'running', worker_id = $2, lease_expires_at = $3
+		WHERE id = $1
+	`
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(claimSQL, liveId, "live-worker", time.Now().Add(LeaseDuration))); err != nil {
+		t.Fatalf("failed to claim task: %v", err)
+	}
+
+	// An expired task of a type this registry does not handle.
+	otherId, err := Create(ctx, db, "type-b", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	expireLease(t, ctx, db, otherId, "dead-worker")
+
+	r := &reaper{
+		db:        db,
+		taskTypes: []string{"type-a"},
+		interval:  ReapInterval,
+		events:    make(chan event, 1),
+		done:      make(chan struct{}),
+	}
+	count, err := r.reap(ctx)
+	if err != nil {
+		t.Fatalf("reap error: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("reap reclaimed %d tasks, want 1", count)
+	}
+
+	wantStatus := map[int]Status{
+		expiredId: StatusPending,
+		liveId:    StatusRunning,
+		otherId:   StatusRunning,
+	}
+	for id, want := range wantStatus {
+		task, err := Get(ctx, db, id)
+		if err != nil {
+			t.Fatalf("failed to get task %d: %v", id, err)
+		}
+		if task.Status != want {
+			t.Errorf("task %d status = %q, want %q", id, task.Status, want)
+		}
+	}
+
+	expired, err := Get(ctx, db, expiredId)
+	if err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+	if expired.WorkerId != nil || expired.LeaseExpiresAt != nil {
+		t.Fatalf("reclaimed task still has a lease: worker=%v lease=%v", expired.WorkerId, expired.LeaseExpiresAt)
+	}
+}
+
+func TestReaper_WakesScanner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(ctx, db, "type-a", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	expireLease(t, ctx, db, taskId, "dead-worker")
+
+	events := make(chan event)
+	r := &reaper{
+		db:        db,
+		taskTypes: []string{"type-a"},
+		interval:  10 * time.Millisecond,
+		events:    events,
+		done:      make(chan struct{}),
+	}
+	go r.run(ctx)
+
+	select {
+	case <-events:
+		// Good - the scanner was woken.
+	case <-time.After(5 * time.Second):
+		t.Fatal("reaper did not wake the scanner")
+	}
+
+	cancel()
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reaper did not stop after context cancellation")
+	}
+}
@@ -5,12 +5,55 @@ import (
 	"sync"
 )
 
+// registration is everything the Registry knows about a single task type.
+type registration struct {
+	handler Handler
+	retry   RetryPolicy
+}
+
+// RegisterOption customizes how a task type is handled.
+type RegisterOption interface {
+	updateRegistration(*registration)
+}
+
+type registerOptionFunc func(*registration)
+
+func (f registerOptionFunc) updateRegistration(r *registration) {
+	f(r)
+}
+
+// WithRetryPolicy overrides DefaultRetryPolicy for a task type.  Backoff
+// fields that are left at zero are taken from DefaultRetryPolicy, so that a
+// partial policy never retries in a tight loop.
+func WithRetryPolicy(policy RetryPolicy) RegisterOption {
+	if policy.InitialBackoff == 0 {
+		policy.InitialBackoff = DefaultRetryPolicy.InitialBackoff
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	if policy.BackoffFactor == 0 {
+		policy.BackoffFactor = DefaultRetryPolicy.BackoffFactor
+	}
+	return registerOptionFunc(func(r *registration) {
+		r.retry = policy
+	})
+}
+
+// WithMaxAttempts overrides only the MaxAttempts field of the retry policy.
+func WithMaxAttempts(maxAttempts int) RegisterOption {
+	return registerOptionFunc(func(r *registration) {
+		r.retry.MaxAttempts = maxAttempts
+	})
+}
+
 // Registry tracks handler registrations for task types.
 // The zero value is ready to use.
 type Registry struct {
 	mu       sync.RWMutex
-	handlers map[string]Handler
+	handlers map[string]registration
 	wg       *sync.WaitGroup // Set by StartHandlers for Wait() support.
+	health   *healthState    // Set by StartHandlers for Health() support.
 }
 
 // setWaitGroup stores a reference to the WaitGroup used by StartHandlers.
@@ -20,6 +63,26 @@ func (r *Registry) setWaitGroup(wg *sync.WaitGroup) {
 	r.wg = wg
 }
 
+// setHealth stores a reference to the health state maintained by StartHandlers.
+func (r *Registry) setHealth(h *healthState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.health = h
+}
+
+// Health reports whether the goroutines started by StartHandlers are working.
+// It returns the zero Health if StartHandlers has not been called.
+func (r *Registry) Health() Health {
+	r.mu.RLock()
+	h := r.health
+	r.mu.RUnlock()
+
+	if h == nil {
+		return Health{}
+	}
+	return h.get()
+}
+
 // Wait blocks until all worker and scanner goroutines have stopped.
 // This should be called after cancelling the context passed to StartHandlers.
 func (r *Registry) Wait() {
@@ -33,8 +96,9 @@ func (r *Registry) Wait() {
 }
 
 // Register adds a handler for the given task type.
+// Options are applied in order on top of DefaultRetryPolicy.
 // Returns an error if a handler is already registered for the given type.
-func (r *Registry) Register(taskType string, handler Handler) error {
+func (r *Registry) Register(taskType string, handler Handler, opts ...RegisterOption) error {
 	if r == nil {
 		panic("vmtask: Registry is nil")
 	}
@@ -44,20 +108,27 @@ func (r *Registry) Register(taskType string, handler Handler) error {
 
 	// Lazy initialization
 	if r.handlers == nil {
-		r.handlers = make(map[string]Handler)
+		r.handlers = make(map[string]registration)
 	}
 
 	if _, exists := r.handlers[taskType]; exists {
 		return fmt.Errorf("vmtask: handler already registered for task type %q", taskType)
 	}
-	r.handlers[taskType] = handler
+	reg := registration{
+		handler: handler,
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt.updateRegistration(&reg)
+	}
+	r.handlers[taskType] = reg
 	return nil
 }
 
 // MustRegister adds a handler for the given task type.
 // Panics if a handler is already registered for the given type.
-func (r *Registry) MustRegister(taskType string, handler Handler) {
-	if err := r.Register(taskType, handler); err != nil {
+func (r *Registry) MustRegister(taskType string, handler Handler, opts ...RegisterOption) {
+	if err := r.Register(taskType, handler, opts...); err != nil {
 		panic(err)
 	}
 }
@@ -73,8 +144,21 @@ func (r *Registry) Get(taskType string) (Handler, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	handler, exists := r.handlers[taskType]
-	return handler, exists
+	reg, exists := r.handlers[taskType]
+	return reg.handler, exists
+}
+
+// retryPolicy returns the retry policy for the given task type.
+// Returns DefaultRetryPolicy if the task type is not registered.
+func (r *Registry) retryPolicy(taskType string) RetryPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reg, exists := r.handlers[taskType]
+	if !exists {
+		return DefaultRetryPolicy
+	}
+	return reg.retry
 }
 
 // Types returns a list of all registered task types.
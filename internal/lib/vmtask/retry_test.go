@@ -0,0 +1,338 @@
+package vmtask
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+)
+
+// retryHandler always fails with a retryable error.
+type retryHandler struct{}
+
+func (h *retryHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, state []byte) Result {
+	return Retry("flaky")
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    10,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     10 * time.Second,
+		BackoffFactor:  2.0,
+	}
+	tests := []struct {
+		failedAttempts int
+		want           time.Duration
+	}{
+		{failedAttempts: 1, want: 1 * time.Second},
+		{failedAttempts: 2, want: 2 * time.Second},
+		{failedAttempts: 3, want: 4 * time.Second},
+		{failedAttempts: 4, want: 8 * time.Second},
+		{failedAttempts: 5, want: 10 * time.Second},
+		{failedAttempts: 50, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.failedAttempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failedAttempts, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_RetryPolicy(t *testing.T) {
+	custom := RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		BackoffFactor:  3.0,
+	}
+	registry := &Registry{}
+	registry.MustRegister("default", &retryHandler{})
+	registry.MustRegister("custom", &retryHandler{}, WithRetryPolicy(custom))
+	registry.MustRegister("attempts", &retryHandler{}, WithMaxAttempts(7))
+	registry.MustRegister("partial", &retryHandler{}, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+	if got := registry.retryPolicy("default"); got != DefaultRetryPolicy {
+		t.Errorf("default policy = %+v, want %+v", got, DefaultRetryPolicy)
+	}
+	if got := registry.retryPolicy("custom"); got != custom {
+		t.Errorf("custom policy = %+v, want %+v", got, custom)
+	}
+	wantAttempts := DefaultRetryPolicy
+	wantAttempts.MaxAttempts = 7
+	if got := registry.retryPolicy("attempts"); got != wantAttempts {
+		t.Errorf("attempts policy = %+v, want %+v", got, wantAttempts)
+	}
+	// Zero backoff fields would retry in a tight loop.
+	wantPartial := DefaultRetryPolicy
+	wantPartial.MaxAttempts = 3
+	if got := registry.retryPolicy("partial"); got != wantPartial {
+		t.Errorf("partial policy = %+v, want %+v", got, wantPartial)
+	}
+}
+
+func TestWorker_RetryableFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(ctx, db, "flaky-type", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	registry := &Registry{}
+	registry.MustRegister("flaky-type", &retryHandler{}, WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+		BackoffFactor:  2.0,
+	}))
+
+	available := make(chan *worker, 1)
+	w := &worker{
+		db:        db,
+		workerId:  newWorkerId(),
+		work:      make(chan taskAssignment, 1),
+		available: available,
+		done:      make(chan struct{}),
+	}
+	s := &scanner{
+		db:        db,
+		registry:  registry,
+		taskTypes: registry.Types(),
+		available: available,
+		events:    make(chan event, 1),
+		done:      make(chan struct{}),
+	}
+
+	// First attempt fails and is scheduled for later.
+	assigned, err := s.scanAndAssign(ctx, w)
+	if err != nil || !assigned {
+		t.Fatalf("first scan: assigned=%v err=%v", assigned, err)
+	}
+	w.processTask(ctx, <-w.work)
+
+	task, err := Get(ctx, db, taskId)
+	if err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+	if task.Status != StatusPending {
+		t.Fatalf("task.Status = %q, want %q", task.Status, StatusPending)
+	}
+	if task.Attempts != 1 {
+		t.Fatalf("task.Attempts = %d, want 1", task.Attempts)
+	}
+	if !task.RunAfter.After(time.Now().Add(30 * time.Minute)) {
+		t.Fatalf("task.RunAfter = %v, want about an hour from now", task.RunAfter)
+	}
+
+	// The task is not due yet, so the scanner must skip it.
+	assigned, err = s.scanAndAssign(ctx, w)
+	if err != nil {
+		t.Fatalf("second scan error: %v", err)
+	}
+	if assigned {
+		t.Fatal("second scan should not claim a task that is not due")
+	}
+
+	// Make the task due, and let the second (final) attempt fail.
+	const dueSQL = `UPDATE tasks SET run_after = NOW() - INTERVAL '1 second' WHERE id = $1`
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(dueSQL, taskId)); err != nil {
+		t.Fatalf("failed to make task due: %v", err)
+	}
+	assigned, err = s.scanAndAssign(ctx, w)
+	if err != nil || !assigned {
+		t.Fatalf("third scan: assigned=%v err=%v", assigned, err)
+	}
+	w.processTask(ctx, <-w.work)
+
+	task, err = Get(ctx, db, taskId)
+	if err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+	if task.Status != StatusFailed {
+		t.Fatalf("task.Status = %q, want %q", task.Status, StatusFailed)
+	}
+	if task.Error == nil || !strings.Contains(*task.Error, "gave up after 2 attempts") {
+		t.Fatalf("task.Error = %v, want it to mention giving up", task.Error)
+	}
+}
+
+// progressHandler fails every other attempt with a retryable error, and makes
+// progress on the others.
+type progressHandler struct {
+	calls atomic.Int32
+}
+
+func (h *progressHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, state []byte) Result {
+	call := h.calls.Add(1)
+	if call%2 == 1 {
+		return Retry("flaky")
+	}
+	return Pending([]byte(fmt.Sprintf(`{"step": %d}`, call)))
+}
+
+func TestWorker_ProgressResetsAttempts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(ctx, db, "progress-type", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	registry := &Registry{}
+	registry.MustRegister("progress-type", &progressHandler{}, WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+		BackoffFactor:  2.0,
+	}))
+
+	available := make(chan *worker, 1)
+	w := &worker{
+		db:        db,
+		workerId:  newWorkerId(),
+		work:      make(chan taskAssignment, 1),
+		available: available,
+		done:      make(chan struct{}),
+	}
+	s := &scanner{
+		db:        db,
+		registry:  registry,
+		taskTypes: registry.Types(),
+		available: available,
+		events:    make(chan event, 1),
+		done:      make(chan struct{}),
+	}
+	const dueSQL = `UPDATE tasks SET run_after = NOW() - INTERVAL '1 second' WHERE id = $1`
+	run := func(step string) *Task {
+		if _, err := vmdb.Exec(ctx, db, vmdb.Positional(dueSQL, taskId)); err != nil {
+			t.Fatalf("%s: failed to make task due: %v", step, err)
+		}
+		assigned, err := s.scanAndAssign(ctx, w)
+		if err != nil || !assigned {
+			t.Fatalf("%s: assigned=%v err=%v", step, assigned, err)
+		}
+		w.processTask(ctx, <-w.work)
+		task, err := Get(ctx, db, taskId)
+		if err != nil {
+			t.Fatalf("%s: failed to get task: %v", step, err)
+		}
+		return task
+	}
+
+	if task := run("first attempt"); task.Attempts != 1 {
+		t.Fatalf("first attempt: task.Attempts = %d, want 1", task.Attempts)
+	}
+
+	// Progress forgets the failed attempt, and the next step may run now.
+	task := run("progress")
+	if task.Status != StatusPending || task.Attempts != 0 {
+		t.Fatalf("progress: task.Status = %q, task.Attempts = %d, want %q and 0", task.Status, task.Attempts, StatusPending)
+	}
+	if task.RunAfter.After(time.Now()) {
+		t.Fatalf("progress: task.RunAfter = %v, want it to be due", task.RunAfter)
+	}
+
+	// Under MaxAttempts of 2, this failure would be final without the reset.
+	task = run("second failure")
+	if task.Status != StatusPending || task.Attempts != 1 {
+		t.Fatalf("second failure: task.Status = %q, task.Attempts = %d, want %q and 1", task.Status, task.Attempts, StatusPending)
+	}
+}
+
+// flakyOnceHandler fails its first attempt with a retryable error, and
+// completes after that.
+type flakyOnceHandler struct {
+	calls atomic.Int32
+}
+
+func (h *flakyOnceHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, state []byte) Result {
+	if h.calls.Add(1) == 1 {
+		// Give the scanner time to go idle with the other worker.
+		time.Sleep(100 * time.Millisecond)
+		return Retry("flaky")
+	}
+	return Completed()
+}
+
+func TestScanner_RunsRetryFromBusyWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(ctx, db, "flaky-type", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	registry := &Registry{}
+	registry.MustRegister("flaky-type", &flakyOnceHandler{}, WithRetryPolicy(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     200 * time.Millisecond,
+		BackoffFactor:  2.0,
+	}))
+
+	// Nothing is sent on events, so the scanner can only find the retry on
+	// its own.
+	available := make(chan *worker, 2)
+	finished := make(chan struct{}, 1)
+	for range 2 {
+		w := &worker{
+			db:        db,
+			workerId:  newWorkerId(),
+			work:      make(chan taskAssignment),
+			available: available,
+			finished:  finished,
+			done:      make(chan struct{}),
+		}
+		go w.run(ctx)
+	}
+	s := &scanner{
+		db:        db,
+		registry:  registry,
+		taskTypes: registry.Types(),
+		available: available,
+		events:    make(chan event),
+		finished:  finished,
+		done:      make(chan struct{}),
+	}
+	go s.run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, err := Get(ctx, db, taskId)
+		if err != nil {
+			t.Fatalf("failed to get task: %v", err)
+		}
+		if task.Status == StatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task.Status = %q, want %q", task.Status, StatusCompleted)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
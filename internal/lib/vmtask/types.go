@@ -35,6 +35,13 @@ type Task struct {
 	ParentId       *int
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
+	// Attempts is the number of times the task has failed with a retryable
+	// error since its handler last returned a new pending or waiting state.
+	Attempts int
+	// RunAfter is the earliest time at which a pending task may be claimed.
+	RunAfter time.Time
+	// Progress is the last progress reported by the handler, if any.
+	Progress *Progress
 }
 
 // Result is returned by a Handler to indicate how the task should proceed.
@@ -45,6 +52,9 @@ type Result struct {
 	NewStatus Status
 	// Error is set when NewStatus is StatusFailed.
 	Error string
+	// Retryable is set when a StatusFailed result should be retried later,
+	// according to the retry policy registered for the task type.
+	Retryable bool
 }
 
 // Handler processes a task and returns a Result indicating next steps.
@@ -53,17 +63,19 @@ type Result struct {
 // - StatusRunning: should not be returned (system manages this)
 // - StatusWaiting: pause until external event resumes the task
 // - StatusCompleted: task finished successfully
-// - StatusFailed: task encountered a permanent error
+// - StatusFailed: task encountered an error (retried later if created with Retry())
 type Handler interface {
 	Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, state []byte) Result
 }
 
-// Pending returns a Result that re-queues the task with updated state.
+// Pending returns a Result that re-queues the task with updated state.  If
+// newState is not nil, the failed attempts of the task are forgotten.
 func Pending(newState []byte) Result {
 	return Result{NewState: newState, NewStatus: StatusPending}
 }
 
-// Waiting returns a Result that pauses the task until resumed externally.
+// Waiting returns a Result that pauses the task until resumed externally.  If
+// newState is not nil, the failed attempts of the task are forgotten.
 func Waiting(newState []byte) Result {
 	return Result{NewState: newState, NewStatus: StatusWaiting}
 }
@@ -82,3 +94,49 @@ func CompletedWithState(newState []byte) Result {
 func Failed(err string) Result {
 	return Result{NewStatus: StatusFailed, Error: err}
 }
+
+// Retry returns a Result indicating a transient failure.  The task is re-queued
+// with exponential backoff until the registered maximum number of attempts is
+// reached, at which point it fails permanently with err.
+func Retry(err string) Result {
+	return Result{NewStatus: StatusFailed, Error: err, Retryable: true}
+}
+
+// RetryWithState is like Retry, but also persists updated state for the next attempt.
+func RetryWithState(newState []byte, err string) Result {
+	return Result{NewState: newState, NewStatus: StatusFailed, Error: err, Retryable: true}
+}
+
+// RetryPolicy controls how retryable failures are handled for a task type.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a task may be run before a
+	// retryable failure becomes permanent.  Values below 1 are treated as 1.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.  If zero,
+	// WithRetryPolicy uses that of DefaultRetryPolicy.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.  If zero, WithRetryPolicy
+	// uses that of DefaultRetryPolicy.
+	MaxBackoff time.Duration
+	// BackoffFactor multiplies the delay after each failed attempt.  If zero,
+	// WithRetryPolicy uses that of DefaultRetryPolicy.
+	BackoffFactor float64
+}
+
+// DefaultRetryPolicy is used for task types registered without WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Second,
+	MaxBackoff:     10 * time.Minute,
+	BackoffFactor:  2.0,
+}
+
+// Backoff returns how long to wait before the next attempt, given the number
+// of attempts that have failed so far.
+func (p RetryPolicy) Backoff(failedAttempts int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < failedAttempts && delay < p.MaxBackoff; i++ {
+		delay = time.Duration(float64(delay) * p.BackoffFactor)
+	}
+	return min(delay, p.MaxBackoff)
+}
@@ -2,11 +2,13 @@ package vmtask
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
 )
 
 const (
@@ -17,12 +19,20 @@ const (
 	HeartbeatInterval = 1 * time.Minute
 )
 
+// errLostLease is returned when a worker tries to record the result of a task
+// that it no longer holds, because the task was cancelled or reclaimed while
+// its handler ran.
+var errLostLease = errors.New("task is no longer leased by this worker")
+
 // taskAssignment represents a claimed task ready to be processed by a worker.
 type taskAssignment struct {
 	taskId   int
 	taskType string
 	state    []byte
+	// attempts is the number of earlier attempts that failed with a retryable error.
+	attempts int
 	handler  Handler
+	retry    RetryPolicy
 }
 
 // worker processes tasks assigned by the scanner.
@@ -34,6 +44,9 @@ type worker struct {
 	work chan taskAssignment
 	// available signals the scanner that this worker is ready for work.
 	available chan<- *worker
+	// finished signals the scanner that this worker finished a task, which
+	// may have scheduled a task to run later.  It may be nil.
+	finished chan<- struct{}
 	// done signals that this worker has stopped.
 	done chan struct{}
 }
@@ -59,13 +72,35 @@ func (w *worker) run(ctx context.Context) {
 			if !ok {
 				return
 			}
+			if ctx.Err() != nil {
+				// Shutting down; let another worker have the task rather than
+				// starting it only to have it cancelled.
+				if err := releaseClaim(ctx, w.db, assignment.taskId, w.workerId); err != nil {
+					slog.ErrorContext(ctx, "vmtask: failed to release task", vmlog.KeyTaskId, assignment.taskId, "error", err)
+				}
+				return
+			}
 			w.processTask(ctx, assignment)
+			select {
+			case w.finished <- struct{}{}:
+			default:
+				// The scanner has a signal it hasn't seen yet.
+			}
 		}
 	}
 }
 
 // processTask handles a single task assignment.
 func (w *worker) processTask(ctx context.Context, assignment taskAssignment) {
+	// Everything logged while processing the task, including by the handler,
+	// is tagged with the task and worker.
+	ctx = vmlog.With(ctx,
+		slog.Int(vmlog.KeyTaskId, assignment.taskId),
+		slog.String(vmlog.KeyTaskType, assignment.taskType),
+		slog.String(vmlog.KeyWorkerId, string(w.workerId)),
+	)
+	ctx = withProgressReporter(ctx, w.db, assignment.taskId, w.workerId)
+
 	// Set up heartbeat to renew lease while processing.
 	heartbeatCtx, cancelHeartbeat := context.WithCancel(ctx)
 	defer cancelHeartbeat()
@@ -74,25 +109,32 @@ func (w *worker) processTask(ctx context.Context, assignment taskAssignment) {
 	// Begin a new transaction for the handler.
 	tx, err := w.db.Begin(ctx, vmdb.WithReadCommitted())
 	if err != nil {
-		log.Printf("vmtask: failed to begin transaction for task %d: %v", assignment.taskId, err)
+		slog.ErrorContext(ctx, "vmtask: failed to begin transaction", "error", err)
 		return
 	}
 	defer tx.Rollback(ctx)
 
 	// Execute the handler.
+	start := time.Now()
 	result := assignment.handler.Handle(ctx, tx, assignment.taskId, assignment.taskType, assignment.state)
+	observeResult(assignment.taskType, result, time.Since(start))
 
 	// Stop heartbeat before updating final state.
 	cancelHeartbeat()
 
 	// Apply the result.
-	if err := w.applyResult(ctx, tx, assignment.taskId, result); err != nil {
-		log.Printf("vmtask: failed to apply result for task %d: %v", assignment.taskId, err)
+	if err := w.applyResult(ctx, tx, assignment, result); errors.Is(err, errLostLease) {
+		// Whoever took the task over decides what happens to it, so the
+		// handler's changes are rolled back.
+		slog.WarnContext(ctx, "vmtask: discarding result of task that was cancelled or reclaimed")
+		return
+	} else if err != nil {
+		slog.ErrorContext(ctx, "vmtask: failed to apply result", "error", err)
 		return
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		log.Printf("vmtask: failed to commit transaction for task %d: %v", assignment.taskId, err)
+		slog.ErrorContext(ctx, "vmtask: failed to commit transaction", "error", err)
 		return
 	}
 }
@@ -108,7 +150,7 @@ func (w *worker) heartbeat(ctx context.Context, taskId int) {
 			return
 		case <-ticker.C:
 			if err := w.renewLease(ctx, taskId); err != nil {
-				log.Printf("vmtask: failed to renew lease for task %d: %v", taskId, err)
+				slog.WarnContext(ctx, "vmtask: failed to renew lease", "error", err)
 				// Continue trying - the main transaction will fail if we truly lost the lease.
 			}
 		}
@@ -135,7 +177,8 @@ func (w *worker) renewLease(ctx context.Context, taskId int) error {
 }
 
 // applyResult updates the task based on the handler's result.
-func (w *worker) applyResult(ctx context.Context, tx vmdb.Runner, taskId int, result Result) error {
+func (w *worker) applyResult(ctx context.Context, tx vmdb.Runner, assignment taskAssignment, result Result) error {
+	taskId := assignment.taskId
 	switch result.NewStatus {
 	case StatusPending:
 		return w.updateTaskState(ctx, tx, taskId, result.NewState, StatusPending)
@@ -147,7 +190,15 @@ func (w *worker) applyResult(ctx context.Context, tx vmdb.Runner, taskId int, re
 		}
 		return w.maybeResumeParent(ctx, tx, taskId)
 	case StatusFailed:
-		if err := w.failTask(ctx, tx, taskId, result.Error); err != nil {
+		errMsg := result.Error
+		if result.Retryable {
+			failedAttempts := assignment.attempts + 1
+			if failedAttempts < max(assignment.retry.MaxAttempts, 1) {
+				return w.retryTask(ctx, tx, taskId, result.NewState, failedAttempts, assignment.retry.Backoff(failedAttempts), errMsg)
+			}
+			errMsg = fmt.Sprintf("%s (gave up after %d attempts)", errMsg, failedAttempts)
+		}
+		if err := w.failTask(ctx, tx, taskId, errMsg); err != nil {
 			return err
 		}
 		return w.maybeResumeParent(ctx, tx, taskId)
@@ -158,7 +209,9 @@ func (w *worker) applyResult(ctx context.Context, tx vmdb.Runner, taskId int, re
 	}
 }
 
-// updateTaskState updates state and status, clearing lease info.
+// updateTaskState updates state and status, clearing lease info.  A new state
+// means the handler made progress, so the task gets a fresh set of attempts
+// under its retry policy, and may run again right away.
 func (w *worker) updateTaskState(ctx context.Context, tx vmdb.Runner, taskId int, newState []byte, status Status) error {
 	var sql string
 	var params []any
@@ -166,22 +219,34 @@ func (w *worker) updateTaskState(ctx context.Context, tx vmdb.Runner, taskId int
 	if newState != nil {
 		sql = `
 			UPDATE tasks
-			SET state = $2, status = $3, worker_id = NULL, lease_expires_at = NULL
-			WHERE id = $1
+			SET state = $2, status = $3, attempts = 0, run_after = NOW(),
+			    worker_id = NULL, lease_expires_at = NULL
+			WHERE id = $1 AND worker_id = $4 AND status = 'running'
 		`
-		params = []any{taskId, newState, string(status)}
+		params = []any{taskId, newState, string(status), string(w.workerId)}
 	} else {
 		sql = `
 			UPDATE tasks
 			SET status = $2, worker_id = NULL, lease_expires_at = NULL
-			WHERE id = $1
+			WHERE id = $1 AND worker_id = $3 AND status = 'running'
 		`
-		params = []any{taskId, string(status)}
+		params = []any{taskId, string(status), string(w.workerId)}
 	}
 
-	if _, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, params...)); err != nil {
+	count, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, params...))
+	if err != nil {
 		return fmt.Errorf("failed to update task state: %w", err)
 	}
+	if count == 0 {
+		return errLostLease
+	}
+
+	if status == StatusPending {
+		// Notify workers that there's work to do.
+		if err := notify(ctx, tx); err != nil {
+			return fmt.Errorf("failed to notify task channel: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -194,21 +259,25 @@ func (w *worker) completeTask(ctx context.Context, tx vmdb.Runner, taskId int, n
 		sql = `
 			UPDATE tasks
 			SET state = $2, status = 'completed', worker_id = NULL, lease_expires_at = NULL
-			WHERE id = $1
+			WHERE id = $1 AND worker_id = $3 AND status = 'running'
 		`
-		params = []any{taskId, newState}
+		params = []any{taskId, newState, string(w.workerId)}
 	} else {
 		sql = `
 			UPDATE tasks
 			SET status = 'completed', worker_id = NULL, lease_expires_at = NULL
-			WHERE id = $1
+			WHERE id = $1 AND worker_id = $2 AND status = 'running'
 		`
-		params = []any{taskId}
+		params = []any{taskId, string(w.workerId)}
 	}
 
-	if _, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, params...)); err != nil {
+	count, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, params...))
+	if err != nil {
 		return fmt.Errorf("failed to complete task: %w", err)
 	}
+	if count == 0 {
+		return errLostLease
+	}
 	return nil
 }
 
@@ -217,11 +286,86 @@ func (w *worker) failTask(ctx context.Context, tx vmdb.Runner, taskId int, errMs
 	const sql = `
 		UPDATE tasks
 		SET status = 'failed', error = $2, worker_id = NULL, lease_expires_at = NULL
-		WHERE id = $1
+		WHERE id = $1 AND worker_id = $3 AND status = 'running'
 	`
-	if _, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, taskId, errMsg)); err != nil {
+	count, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, taskId, errMsg, string(w.workerId)))
+	if err != nil {
 		return fmt.Errorf("failed to fail task: %w", err)
 	}
+	if count == 0 {
+		return errLostLease
+	}
+	return nil
+}
+
+// retryTask re-queues a task after a retryable failure, delaying it by backoff.
+func (w *worker) retryTask(ctx context.Context, tx vmdb.Runner, taskId int, newState []byte, failedAttempts int, backoff time.Duration, errMsg string) error {
+	runAfter := time.Now().Add(backoff)
+	slog.WarnContext(ctx, "vmtask: task failed, will retry", "attempt", failedAttempts, "backoff", backoff, "error", errMsg)
+
+	var sql string
+	var params []any
+
+	if newState != nil {
+		sql = `
+			UPDATE tasks
+			SET state = $4, status = 'pending', attempts = $2, run_after = $3,
+			    worker_id = NULL, lease_expires_at = NULL
+			WHERE id = $1 AND worker_id = $5 AND status = 'running'
+		`
+		params = []any{taskId, failedAttempts, runAfter, newState, string(w.workerId)}
+	} else {
+		sql = `
+			UPDATE tasks
+			SET status = 'pending', attempts = $2, run_after = $3,
+			    worker_id = NULL, lease_expires_at = NULL
+			WHERE id = $1 AND worker_id = $4 AND status = 'running'
+		`
+		params = []any{taskId, failedAttempts, runAfter, string(w.workerId)}
+	}
+
+	count, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, params...))
+	if err != nil {
+		return fmt.Errorf("failed to schedule task retry: %w", err)
+	}
+	if count == 0 {
+		return errLostLease
+	}
+
+	// The retry isn't due yet, but scanners need to learn when it will be.
+	if err := notify(ctx, tx); err != nil {
+		return fmt.Errorf("failed to notify task channel: %w", err)
+	}
+	return nil
+}
+
+// releaseTimeout bounds how long releasing a claimed task may take during shutdown.
+const releaseTimeout = 5 * time.Second
+
+// releaseClaim moves a task that was claimed by workerId, but never handed to its
+// handler, back to pending.  This lets another worker claim it right away instead
+// of waiting for the lease to expire.  It is called during shutdown, so it keeps
+// going even if ctx has been cancelled.
+func releaseClaim(ctx context.Context, db vmdb.Runner, taskId int, workerId WorkerId) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+
+	const sql = `
+		UPDATE tasks
+		SET status = 'pending', worker_id = NULL, lease_expires_at = NULL
+		WHERE id = $1 AND worker_id = $2 AND status = 'running'
+	`
+	count, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, taskId, string(workerId)))
+	if err != nil {
+		return fmt.Errorf("failed to release task: %w", err)
+	}
+
+	if count > 0 {
+		// Notify workers in other processes that there's work to do.
+		if err := notify(ctx, db); err != nil {
+			return fmt.Errorf("failed to notify task channel: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -3,6 +3,7 @@ package vmtask
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -208,6 +209,153 @@ func TestWorker_ProcessesAssignment(t *testing.T) {
 	}
 }
 
+// cancellingHandler cancels its own task, like a user would while it runs,
+// and then reports success.
+type cancellingHandler struct {
+	db vmdb.DbRunner
+}
+
+func (h *cancellingHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, state []byte) Result {
+	if err := Cancel(ctx, h.db, taskId); err != nil {
+		return Failed(err.Error())
+	}
+	return Completed()
+}
+
+func TestWorker_DiscardsResultOfCancelledTask(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(ctx, db, "test-type", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	const claimSQL = `
+		UPDATE tasks
+		SET status = 'running',
+		    worker_id = $1,
+		    lease_expires_at = $2
+		WHERE id = $3
+	`
+	workerId := newWorkerId()
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(claimSQL, string(workerId), time.Now().Add(LeaseDuration), taskId)); err != nil {
+		t.Fatalf("failed to claim task: %v", err)
+	}
+
+	w := &worker{
+		db:        db,
+		workerId:  workerId,
+		work:      make(chan taskAssignment, 1),
+		available: make(chan *worker, 1),
+		done:      make(chan struct{}),
+	}
+	w.processTask(ctx, taskAssignment{
+		taskId:   taskId,
+		taskType: "test-type",
+		handler:  &cancellingHandler{db: db},
+	})
+
+	// The cancellation wins over the handler's result.
+	task, err := Get(ctx, db, taskId)
+	if err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+	if task.Status != StatusFailed {
+		t.Fatalf("task.Status = %q, want %q", task.Status, StatusFailed)
+	}
+	if task.Error == nil || *task.Error != "cancelled" {
+		t.Fatalf("task.Error = %v, want 'cancelled'", task.Error)
+	}
+}
+
+// pendingOnceHandler asks to run again after its first attempt, and
+// completes after that.
+type pendingOnceHandler struct {
+	calls atomic.Int32
+}
+
+func (h *pendingOnceHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, state []byte) Result {
+	if h.calls.Add(1) == 1 {
+		// Give the scanner time to go idle with the other worker.
+		time.Sleep(100 * time.Millisecond)
+		return Pending([]byte(`{"step":2}`))
+	}
+	return Completed()
+}
+
+func TestWorker_PendingResultWakesScanner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(ctx, db, "two-step", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	registry := &Registry{}
+	registry.MustRegister("two-step", &pendingOnceHandler{})
+
+	// The workers don't tell the scanner when they finish, so only a NOTIFY
+	// can wake it up.
+	events := make(chan event)
+	l := &listener{
+		pgConfig: *pg.Config(),
+		health:   &healthState{},
+		events:   events,
+		done:     make(chan struct{}),
+	}
+	conn, err := l.connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect listener: %v", err)
+	}
+	go l.run(ctx, conn)
+
+	available := make(chan *worker, 2)
+	for range 2 {
+		w := &worker{
+			db:        db,
+			workerId:  newWorkerId(),
+			work:      make(chan taskAssignment),
+			available: available,
+			done:      make(chan struct{}),
+		}
+		go w.run(ctx)
+	}
+	s := &scanner{
+		db:        db,
+		registry:  registry,
+		taskTypes: registry.Types(),
+		available: available,
+		events:    events,
+		done:      make(chan struct{}),
+	}
+	go s.run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, err := Get(ctx, db, taskId)
+		if err != nil {
+			t.Fatalf("failed to get task: %v", err)
+		}
+		if task.Status == StatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task.Status = %q, want %q", task.Status, StatusCompleted)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 func TestRegistry_Wait_NoHandlersStarted(t *testing.T) {
 	// Wait() should not block if StartHandlers was never called.
 	registry := &Registry{}
@@ -224,3 +372,78 @@ func TestRegistry_Wait_NoHandlersStarted(t *testing.T) {
 		t.Fatal("Wait() should return immediately if StartHandlers was never called")
 	}
 }
+
+func TestScanner_ReleasesClaimOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := exam.New(t)
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	taskId, err := Create(context.Background(), db, "test-type", nil)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+
+	registry := &Registry{}
+	registry.MustRegister("test-type", &trackingHandler{complete: true})
+
+	// Nothing ever reads from work, as if the worker stopped during shutdown.
+	w := &worker{
+		db:       db,
+		workerId: newWorkerId(),
+		work:     make(chan taskAssignment),
+		done:     make(chan struct{}),
+	}
+	s := &scanner{
+		db:        db,
+		registry:  registry,
+		taskTypes: registry.Types(),
+		done:      make(chan struct{}),
+	}
+
+	scanErr := make(chan error, 1)
+	go func() {
+		_, err := s.scanAndAssign(ctx, w)
+		scanErr <- err
+	}()
+
+	// Wait for the claim to be committed, then shut down.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		task, err := Get(context.Background(), db, taskId)
+		if err != nil {
+			t.Fatalf("failed to get task: %v", err)
+		}
+		if task.Status == StatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("scanner did not claim the task")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-scanErr:
+		if err == nil {
+			t.Fatal("scanAndAssign succeeded, want a cancellation error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scanAndAssign did not return after cancellation")
+	}
+
+	task, err := Get(context.Background(), db, taskId)
+	if err != nil {
+		t.Fatalf("failed to get task: %v", err)
+	}
+	if task.Status != StatusPending {
+		t.Fatalf("task.Status = %q, want %q", task.Status, StatusPending)
+	}
+	if task.WorkerId != nil || task.LeaseExpiresAt != nil {
+		t.Fatalf("released task still has a lease: worker=%v lease=%v", task.WorkerId, task.LeaseExpiresAt)
+	}
+}
@@ -0,0 +1,126 @@
+package vmtest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+)
+
+// WriteDvd writes a tiny synthetic DVD with the given titles to dir: a
+// VIDEO_TS folder with VIDEO_TS.IFO, one VTS_xx_0.IFO per title set, and a
+// VOB file.  Titles must be numbered from 1 in order, their title sets must be
+// numbered from 1, and their durations must be whole frames at 25 fps.  The
+// audio and subtitles of a title set are taken from its first title.
+// vmdisc.ParseDvd returns titles as given.
+func WriteDvd(e exam.E, dir string, titles []vmdisc.DvdTitle) {
+	e.Helper()
+	videoTs := filepath.Join(dir, "VIDEO_TS")
+	if err := os.MkdirAll(videoTs, 0755); err != nil {
+		e.Fatalf("could not create VIDEO_TS directory: %v", err)
+	}
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(videoTs, name), data, 0644); err != nil {
+			e.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	// VIDEO_TS.IFO has the title search pointer table in its second sector.
+	vmg := newIfo("DVDVIDEO-VMG", 2)
+	binary.BigEndian.PutUint32(vmg[0xC4:], 1)
+	binary.BigEndian.PutUint16(vmg[2048:], uint16(len(titles)))
+	titleSets := make(map[int][]vmdisc.DvdTitle)
+	for i, title := range titles {
+		if title.Number != i+1 {
+			e.Fatalf("title %d has number %d", i+1, title.Number)
+		}
+		entry := vmg[2048+8+i*12:]
+		entry[1] = byte(title.Angles)
+		binary.BigEndian.PutUint16(entry[2:], uint16(title.Chapters))
+		entry[6] = byte(title.TitleSet)
+		titleSets[title.TitleSet] = append(titleSets[title.TitleSet], title)
+		entry[7] = byte(len(titleSets[title.TitleSet]))
+	}
+	write("VIDEO_TS.IFO", vmg)
+
+	for number, titles := range titleSets {
+		write(fmt.Sprintf("VTS_%02d_0.IFO", number), newVtsIfo(e, titles))
+	}
+	write("VTS_01_1.VOB", []byte("VTS_01_1.VOB"))
+}
+
+func newIfo(magic string, sectors int) []byte {
+	b := make([]byte, sectors*2048)
+	copy(b, magic)
+	return b
+}
+
+// newVtsIfo returns a VTS_xx_0.IFO with the part-of-title table in its second
+// sector and the program chain table in its third, with one program chain per
+// title.
+func newVtsIfo(e exam.E, titles []vmdisc.DvdTitle) []byte {
+	vts := newIfo("DVDVIDEO-VTS", 3)
+	binary.BigEndian.PutUint32(vts[0xC8:], 1)
+	binary.BigEndian.PutUint32(vts[0xCC:], 2)
+
+	first := titles[0]
+	binary.BigEndian.PutUint16(vts[0x202:], uint16(len(first.Audio)))
+	for i, stream := range first.Audio {
+		attr := vts[0x204+i*8:]
+		codec := slices.Index([]string{"ac3", "", "mpeg1", "mpeg2", "lpcm", "", "dts"}, stream.Codec)
+		if codec < 0 {
+			e.Fatalf("unknown audio codec %q", stream.Codec)
+		}
+		attr[0] = byte(codec) << 5
+		if stream.Language != "" {
+			attr[0] |= 1 << 2
+			copy(attr[2:4], stream.Language)
+		}
+		attr[1] = byte(stream.Channels - 1)
+	}
+	binary.BigEndian.PutUint16(vts[0x254:], uint16(len(first.SubtitleLanguages)))
+	for i, language := range first.SubtitleLanguages {
+		attr := vts[0x256+i*6:]
+		if language != "" {
+			attr[0] = 1
+			copy(attr[2:4], language)
+		}
+	}
+
+	const ptt, pgci = 2048, 4096
+	binary.BigEndian.PutUint16(vts[ptt:], uint16(len(titles)))
+	binary.BigEndian.PutUint16(vts[pgci:], uint16(len(titles)))
+	for i, title := range titles {
+		// Each title has a single part, which starts its own program chain.
+		pttOffset := 8 + len(titles)*4 + i*4
+		binary.BigEndian.PutUint32(vts[ptt+8+i*4:], uint32(pttOffset))
+		binary.BigEndian.PutUint16(vts[ptt+pttOffset:], uint16(i+1))
+		binary.BigEndian.PutUint16(vts[ptt+pttOffset+2:], 1)
+
+		pgcOffset := 0x100 + i*0x10
+		binary.BigEndian.PutUint32(vts[pgci+8+i*8+4:], uint32(pgcOffset))
+		copy(vts[pgci+pgcOffset+4:], playbackTime(e, title.Duration))
+	}
+	return vts
+}
+
+// playbackTime encodes d as a BCD playback time at 25 fps.
+func playbackTime(e exam.E, d time.Duration) []byte {
+	const frame = time.Second / 25
+	if d%frame != 0 {
+		e.Fatalf("duration %v is not a whole number of frames", d)
+	}
+	bcd := func(v int) byte { return byte(v/10<<4 | v%10) }
+	frames := int(d % time.Second / frame)
+	return []byte{
+		bcd(int(d / time.Hour)),
+		bcd(int(d % time.Hour / time.Minute)),
+		bcd(int(d % time.Minute / time.Second)),
+		1<<6 | bcd(frames),
+	}
+}
@@ -0,0 +1,118 @@
+package vmtest
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/config"
+)
+
+// TmdbApiKey is the only API key accepted by the fake TMDb server.
+const TmdbApiKey = "test-api-key"
+
+//go:embed tmdb_fixtures/*.json
+var tmdbFixtures embed.FS
+
+// tmdbRecording is a single request/response pair captured from the real TMDb API.
+// The api_key query parameter is never part of a recording.
+type tmdbRecording struct {
+	Request struct {
+		Path  string            `json:"path"`
+		Query map[string]string `json:"query"`
+	} `json:"request"`
+	Response struct {
+		Status int             `json:"status"`
+		Body   json.RawMessage `json:"body"`
+	} `json:"response"`
+}
+
+type Tmdb struct {
+	server     *httptest.Server
+	recordings []tmdbRecording
+}
+
+// BaseUrl returns the URL to use in place of https://api.themoviedb.org/3.
+func (t *Tmdb) BaseUrl() string {
+	return t.server.URL + "/3"
+}
+
+func (t *Tmdb) Config() *config.Tmdb {
+	return &config.Tmdb{
+		ApiKey:  TmdbApiKey,
+		BaseUrl: t.BaseUrl(),
+	}
+}
+
+func (t *Tmdb) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := map[string]string{}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			query[k] = v[0]
+		}
+	}
+	if query["api_key"] != TmdbApiKey {
+		writeTmdbStatus(w, http.StatusUnauthorized, 7, "Invalid API key: You must be granted a valid key.")
+		return
+	}
+	delete(query, "api_key")
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/3")
+	if !ok {
+		writeTmdbStatus(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return
+	}
+	for _, rec := range t.recordings {
+		if rec.Request.Path == path && maps.Equal(rec.Request.Query, query) {
+			w.Header().Set("Content-Type", "application/json;charset=utf-8")
+			w.WriteHeader(rec.Response.Status)
+			w.Write(rec.Response.Body)
+			return
+		}
+	}
+	writeTmdbStatus(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+}
+
+func writeTmdbStatus(w http.ResponseWriter, httpStatus int, code int, message string) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":        false,
+		"status_code":    code,
+		"status_message": message,
+	})
+}
+
+// NewTmdb starts a local HTTP server that replays recorded TMDb API responses,
+// so that tests never need to reach the real TMDb.  The server is shut down
+// when the test finishes.
+func NewTmdb(e exam.E) *Tmdb {
+	e.Helper()
+	t := &Tmdb{}
+	err := fs.WalkDir(tmdbFixtures, "tmdb_fixtures", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := tmdbFixtures.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var rec tmdbRecording
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		t.recordings = append(t.recordings, rec)
+		return nil
+	})
+	if err != nil {
+		e.Fatalf("failed to load TMDb fixtures: %v", err)
+	}
+	t.server = httptest.NewServer(t)
+	e.Cleanup(t.server.Close)
+	return t
+}
@@ -0,0 +1,172 @@
+package vmtmdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+)
+
+var (
+	ErrNoApiKey = errors.New("no TMDb API key configured")
+	ErrStatus   = errors.New("unexpected status from TMDb")
+	// ErrNotFound is returned along with ErrStatus when TMDb has no resource
+	// with the requested ID.
+	ErrNotFound = errors.New("not found on TMDb")
+)
+
+// ImageBaseUrl is the prefix for all TMDb image paths.
+const ImageBaseUrl = "https://image.tmdb.org/t/p"
+
+// PageSize is the number of results TMDb returns per page of a search.
+// It is fixed by TMDb and cannot be changed by the caller.
+const PageSize = 20
+
+// Client talks to the TMDb v3 REST API.
+type Client struct {
+	ApiKey  string
+	BaseUrl string
+
+	// HttpClient is used to make requests.  If nil, http.DefaultClient is used.
+	HttpClient *http.Client
+}
+
+// New creates a Client from the TMDb section of the config.
+func New(cfg *config.Tmdb) *Client {
+	return &Client{
+		ApiKey:  cfg.ApiKey,
+		BaseUrl: cfg.BaseUrl,
+	}
+}
+
+// Movie is a single movie as returned by TMDb search.
+type Movie struct {
+	Id          uint64 `json:"id"`
+	Title       string `json:"title"`
+	Overview    string `json:"overview"`
+	ReleaseDate string `json:"release_date"`
+	PosterPath  string `json:"poster_path"`
+}
+
+// ReleaseYear parses the year out of ReleaseDate.
+// Returns nil if TMDb does not know the release date.
+func (m *Movie) ReleaseYear() *uint32 {
+	yearStr, _, _ := strings.Cut(m.ReleaseDate, "-")
+	year, err := strconv.ParseUint(yearStr, 10, 32)
+	if err != nil || year == 0 {
+		return nil
+	}
+	out := uint32(year)
+	return &out
+}
+
+// SearchMoviesPage is one page of results from SearchMovies.
+type SearchMoviesPage struct {
+	Page         int     `json:"page"`
+	Results      []Movie `json:"results"`
+	TotalPages   int     `json:"total_pages"`
+	TotalResults int     `json:"total_results"`
+}
+
+// SearchMovies runs a TMDb movie search for query.  Pages are numbered starting at 1.
+func (c *Client) SearchMovies(ctx context.Context, query string, page int) (*SearchMoviesPage, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("page", strconv.Itoa(page))
+	var out SearchMoviesPage
+	if err := c.get(ctx, "/search/movie", params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Genre is a TMDb movie genre.
+type Genre struct {
+	Id   uint32 `json:"id"`
+	Name string `json:"name"`
+}
+
+// MovieDetails is a single movie as returned by GetMovie.  It holds more than
+// the Movie returned by a search.
+type MovieDetails struct {
+	Movie
+	// Runtime is the length of the movie in minutes, or 0 if TMDb doesn't know it.
+	Runtime      uint32  `json:"runtime"`
+	Genres       []Genre `json:"genres"`
+	BackdropPath string  `json:"backdrop_path"`
+}
+
+// GetMovie fetches the details of the movie with the given TMDb ID.
+func (c *Client) GetMovie(ctx context.Context, id uint64) (*MovieDetails, error) {
+	var out MovieDetails
+	if err := c.get(ctx, "/movie/"+strconv.FormatUint(id, 10), url.Values{}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PosterUrl returns the full URL for a poster path returned by TMDb.
+// Returns nil if posterPath is empty.
+func PosterUrl(posterPath string) *string {
+	if posterPath == "" {
+		return nil
+	}
+	out := ImageBaseUrl + "/w500" + posterPath
+	return &out
+}
+
+// statusMessage is the error body that TMDb returns on failure.
+type statusMessage struct {
+	StatusCode    int    `json:"status_code"`
+	StatusMessage string `json:"status_message"`
+}
+
+func (c *Client) get(ctx context.Context, path string, params url.Values, out any) error {
+	if c.ApiKey == "" {
+		return ErrNoApiKey
+	}
+	params.Set("api_key", c.ApiKey)
+	reqUrl := strings.TrimSuffix(c.BaseUrl, "/") + path + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return fmt.Errorf("could not build TMDb request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := c.HttpClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// A *url.Error includes the request URL, and with it the API key.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("TMDb request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		statusErr := ErrStatus
+		if resp.StatusCode == http.StatusNotFound {
+			statusErr = fmt.Errorf("%w: %w", ErrStatus, ErrNotFound)
+		}
+		var msg statusMessage
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil || msg.StatusMessage == "" {
+			return fmt.Errorf("%w: %s returned %d", statusErr, path, resp.StatusCode)
+		}
+		return fmt.Errorf("%w: %s returned %d: %s", statusErr, path, resp.StatusCode, msg.StatusMessage)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not decode TMDb response from %s: %w", path, err)
+	}
+	return nil
+}
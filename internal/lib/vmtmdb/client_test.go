@@ -0,0 +1,135 @@
+package vmtmdb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/lib/vmtmdb"
+)
+
+func TestSearchMovies(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	fake := vmtest.NewTmdb(e)
+
+	e.Run("first page", func(e exam.E) {
+		client := vmtmdb.New(fake.Config())
+		page, err := client.SearchMovies(ctx, "alien", 1)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, page.Page, 1)
+		exam.Equal(e, env, page.TotalPages, 2)
+		exam.Equal(e, env, page.TotalResults, 23)
+		exam.Equal(e, env, len(page.Results), vmtmdb.PageSize).Must()
+		exam.Equal(e, env, page.Results[0], vmtmdb.Movie{
+			Id:          348,
+			Title:       "Alien",
+			Overview:    "During its return to the earth, commercial spaceship Nostromo intercepts a distress signal from a distant planet.",
+			ReleaseDate: "1979-05-25",
+			PosterPath:  "/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg",
+		})
+	})
+
+	e.Run("no results", func(e exam.E) {
+		client := vmtmdb.New(fake.Config())
+		page, err := client.SearchMovies(ctx, "zzzz no such movie", 1)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, page.TotalResults, 0)
+		exam.Equal(e, env, len(page.Results), 0)
+	})
+
+	e.Run("bad api key", func(e exam.E) {
+		cfg := fake.Config()
+		cfg.ApiKey = "wrong"
+		client := vmtmdb.New(cfg)
+		_, err := client.SearchMovies(ctx, "alien", 1)
+		exam.Match(e, env, err, match.ErrorIs(vmtmdb.ErrStatus))
+	})
+
+	e.Run("missing api key", func(e exam.E) {
+		cfg := fake.Config()
+		cfg.ApiKey = ""
+		client := vmtmdb.New(cfg)
+		_, err := client.SearchMovies(ctx, "alien", 1)
+		exam.Match(e, env, err, match.ErrorIs(vmtmdb.ErrNoApiKey))
+	})
+}
+
+func TestMovieReleaseYear(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc         exam.Loc
+		releaseDate string
+		want        match.Matcher
+	}{
+		{loc: exam.Here(), releaseDate: "1979-05-25", want: match.Pointer(match.Equal(uint32(1979)))},
+		{loc: exam.Here(), releaseDate: "2024", want: match.Pointer(match.Equal(uint32(2024)))},
+		{loc: exam.Here(), releaseDate: "", want: match.Nil()},
+		{loc: exam.Here(), releaseDate: "unknown", want: match.Nil()},
+	}
+	for _, tt := range tests {
+		e.Run(tt.releaseDate, func(e exam.E) {
+			e.Log(tt.loc)
+			m := vmtmdb.Movie{ReleaseDate: tt.releaseDate}
+			exam.Match(e, env, m.ReleaseYear(), tt.want)
+		})
+	}
+}
+
+func TestGetMovie(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	fake := vmtest.NewTmdb(e)
+
+	e.Run("found", func(e exam.E) {
+		client := vmtmdb.New(fake.Config())
+		movie, err := client.GetMovie(ctx, 348)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, *movie, vmtmdb.MovieDetails{
+			Movie: vmtmdb.Movie{
+				Id:          348,
+				Title:       "Alien",
+				Overview:    "During its return to the earth, commercial spaceship Nostromo intercepts a distress signal from a distant planet.",
+				ReleaseDate: "1979-05-25",
+				PosterPath:  "/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg",
+			},
+			Runtime:      117,
+			Genres:       []vmtmdb.Genre{{Id: 27, Name: "Horror"}, {Id: 878, Name: "Science Fiction"}},
+			BackdropPath: "/AmR3JG1VQVxU8TfAvljUhfSFUOx.jpg",
+		})
+	})
+
+	e.Run("not found", func(e exam.E) {
+		client := vmtmdb.New(fake.Config())
+		_, err := client.GetMovie(ctx, 999999999)
+		exam.Match(e, env, err, match.ErrorIs(vmtmdb.ErrNotFound))
+		exam.Match(e, env, err, match.ErrorIs(vmtmdb.ErrStatus))
+	})
+
+	e.Run("bad api key", func(e exam.E) {
+		cfg := fake.Config()
+		cfg.ApiKey = "wrong"
+		client := vmtmdb.New(cfg)
+		_, err := client.GetMovie(ctx, 348)
+		exam.Match(e, env, err, match.ErrorIs(vmtmdb.ErrStatus))
+		exam.Match(e, env, err, match.Not(match.ErrorIs(vmtmdb.ErrNotFound)))
+	})
+
+	e.Run("unreachable", func(e exam.E) {
+		cfg := fake.Config()
+		cfg.BaseUrl = "http://127.0.0.1:1"
+		cfg.ApiKey = "secret-key"
+		client := vmtmdb.New(cfg)
+		_, err := client.GetMovie(ctx, 348)
+		exam.NotNil(e, env, err).Must()
+		exam.Equal(e, env, strings.Contains(err.Error(), "secret-key"), false).Log(err)
+	})
+}
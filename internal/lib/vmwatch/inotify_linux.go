@@ -0,0 +1,152 @@
+//go:build linux
+
+package vmwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB | unix.IN_ONLYDIR
+
+// inotify reports changes under a directory tree.  inotify watches are not
+// recursive, so every subdirectory gets its own watch.
+type inotify struct {
+	dir string
+	fd  int
+	// file wraps fd.  Its Fd method must not be used, since that would make
+	// reads blocking again.
+	file *os.File
+	// watches maps watch descriptors to paths relative to dir.
+	watches map[int32]string
+}
+
+func newInotify(dir string) (*inotify, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// A non-blocking fd uses the runtime poller, so closing the file interrupts
+	// a pending read.
+	in := &inotify{
+		dir:     dir,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: make(map[int32]string),
+	}
+	if err := in.addWatches(""); err != nil {
+		in.file.Close()
+		return nil, err
+	}
+	return in, nil
+}
+
+// addWatches watches rel and every directory under it.
+func (in *inotify) addWatches(rel string) error {
+	return filepath.WalkDir(filepath.Join(in.dir, rel), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == filepath.Join(in.dir, rel) {
+				return err
+			}
+			// Removed while we were walking.
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := unix.InotifyAddWatch(in.fd, path, inotifyMask)
+		if err != nil {
+			return err
+		}
+		pathRel, err := filepath.Rel(in.dir, path)
+		if err != nil {
+			return err
+		}
+		if pathRel == "." {
+			pathRel = ""
+		}
+		in.watches[int32(wd)] = pathRel
+		return nil
+	})
+}
+
+// run sends the name of the top-level directory affected by each event to
+// changes until ctx is cancelled.
+func (in *inotify) run(ctx context.Context, changes chan<- string) {
+	go func() {
+		<-ctx.Done()
+		in.file.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "vmwatch: failed to read inotify events", "dir", in.dir, "error", err)
+			}
+			return
+		}
+		for _, name := range in.parse(ctx, buf[:n]) {
+			select {
+			case changes <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// parse returns the names of the top-level directories affected by the events
+// in buf.
+func (in *inotify) parse(ctx context.Context, buf []byte) []string {
+	var names []string
+	for len(buf) >= unix.SizeofInotifyEvent {
+		wd := int32(binary.NativeEndian.Uint32(buf[0:4]))
+		mask := binary.NativeEndian.Uint32(buf[4:8])
+		nameLen := int(binary.NativeEndian.Uint32(buf[12:16]))
+		end := min(unix.SizeofInotifyEvent+nameLen, len(buf))
+		name := string(bytes.TrimRight(buf[unix.SizeofInotifyEvent:end], "\x00"))
+		buf = buf[end:]
+
+		if mask&unix.IN_Q_OVERFLOW != 0 {
+			// Some events were dropped, so assume that everything changed.
+			entries, _ := os.ReadDir(in.dir)
+			for _, entry := range entries {
+				if entry.IsDir() && !ignored(entry.Name()) {
+					names = append(names, entry.Name())
+				}
+			}
+			continue
+		}
+		if mask&unix.IN_IGNORED != 0 {
+			delete(in.watches, wd)
+			continue
+		}
+		dir, ok := in.watches[wd]
+		if !ok {
+			continue
+		}
+		rel := filepath.Join(dir, name)
+		if mask&unix.IN_ISDIR != 0 && mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			if err := in.addWatches(rel); err != nil {
+				slog.WarnContext(ctx, "vmwatch: failed to watch directory", "dir", in.dir, "path", rel, "error", err)
+			}
+		}
+		top, _, _ := strings.Cut(rel, string(filepath.Separator))
+		if top == "" || ignored(top) {
+			continue
+		}
+		names = append(names, top)
+	}
+	return names
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package vmwatch
+
+import (
+	"context"
+	"errors"
+)
+
+type inotify struct{}
+
+func newInotify(dir string) (*inotify, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func (in *inotify) run(ctx context.Context, changes chan<- string) {}
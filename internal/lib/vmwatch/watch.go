@@ -0,0 +1,181 @@
+// Package vmwatch reports directories that have stopped changing.
+package vmwatch
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultPollInterval is used when Watcher.PollInterval is not set.
+const DefaultPollInterval = 10 * time.Second
+
+// Watcher watches the directories directly under Dir, and calls OnQuiet for
+// each one that has been added or changed once nothing in it has changed for
+// QuietPeriod.  Directories that are already there when Run is called are
+// treated as just added, so that those added while nothing was watching are
+// not missed.  Directories whose names start with a dot are ignored.
+//
+// Changes are detected with inotify where it is available, and by scanning Dir
+// every PollInterval otherwise.
+type Watcher struct {
+	Dir          string
+	QuietPeriod  time.Duration
+	PollInterval time.Duration
+	// OnQuiet is called with the name of the directory, relative to Dir.  Calls
+	// are made one at a time.
+	OnQuiet func(ctx context.Context, name string)
+}
+
+// Run watches Dir until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	w.run(ctx, false)
+}
+
+func (w *Watcher) run(ctx context.Context, forcePoll bool) {
+	changes := make(chan string)
+	var in *inotify
+	var err error
+	if !forcePoll {
+		in, err = newInotify(w.Dir)
+		if err != nil {
+			slog.WarnContext(ctx, "vmwatch: inotify is not available, polling instead", "dir", w.Dir, "error", err)
+		}
+	}
+	if in != nil {
+		go in.run(ctx, changes)
+	} else {
+		go w.poll(ctx, changes)
+	}
+	w.debounce(ctx, w.existing(), changes)
+}
+
+// existing returns the names of the directories directly under Dir that are
+// not ignored.
+func (w *Watcher) existing() []string {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !ignored(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// debounce calls OnQuiet for each of initial, and for each name received on
+// changes, once no more changes have been received for it for QuietPeriod.
+func (w *Watcher) debounce(ctx context.Context, initial []string, changes <-chan string) {
+	tick := max(w.QuietPeriod/4, 10*time.Millisecond)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	lastChange := make(map[string]time.Time)
+	for _, name := range initial {
+		lastChange[name] = time.Now()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name := <-changes:
+			lastChange[name] = time.Now()
+		case now := <-ticker.C:
+			for name, last := range lastChange {
+				if now.Sub(last) < w.QuietPeriod {
+					continue
+				}
+				delete(lastChange, name)
+				// The directory may have been removed or renamed in the meantime.
+				info, err := os.Stat(filepath.Join(w.Dir, name))
+				if err != nil || !info.IsDir() {
+					continue
+				}
+				w.OnQuiet(ctx, name)
+			}
+		}
+	}
+}
+
+// fingerprint summarizes the contents of a directory tree.
+type fingerprint struct {
+	entries int
+	size    int64
+	modTime time.Time
+}
+
+func (w *Watcher) poll(ctx context.Context, changes chan<- string) {
+	interval := w.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := w.snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cur := w.snapshot()
+		for name, fp := range cur {
+			if old, ok := prev[name]; ok && old == fp {
+				continue
+			}
+			select {
+			case changes <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+		prev = cur
+	}
+}
+
+// snapshot returns the fingerprints of the directories directly under Dir.
+func (w *Watcher) snapshot() map[string]fingerprint {
+	out := make(map[string]fingerprint)
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return out
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || ignored(entry.Name()) {
+			continue
+		}
+		var fp fingerprint
+		// Errors are ignored, since files may come and go while we walk.
+		_ = filepath.WalkDir(filepath.Join(w.Dir, entry.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			fp.entries++
+			fp.size += info.Size()
+			if info.ModTime().After(fp.modTime) {
+				fp.modTime = info.ModTime()
+			}
+			return nil
+		})
+		out[entry.Name()] = fp
+	}
+	return out
+}
+
+// ignored reports whether changes to the directory with the given name are
+// ignored.  Tools commonly use hidden names for work in progress.
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,151 @@
+package vmwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+)
+
+type quietEvent struct {
+	name string
+	at   time.Time
+}
+
+func startWatcher(e exam.E, dir string, forcePoll bool) <-chan quietEvent {
+	events := make(chan quietEvent, 10)
+	w := &Watcher{
+		Dir:          dir,
+		QuietPeriod:  200 * time.Millisecond,
+		PollInterval: 20 * time.Millisecond,
+		OnQuiet: func(ctx context.Context, name string) {
+			events <- quietEvent{name: name, at: time.Now()}
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.run(ctx, forcePoll)
+		close(done)
+	}()
+	e.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	// Give the watcher a chance to take its initial snapshot.
+	time.Sleep(50 * time.Millisecond)
+	return events
+}
+
+func writeFile(e exam.E, path string, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		e.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		e.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func expectQuiet(e exam.E, events <-chan quietEvent) quietEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		e.Fatalf("timed out waiting for a quiet directory")
+		return quietEvent{}
+	}
+}
+
+func expectNoQuiet(e exam.E, events <-chan quietEvent) {
+	select {
+	case ev := <-events:
+		e.Fatalf("unexpected quiet directory %q", ev.name)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	modes := map[string]bool{
+		"inotify": false,
+		"poll":    true,
+	}
+	for mode, forcePoll := range modes {
+		e.Run(mode, func(e exam.E) {
+			e.Run("new directory", func(e exam.E) {
+				dir := e.TempDir()
+				events := startWatcher(e, dir, forcePoll)
+
+				writeFile(e, filepath.Join(dir, "new", "VIDEO_TS", "VTS_01_1.VOB"), "new")
+				ev := expectQuiet(e, events)
+				exam.Equal(e, env, "new", ev.name)
+				expectNoQuiet(e, events)
+			})
+
+			e.Run("existing directory", func(e exam.E) {
+				dir := e.TempDir()
+				writeFile(e, filepath.Join(dir, "existing", "VIDEO_TS", "VTS_01_1.VOB"), "old")
+				writeFile(e, filepath.Join(dir, ".partial", "VIDEO_TS", "VTS_01_1.VOB"), "hidden")
+				events := startWatcher(e, dir, forcePoll)
+
+				ev := expectQuiet(e, events)
+				exam.Equal(e, env, "existing", ev.name)
+				expectNoQuiet(e, events)
+			})
+
+			e.Run("waits while directory changes", func(e exam.E) {
+				dir := e.TempDir()
+				events := startWatcher(e, dir, forcePoll)
+
+				path := filepath.Join(dir, "ripping", "VIDEO_TS", "VTS_01_1.VOB")
+				var lastWrite time.Time
+				for i := range 10 {
+					writeFile(e, path, string(make([]byte, i+1)))
+					lastWrite = time.Now()
+					time.Sleep(50 * time.Millisecond)
+				}
+				ev := expectQuiet(e, events)
+				exam.Equal(e, env, "ripping", ev.name)
+				if ev.at.Sub(lastWrite) < 150*time.Millisecond {
+					e.Errorf("directory reported quiet %v after the last write", ev.at.Sub(lastWrite))
+				}
+			})
+
+			e.Run("changed existing directory", func(e exam.E) {
+				dir := e.TempDir()
+				writeFile(e, filepath.Join(dir, "existing", "VIDEO_TS", "VTS_01_1.VOB"), "old")
+				events := startWatcher(e, dir, forcePoll)
+
+				writeFile(e, filepath.Join(dir, "existing", "VIDEO_TS", "VTS_01_2.VOB"), "more")
+				ev := expectQuiet(e, events)
+				exam.Equal(e, env, "existing", ev.name)
+			})
+
+			e.Run("ignored entries", func(e exam.E) {
+				dir := e.TempDir()
+				events := startWatcher(e, dir, forcePoll)
+
+				writeFile(e, filepath.Join(dir, ".partial", "VIDEO_TS", "VTS_01_1.VOB"), "hidden")
+				writeFile(e, filepath.Join(dir, "file.iso"), "not a directory")
+				expectNoQuiet(e, events)
+			})
+
+			e.Run("removed directory", func(e exam.E) {
+				dir := e.TempDir()
+				events := startWatcher(e, dir, forcePoll)
+
+				writeFile(e, filepath.Join(dir, "gone", "VIDEO_TS", "VTS_01_1.VOB"), "new")
+				if err := os.RemoveAll(filepath.Join(dir, "gone")); err != nil {
+					e.Fatalf("failed to remove directory: %v", err)
+				}
+				expectNoQuiet(e, events)
+			})
+		})
+	}
+}
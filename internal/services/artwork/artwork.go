@@ -0,0 +1,113 @@
+package artwork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+)
+
+// Kind is the kind of an image of a card.
+type Kind string
+
+const (
+	KindPoster Kind = "poster"
+	// KindFanart is a wide background image.
+	KindFanart Kind = "fanart"
+)
+
+// Kinds lists every Kind.
+var Kinds = []Kind{KindPoster, KindFanart}
+
+// Valid reports whether k is one of Kinds.
+func (k Kind) Valid() bool {
+	for _, kind := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Size is the size of an image to serve: either SizeOriginal, or the name of
+// one of Thumbnails.
+type Size string
+
+// SizeOriginal is the image as it was downloaded.
+const SizeOriginal Size = "original"
+
+// Thumbnail is a smaller copy of an image, made when the image is downloaded.
+type Thumbnail struct {
+	Size Size
+	// Width is the width of the thumbnail in pixels.  The height keeps the
+	// aspect ratio of the image.  Images that are narrower are not scaled up.
+	Width int
+}
+
+// Thumbnails lists the thumbnails made of every image.
+var Thumbnails = []Thumbnail{
+	{Size: "w185", Width: 185},
+	{Size: "w342", Width: 342},
+	{Size: "w780", Width: 780},
+}
+
+// Valid reports whether s is SizeOriginal or the size of one of Thumbnails.
+func (s Size) Valid() bool {
+	if s == SizeOriginal {
+		return true
+	}
+	for _, t := range Thumbnails {
+		if s == t.Size {
+			return true
+		}
+	}
+	return false
+}
+
+// fileName returns the name of the file holding the image of the given size,
+// within the directory of the image.  Thumbnails are always JPEG.
+func (s Size) fileName() string {
+	if s == SizeOriginal {
+		return string(SizeOriginal)
+	}
+	return string(s) + ".jpg"
+}
+
+// Artwork describes a downloaded image.
+type Artwork struct {
+	CardId      uint32
+	Kind        Kind
+	SourceUrl   string
+	ContentType string
+	// Sha256 is the hash of the original image, in hex.
+	Sha256       string
+	Width        int
+	Height       int
+	DownloadedAt time.Time
+}
+
+// Path returns the path to the file holding the image in the given size.
+func (a *Artwork) Path(paths config.Paths, pk config.PathKind, size Size) string {
+	return filepath.Join(paths.ArtworkId(pk, a.CardId, string(a.Kind)), size.fileName())
+}
+
+// GetArtwork returns the image of the given kind of a card.  Returns nil if
+// the image has not been downloaded.
+func GetArtwork(ctx context.Context, db vmdb.Runner, cardId uint32, kind Kind) (*Artwork, error) {
+	const sql = `
+		SELECT card_id, kind, source_url, content_type, sha256, width, height, downloaded_at
+		FROM artwork
+		WHERE card_id = $1 AND kind = $2
+	`
+	a, err := vmdb.QueryOne[Artwork](ctx, db, vmdb.Positional(sql, cardId, kind))
+	if errors.Is(err, vmdb.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query artwork: %w", err)
+	}
+	return &a, nil
+}
@@ -0,0 +1,230 @@
+package artwork
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// TaskTypeArtworkDownload is the task type for downloading an image of a card,
+// and making its thumbnails.
+const TaskTypeArtworkDownload = "artwork_download"
+
+// MaxDownloadBytes bounds the size of a downloaded image.
+const MaxDownloadBytes = 32 << 20
+
+// MaxImagePixels bounds the width times the height of a downloaded image.  A
+// small file can claim huge dimensions, and decoding it would allocate all of
+// them.
+const MaxImagePixels = 40 << 20
+
+// DownloadState represents the state of an artwork download task.
+type DownloadState struct {
+	CardId uint32 `json:"card_id"`
+	Kind   Kind   `json:"kind"`
+	// Url is where to download the image from.
+	Url string `json:"url"`
+	// TmdbPath is the TMDb image path of the card's movie that Url was made
+	// from.  If set, the image is only stored while the movie still has it,
+	// so that an earlier download can't replace a newer one.
+	TmdbPath string `json:"tmdb_path,omitempty"`
+}
+
+// tmdbPathColumn returns the column of catalog_movies that holds the TMDb
+// image path of this kind.
+func (k Kind) tmdbPathColumn() string {
+	if k == KindFanart {
+		return "backdrop_path"
+	}
+	return "poster_path"
+}
+
+// DownloadHandler processes artwork download tasks.  It stores the image and
+// its thumbnails under config.Paths.ArtworkId, replacing any earlier image of
+// the same kind.  Downloads whose DownloadState.TmdbPath is out of date are
+// completed without storing anything.
+type DownloadHandler struct {
+	Paths config.Paths
+	// HttpClient is used to download images.  If nil, http.DefaultClient is
+	// used.
+	HttpClient *http.Client
+}
+
+// Handle implements vmtask.Handler.
+func (h *DownloadHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, stateBytes []byte) vmtask.Result {
+	var state DownloadState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to unmarshal state: %v", err))
+	}
+	if !state.Kind.Valid() {
+		return vmtask.Failed(fmt.Sprintf("unknown artwork kind %q", state.Kind))
+	}
+
+	const selectSql = `SELECT COUNT(*) FROM catalog_cards WHERE id = $1`
+	count, err := vmdb.QueryOne[int](ctx, db, vmdb.Positional(selectSql, state.CardId))
+	if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to query catalog_cards: %v", err))
+	}
+	if count == 0 {
+		// The card was deleted, so there is nothing to download.  Its image
+		// may have been downloaded by an earlier task, so clean that up.
+		if err := os.RemoveAll(h.Paths.ArtworkId(config.PathKindAbsolute, state.CardId, string(state.Kind))); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to remove old image: %v", err))
+		}
+		return vmtask.Completed()
+	}
+
+	data, result := h.download(ctx, state.Url)
+	if result != nil {
+		return *result
+	}
+	// Downloading the same bytes again won't help with any of these.
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to decode image from %s: %v", state.Url, err))
+	}
+	if int64(imgConfig.Width)*int64(imgConfig.Height) > MaxImagePixels {
+		return vmtask.Failed(fmt.Sprintf("image at %s is %dx%d, which is more than %d pixels", state.Url, imgConfig.Width, imgConfig.Height, MaxImagePixels))
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to decode image from %s: %v", state.Url, err))
+	}
+	sum := sha256.Sum256(data)
+	artwork := Artwork{
+		CardId:      state.CardId,
+		Kind:        state.Kind,
+		SourceUrl:   state.Url,
+		ContentType: http.DetectContentType(data),
+		Sha256:      hex.EncodeToString(sum[:]),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}
+
+	if state.TmdbPath != "" {
+		// Lock the movie, so that other downloads of the card wait until this
+		// one has stored its image.
+		selectSql := fmt.Sprintf("SELECT %s FROM catalog_movies WHERE card_id = $1 FOR UPDATE", state.Kind.tmdbPathColumn())
+		current, err := vmdb.QueryOne[*string](ctx, db, vmdb.Positional(selectSql, state.CardId))
+		if err != nil && !errors.Is(err, vmdb.ErrNotFound) {
+			return vmtask.Retry(fmt.Sprintf("failed to query catalog_movies: %v", err))
+		}
+		if current == nil || *current != state.TmdbPath {
+			// The movie has moved on, and any newer image has its own task.
+			return vmtask.Completed()
+		}
+	}
+
+	// Write everything to a staging directory first, so that the image is
+	// never served with thumbnails from an earlier download.
+	stagingPath := h.Paths.ArtworkIdStaging(config.PathKindAbsolute, state.CardId, string(state.Kind))
+	if err := os.RemoveAll(stagingPath); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to clear staging path: %v", err))
+	}
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to create staging path: %v", err))
+	}
+	if err := os.WriteFile(filepath.Join(stagingPath, SizeOriginal.fileName()), data, 0644); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to write image: %v", err))
+	}
+	if err := writeThumbnails(stagingPath, img); err != nil {
+		return vmtask.Retry(err.Error())
+	}
+
+	path := h.Paths.ArtworkId(config.PathKindAbsolute, state.CardId, string(state.Kind))
+	if err := os.RemoveAll(path); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to remove old image: %v", err))
+	}
+	if err := os.Rename(stagingPath, path); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to rename staging path: %v", err))
+	}
+
+	const upsertSql = `
+		INSERT INTO artwork (card_id, kind, source_url, content_type, sha256, width, height, downloaded_at)
+		VALUES (@cardId, @kind, @sourceUrl, @contentType, @sha256, @width, @height, NOW())
+		ON CONFLICT (card_id, kind) DO UPDATE
+		SET source_url = EXCLUDED.source_url,
+			content_type = EXCLUDED.content_type,
+			sha256 = EXCLUDED.sha256,
+			width = EXCLUDED.width,
+			height = EXCLUDED.height,
+			downloaded_at = EXCLUDED.downloaded_at
+	`
+	_, err = vmdb.Exec(ctx, db, vmdb.Named(upsertSql, map[string]any{
+		"cardId":      artwork.CardId,
+		"kind":        artwork.Kind,
+		"sourceUrl":   artwork.SourceUrl,
+		"contentType": artwork.ContentType,
+		"sha256":      artwork.Sha256,
+		"width":       artwork.Width,
+		"height":      artwork.Height,
+	}))
+	if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to store artwork: %v", err))
+	}
+	return vmtask.Completed()
+}
+
+// download fetches the image at url.  On failure it returns the result of the
+// task instead.
+func (h *DownloadHandler) download(ctx context.Context, url string) ([]byte, *vmtask.Result) {
+	fail := func(result vmtask.Result) ([]byte, *vmtask.Result) {
+		return nil, &result
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fail(vmtask.Failed(fmt.Sprintf("invalid url %q: %v", url, err)))
+	}
+	client := h.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail(vmtask.Retry(fmt.Sprintf("failed to download %s: %v", url, err)))
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		// Retrying won't make the image appear.
+		return fail(vmtask.Failed(fmt.Sprintf("failed to download %s: %s", url, resp.Status)))
+	case resp.StatusCode != http.StatusOK:
+		return fail(vmtask.Retry(fmt.Sprintf("failed to download %s: %s", url, resp.Status)))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxDownloadBytes+1))
+	if err != nil {
+		return fail(vmtask.Retry(fmt.Sprintf("failed to read %s: %v", url, err)))
+	}
+	if len(data) > MaxDownloadBytes {
+		return fail(vmtask.Failed(fmt.Sprintf("image at %s is larger than %d bytes", url, MaxDownloadBytes)))
+	}
+	return data, nil
+}
+
+// CreateDownloadTask creates a task that downloads the image at url as the
+// image of the given kind of a card.  tmdbPath is the TMDb image path that url
+// was made from, if any; see DownloadState.TmdbPath.
+func CreateDownloadTask(ctx context.Context, db vmdb.Runner, cardId uint32, kind Kind, url string, tmdbPath string) (int, error) {
+	stateBytes, err := json.Marshal(DownloadState{CardId: cardId, Kind: kind, Url: url, TmdbPath: tmdbPath})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return vmtask.Create(ctx, db, TaskTypeArtworkDownload, stateBytes)
+}
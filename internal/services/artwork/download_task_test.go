@@ -0,0 +1,223 @@
+package artwork_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/artwork"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+)
+
+// newPng returns a PNG image of the given size.
+func newPng(e exam.E, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		e.Fatalf("could not encode image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// newHugeGif returns a small GIF image that claims to be 65535 pixels square.
+func newHugeGif(e exam.E) []byte {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 1, 1), []color.Color{color.Black}), nil); err != nil {
+		e.Fatalf("could not encode image: %v", err)
+	}
+	data := buf.Bytes()
+	// The logical screen width and height follow the 6 byte signature.
+	binary.LittleEndian.PutUint16(data[6:], 0xffff)
+	binary.LittleEndian.PutUint16(data[8:], 0xffff)
+	return data
+}
+
+// postCard creates a movie card and returns its ID.
+func postCard(e exam.E, env deep.Env, pg *vmtest.Postgres) uint32 {
+	service := &catalog.CatalogService{Db: pg.DbRunner(e)}
+	resp, err := service.PostCard(context.Background(), vmapi.PostCardRequestObject{
+		Body: &vmapi.CardPost{
+			Name:    "Alien",
+			Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{}},
+		},
+	})
+	exam.Nil(e, env, err).Log(err).Must()
+	return resp.(vmapi.PostCard201JSONResponse).Id
+}
+
+func TestDownloadHandler(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	poster := newPng(e, 600, 900)
+	huge := newHugeGif(e)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/poster.png":
+			w.Write(poster)
+		case "/huge.gif":
+			w.Write(huge)
+		case "/text":
+			w.Write([]byte("not an image"))
+		case "/flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		loc           exam.Loc
+		name          string
+		path          string
+		wantStatus    vmtask.Status
+		wantRetryable bool
+		wantStored    bool
+	}{
+		{
+			loc:        exam.Here(),
+			name:       "image",
+			path:       "/poster.png",
+			wantStatus: vmtask.StatusCompleted,
+			wantStored: true,
+		},
+		{
+			loc:        exam.Here(),
+			name:       "not found",
+			path:       "/missing.png",
+			wantStatus: vmtask.StatusFailed,
+		},
+		{
+			loc:        exam.Here(),
+			name:       "not an image",
+			path:       "/text",
+			wantStatus: vmtask.StatusFailed,
+		},
+		{
+			loc:        exam.Here(),
+			name:       "too many pixels",
+			path:       "/huge.gif",
+			wantStatus: vmtask.StatusFailed,
+		},
+		{
+			loc:           exam.Here(),
+			name:          "server error",
+			path:          "/flaky",
+			wantStatus:    vmtask.StatusFailed,
+			wantRetryable: true,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			paths := config.Paths{RootDir: e.TempDir()}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("could not bootstrap paths: %v", err)
+			}
+			cardId := postCard(e, env, pg)
+
+			handler := &artwork.DownloadHandler{Paths: paths}
+			state, err := json.Marshal(artwork.DownloadState{CardId: cardId, Kind: artwork.KindPoster, Url: server.URL + tt.path})
+			exam.Nil(e, env, err).Log(err).Must()
+			result := handler.Handle(ctx, db, 0, artwork.TaskTypeArtworkDownload, state)
+			exam.Equal(e, env, result.NewStatus, tt.wantStatus).Log(result).Log(tt.loc)
+			exam.Equal(e, env, result.Retryable, tt.wantRetryable).Log(result).Log(tt.loc)
+
+			got, err := artwork.GetArtwork(ctx, db, cardId, artwork.KindPoster)
+			exam.Nil(e, env, err).Log(err).Must()
+			if !tt.wantStored {
+				exam.Equal(e, env, got, (*artwork.Artwork)(nil)).Log(tt.loc)
+				return
+			}
+			exam.Equal(e, env, got.SourceUrl, server.URL+tt.path).Log(tt.loc)
+			exam.Equal(e, env, got.ContentType, "image/png").Log(tt.loc)
+			exam.Equal(e, env, got.Width, 600).Log(tt.loc)
+			exam.Equal(e, env, got.Height, 900).Log(tt.loc)
+			original, err := os.ReadFile(got.Path(paths, config.PathKindAbsolute, artwork.SizeOriginal))
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, original, poster).Log(tt.loc)
+			for _, thumbnail := range artwork.Thumbnails {
+				path := got.Path(paths, config.PathKindAbsolute, thumbnail.Size)
+				exam.Equal(e, env, vmtest.FileExists(e, path), true).Log(thumbnail.Size).Log(tt.loc)
+			}
+			staging := paths.ArtworkIdStaging(config.PathKindAbsolute, cardId, string(artwork.KindPoster))
+			exam.Equal(e, env, vmtest.FileExists(e, staging), false).Log(tt.loc)
+		})
+	}
+
+	e.Run("replaces earlier image", func(e exam.E) {
+		defer pg.Reset(e)
+		paths := config.Paths{RootDir: e.TempDir()}
+		if err := paths.Bootstrap(); err != nil {
+			e.Fatalf("could not bootstrap paths: %v", err)
+		}
+		cardId := postCard(e, env, pg)
+		handler := &artwork.DownloadHandler{Paths: paths}
+		for range 2 {
+			state, err := json.Marshal(artwork.DownloadState{CardId: cardId, Kind: artwork.KindPoster, Url: server.URL + "/poster.png"})
+			exam.Nil(e, env, err).Log(err).Must()
+			result := handler.Handle(ctx, db, 0, artwork.TaskTypeArtworkDownload, state)
+			exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Must()
+		}
+		const sql = "SELECT COUNT(*) FROM artwork WHERE card_id = $1"
+		count, err := vmdb.QueryOne[int](ctx, db, vmdb.Positional(sql, cardId))
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, count, 1)
+	})
+
+	e.Run("tmdb path", func(e exam.E) {
+		defer pg.Reset(e)
+		paths := config.Paths{RootDir: e.TempDir()}
+		if err := paths.Bootstrap(); err != nil {
+			e.Fatalf("could not bootstrap paths: %v", err)
+		}
+		cardId := postCard(e, env, pg)
+		const sql = "UPDATE catalog_movies SET poster_path = '/new.jpg' WHERE card_id = $1"
+		_, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, cardId))
+		exam.Nil(e, env, err).Log(err).Must()
+		handler := &artwork.DownloadHandler{Paths: paths}
+		download := func(e exam.E, tmdbPath string) *artwork.Artwork {
+			state, err := json.Marshal(artwork.DownloadState{CardId: cardId, Kind: artwork.KindPoster, Url: server.URL + "/poster.png", TmdbPath: tmdbPath})
+			exam.Nil(e, env, err).Log(err).Must()
+			result := handler.Handle(ctx, db, 0, artwork.TaskTypeArtworkDownload, state)
+			exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Must()
+			got, err := artwork.GetArtwork(ctx, db, cardId, artwork.KindPoster)
+			exam.Nil(e, env, err).Log(err).Must()
+			return got
+		}
+
+		// A retried download for an earlier poster must not be stored.
+		exam.Equal(e, env, download(e, "/old.jpg"), (*artwork.Artwork)(nil))
+		exam.NotNil(e, env, download(e, "/new.jpg"))
+	})
+
+	e.Run("deleted card", func(e exam.E) {
+		defer pg.Reset(e)
+		paths := config.Paths{RootDir: e.TempDir()}
+		handler := &artwork.DownloadHandler{Paths: paths}
+		state, err := json.Marshal(artwork.DownloadState{CardId: 1, Kind: artwork.KindPoster, Url: server.URL + "/poster.png"})
+		exam.Nil(e, env, err).Log(err).Must()
+		result := handler.Handle(ctx, db, 0, artwork.TaskTypeArtworkDownload, state)
+		exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result)
+	})
+}
@@ -0,0 +1,74 @@
+package artwork
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+)
+
+// CacheControl is the Cache-Control header of served images.  Images change
+// when they are downloaded again, so clients should revalidate them with
+// their ETag now and then.
+const CacheControl = "public, max-age=3600"
+
+// RegisterRoutes adds the artwork endpoints to mux under baseUrl:
+//
+//	GET {baseUrl}/catalog/cards/{id}/artwork/{kind}  get an image of a card, optionally as the thumbnail named by size
+func (s *ArtworkService) RegisterRoutes(mux *http.ServeMux, baseUrl string) {
+	mux.HandleFunc("GET "+baseUrl+"/catalog/cards/{id}/artwork/{kind}", s.handleGetArtwork)
+}
+
+func (s *ArtworkService) handleGetArtwork(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil || id == 0 {
+		vmerr.Middleware(w, r, vmerr.BadRequest(fmt.Errorf("invalid card id %q", r.PathValue("id"))))
+		return
+	}
+	kind := Kind(r.PathValue("kind"))
+	if !kind.Valid() {
+		vmerr.Middleware(w, r, vmerr.BadRequest(fmt.Errorf("unknown artwork kind %q", kind)))
+		return
+	}
+	size := SizeOriginal
+	if query := r.URL.Query(); query.Has("size") {
+		size = Size(query.Get("size"))
+		if !size.Valid() {
+			vmerr.Middleware(w, r, vmerr.BadRequest(fmt.Errorf("unknown artwork size %q", size)))
+			return
+		}
+	}
+
+	artwork, err := GetArtwork(r.Context(), s.Db, uint32(id), kind)
+	if err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	} else if artwork == nil {
+		vmerr.Middleware(w, r, vmerr.NotFound(fmt.Errorf("card with id %d has no %s", id, kind)))
+		return
+	}
+	f, err := os.Open(artwork.Path(s.Paths, config.PathKindAbsolute, size))
+	if errors.Is(err, os.ErrNotExist) {
+		// The row was written after the files, so they were removed since.
+		vmerr.Middleware(w, r, vmerr.NotFound(fmt.Errorf("%s of card with id %d is missing", kind, id)))
+		return
+	} else if err != nil {
+		vmerr.Middleware(w, r, fmt.Errorf("failed to open %s: %w", kind, err))
+		return
+	}
+	defer f.Close()
+
+	contentType := artwork.ContentType
+	if size != SizeOriginal {
+		contentType = "image/jpeg"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", CacheControl)
+	w.Header().Set("ETag", fmt.Sprintf("%q", artwork.Sha256+"-"+string(size)))
+	// ServeContent answers conditional and range requests.
+	http.ServeContent(w, r, "", artwork.DownloadedAt, f)
+}
@@ -0,0 +1,150 @@
+package artwork_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/artwork"
+)
+
+// These requests are all rejected before the database is touched.
+func TestRoutes_BadRequests(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	mux := http.NewServeMux()
+	(&artwork.ArtworkService{}).RegisterRoutes(mux, "/api/v1")
+
+	tests := []struct {
+		name   string
+		loc    exam.Loc
+		method string
+		target string
+		want   int
+	}{
+		{
+			name:   "non-numeric id",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/catalog/cards/abc/artwork/poster",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "zero id",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/catalog/cards/0/artwork/poster",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "unknown kind",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/catalog/cards/1/artwork/banner",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "unknown size",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/catalog/cards/1/artwork/poster?size=w92",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "wrong method",
+			loc:    exam.Here(),
+			method: http.MethodPost,
+			target: "/api/v1/catalog/cards/1/artwork/poster",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			exam.Equal(e, env, rec.Code, tt.want).Log(tt.loc).Log(rec.Body.String())
+		})
+	}
+}
+
+func TestRoutes_GetArtwork(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	paths := config.Paths{RootDir: e.TempDir()}
+	if err := paths.Bootstrap(); err != nil {
+		e.Fatalf("could not bootstrap paths: %v", err)
+	}
+	poster := newPng(e, 600, 900)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(poster)
+	}))
+	defer server.Close()
+
+	cardId := postCard(e, env, pg)
+	handler := &artwork.DownloadHandler{Paths: paths}
+	state, err := json.Marshal(artwork.DownloadState{CardId: cardId, Kind: artwork.KindPoster, Url: server.URL + "/poster.png"})
+	exam.Nil(e, env, err).Log(err).Must()
+	result := handler.Handle(ctx, db, 0, artwork.TaskTypeArtworkDownload, state)
+	exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Must()
+	stored, err := artwork.GetArtwork(ctx, db, cardId, artwork.KindPoster)
+	exam.Nil(e, env, err).Log(err).Must()
+
+	mux := http.NewServeMux()
+	(&artwork.ArtworkService{Db: db, Paths: paths}).RegisterRoutes(mux, "/api/v1")
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	posterUrl := fmt.Sprintf("/api/v1/catalog/cards/%d/artwork/poster", cardId)
+
+	e.Run("original", func(e exam.E) {
+		rec := get(posterUrl, nil)
+		exam.Equal(e, env, rec.Code, http.StatusOK).Log(rec.Body.String()).Must()
+		exam.Equal(e, env, rec.Body.Bytes(), poster)
+		exam.Equal(e, env, rec.Header().Get("Content-Type"), "image/png")
+		exam.Equal(e, env, rec.Header().Get("Cache-Control"), artwork.CacheControl)
+		exam.Equal(e, env, rec.Header().Get("ETag"), fmt.Sprintf("%q", stored.Sha256+"-original"))
+	})
+
+	e.Run("thumbnail", func(e exam.E) {
+		rec := get(posterUrl+"?size=w185", nil)
+		exam.Equal(e, env, rec.Code, http.StatusOK).Log(rec.Body.String()).Must()
+		exam.Equal(e, env, rec.Header().Get("Content-Type"), "image/jpeg")
+		exam.Equal(e, env, rec.Header().Get("ETag"), fmt.Sprintf("%q", stored.Sha256+"-w185"))
+	})
+
+	e.Run("not modified", func(e exam.E) {
+		etag := get(posterUrl, nil).Header().Get("ETag")
+		rec := get(posterUrl, http.Header{"If-None-Match": {etag}})
+		exam.Equal(e, env, rec.Code, http.StatusNotModified)
+		exam.Equal(e, env, rec.Body.Len(), 0)
+	})
+
+	e.Run("not downloaded", func(e exam.E) {
+		rec := get(fmt.Sprintf("/api/v1/catalog/cards/%d/artwork/fanart", cardId), nil)
+		exam.Equal(e, env, rec.Code, http.StatusNotFound).Log(rec.Body.String())
+	})
+
+	e.Run("unknown card", func(e exam.E) {
+		rec := get(fmt.Sprintf("/api/v1/catalog/cards/%d/artwork/poster", cardId+1), nil)
+		exam.Equal(e, env, rec.Code, http.StatusNotFound).Log(rec.Body.String())
+	})
+}
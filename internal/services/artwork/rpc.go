@@ -0,0 +1,13 @@
+package artwork
+
+import (
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+)
+
+// ArtworkService serves the downloaded images of cards.  Its endpoints are not
+// part of vmapi, so it serves them itself; see RegisterRoutes.
+type ArtworkService struct {
+	Db    vmdb.DbRunner
+	Paths config.Paths
+}
@@ -0,0 +1,87 @@
+package artwork
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+)
+
+// thumbnailQuality is the JPEG quality of thumbnails.
+const thumbnailQuality = 85
+
+// writeThumbnails writes every one of Thumbnails of img into dir.
+func writeThumbnails(dir string, img image.Image) error {
+	src := toRGBA(img)
+	for _, t := range Thumbnails {
+		path := filepath.Join(dir, t.Size.fileName())
+		if err := writeJpeg(path, scaleToWidth(src, t.Width)); err != nil {
+			return fmt.Errorf("failed to write %s thumbnail: %w", t.Size, err)
+		}
+	}
+	return nil
+}
+
+func writeJpeg(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// toRGBA returns img as an *image.RGBA whose bounds start at the origin,
+// copying it only if it is not one already.
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	if rgba, ok := img.(*image.RGBA); ok && bounds.Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// scaleToWidth returns a copy of src that is width pixels wide, keeping its
+// aspect ratio.  Each pixel of the copy is the average of the pixels of src
+// that it covers.  Images that are already narrower are returned unscaled.
+// The bounds of src must start at the origin, as those from toRGBA do.
+func scaleToWidth(src *image.RGBA, width int) *image.RGBA {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	if srcW <= width {
+		return src
+	}
+	height := max(1, (srcH*width+srcW/2)/srcW)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0 := y * srcH / height
+		y1 := max((y+1)*srcH/height, y0+1)
+		for x := range width {
+			x0 := x * srcW / width
+			x1 := max((x+1)*srcW/width, x0+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			offset := y*dst.Stride + x*4
+			for c := range sum {
+				dst.Pix[offset+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
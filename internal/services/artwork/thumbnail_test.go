@@ -0,0 +1,112 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+)
+
+func TestScaleToWidth(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		name       string
+		loc        exam.Loc
+		srcWidth   int
+		srcHeight  int
+		width      int
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:       "poster",
+			loc:        exam.Here(),
+			srcWidth:   600,
+			srcHeight:  900,
+			width:      185,
+			wantWidth:  185,
+			wantHeight: 278,
+		},
+		{
+			name:       "fanart",
+			loc:        exam.Here(),
+			srcWidth:   1920,
+			srcHeight:  1080,
+			width:      780,
+			wantWidth:  780,
+			wantHeight: 439,
+		},
+		{
+			name:       "very wide",
+			loc:        exam.Here(),
+			srcWidth:   1000,
+			srcHeight:  1,
+			width:      185,
+			wantWidth:  185,
+			wantHeight: 1,
+		},
+		{
+			name:       "narrower than thumbnail",
+			loc:        exam.Here(),
+			srcWidth:   100,
+			srcHeight:  150,
+			width:      185,
+			wantWidth:  100,
+			wantHeight: 150,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			src := image.NewGray(image.Rect(0, 0, tt.srcWidth, tt.srcHeight))
+			got := scaleToWidth(toRGBA(src), tt.width).Bounds()
+			exam.Equal(e, env, got.Dx(), tt.wantWidth).Log(tt.loc)
+			exam.Equal(e, env, got.Dy(), tt.wantHeight).Log(tt.loc)
+		})
+	}
+
+	e.Run("averages pixels", func(e exam.E) {
+		// Alternating black and white columns average to grey.
+		src := image.NewRGBA(image.Rect(10, 10, 14, 12))
+		for x := 10; x < 14; x++ {
+			for y := 10; y < 12; y++ {
+				c := color.RGBA{A: 255}
+				if x%2 == 0 {
+					c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+				}
+				src.SetRGBA(x, y, c)
+			}
+		}
+		got := scaleToWidth(toRGBA(src), 2)
+		exam.Equal(e, env, got.Bounds(), image.Rect(0, 0, 2, 1))
+		for x := range 2 {
+			exam.Equal(e, env, got.RGBAAt(x, 0), color.RGBA{R: 128, G: 128, B: 128, A: 255})
+		}
+	})
+}
+
+func TestWriteThumbnails(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	dir := t.TempDir()
+
+	src := image.NewRGBA(image.Rect(0, 0, 500, 750))
+	exam.Nil(e, env, writeThumbnails(dir, src)).Must()
+
+	// Only w185 and w342 are scaled; w780 keeps the width of the image.
+	wantWidths := map[Size]int{"w185": 185, "w342": 342, "w780": 500}
+	for _, thumbnail := range Thumbnails {
+		f, err := os.Open(filepath.Join(dir, thumbnail.Size.fileName()))
+		exam.Nil(e, env, err).Log(err).Must()
+		cfg, err := jpeg.DecodeConfig(f)
+		f.Close()
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, cfg.Width, wantWidths[thumbnail.Size]).Log(thumbnail.Size)
+	}
+}
@@ -11,68 +11,126 @@ import (
 	"github.com/krelinga/video-manager/internal/lib/vmpage"
 )
 
+// cardColumns are the columns of a cardRow, from catalog_cards c joined with
+// catalog_movies m and catalog_movie_editions me.
+const cardColumns = `
+	c.id, c.name, c.note,
+	m.card_id IS NOT NULL AS is_movie,
+	m.release_year, m.tmdb_id, m.fanart_id,
+	me.card_id IS NOT NULL AS is_movie_edition,
+	me.kind_id, me.movie_card_id
+`
+
+type cardRow struct {
+	Id             uint32
+	Name           string
+	Note           *string
+	IsMovie        bool
+	ReleaseYear    *uint32
+	TmdbId         *uint64
+	FanartId       *string
+	IsMovieEdition bool
+	KindId         *uint32
+	MovieCardId    *uint32
+}
+
+func (r *cardRow) toCard() vmapi.Card {
+	card := vmapi.Card{
+		Id:   r.Id,
+		Name: r.Name,
+		Note: r.Note,
+	}
+	if r.IsMovie {
+		card.Details.Movie = &vmapi.Movie{
+			ReleaseYear: r.ReleaseYear,
+			TmdbId:      r.TmdbId,
+			FanartId:    r.FanartId,
+		}
+	} else if r.IsMovieEdition {
+		var kindId, movieId uint32
+		if r.KindId != nil {
+			kindId = *r.KindId
+		}
+		if r.MovieCardId != nil {
+			movieId = *r.MovieCardId
+		}
+		card.Details.MovieEdition = &vmapi.MovieEdition{
+			KindId:  kindId,
+			MovieId: movieId,
+		}
+	}
+	return card
+}
+
+// cardSortKeys holds the sort key of each CardSort other than CardSortId.
+// Cards without a release year get a key that puts them last.
+var cardSortKeys = map[CardSort]string{
+	CardSortReleaseYear:     "COALESCE(m.release_year, 2147483647)",
+	CardSortReleaseYearDesc: "-COALESCE(m.release_year, -1)",
+}
+
 func (s *CatalogService) ListCards(ctx context.Context, request vmapi.ListCardsRequestObject) (vmapi.ListCardsResponseObject, error) {
-	const sql = `
-		SELECT 
-			c.id, c.name, c.note,
-			m.card_id IS NOT NULL AS is_movie,
-			m.tmdb_id, m.fanart_id,
-			me.card_id IS NOT NULL AS is_movie_edition,
-			me.kind_id, me.movie_card_id
-		FROM catalog_cards c
-		LEFT JOIN catalog_movies m ON m.card_id = c.id
-		LEFT JOIN catalog_movie_editions me ON me.card_id = c.id
-		WHERE c.id > @lastSeenId
-		ORDER BY c.id ASC
-		LIMIT @limit;
+	opts := ListCardsOptionsFromContext(ctx)
+	if opts.MinReleaseYear != nil && opts.MaxReleaseYear != nil && *opts.MinReleaseYear > *opts.MaxReleaseYear {
+		return nil, vmerr.BadRequest(fmt.Errorf("%s must not be greater than %s", QueryMinReleaseYear, QueryMaxReleaseYear))
+	}
+	const filter = `
+		(@minReleaseYear::integer IS NULL OR m.release_year >= @minReleaseYear::integer)
+		AND (@maxReleaseYear::integer IS NULL OR m.release_year <= @maxReleaseYear::integer)
 	`
 
 	var entries []vmapi.Card
 	query := &vmpage.ListQuery{
-		Sql:       sql,
 		Want:      request.Params.PageSize,
 		Default:   50,
 		Max:       100,
 		PageToken: request.Params.PageToken,
-	}
-	type row struct {
-		Id             uint32
-		Name           string
-		Note           *string
-		IsMovie        bool
-		TmdbId         *uint64
-		FanartId       *string
-		IsMovieEdition bool
-		KindId         *uint32
-		MovieCardId    *uint32
-	}
-	nextPageToken, err := vmpage.ListPtr(ctx, s.Db, query, func(r *row) uint32 {
-		card := vmapi.Card{
-			Id:   r.Id,
-			Name: r.Name,
-			Note: r.Note,
+		Params: map[string]any{
+			"minReleaseYear": opts.MinReleaseYear,
+			"maxReleaseYear": opts.MaxReleaseYear,
+		},
+	}
+	var nextPageToken *string
+	var err error
+	switch opts.Sort {
+	case "", CardSortId:
+		query.Sql = fmt.Sprintf(`
+			SELECT %s
+			FROM catalog_cards c
+			LEFT JOIN catalog_movies m ON m.card_id = c.id
+			LEFT JOIN catalog_movie_editions me ON me.card_id = c.id
+			WHERE c.id > @lastSeenId AND %s
+			ORDER BY c.id ASC
+			LIMIT @limit;
+		`, cardColumns, filter)
+		nextPageToken, err = vmpage.ListPtr(ctx, s.Db, query, func(r *cardRow) uint32 {
+			entries = append(entries, r.toCard())
+			return r.Id
+		})
+	default:
+		key, ok := cardSortKeys[opts.Sort]
+		if !ok {
+			return nil, vmerr.BadRequest(fmt.Errorf("unknown %s %q", QuerySort, opts.Sort))
 		}
-		if r.IsMovie {
-			card.Details.Movie = &vmapi.Movie{
-				TmdbId:   r.TmdbId,
-				FanartId: r.FanartId,
-			}
-		} else if r.IsMovieEdition {
-			var kindId, movieId uint32
-			if r.KindId != nil {
-				kindId = *r.KindId
-			}
-			if r.MovieCardId != nil {
-				movieId = *r.MovieCardId
-			}
-			card.Details.MovieEdition = &vmapi.MovieEdition{
-				KindId:  kindId,
-				MovieId: movieId,
-			}
+		query.Sql = fmt.Sprintf(`
+			SELECT %[1]s, %[2]s AS sort_key
+			FROM catalog_cards c
+			LEFT JOIN catalog_movies m ON m.card_id = c.id
+			LEFT JOIN catalog_movie_editions me ON me.card_id = c.id
+			WHERE (@lastSeenKey::bigint IS NULL OR (%[2]s, c.id) > (@lastSeenKey::bigint, @lastSeenId))
+				AND %[3]s
+			ORDER BY %[2]s ASC, c.id ASC
+			LIMIT @limit;
+		`, cardColumns, key, filter)
+		type sortedRow struct {
+			cardRow
+			SortKey int64
 		}
-		entries = append(entries, card)
-		return r.Id
-	})
+		nextPageToken, err = vmpage.ListSortedPtr(ctx, s.Db, query, string(opts.Sort), func(r *sortedRow) (int64, uint32) {
+			entries = append(entries, r.toCard())
+			return r.SortKey, r.Id
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -99,14 +157,19 @@ func (s *CatalogService) PostCard(ctx context.Context, request vmapi.PostCardReq
 	}
 	defer tx.Rollback(ctx)
 
-	// Check if card with the given name already exists
-	const nameQuery = "SELECT COUNT(*) FROM catalog_cards WHERE LOWER(name) = LOWER($1)"
-	count, err := vmdb.QueryOne[int](ctx, tx, vmdb.Positional(nameQuery, name))
-	if err != nil {
-		return nil, fmt.Errorf("could not check for existing card name: %w", err)
-	}
-	if count > 0 {
-		return nil, vmerr.AlreadyExists(errors.New("card with the given name already exists"))
+	// Check if card with the given name already exists.  Seasons and episodes
+	// are told apart by their numbers within their parent, so names like
+	// "Season 1" may repeat.
+	details := CardPostDetailsFromContext(ctx)
+	if details.Season == nil && details.Episode == nil {
+		const nameQuery = "SELECT COUNT(*) FROM catalog_cards WHERE LOWER(name) = LOWER($1)"
+		count, err := vmdb.QueryOne[int](ctx, tx, vmdb.Positional(nameQuery, name))
+		if err != nil {
+			return nil, fmt.Errorf("could not check for existing card name: %w", err)
+		}
+		if count > 0 {
+			return nil, vmerr.AlreadyExists(errors.New("card with the given name already exists"))
+		}
 	}
 
 	// Insert the card
@@ -121,23 +184,38 @@ func (s *CatalogService) PostCard(ctx context.Context, request vmapi.PostCardReq
 	}
 
 	// Handle card details if provided
-	// Validate that exactly one of Movie or MovieEdition is set
-	hasMovie := request.Body.Details.Movie != nil
-	hasMovieEdition := request.Body.Details.MovieEdition != nil
-	if hasMovie && hasMovieEdition {
-		return nil, vmerr.BadRequest(errors.New("exactly one of Movie or MovieEdition must be set, not both"))
+	// Validate that exactly one kind of details is set
+	kindsSet := 0
+	for _, isSet := range []bool{
+		request.Body.Details.Movie != nil,
+		request.Body.Details.MovieEdition != nil,
+		details.Series != nil,
+		details.Season != nil,
+		details.Episode != nil,
+	} {
+		if isSet {
+			kindsSet++
+		}
+	}
+	if kindsSet == 0 {
+		return nil, vmerr.BadRequest(errors.New("exactly one of Movie, MovieEdition, Series, Season or Episode must be set"))
 	}
-	if !hasMovie && !hasMovieEdition {
-		return nil, vmerr.BadRequest(errors.New("exactly one of Movie or MovieEdition must be set"))
+	if kindsSet > 1 {
+		return nil, vmerr.BadRequest(errors.New("exactly one of Movie, MovieEdition, Series, Season or Episode must be set, not several"))
 	}
 
 	if request.Body.Details.Movie != nil {
 		movie := request.Body.Details.Movie
-		const insertMovieQuery = "INSERT INTO catalog_movies (card_id, tmdb_id, fanart_id) VALUES ($1, $2, $3)"
-		_, err = vmdb.Exec(ctx, tx, vmdb.Positional(insertMovieQuery, cardId, movie.TmdbId, movie.FanartId))
+		const insertMovieQuery = "INSERT INTO catalog_movies (card_id, release_year, tmdb_id, fanart_id) VALUES ($1, $2, $3, $4)"
+		_, err = vmdb.Exec(ctx, tx, vmdb.Positional(insertMovieQuery, cardId, movie.ReleaseYear, movie.TmdbId, movie.FanartId))
 		if err != nil {
 			return nil, fmt.Errorf("failed to insert movie details: %w", err)
 		}
+		if movie.TmdbId != nil {
+			if _, err := createTmdbRefreshTask(ctx, tx, cardId, *movie.TmdbId); err != nil {
+				return nil, fmt.Errorf("failed to create TMDb refresh task: %w", err)
+			}
+		}
 	} else if request.Body.Details.MovieEdition != nil {
 		movieEdition := request.Body.Details.MovieEdition
 
@@ -166,6 +244,8 @@ func (s *CatalogService) PostCard(ctx context.Context, request vmapi.PostCardReq
 		if err != nil {
 			return nil, fmt.Errorf("failed to insert movie edition details: %w", err)
 		}
+	} else if err := insertSeriesDetails(ctx, tx, cardId, details); err != nil {
+		return nil, err
 	}
 
 	card, err := getCard(ctx, tx, cardId)
@@ -198,60 +278,20 @@ func (s *CatalogService) DeleteCard(ctx context.Context, request vmapi.DeleteCar
 }
 
 func getCard(ctx context.Context, runner vmdb.Runner, id uint32) (vmapi.Card, error) {
-	const sql = `
-		SELECT 
-			c.id, c.name, c.note,
-			m.card_id IS NOT NULL AS is_movie,
-			m.tmdb_id, m.fanart_id,
-			me.card_id IS NOT NULL AS is_movie_edition,
-			me.kind_id, me.movie_card_id
+	sql := fmt.Sprintf(`
+		SELECT %s
 		FROM catalog_cards c
 		LEFT JOIN catalog_movies m ON m.card_id = c.id
 		LEFT JOIN catalog_movie_editions me ON me.card_id = c.id
 		WHERE c.id = $1;
-	`
-	type row struct {
-		Id             uint32
-		Name           string
-		Note           *string
-		IsMovie        bool
-		TmdbId         *uint64
-		FanartId       *string
-		IsMovieEdition bool
-		KindId         *uint32
-		MovieCardId    *uint32
-	}
-	r, err := vmdb.QueryOne[row](ctx, runner, vmdb.Positional(sql, id))
+	`, cardColumns)
+	r, err := vmdb.QueryOne[cardRow](ctx, runner, vmdb.Positional(sql, id))
 	if errors.Is(err, vmdb.ErrNotFound) {
 		return vmapi.Card{}, vmerr.NotFound(fmt.Errorf("card with id %d not found", id))
 	} else if err != nil {
 		return vmapi.Card{}, err
 	}
-
-	card := vmapi.Card{
-		Id:   r.Id,
-		Name: r.Name,
-		Note: r.Note,
-	}
-	if r.IsMovie {
-		card.Details.Movie = &vmapi.Movie{
-			TmdbId:   r.TmdbId,
-			FanartId: r.FanartId,
-		}
-	} else if r.IsMovieEdition {
-		var kindId, movieId uint32
-		if r.KindId != nil {
-			kindId = *r.KindId
-		}
-		if r.MovieCardId != nil {
-			movieId = *r.MovieCardId
-		}
-		card.Details.MovieEdition = &vmapi.MovieEdition{
-			KindId:  kindId,
-			MovieId: movieId,
-		}
-	}
-	return card, nil
+	return r.toCard(), nil
 }
 
 func (s *CatalogService) GetCard(ctx context.Context, request vmapi.GetCardRequestObject) (vmapi.GetCardResponseObject, error) {
@@ -288,8 +328,13 @@ func (s *CatalogService) PatchCard(ctx context.Context, request vmapi.PatchCardR
 		return nil, err
 	}
 
-	for _, patch := range *request.Body {
+	details := CardPatchDetailsFromContext(ctx)
+	for i, patch := range *request.Body {
 		var fieldsSet int
+		var extra CardPatchDetails
+		if i < len(details) {
+			extra = details[i]
+		}
 
 		if patch.Name != nil {
 			fieldsSet++
@@ -341,12 +386,17 @@ func (s *CatalogService) PatchCard(ctx context.Context, request vmapi.PatchCardR
 				return nil, vmerr.BadRequest(errors.New("exactly one field must be set in Movie patch"))
 			}
 
-			if moviePatch.TmdbId != nil {
+			// Only refresh from TMDb when the tmdb_id actually changes.
+			if current := currentCard.Details.Movie.TmdbId; moviePatch.TmdbId != nil && (current == nil || *current != *moviePatch.TmdbId) {
 				const query = "UPDATE catalog_movies SET tmdb_id = $1 WHERE card_id = $2;"
 				_, err := vmdb.Exec(ctx, tx, vmdb.Positional(query, *moviePatch.TmdbId, id))
 				if err != nil {
 					return nil, fmt.Errorf("could not update tmdb_id: %w", err)
 				}
+				if _, err := createTmdbRefreshTask(ctx, tx, id, *moviePatch.TmdbId); err != nil {
+					return nil, fmt.Errorf("failed to create TMDb refresh task: %w", err)
+				}
+				currentCard.Details.Movie.TmdbId = moviePatch.TmdbId
 			}
 
 			if moviePatch.FanartId != nil {
@@ -358,7 +408,7 @@ func (s *CatalogService) PatchCard(ctx context.Context, request vmapi.PatchCardR
 			}
 
 			if moviePatch.ReleaseYear != nil {
-				const query = "UPDATE catalog_movies SET release_year = $1 WHERE card_id = $2;"
+				const query = "UPDATE catalog_movies SET release_year = $1, release_year_from_tmdb = FALSE WHERE card_id = $2;"
 				_, err := vmdb.Exec(ctx, tx, vmdb.Positional(query, *moviePatch.ReleaseYear, id))
 				if err != nil {
 					return nil, fmt.Errorf("could not update release_year: %w", err)
@@ -395,6 +445,19 @@ func (s *CatalogService) PatchCard(ctx context.Context, request vmapi.PatchCardR
 			}
 		}
 
+		seriesFieldsSet := 0
+		for _, isSet := range []bool{extra.Series != nil, extra.Season != nil, extra.Episode != nil} {
+			if isSet {
+				seriesFieldsSet++
+			}
+		}
+		fieldsSet += seriesFieldsSet
+		if seriesFieldsSet == 1 && fieldsSet == 1 {
+			if err := patchSeriesDetails(ctx, tx, id, extra); err != nil {
+				return nil, err
+			}
+		}
+
 		if fieldsSet == 0 {
 			return nil, vmerr.BadRequest(errors.New("no valid fields to patch"))
 		}
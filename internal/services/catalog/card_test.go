@@ -10,6 +10,7 @@ import (
 	"github.com/krelinga/video-manager-api/go/vmapi"
 	"github.com/krelinga/video-manager/internal/lib/vmerr"
 	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/catalog"
 )
 
 func TestListCards(t *testing.T) {
@@ -229,6 +230,192 @@ func TestListCards(t *testing.T) {
 	})
 }
 
+func TestListCards_ReleaseYear(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewCatalogService(e, pg)
+
+	// Cards are created in this order, so their ids are in this order too.
+	years := []struct {
+		name string
+		year *uint32
+	}{
+		{"A", Set(uint32(2001))},
+		{"B", Set(uint32(1995))},
+		{"C", nil},
+		{"D", Set(uint32(2010))},
+		{"E", Set(uint32(2001))},
+	}
+	var movieId uint32
+	for _, y := range years {
+		resp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{
+				Name: y.name,
+				Details: vmapi.CardPostDetails{
+					Movie: &vmapi.Movie{ReleaseYear: y.year},
+				},
+			},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		movieId = resp.(vmapi.PostCard201JSONResponse).Id
+	}
+	kindResp, err := service.PostMovieEditionKind(ctx, vmapi.PostMovieEditionKindRequestObject{
+		Body: &vmapi.MovieEditionKindPost{Name: "Director's Cut"},
+	})
+	exam.Nil(e, env, err).Log(err).Must()
+	_, err = service.PostCard(ctx, vmapi.PostCardRequestObject{
+		Body: &vmapi.CardPost{
+			Name: "F",
+			Details: vmapi.CardPostDetails{
+				MovieEdition: &vmapi.MovieEdition{
+					KindId:  kindResp.(vmapi.PostMovieEditionKind201JSONResponse).Id,
+					MovieId: movieId,
+				},
+			},
+		},
+	})
+	exam.Nil(e, env, err).Log(err).Must()
+
+	// listAll lists every card, two at a time, and returns their names.
+	listAll := func(opts catalog.ListCardsOptions) ([]string, error) {
+		ctx := catalog.WithListCardsOptions(ctx, opts)
+		var names []string
+		var pageToken *string
+		for {
+			resp, err := service.ListCards(ctx, vmapi.ListCardsRequestObject{
+				Params: vmapi.ListCardsParams{
+					PageSize:  Set(uint32(2)),
+					PageToken: pageToken,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			page := resp.(vmapi.ListCards200JSONResponse)
+			for _, card := range page.Cards {
+				names = append(names, card.Name)
+			}
+			if page.NextPageToken == nil {
+				return names, nil
+			}
+			pageToken = page.NextPageToken
+		}
+	}
+
+	tests := []struct {
+		loc     exam.Loc
+		name    string
+		opts    catalog.ListCardsOptions
+		want    []string
+		wantErr match.Matcher
+	}{
+		{
+			loc:     exam.Here(),
+			name:    "default order",
+			want:    []string{"A", "B", "C", "D", "E", "F"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "sort by id",
+			opts:    catalog.ListCardsOptions{Sort: catalog.CardSortId},
+			want:    []string{"A", "B", "C", "D", "E", "F"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "sort by release year",
+			opts:    catalog.ListCardsOptions{Sort: catalog.CardSortReleaseYear},
+			want:    []string{"B", "A", "E", "D", "C", "F"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "sort by release year descending",
+			opts:    catalog.ListCardsOptions{Sort: catalog.CardSortReleaseYearDesc},
+			want:    []string{"D", "A", "E", "B", "C", "F"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "minimum release year",
+			opts:    catalog.ListCardsOptions{MinReleaseYear: Set(uint32(2001))},
+			want:    []string{"A", "D", "E"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "maximum release year",
+			opts:    catalog.ListCardsOptions{MaxReleaseYear: Set(uint32(2000))},
+			want:    []string{"B"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "release year range sorted descending",
+			opts: catalog.ListCardsOptions{
+				MinReleaseYear: Set(uint32(1995)),
+				MaxReleaseYear: Set(uint32(2001)),
+				Sort:           catalog.CardSortReleaseYearDesc,
+			},
+			want:    []string{"A", "E", "B"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "empty release year range",
+			opts: catalog.ListCardsOptions{
+				MinReleaseYear: Set(uint32(2002)),
+				MaxReleaseYear: Set(uint32(2001)),
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "unknown sort",
+			opts:    catalog.ListCardsOptions{Sort: "name"},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			got, err := listAll(tt.opts)
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+
+	e.Run("page token from another sort order", func(e exam.E) {
+		// firstToken returns the next page token of the first page in the
+		// given order.
+		firstToken := func(e exam.E, sort catalog.CardSort) *string {
+			sortedCtx := catalog.WithListCardsOptions(ctx, catalog.ListCardsOptions{Sort: sort})
+			resp, err := service.ListCards(sortedCtx, vmapi.ListCardsRequestObject{
+				Params: vmapi.ListCardsParams{PageSize: Set(uint32(2))},
+			})
+			exam.Nil(e, env, err).Log(err).Must()
+			pageToken := resp.(vmapi.ListCards200JSONResponse).NextPageToken
+			exam.Match(e, env, pageToken, match.Not(match.Nil())).Must()
+			return pageToken
+		}
+		pairs := []struct{ from, to catalog.CardSort }{
+			{catalog.CardSortId, catalog.CardSortReleaseYear},
+			{catalog.CardSortReleaseYear, catalog.CardSortId},
+			{catalog.CardSortReleaseYear, catalog.CardSortReleaseYearDesc},
+		}
+		for _, pair := range pairs {
+			sortedCtx := catalog.WithListCardsOptions(ctx, catalog.ListCardsOptions{Sort: pair.to})
+			_, err := service.ListCards(sortedCtx, vmapi.ListCardsRequestObject{
+				Params: vmapi.ListCardsParams{PageSize: Set(uint32(2)), PageToken: firstToken(e, pair.from)},
+			})
+			exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest)).Log(err).Log(pair)
+		}
+	})
+}
+
 func TestPostCard(t *testing.T) {
 	ctx := context.Background()
 	e := exam.New(t)
@@ -366,8 +553,9 @@ func TestPostCard(t *testing.T) {
 					Name: "New Movie",
 					Details: vmapi.CardPostDetails{
 						Movie: &vmapi.Movie{
-							TmdbId:   &tmdbId,
-							FanartId: &fanartId,
+							TmdbId:      &tmdbId,
+							FanartId:    &fanartId,
+							ReleaseYear: Set(uint32(1999)),
 						},
 					},
 				}
@@ -381,8 +569,9 @@ func TestPostCard(t *testing.T) {
 						Fields: map[deep.Field]match.Matcher{
 							deep.NamedField("Movie"): match.Pointer(match.Struct{
 								Fields: map[deep.Field]match.Matcher{
-									deep.NamedField("TmdbId"):   match.Pointer(match.Equal(uint64(12345))),
-									deep.NamedField("FanartId"): match.Pointer(match.Equal("fanart123")),
+									deep.NamedField("TmdbId"):      match.Pointer(match.Equal(uint64(12345))),
+									deep.NamedField("FanartId"):    match.Pointer(match.Equal("fanart123")),
+									deep.NamedField("ReleaseYear"): match.Pointer(match.Equal(uint32(1999))),
 								},
 							}),
 							deep.NamedField("MovieEdition"): match.Nil(),
@@ -684,7 +873,8 @@ func TestGetCard(t *testing.T) {
 						Name: "Test Movie",
 						Details: vmapi.CardPostDetails{
 							Movie: &vmapi.Movie{
-								TmdbId: &tmdbId,
+								TmdbId:      &tmdbId,
+								ReleaseYear: Set(uint32(2001)),
 							},
 						},
 					},
@@ -702,7 +892,8 @@ func TestGetCard(t *testing.T) {
 						Fields: map[deep.Field]match.Matcher{
 							deep.NamedField("Movie"): match.Pointer(match.Struct{
 								Fields: map[deep.Field]match.Matcher{
-									deep.NamedField("TmdbId"): match.Pointer(match.Equal(uint64(12345))),
+									deep.NamedField("TmdbId"):      match.Pointer(match.Equal(uint64(12345))),
+									deep.NamedField("ReleaseYear"): match.Pointer(match.Equal(uint32(2001))),
 								},
 							}),
 						},
@@ -1037,8 +1228,20 @@ func TestPatchCard(t *testing.T) {
 					},
 				},
 			},
-			wantErr:  match.Nil(),
-			wantResp: match.Not(match.Nil()),
+			wantErr: match.Nil(),
+			wantResp: match.Interface(match.Struct{
+				Fields: map[deep.Field]match.Matcher{
+					deep.NamedField("Details"): match.Struct{
+						Fields: map[deep.Field]match.Matcher{
+							deep.NamedField("Movie"): match.Pointer(match.Struct{
+								Fields: map[deep.Field]match.Matcher{
+									deep.NamedField("ReleaseYear"): match.Pointer(match.Equal(uint32(2023))),
+								},
+							}),
+						},
+					},
+				},
+			}),
 		},
 		{
 			loc:   exam.Here(),
@@ -0,0 +1,56 @@
+package catalog
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+)
+
+// RegisterRoutes adds the catalog endpoints that are not part of vmapi to mux
+// under baseUrl:
+//
+//	GET {baseUrl}/catalog/cards/search  search cards by q, optionally filtered by kind
+func (s *CatalogService) RegisterRoutes(mux *http.ServeMux, baseUrl string) {
+	mux.HandleFunc("GET "+baseUrl+"/catalog/cards/search", s.handleSearchCards)
+}
+
+func (s *CatalogService) handleSearchCards(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := SearchCardsParams{
+		Query: query.Get("q"),
+	}
+	if query.Has("kind") {
+		kind := CardKind(query.Get("kind"))
+		params.Kind = &kind
+	}
+	var err error
+	if params.PageSize, err = parseUint32(query, "page_size"); err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	}
+	if query.Has("page_token") {
+		pageToken := query.Get("page_token")
+		params.PageToken = &pageToken
+	}
+
+	page, err := s.SearchCards(r.Context(), params)
+	if err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	}
+	var body any = page
+	cards, err := s.extend(r.Context(), page.Cards)
+	if err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	} else if cards != nil {
+		body = extendedCardPage{CardPage: *page, Cards: cards}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.ErrorContext(r.Context(), "catalog: failed to encode response", "error", err)
+	}
+}
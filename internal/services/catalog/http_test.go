@@ -0,0 +1,63 @@
+package catalog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+)
+
+// These requests are all rejected before the database is touched.
+func TestRoutes_BadRequests(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	mux := http.NewServeMux()
+	(&catalog.CatalogService{}).RegisterRoutes(mux, "/api/v1")
+
+	tests := []struct {
+		name   string
+		loc    exam.Loc
+		method string
+		target string
+		want   int
+	}{
+		{
+			name:   "missing query",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/catalog/cards/search",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "unknown kind",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/catalog/cards/search?q=matrix&kind=book",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "bad page_size",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/catalog/cards/search?q=matrix&page_size=-1",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "wrong method",
+			loc:    exam.Here(),
+			method: http.MethodPost,
+			target: "/api/v1/catalog/cards/search?q=matrix",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			exam.Equal(e, env, rec.Code, tt.want).Log(tt.loc).Log(rec.Body.String())
+		})
+	}
+}
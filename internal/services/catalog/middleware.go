@@ -0,0 +1,312 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmbody"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+)
+
+// The ListCards query parameters that set the fields of ListCardsOptions.
+const (
+	QueryMinReleaseYear = "min_release_year"
+	QueryMaxReleaseYear = "max_release_year"
+	QuerySort           = "sort"
+)
+
+// CardSort is the order in which ListCards returns cards.
+type CardSort string
+
+const (
+	// CardSortId sorts cards by id.  The zero value of CardSort means the
+	// same thing.
+	CardSortId CardSort = "id"
+	// CardSortReleaseYear sorts cards by release year, oldest first.
+	CardSortReleaseYear CardSort = "release_year"
+	// CardSortReleaseYearDesc sorts cards by release year, newest first.
+	CardSortReleaseYearDesc CardSort = "-release_year"
+)
+
+// ListCardsOptions filters and sorts the cards returned by ListCards.
+type ListCardsOptions struct {
+	// MinReleaseYear and MaxReleaseYear, if set, only include cards released
+	// in that range of years, inclusive.  Cards without a release year are
+	// left out.
+	MinReleaseYear *uint32
+	MaxReleaseYear *uint32
+	// Sort is the order of the cards.  Cards without a release year come last
+	// when sorting by release year, and ties are broken by id.
+	Sort CardSort
+}
+
+type listCardsOptionsKey struct{}
+
+// WithListCardsOptions returns a copy of ctx that carries opts to ListCards.
+func WithListCardsOptions(ctx context.Context, opts ListCardsOptions) context.Context {
+	return context.WithValue(ctx, listCardsOptionsKey{}, opts)
+}
+
+// ListCardsOptionsFromContext returns the options attached to ctx by
+// WithListCardsOptions, or the zero value if there are none.
+func ListCardsOptionsFromContext(ctx context.Context) ListCardsOptions {
+	opts, _ := ctx.Value(listCardsOptionsKey{}).(ListCardsOptions)
+	return opts
+}
+
+// CardPostDetails holds the fields of the PostCard details that
+// vmapi.CardPostDetails can't express yet.  Like the others, exactly one of
+// the details must be set.
+type CardPostDetails struct {
+	Series  *Series  `json:"series,omitempty"`
+	Season  *Season  `json:"season,omitempty"`
+	Episode *Episode `json:"episode,omitempty"`
+}
+
+type cardPostDetailsKey struct{}
+
+// WithCardPostDetails returns a copy of ctx that carries details to PostCard.
+func WithCardPostDetails(ctx context.Context, details CardPostDetails) context.Context {
+	return context.WithValue(ctx, cardPostDetailsKey{}, details)
+}
+
+// CardPostDetailsFromContext returns the details attached to ctx by
+// WithCardPostDetails, or the zero value if there are none.
+func CardPostDetailsFromContext(ctx context.Context) CardPostDetails {
+	details, _ := ctx.Value(cardPostDetailsKey{}).(CardPostDetails)
+	return details
+}
+
+// CardPatchDetails holds the fields of one PatchCard patch that
+// vmapi.CardPatch can't express yet.
+type CardPatchDetails struct {
+	Series  *SeriesPatch  `json:"series,omitempty"`
+	Season  *SeasonPatch  `json:"season,omitempty"`
+	Episode *EpisodePatch `json:"episode,omitempty"`
+}
+
+type cardPatchDetailsKey struct{}
+
+// WithCardPatchDetails returns a copy of ctx that carries details to
+// PatchCard.  The entries of details match the patches in the request body by
+// index.
+func WithCardPatchDetails(ctx context.Context, details []CardPatchDetails) context.Context {
+	return context.WithValue(ctx, cardPatchDetailsKey{}, details)
+}
+
+// CardPatchDetailsFromContext returns the details attached to ctx by
+// WithCardPatchDetails, or nil if there are none.
+func CardPatchDetailsFromContext(ctx context.Context) []CardPatchDetails {
+	details, _ := ctx.Value(cardPatchDetailsKey{}).([]CardPatchDetails)
+	return details
+}
+
+// StrictMiddleware adds these parts of the catalog API, which package vmbody
+// explains:
+//   - the min_release_year, max_release_year and sort query parameters of
+//     ListCards.
+//   - details.series, details.season and details.episode in the body of
+//     PostCard, which needs vmbody.Middleware.
+//   - series, season and episode patches in the body of PatchCard, which
+//     needs vmbody.Middleware.
+//   - details.series, details.season and details.episode of the cards
+//     returned by GetCard, ListCards, PostCard and PatchCard.
+func (s *CatalogService) StrictMiddleware(f vmapi.StrictHandlerFunc, operationID string) vmapi.StrictHandlerFunc {
+	switch operationID {
+	case "ListCards":
+		return s.detailsMiddleware(listCardsOptionsMiddleware(f))
+	case "PostCard":
+		return s.detailsMiddleware(postDetailsMiddleware(f))
+	case "PatchCard":
+		return s.detailsMiddleware(patchDetailsMiddleware(f))
+	case "GetCard":
+		return s.detailsMiddleware(f)
+	default:
+		return f
+	}
+}
+
+func listCardsOptionsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		query := r.URL.Query()
+		var opts ListCardsOptions
+		var err error
+		if opts.MinReleaseYear, err = parseUint32(query, QueryMinReleaseYear); err != nil {
+			return nil, err
+		}
+		if opts.MaxReleaseYear, err = parseUint32(query, QueryMaxReleaseYear); err != nil {
+			return nil, err
+		}
+		opts.Sort = CardSort(query.Get(QuerySort))
+		return f(WithListCardsOptions(ctx, opts), w, r, request)
+	}
+}
+
+// parseUint32 parses the named query parameter, if it is set.
+func parseUint32(query url.Values, name string) (*uint32, error) {
+	if !query.Has(name) {
+		return nil, nil
+	}
+	v, err := strconv.ParseUint(query.Get(name), 10, 32)
+	if err != nil {
+		return nil, vmerr.BadRequest(fmt.Errorf("invalid %s %q: %w", name, query.Get(name), err))
+	}
+	out := uint32(v)
+	return &out, nil
+}
+
+func postDetailsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		if body, ok := vmbody.FromContext(ctx); ok {
+			var post struct {
+				Details CardPostDetails `json:"details"`
+			}
+			if err := json.Unmarshal(body, &post); err != nil {
+				return nil, vmerr.BadRequest(fmt.Errorf("can't decode JSON body: %w", err))
+			}
+			ctx = WithCardPostDetails(ctx, post.Details)
+		}
+		return f(ctx, w, r, request)
+	}
+}
+
+func patchDetailsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		if body, ok := vmbody.FromContext(ctx); ok {
+			var patches []CardPatchDetails
+			if err := json.Unmarshal(body, &patches); err != nil {
+				return nil, vmerr.BadRequest(fmt.Errorf("can't decode JSON body: %w", err))
+			}
+			ctx = WithCardPatchDetails(ctx, patches)
+		}
+		return f(ctx, w, r, request)
+	}
+}
+
+func (s *CatalogService) detailsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		response, err := f(ctx, w, r, request)
+		if err != nil {
+			return response, err
+		}
+		switch resp := response.(type) {
+		case vmapi.GetCard200JSONResponse:
+			c, err := s.extend(ctx, []vmapi.Card{vmapi.Card(resp)})
+			if err != nil || c == nil {
+				return response, err
+			}
+			return getExtendedCardResponse(c[0]), nil
+		case vmapi.PostCard201JSONResponse:
+			c, err := s.extend(ctx, []vmapi.Card{vmapi.Card(resp)})
+			if err != nil || c == nil {
+				return response, err
+			}
+			return postExtendedCardResponse(c[0]), nil
+		case vmapi.PatchCard200JSONResponse:
+			c, err := s.extend(ctx, []vmapi.Card{vmapi.Card(resp)})
+			if err != nil || c == nil {
+				return response, err
+			}
+			return patchExtendedCardResponse(c[0]), nil
+		case vmapi.ListCards200JSONResponse:
+			c, err := s.extend(ctx, resp.Cards)
+			if err != nil || c == nil {
+				return response, err
+			}
+			page := extendedCardPage{
+				CardPage: vmapi.CardPage(resp),
+				Cards:    c,
+			}
+			return listExtendedCardResponse(page), nil
+		default:
+			return response, nil
+		}
+	}
+}
+
+// extend adds the series, season and episode details that vmapi.Card can't
+// express to each of cards.  It returns nil if there is nothing to add.
+func (s *CatalogService) extend(ctx context.Context, cards []vmapi.Card) ([]extendedCard, error) {
+	ids := make([]uint32, len(cards))
+	for i, c := range cards {
+		ids[i] = c.Id
+	}
+	details, err := getSeriesDetails(ctx, s.Db, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		return nil, nil
+	}
+	out := make([]extendedCard, len(cards))
+	for i, c := range cards {
+		out[i] = extendedCard{
+			Card: c,
+			Details: extendedCardDetails{
+				CardDetails:   c.Details,
+				seriesDetails: details[c.Id],
+			},
+		}
+	}
+	return out, nil
+}
+
+// The types below add series, seasons and episodes to the vmapi types.
+// Fields of the embedded types are shadowed by the fields of the same name
+// here.
+
+type extendedCardDetails struct {
+	vmapi.CardDetails
+	seriesDetails
+}
+
+type extendedCard struct {
+	vmapi.Card
+	Details extendedCardDetails `json:"details"`
+}
+
+type extendedCardPage struct {
+	vmapi.CardPage
+	Cards []extendedCard `json:"cards"`
+}
+
+type getExtendedCardResponse extendedCard
+
+func (response getExtendedCardResponse) VisitGetCardResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(extendedCard(response))
+}
+
+type postExtendedCardResponse extendedCard
+
+func (response postExtendedCardResponse) VisitPostCardResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+
+	return json.NewEncoder(w).Encode(extendedCard(response))
+}
+
+type patchExtendedCardResponse extendedCard
+
+func (response patchExtendedCardResponse) VisitPatchCardResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(extendedCard(response))
+}
+
+type listExtendedCardResponse extendedCardPage
+
+func (response listExtendedCardResponse) VisitListCardsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(extendedCardPage(response))
+}
@@ -0,0 +1,185 @@
+package catalog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/vmbody"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+)
+
+func TestStrictMiddleware(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc       exam.Loc
+		name      string
+		operation string
+		target    string
+		want      catalog.ListCardsOptions
+		wantErr   match.Matcher
+	}{
+		{
+			loc:       exam.Here(),
+			name:      "no options",
+			operation: "ListCards",
+			target:    "/cards",
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "all options",
+			operation: "ListCards",
+			target:    "/cards?min_release_year=1990&max_release_year=1999&sort=-release_year",
+			want: catalog.ListCardsOptions{
+				MinReleaseYear: Set(uint32(1990)),
+				MaxReleaseYear: Set(uint32(1999)),
+				Sort:           catalog.CardSortReleaseYearDesc,
+			},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "malformed min release year",
+			operation: "ListCards",
+			target:    "/cards?min_release_year=last",
+			wantErr:   vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "negative max release year",
+			operation: "ListCards",
+			target:    "/cards?max_release_year=-1",
+			wantErr:   vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "other operations are not affected",
+			operation: "GetCard",
+			target:    "/cards/1?min_release_year=last",
+			wantErr:   match.Nil(),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			var got catalog.ListCardsOptions
+			next := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+				// Round-trip through ListCards's view of the context.
+				got = catalog.ListCardsOptionsFromContext(ctx)
+				return nil, nil
+			}
+			f := (&catalog.CatalogService{}).StrictMiddleware(next, tt.operation)
+			_, err := f(context.Background(), httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.target, nil), nil)
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+}
+
+func TestStrictMiddleware_Details(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc       exam.Loc
+		name      string
+		method    string
+		operation string
+		body      string
+		wantPost  catalog.CardPostDetails
+		wantPatch []catalog.CardPatchDetails
+		wantErr   match.Matcher
+	}{
+		{
+			loc:       exam.Here(),
+			name:      "post series",
+			method:    http.MethodPost,
+			operation: "PostCard",
+			body:      `{"name": "Lost", "details": {"series": {"first_air_year": 2004}}}`,
+			wantPost:  catalog.CardPostDetails{Series: &catalog.Series{FirstAirYear: Set(uint32(2004))}},
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "post episode",
+			method:    http.MethodPost,
+			operation: "PostCard",
+			body:      `{"name": "Pilot", "details": {"episode": {"season_id": 2, "episode_number": 1}}}`,
+			wantPost:  catalog.CardPostDetails{Episode: &catalog.Episode{SeasonId: 2, EpisodeNumber: 1}},
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "post movie",
+			method:    http.MethodPost,
+			operation: "PostCard",
+			body:      `{"name": "Heat", "details": {"movie": {}}}`,
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "post malformed season",
+			method:    http.MethodPost,
+			operation: "PostCard",
+			body:      `{"name": "Lost", "details": {"season": {"season_number": -1}}}`,
+			wantErr:   vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "patch",
+			method:    http.MethodPatch,
+			operation: "PatchCard",
+			body:      `[{"note": "n"}, {"series": {"tmdb_id": 4607}}, {"season": {"season_number": 2}}, {"episode": {"episode_number": 3}}]`,
+			wantPatch: []catalog.CardPatchDetails{
+				{},
+				{Series: &catalog.SeriesPatch{TmdbId: Set(uint64(4607))}},
+				{Season: &catalog.SeasonPatch{SeasonNumber: Set(uint32(2))}},
+				{Episode: &catalog.EpisodePatch{EpisodeNumber: Set(uint32(3))}},
+			},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "other operations are not affected",
+			method:    http.MethodPost,
+			operation: "PostMovieEditionKind",
+			body:      `{"name": "Extended", "details": {"series": {}}}`,
+			wantErr:   match.Nil(),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			var gotPost catalog.CardPostDetails
+			var gotPatch []catalog.CardPatchDetails
+			next := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+				gotPost = catalog.CardPostDetailsFromContext(ctx)
+				gotPatch = catalog.CardPatchDetailsFromContext(ctx)
+				return nil, nil
+			}
+			var err error
+			// Like the strict handler, decode the body before the middleware runs.
+			handler := vmbody.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body any
+				if decodeErr := json.NewDecoder(r.Body).Decode(&body); decodeErr != nil {
+					e.Fatalf("could not decode body: %v", decodeErr)
+				}
+				f := (&catalog.CatalogService{}).StrictMiddleware(next, tt.operation)
+				_, err = f(r.Context(), w, r, nil)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/cards", strings.NewReader(tt.body)))
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, gotPost, tt.wantPost).Log(tt.loc)
+			exam.Equal(e, env, gotPatch, tt.wantPatch).Log(tt.loc)
+		})
+	}
+}
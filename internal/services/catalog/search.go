@@ -0,0 +1,120 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmpage"
+)
+
+// CardKind is the kind of a card, as used to filter SearchCards.
+type CardKind string
+
+const (
+	CardKindMovie        CardKind = "movie"
+	CardKindMovieEdition CardKind = "movie_edition"
+	CardKindSeries       CardKind = "series"
+	CardKindSeason       CardKind = "season"
+	CardKindEpisode      CardKind = "episode"
+)
+
+// cardKindTables holds the table of the cards of each CardKind.
+var cardKindTables = map[CardKind]string{
+	CardKindMovie:        "catalog_movies",
+	CardKindMovieEdition: "catalog_movie_editions",
+	CardKindSeries:       "catalog_series",
+	CardKindSeason:       "catalog_seasons",
+	CardKindEpisode:      "catalog_episodes",
+}
+
+// SearchCardsParams filters and pages the results of SearchCards.
+type SearchCardsParams struct {
+	// Query is matched against the names and notes of cards.  It may use the
+	// web search syntax of PostgreSQL, such as quoted phrases and -word.
+	Query     string
+	Kind      *CardKind
+	PageSize  *uint32
+	PageToken *string
+}
+
+// searchRankScale turns the floating point relevance of a card into the
+// integer sort key that vmpage pages by.
+const searchRankScale = 1000000
+
+// SearchCards returns the cards whose name or note match params.Query, most
+// relevant first.  Whole words are found with full-text search, and names and
+// notes that are close to the query with trigram similarity, so that typos
+// still match.
+func (s *CatalogService) SearchCards(ctx context.Context, params SearchCardsParams) (*vmapi.CardPage, error) {
+	q := strings.TrimSpace(params.Query)
+	if q == "" {
+		return nil, vmerr.BadRequest(errors.New("query must be non-empty"))
+	}
+	kindFilter := "TRUE"
+	if params.Kind != nil {
+		table, ok := cardKindTables[*params.Kind]
+		if !ok {
+			return nil, vmerr.BadRequest(fmt.Errorf("unknown card kind %q", *params.Kind))
+		}
+		kindFilter = fmt.Sprintf("EXISTS (SELECT 1 FROM %s k WHERE k.card_id = c.id)", table)
+	}
+
+	// The sort key is the negated relevance, so that ascending keys put the
+	// most relevant cards first.
+	sql := fmt.Sprintf(`
+		WITH ranked AS (
+			SELECT c.id AS card_id,
+				-((
+					ts_rank(c.search, websearch_to_tsquery('english', @query::text))
+					+ word_similarity(@query::text, c.name)
+					+ 0.5 * COALESCE(word_similarity(@query::text, c.note), 0)
+				) * %d)::bigint AS sort_key
+			FROM catalog_cards c
+			WHERE c.search @@ websearch_to_tsquery('english', @query::text)
+				OR c.name %% @query::text
+				OR @query::text <%% c.name
+				OR @query::text <%% c.note
+		)
+		SELECT %s, r.sort_key
+		FROM ranked r
+		JOIN catalog_cards c ON c.id = r.card_id
+		LEFT JOIN catalog_movies m ON m.card_id = c.id
+		LEFT JOIN catalog_movie_editions me ON me.card_id = c.id
+		WHERE (@lastSeenKey::bigint IS NULL OR (r.sort_key, c.id) > (@lastSeenKey::bigint, @lastSeenId))
+			AND %s
+		ORDER BY r.sort_key ASC, c.id ASC
+		LIMIT @limit;
+	`, searchRankScale, cardColumns, kindFilter)
+
+	query := &vmpage.ListQuery{
+		Sql:       sql,
+		Want:      params.PageSize,
+		Default:   50,
+		Max:       100,
+		PageToken: params.PageToken,
+		Params: map[string]any{
+			"query": q,
+		},
+	}
+	type row struct {
+		cardRow
+		SortKey int64
+	}
+	page := &vmapi.CardPage{
+		Cards: []vmapi.Card{},
+	}
+	// Tokens are tied to the query, since the order depends on it.
+	nextPageToken, err := vmpage.ListSortedPtr(ctx, s.Db, query, "search:"+q, func(r *row) (int64, uint32) {
+		page.Cards = append(page.Cards, r.toCard())
+		return r.SortKey, r.Id
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.NextPageToken = nextPageToken
+	return page, nil
+}
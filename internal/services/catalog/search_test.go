@@ -0,0 +1,159 @@
+package catalog_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+)
+
+func TestSearchCards(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewCatalogService(e, pg)
+
+	postMovie := func(name string, note *string) uint32 {
+		resp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{
+				Name:    name,
+				Note:    note,
+				Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{}},
+			},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		return resp.(vmapi.PostCard201JSONResponse).Id
+	}
+	matrixId := postMovie("The Matrix", nil)
+	postMovie("The Godfather", Set("An offer he can't refuse"))
+	postMovie("Heat", Set("Pacino again, years after The Godfather"))
+	kindResp, err := service.PostMovieEditionKind(ctx, vmapi.PostMovieEditionKindRequestObject{
+		Body: &vmapi.MovieEditionKindPost{Name: "Extended"},
+	})
+	exam.Nil(e, env, err).Log(err).Must()
+	_, err = service.PostCard(ctx, vmapi.PostCardRequestObject{
+		Body: &vmapi.CardPost{
+			Name: "The Matrix (Extended)",
+			Details: vmapi.CardPostDetails{
+				MovieEdition: &vmapi.MovieEdition{
+					KindId:  kindResp.(vmapi.PostMovieEditionKind201JSONResponse).Id,
+					MovieId: matrixId,
+				},
+			},
+		},
+	})
+	exam.Nil(e, env, err).Log(err).Must()
+
+	// searchAll follows page tokens until the last page, and returns the names
+	// of the cards found.
+	searchAll := func(params catalog.SearchCardsParams) ([]string, error) {
+		names := []string{}
+		for {
+			page, err := service.SearchCards(ctx, params)
+			if err != nil {
+				return nil, err
+			}
+			for _, card := range page.Cards {
+				names = append(names, card.Name)
+			}
+			if page.NextPageToken == nil {
+				return names, nil
+			}
+			params.PageToken = page.NextPageToken
+		}
+	}
+	kind := func(k catalog.CardKind) *catalog.CardKind { return &k }
+
+	tests := []struct {
+		loc     exam.Loc
+		name    string
+		params  catalog.SearchCardsParams
+		want    []string
+		wantErr match.Matcher
+	}{
+		{
+			loc:     exam.Here(),
+			name:    "typo in name",
+			params:  catalog.SearchCardsParams{Query: "matrx"},
+			want:    []string{"The Matrix", "The Matrix (Extended)"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "one card per page",
+			params:  catalog.SearchCardsParams{Query: "matrx", PageSize: Set(uint32(1))},
+			want:    []string{"The Matrix", "The Matrix (Extended)"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "name ranks above note",
+			params:  catalog.SearchCardsParams{Query: "godfather"},
+			want:    []string{"The Godfather", "Heat"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "word in note",
+			params:  catalog.SearchCardsParams{Query: "refused"},
+			want:    []string{"The Godfather"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "movies only",
+			params:  catalog.SearchCardsParams{Query: "matrix", Kind: kind(catalog.CardKindMovie)},
+			want:    []string{"The Matrix"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "movie editions only",
+			params:  catalog.SearchCardsParams{Query: "matrix", Kind: kind(catalog.CardKindMovieEdition)},
+			want:    []string{"The Matrix (Extended)"},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "no matches",
+			params:  catalog.SearchCardsParams{Query: "xyzzy"},
+			want:    []string{},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "empty query",
+			params:  catalog.SearchCardsParams{Query: "  "},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:     exam.Here(),
+			name:    "unknown kind",
+			params:  catalog.SearchCardsParams{Query: "matrix", Kind: kind("book")},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			got, err := searchAll(tt.params)
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+
+	e.Run("page token from another query", func(e exam.E) {
+		page, err := service.SearchCards(ctx, catalog.SearchCardsParams{Query: "matrix", PageSize: Set(uint32(1))})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Match(e, env, page.NextPageToken, match.Not(match.Nil())).Must()
+		_, err = service.SearchCards(ctx, catalog.SearchCardsParams{Query: "godfather", PageToken: page.NextPageToken})
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest)).Log(err)
+	})
+}
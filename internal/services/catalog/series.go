@@ -0,0 +1,260 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+)
+
+// Series is the details of a TV series card.
+type Series struct {
+	FirstAirYear *uint32 `json:"first_air_year,omitempty"`
+	TmdbId       *uint64 `json:"tmdb_id,omitempty"`
+}
+
+// Season is the details of a card for one season of a TV series.  Season 0
+// holds the specials of a series.
+type Season struct {
+	SeriesId     uint32 `json:"series_id"`
+	SeasonNumber uint32 `json:"season_number"`
+}
+
+// Episode is the details of a card for one episode of a season.  Like movies,
+// episodes can be linked to the media that hold them.
+type Episode struct {
+	SeasonId      uint32 `json:"season_id"`
+	EpisodeNumber uint32 `json:"episode_number"`
+}
+
+// SeriesPatch changes one field of a series card.
+type SeriesPatch struct {
+	FirstAirYear *uint32 `json:"first_air_year,omitempty"`
+	TmdbId       *uint64 `json:"tmdb_id,omitempty"`
+}
+
+// SeasonPatch changes the number of a season card.
+type SeasonPatch struct {
+	SeasonNumber *uint32 `json:"season_number,omitempty"`
+}
+
+// EpisodePatch changes the number of an episode card.
+type EpisodePatch struct {
+	EpisodeNumber *uint32 `json:"episode_number,omitempty"`
+}
+
+// seriesDetails holds the details of a series, season or episode card.  At
+// most one of its fields is set.
+type seriesDetails struct {
+	Series  *Series  `json:"series,omitempty"`
+	Season  *Season  `json:"season,omitempty"`
+	Episode *Episode `json:"episode,omitempty"`
+}
+
+// getSeriesDetails returns the details of the series, season and episode cards
+// among the given cards, by card ID.
+func getSeriesDetails(ctx context.Context, runner vmdb.Runner, cardIds []uint32) (map[uint32]seriesDetails, error) {
+	const sql = `
+		SELECT c.id,
+			s.card_id IS NOT NULL AS is_series, s.first_air_year, s.tmdb_id,
+			se.card_id IS NOT NULL AS is_season, se.series_card_id, se.season_number,
+			e.card_id IS NOT NULL AS is_episode, e.season_card_id, e.episode_number
+		FROM catalog_cards c
+		LEFT JOIN catalog_series s ON s.card_id = c.id
+		LEFT JOIN catalog_seasons se ON se.card_id = c.id
+		LEFT JOIN catalog_episodes e ON e.card_id = c.id
+		WHERE c.id = ANY($1)
+			AND (s.card_id IS NOT NULL OR se.card_id IS NOT NULL OR e.card_id IS NOT NULL)
+	`
+	type row struct {
+		Id            uint32
+		IsSeries      bool
+		FirstAirYear  *uint32
+		TmdbId        *uint64
+		IsSeason      bool
+		SeriesCardId  *uint32
+		SeasonNumber  *uint32
+		IsEpisode     bool
+		SeasonCardId  *uint32
+		EpisodeNumber *uint32
+	}
+	details := make(map[uint32]seriesDetails)
+	err := vmdb.Query(ctx, runner, vmdb.Positional(sql, cardIds), func(r row) bool {
+		switch {
+		case r.IsSeries:
+			details[r.Id] = seriesDetails{Series: &Series{FirstAirYear: r.FirstAirYear, TmdbId: r.TmdbId}}
+		case r.IsSeason:
+			details[r.Id] = seriesDetails{Season: &Season{SeriesId: *r.SeriesCardId, SeasonNumber: *r.SeasonNumber}}
+		case r.IsEpisode:
+			details[r.Id] = seriesDetails{Episode: &Episode{SeasonId: *r.SeasonCardId, EpisodeNumber: *r.EpisodeNumber}}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch series details: %w", err)
+	}
+	return details, nil
+}
+
+// insertSeriesDetails stores the series, season or episode details of a new
+// card, after checking that its parent exists and that its number is free.
+func insertSeriesDetails(ctx context.Context, runner vmdb.Runner, cardId uint32, details CardPostDetails) error {
+	switch {
+	case details.Series != nil:
+		series := details.Series
+		const insertSeriesQuery = "INSERT INTO catalog_series (card_id, first_air_year, tmdb_id) VALUES ($1, $2, $3)"
+		_, err := vmdb.Exec(ctx, runner, vmdb.Positional(insertSeriesQuery, cardId, series.FirstAirYear, series.TmdbId))
+		if err != nil {
+			return fmt.Errorf("failed to insert series details: %w", err)
+		}
+	case details.Season != nil:
+		season := details.Season
+		if err := checkSeries(ctx, runner, season.SeriesId); err != nil {
+			return err
+		}
+		if err := checkSeasonNumber(ctx, runner, season.SeriesId, season.SeasonNumber); err != nil {
+			return err
+		}
+		const insertSeasonQuery = "INSERT INTO catalog_seasons (card_id, series_card_id, season_number) VALUES ($1, $2, $3)"
+		_, err := vmdb.Exec(ctx, runner, vmdb.Positional(insertSeasonQuery, cardId, season.SeriesId, season.SeasonNumber))
+		if err != nil {
+			return fmt.Errorf("failed to insert season details: %w", err)
+		}
+	case details.Episode != nil:
+		episode := details.Episode
+		if err := checkSeason(ctx, runner, episode.SeasonId); err != nil {
+			return err
+		}
+		if err := checkEpisodeNumber(ctx, runner, episode.SeasonId, episode.EpisodeNumber); err != nil {
+			return err
+		}
+		const insertEpisodeQuery = "INSERT INTO catalog_episodes (card_id, season_card_id, episode_number) VALUES ($1, $2, $3)"
+		_, err := vmdb.Exec(ctx, runner, vmdb.Positional(insertEpisodeQuery, cardId, episode.SeasonId, episode.EpisodeNumber))
+		if err != nil {
+			return fmt.Errorf("failed to insert episode details: %w", err)
+		}
+	}
+	return nil
+}
+
+// patchSeriesDetails applies the series, season or episode patch in details
+// to the card with the given ID.
+func patchSeriesDetails(ctx context.Context, runner vmdb.Runner, id uint32, details CardPatchDetails) error {
+	all, err := getSeriesDetails(ctx, runner, []uint32{id})
+	if err != nil {
+		return err
+	}
+	current := all[id]
+	switch {
+	case details.Series != nil:
+		if current.Series == nil {
+			return vmerr.BadRequest(errors.New("cannot patch series fields on a non-series card"))
+		}
+		seriesPatch := details.Series
+		if (seriesPatch.FirstAirYear != nil) == (seriesPatch.TmdbId != nil) {
+			return vmerr.BadRequest(errors.New("exactly one field must be set in Series patch"))
+		}
+		if seriesPatch.FirstAirYear != nil {
+			const query = "UPDATE catalog_series SET first_air_year = $1 WHERE card_id = $2;"
+			if _, err := vmdb.Exec(ctx, runner, vmdb.Positional(query, *seriesPatch.FirstAirYear, id)); err != nil {
+				return fmt.Errorf("could not update first_air_year: %w", err)
+			}
+		}
+		if seriesPatch.TmdbId != nil {
+			const query = "UPDATE catalog_series SET tmdb_id = $1 WHERE card_id = $2;"
+			if _, err := vmdb.Exec(ctx, runner, vmdb.Positional(query, *seriesPatch.TmdbId, id)); err != nil {
+				return fmt.Errorf("could not update tmdb_id: %w", err)
+			}
+		}
+	case details.Season != nil:
+		if current.Season == nil {
+			return vmerr.BadRequest(errors.New("cannot patch season fields on a non-season card"))
+		}
+		if details.Season.SeasonNumber == nil {
+			return vmerr.BadRequest(errors.New("exactly one field must be set in Season patch"))
+		}
+		number := *details.Season.SeasonNumber
+		if number == current.Season.SeasonNumber {
+			return nil
+		}
+		if err := checkSeasonNumber(ctx, runner, current.Season.SeriesId, number); err != nil {
+			return err
+		}
+		const query = "UPDATE catalog_seasons SET season_number = $1 WHERE card_id = $2;"
+		if _, err := vmdb.Exec(ctx, runner, vmdb.Positional(query, number, id)); err != nil {
+			return fmt.Errorf("could not update season_number: %w", err)
+		}
+	case details.Episode != nil:
+		if current.Episode == nil {
+			return vmerr.BadRequest(errors.New("cannot patch episode fields on a non-episode card"))
+		}
+		if details.Episode.EpisodeNumber == nil {
+			return vmerr.BadRequest(errors.New("exactly one field must be set in Episode patch"))
+		}
+		number := *details.Episode.EpisodeNumber
+		if number == current.Episode.EpisodeNumber {
+			return nil
+		}
+		if err := checkEpisodeNumber(ctx, runner, current.Episode.SeasonId, number); err != nil {
+			return err
+		}
+		const query = "UPDATE catalog_episodes SET episode_number = $1 WHERE card_id = $2;"
+		if _, err := vmdb.Exec(ctx, runner, vmdb.Positional(query, number, id)); err != nil {
+			return fmt.Errorf("could not update episode_number: %w", err)
+		}
+	}
+	return nil
+}
+
+func checkSeries(ctx context.Context, runner vmdb.Runner, seriesId uint32) error {
+	const query = "SELECT COUNT(*) FROM catalog_series WHERE card_id = $1"
+	count, err := vmdb.QueryOne[int](ctx, runner, vmdb.Positional(query, seriesId))
+	if err != nil {
+		return fmt.Errorf("could not verify series existence: %w", err)
+	}
+	if count == 0 {
+		return vmerr.BadRequest(fmt.Errorf("series card with id %d not found", seriesId))
+	}
+	return nil
+}
+
+func checkSeason(ctx context.Context, runner vmdb.Runner, seasonId uint32) error {
+	const query = "SELECT COUNT(*) FROM catalog_seasons WHERE card_id = $1"
+	count, err := vmdb.QueryOne[int](ctx, runner, vmdb.Positional(query, seasonId))
+	if err != nil {
+		return fmt.Errorf("could not verify season existence: %w", err)
+	}
+	if count == 0 {
+		return vmerr.BadRequest(fmt.Errorf("season card with id %d not found", seasonId))
+	}
+	return nil
+}
+
+func checkSeasonNumber(ctx context.Context, runner vmdb.Runner, seriesId, number uint32) error {
+	const query = "SELECT COUNT(*) FROM catalog_seasons WHERE series_card_id = $1 AND season_number = $2"
+	count, err := vmdb.QueryOne[int](ctx, runner, vmdb.Positional(query, seriesId, number))
+	if err != nil {
+		return fmt.Errorf("could not check for existing season: %w", err)
+	}
+	if count > 0 {
+		return vmerr.AlreadyExists(fmt.Errorf("season %d of series card %d already exists", number, seriesId))
+	}
+	return nil
+}
+
+func checkEpisodeNumber(ctx context.Context, runner vmdb.Runner, seasonId, number uint32) error {
+	if number == 0 {
+		return vmerr.BadRequest(errors.New("episode number must be positive"))
+	}
+	const query = "SELECT COUNT(*) FROM catalog_episodes WHERE season_card_id = $1 AND episode_number = $2"
+	count, err := vmdb.QueryOne[int](ctx, runner, vmdb.Positional(query, seasonId, number))
+	if err != nil {
+		return fmt.Errorf("could not check for existing episode: %w", err)
+	}
+	if count > 0 {
+		return vmerr.AlreadyExists(fmt.Errorf("episode %d of season card %d already exists", number, seasonId))
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package catalog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+)
+
+func TestSeriesCards(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewCatalogService(e, pg)
+
+	// serve runs the operation through the middleware with the given
+	// context, and returns the decoded JSON response body.
+	serve := func(e exam.E, ctx context.Context, operation string, request any) (map[string]any, error) {
+		next := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+			switch request := request.(type) {
+			case vmapi.PostCardRequestObject:
+				return service.PostCard(ctx, request)
+			case vmapi.PatchCardRequestObject:
+				return service.PatchCard(ctx, request)
+			case vmapi.GetCardRequestObject:
+				return service.GetCard(ctx, request)
+			default:
+				return service.ListCards(ctx, request.(vmapi.ListCardsRequestObject))
+			}
+		}
+		rec := httptest.NewRecorder()
+		resp, err := service.StrictMiddleware(next, operation)(ctx, rec, httptest.NewRequest(http.MethodGet, "/", nil), request)
+		if err != nil {
+			return nil, err
+		}
+		switch resp := resp.(type) {
+		case vmapi.PostCardResponseObject:
+			err = resp.VisitPostCardResponse(rec)
+		case vmapi.PatchCardResponseObject:
+			err = resp.VisitPatchCardResponse(rec)
+		case vmapi.GetCardResponseObject:
+			err = resp.VisitGetCardResponse(rec)
+		case vmapi.ListCardsResponseObject:
+			err = resp.VisitListCardsResponse(rec)
+		}
+		exam.Nil(e, env, err).Log(err).Must()
+		var body map[string]any
+		err = json.Unmarshal(rec.Body.Bytes(), &body)
+		exam.Nil(e, env, err).Log(err).Must()
+		return body, nil
+	}
+	post := func(e exam.E, name string, details catalog.CardPostDetails) (map[string]any, error) {
+		postCtx := catalog.WithCardPostDetails(ctx, details)
+		return serve(e, postCtx, "PostCard", vmapi.PostCardRequestObject{Body: &vmapi.CardPost{Name: name}})
+	}
+	mustPost := func(e exam.E, name string, details catalog.CardPostDetails) uint32 {
+		body, err := post(e, name, details)
+		exam.Nil(e, env, err).Log(err).Must()
+		return uint32(body["id"].(float64))
+	}
+	details := func(body map[string]any) map[string]any {
+		return body["details"].(map[string]any)
+	}
+
+	e.Run("post and get", func(e exam.E) {
+		defer pg.Reset(e)
+		seriesBody, err := post(e, "Lost", catalog.CardPostDetails{
+			Series: &catalog.Series{FirstAirYear: Set(uint32(2004)), TmdbId: Set(uint64(4607))},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, details(seriesBody), map[string]any{
+			"series": any(map[string]any{"first_air_year": float64(2004), "tmdb_id": float64(4607)}),
+		})
+		seriesId := uint32(seriesBody["id"].(float64))
+
+		seasonBody, err := post(e, "Lost Season 1", catalog.CardPostDetails{
+			Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 1},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, details(seasonBody), map[string]any{
+			"season": any(map[string]any{"series_id": float64(seriesId), "season_number": float64(1)}),
+		})
+		seasonId := uint32(seasonBody["id"].(float64))
+
+		episodeId := mustPost(e, "Lost S01E01", catalog.CardPostDetails{
+			Episode: &catalog.Episode{SeasonId: seasonId, EpisodeNumber: 1},
+		})
+		body, err := serve(e, ctx, "GetCard", vmapi.GetCardRequestObject{Id: episodeId})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, details(body), map[string]any{
+			"episode": any(map[string]any{"season_id": float64(seasonId), "episode_number": float64(1)}),
+		})
+
+		// Movies are listed alongside, with their details untouched.
+		_, err = service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{Name: "Heat", Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{}}},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		body, err = serve(e, ctx, "ListCards", vmapi.ListCardsRequestObject{})
+		exam.Nil(e, env, err).Log(err).Must()
+		var kinds []string
+		for _, card := range body["cards"].([]any) {
+			for kind := range details(card.(map[string]any)) {
+				kinds = append(kinds, kind)
+			}
+		}
+		exam.Equal(e, env, kinds, []string{"series", "season", "episode", "movie"})
+	})
+
+	e.Run("post errors", func(e exam.E) {
+		defer pg.Reset(e)
+		seriesId := mustPost(e, "Lost", catalog.CardPostDetails{Series: &catalog.Series{}})
+		seasonId := mustPost(e, "Lost Season 1", catalog.CardPostDetails{
+			Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 1},
+		})
+		mustPost(e, "Lost S01E01", catalog.CardPostDetails{
+			Episode: &catalog.Episode{SeasonId: seasonId, EpisodeNumber: 1},
+		})
+
+		tests := []struct {
+			loc     exam.Loc
+			name    string
+			details catalog.CardPostDetails
+			wantErr match.Matcher
+		}{
+			{
+				loc:     exam.Here(),
+				name:    "season of a missing series",
+				details: catalog.CardPostDetails{Season: &catalog.Season{SeriesId: 9999, SeasonNumber: 2}},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+			{
+				loc:     exam.Here(),
+				name:    "season of a season",
+				details: catalog.CardPostDetails{Season: &catalog.Season{SeriesId: seasonId, SeasonNumber: 2}},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+			{
+				loc:     exam.Here(),
+				name:    "duplicate season",
+				details: catalog.CardPostDetails{Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 1}},
+				wantErr: vmtest.HttpError(vmerr.ProblemAlreadyExists),
+			},
+			{
+				loc:     exam.Here(),
+				name:    "episode of a series",
+				details: catalog.CardPostDetails{Episode: &catalog.Episode{SeasonId: seriesId, EpisodeNumber: 2}},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+			{
+				loc:     exam.Here(),
+				name:    "duplicate episode",
+				details: catalog.CardPostDetails{Episode: &catalog.Episode{SeasonId: seasonId, EpisodeNumber: 1}},
+				wantErr: vmtest.HttpError(vmerr.ProblemAlreadyExists),
+			},
+			{
+				loc:     exam.Here(),
+				name:    "episode zero",
+				details: catalog.CardPostDetails{Episode: &catalog.Episode{SeasonId: seasonId}},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+			{
+				loc:  exam.Here(),
+				name: "two kinds",
+				details: catalog.CardPostDetails{
+					Series: &catalog.Series{},
+					Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 2},
+				},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+		}
+		for _, tt := range tests {
+			e.Run(tt.name, func(e exam.E) {
+				_, err := post(e, "New Card", tt.details)
+				exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			})
+		}
+
+		e.Run("movie and series", func(e exam.E) {
+			postCtx := catalog.WithCardPostDetails(ctx, catalog.CardPostDetails{Series: &catalog.Series{}})
+			_, err := service.PostCard(postCtx, vmapi.PostCardRequestObject{
+				Body: &vmapi.CardPost{Name: "New Card", Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{}}},
+			})
+			exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest)).Log(err)
+		})
+	})
+
+	e.Run("seasons and episodes of different series share names", func(e exam.E) {
+		defer pg.Reset(e)
+		for _, series := range []string{"Lost", "Fringe"} {
+			seriesId := mustPost(e, series, catalog.CardPostDetails{Series: &catalog.Series{}})
+			seasonId := mustPost(e, "Season 1", catalog.CardPostDetails{
+				Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 1},
+			})
+			mustPost(e, "Pilot", catalog.CardPostDetails{
+				Episode: &catalog.Episode{SeasonId: seasonId, EpisodeNumber: 1},
+			})
+		}
+
+		// Other kinds of cards still need unique names.
+		_, err := post(e, "lost", catalog.CardPostDetails{Series: &catalog.Series{}})
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemAlreadyExists)).Log(err)
+	})
+
+	e.Run("patch", func(e exam.E) {
+		defer pg.Reset(e)
+		seriesId := mustPost(e, "Lost", catalog.CardPostDetails{Series: &catalog.Series{}})
+		seasonId := mustPost(e, "Lost Season 1", catalog.CardPostDetails{
+			Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 1},
+		})
+		mustPost(e, "Lost Season 2", catalog.CardPostDetails{
+			Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 2},
+		})
+		episodeId := mustPost(e, "Lost S01E01", catalog.CardPostDetails{
+			Episode: &catalog.Episode{SeasonId: seasonId, EpisodeNumber: 1},
+		})
+		movieResp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{Name: "Heat", Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{}}},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		movieId := movieResp.(vmapi.PostCard201JSONResponse).Id
+
+		tests := []struct {
+			loc         exam.Loc
+			name        string
+			id          uint32
+			patches     []catalog.CardPatchDetails
+			wantDetails map[string]any
+			wantErr     match.Matcher
+		}{
+			{
+				loc:  exam.Here(),
+				name: "series fields",
+				id:   seriesId,
+				patches: []catalog.CardPatchDetails{
+					{Series: &catalog.SeriesPatch{FirstAirYear: Set(uint32(2004))}},
+					{Series: &catalog.SeriesPatch{TmdbId: Set(uint64(4607))}},
+				},
+				wantDetails: map[string]any{
+					"series": any(map[string]any{"first_air_year": float64(2004), "tmdb_id": float64(4607)}),
+				},
+				wantErr: match.Nil(),
+			},
+			{
+				loc:  exam.Here(),
+				name: "episode number",
+				id:   episodeId,
+				patches: []catalog.CardPatchDetails{
+					{Episode: &catalog.EpisodePatch{EpisodeNumber: Set(uint32(3))}},
+				},
+				wantDetails: map[string]any{
+					"episode": any(map[string]any{"season_id": float64(seasonId), "episode_number": float64(3)}),
+				},
+				wantErr: match.Nil(),
+			},
+			{
+				loc:  exam.Here(),
+				name: "season number taken",
+				id:   seasonId,
+				patches: []catalog.CardPatchDetails{
+					{Season: &catalog.SeasonPatch{SeasonNumber: Set(uint32(2))}},
+				},
+				wantErr: vmtest.HttpError(vmerr.ProblemAlreadyExists),
+			},
+			{
+				loc:  exam.Here(),
+				name: "two series fields",
+				id:   seriesId,
+				patches: []catalog.CardPatchDetails{
+					{Series: &catalog.SeriesPatch{FirstAirYear: Set(uint32(2004)), TmdbId: Set(uint64(4607))}},
+				},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+			{
+				loc:  exam.Here(),
+				name: "season fields on a series",
+				id:   seriesId,
+				patches: []catalog.CardPatchDetails{
+					{Season: &catalog.SeasonPatch{SeasonNumber: Set(uint32(3))}},
+				},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+			{
+				loc:  exam.Here(),
+				name: "series fields on a movie",
+				id:   movieId,
+				patches: []catalog.CardPatchDetails{
+					{Series: &catalog.SeriesPatch{TmdbId: Set(uint64(1))}},
+				},
+				wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+			},
+		}
+		for _, tt := range tests {
+			e.Run(tt.name, func(e exam.E) {
+				// The vmapi part of each patch is empty, as in a request that only
+				// sets the fields that vmapi leaves out.
+				body := make(vmapi.PatchCardJSONRequestBody, len(tt.patches))
+				patchCtx := catalog.WithCardPatchDetails(ctx, tt.patches)
+				resp, err := serve(e, patchCtx, "PatchCard", vmapi.PatchCardRequestObject{Id: tt.id, Body: &body})
+				exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+				if tt.wantDetails != nil {
+					exam.Equal(e, env, details(resp), tt.wantDetails).Log(tt.loc)
+				}
+			})
+		}
+	})
+
+	e.Run("deleting a series deletes its seasons and episodes", func(e exam.E) {
+		defer pg.Reset(e)
+		seriesId := mustPost(e, "Lost", catalog.CardPostDetails{Series: &catalog.Series{}})
+		seasonId := mustPost(e, "Lost Season 1", catalog.CardPostDetails{
+			Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 1},
+		})
+		episodeId := mustPost(e, "Lost S01E01", catalog.CardPostDetails{
+			Episode: &catalog.Episode{SeasonId: seasonId, EpisodeNumber: 1},
+		})
+
+		_, err := service.DeleteCard(ctx, vmapi.DeleteCardRequestObject{Id: seriesId})
+		exam.Nil(e, env, err).Log(err).Must()
+		for _, id := range []uint32{seasonId, episodeId} {
+			_, err := service.GetCard(ctx, vmapi.GetCardRequestObject{Id: id})
+			exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemNotFound)).Log(err).Log(id)
+		}
+	})
+}
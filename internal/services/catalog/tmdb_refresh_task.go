@@ -0,0 +1,224 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtmdb"
+	"github.com/krelinga/video-manager/internal/services/artwork"
+)
+
+// TaskTypeTmdbRefresh is the task type for filling in the details of a movie
+// card from TMDb.  These tasks are created whenever the tmdb_id of a movie
+// card is set or changed.
+const TaskTypeTmdbRefresh = "tmdb_refresh"
+
+// TmdbRefreshState represents the state of a TMDb refresh task.
+type TmdbRefreshState struct {
+	CardId uint32 `json:"card_id"`
+	// TmdbId is the tmdb_id of the card when the task was created.
+	TmdbId uint64 `json:"tmdb_id"`
+}
+
+// TmdbRefreshHandler processes TMDb refresh tasks.  It fetches the title,
+// release year, overview, runtime, genres and artwork of a movie from TMDb,
+// and stores them with its card.  It then creates tasks that download the
+// poster and backdrop of the movie as the poster and fanart of the card.
+type TmdbRefreshHandler struct {
+	Client *vmtmdb.Client
+	// ImageBaseUrl is the prefix of the TMDb image paths to download.  If
+	// empty, vmtmdb.ImageBaseUrl is used.
+	ImageBaseUrl string
+}
+
+// Handle implements vmtask.Handler.
+func (h *TmdbRefreshHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, stateBytes []byte) vmtask.Result {
+	var state TmdbRefreshState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to unmarshal state: %v", err))
+	}
+
+	const selectSql = `SELECT tmdb_id FROM catalog_movies WHERE card_id = $1`
+	tmdbId, err := vmdb.QueryOne[*uint64](ctx, db, vmdb.Positional(selectSql, state.CardId))
+	if errors.Is(err, vmdb.ErrNotFound) {
+		// The card was deleted, so there is nothing to refresh.
+		return vmtask.Completed()
+	} else if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to query catalog_movies: %v", err))
+	}
+	if tmdbId == nil || *tmdbId != state.TmdbId {
+		// The tmdb_id changed since this task was created, and the task
+		// created by that change will do the refresh.
+		return vmtask.Completed()
+	}
+
+	movie, err := h.Client.GetMovie(ctx, state.TmdbId)
+	if errors.Is(err, vmtmdb.ErrNotFound) || errors.Is(err, vmtmdb.ErrNoApiKey) {
+		// Retrying won't help until the card or the config changes.
+		return vmtask.Failed(fmt.Sprintf("failed to fetch TMDb movie %d: %v", state.TmdbId, err))
+	} else if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to fetch TMDb movie %d: %v", state.TmdbId, err))
+	}
+
+	if err := storeTmdbMovie(ctx, db, state.CardId, movie); err != nil {
+		return vmtask.Retry(err.Error())
+	}
+	images := []struct {
+		kind artwork.Kind
+		path string
+	}{
+		{artwork.KindPoster, movie.PosterPath},
+		{artwork.KindFanart, movie.BackdropPath},
+	}
+	for _, image := range images {
+		if image.path == "" {
+			continue
+		}
+		if _, err := artwork.CreateDownloadTask(ctx, db, state.CardId, image.kind, h.imageUrl(image.path), image.path); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to create %s download task: %v", image.kind, err))
+		}
+	}
+	return vmtask.Completed()
+}
+
+// imageUrl returns the URL of the original size of the TMDb image at path.
+func (h *TmdbRefreshHandler) imageUrl(path string) string {
+	baseUrl := h.ImageBaseUrl
+	if baseUrl == "" {
+		baseUrl = vmtmdb.ImageBaseUrl
+	}
+	return baseUrl + "/original" + path
+}
+
+// storeTmdbMovie replaces the TMDb details of the given movie card with those
+// of movie.  A release year that the user gave is kept, while one that is
+// missing or came from TMDb is replaced.
+func storeTmdbMovie(ctx context.Context, db vmdb.Runner, cardId uint32, movie *vmtmdb.MovieDetails) error {
+	nonEmpty := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	var runtime *uint32
+	if movie.Runtime != 0 {
+		runtime = &movie.Runtime
+	}
+	const updateSql = `
+		UPDATE catalog_movies
+		SET title = @title,
+			release_year = CASE
+				WHEN release_year IS NULL OR release_year_from_tmdb THEN @releaseYear
+				ELSE release_year
+			END,
+			release_year_from_tmdb = release_year IS NULL OR release_year_from_tmdb,
+			overview = @overview,
+			runtime_minutes = @runtime,
+			poster_path = @posterPath,
+			backdrop_path = @backdropPath,
+			tmdb_refreshed_at = NOW()
+		WHERE card_id = @cardId
+	`
+	_, err := vmdb.Exec(ctx, db, vmdb.Named(updateSql, map[string]any{
+		"cardId":       cardId,
+		"title":        nonEmpty(movie.Title),
+		"releaseYear":  movie.ReleaseYear(),
+		"overview":     nonEmpty(movie.Overview),
+		"runtime":      runtime,
+		"posterPath":   nonEmpty(movie.PosterPath),
+		"backdropPath": nonEmpty(movie.BackdropPath),
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to update movie details: %w", err)
+	}
+
+	const deleteSql = `DELETE FROM catalog_movie_genres WHERE card_id = $1`
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(deleteSql, cardId)); err != nil {
+		return fmt.Errorf("failed to delete movie genres: %w", err)
+	}
+	const genreSql = `
+		INSERT INTO catalog_movie_genres (card_id, position, tmdb_genre_id, name)
+		VALUES ($1, $2, $3, $4)
+	`
+	for i, genre := range movie.Genres {
+		if _, err := vmdb.Exec(ctx, db, vmdb.Positional(genreSql, cardId, i, genre.Id, genre.Name)); err != nil {
+			return fmt.Errorf("failed to insert movie genre %q: %w", genre.Name, err)
+		}
+	}
+	return nil
+}
+
+// TmdbMovie is the details of a movie card that were fetched from TMDb.
+type TmdbMovie struct {
+	Title       *string
+	ReleaseYear *uint32
+	Overview    *string
+	// RuntimeMinutes is the length of the movie.
+	RuntimeMinutes *uint32
+	Genres         []string
+	// PosterPath and BackdropPath are TMDb image paths, to be resolved
+	// against vmtmdb.ImageBaseUrl.
+	PosterPath   *string
+	BackdropPath *string
+}
+
+// GetTmdbMovie returns the TMDb details stored for the given movie card.
+// Returns nil if the movie has not been refreshed from TMDb.
+func GetTmdbMovie(ctx context.Context, db vmdb.Runner, cardId uint32) (*TmdbMovie, error) {
+	const movieSql = `
+		SELECT title, release_year, overview, runtime_minutes, poster_path, backdrop_path
+		FROM catalog_movies
+		WHERE card_id = $1 AND tmdb_refreshed_at IS NOT NULL
+	`
+	type movieRow struct {
+		Title          *string
+		ReleaseYear    *uint32
+		Overview       *string
+		RuntimeMinutes *uint32
+		PosterPath     *string
+		BackdropPath   *string
+	}
+	r, err := vmdb.QueryOne[movieRow](ctx, db, vmdb.Positional(movieSql, cardId))
+	if errors.Is(err, vmdb.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query movie details: %w", err)
+	}
+	movie := TmdbMovie{
+		Title:          r.Title,
+		ReleaseYear:    r.ReleaseYear,
+		Overview:       r.Overview,
+		RuntimeMinutes: r.RuntimeMinutes,
+		PosterPath:     r.PosterPath,
+		BackdropPath:   r.BackdropPath,
+	}
+
+	const genreSql = `
+		SELECT name
+		FROM catalog_movie_genres
+		WHERE card_id = $1
+		ORDER BY position
+	`
+	err = vmdb.Query(ctx, db, vmdb.Positional(genreSql, cardId), func(name string) bool {
+		movie.Genres = append(movie.Genres, name)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query movie genres: %w", err)
+	}
+	return &movie, nil
+}
+
+// createTmdbRefreshTask creates a refresh task for the given movie card, which
+// has the given tmdb_id.
+func createTmdbRefreshTask(ctx context.Context, db vmdb.Runner, cardId uint32, tmdbId uint64) (int, error) {
+	stateBytes, err := json.Marshal(TmdbRefreshState{CardId: cardId, TmdbId: tmdbId})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return vmtask.Create(ctx, db, TaskTypeTmdbRefresh, stateBytes)
+}
@@ -0,0 +1,285 @@
+package catalog_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/lib/vmtmdb"
+	"github.com/krelinga/video-manager/internal/services/artwork"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+)
+
+func TestTmdbRefreshHandler(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	service := NewCatalogService(e, pg)
+	fake := vmtest.NewTmdb(e)
+
+	alien := &catalog.TmdbMovie{
+		Title:          Set("Alien"),
+		ReleaseYear:    Set(uint32(1979)),
+		Overview:       Set("During its return to the earth, commercial spaceship Nostromo intercepts a distress signal from a distant planet."),
+		RuntimeMinutes: Set(uint32(117)),
+		Genres:         []string{"Horror", "Science Fiction"},
+		PosterPath:     Set("/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg"),
+		BackdropPath:   Set("/AmR3JG1VQVxU8TfAvljUhfSFUOx.jpg"),
+	}
+
+	tests := []struct {
+		loc  exam.Loc
+		name string
+		// tmdbId is the tmdb_id of the card, and stateTmdbId the one the task
+		// was created for.
+		tmdbId      *uint64
+		stateTmdbId uint64
+		noApiKey    bool
+		wantStatus  vmtask.Status
+		want        *catalog.TmdbMovie
+		// wantDownloads lists the artwork download tasks that were created.
+		wantDownloads []artwork.DownloadState
+	}{
+		{
+			loc:         exam.Here(),
+			name:        "found",
+			tmdbId:      Set(uint64(348)),
+			stateTmdbId: 348,
+			wantStatus:  vmtask.StatusCompleted,
+			want:        alien,
+			wantDownloads: []artwork.DownloadState{
+				{Kind: artwork.KindPoster, Url: "http://images.test/original/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg", TmdbPath: "/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg"},
+				{Kind: artwork.KindFanart, Url: "http://images.test/original/AmR3JG1VQVxU8TfAvljUhfSFUOx.jpg", TmdbPath: "/AmR3JG1VQVxU8TfAvljUhfSFUOx.jpg"},
+			},
+		},
+		{
+			loc:         exam.Here(),
+			name:        "not found",
+			tmdbId:      Set(uint64(999999999)),
+			stateTmdbId: 999999999,
+			wantStatus:  vmtask.StatusFailed,
+		},
+		{
+			loc:         exam.Here(),
+			name:        "tmdb_id changed since",
+			tmdbId:      Set(uint64(679)),
+			stateTmdbId: 348,
+			wantStatus:  vmtask.StatusCompleted,
+		},
+		{
+			loc:         exam.Here(),
+			name:        "missing api key",
+			tmdbId:      Set(uint64(348)),
+			stateTmdbId: 348,
+			noApiKey:    true,
+			wantStatus:  vmtask.StatusFailed,
+		},
+	}
+	// downloads returns the states of the artwork download tasks, in the
+	// order they were created.
+	downloads := func(e exam.E) []artwork.DownloadState {
+		const sql = "SELECT state FROM tasks WHERE task_type = $1 ORDER BY id"
+		var states []artwork.DownloadState
+		err := vmdb.Query(ctx, db, vmdb.Positional(sql, artwork.TaskTypeArtworkDownload), func(stateBytes []byte) bool {
+			var state artwork.DownloadState
+			if err := json.Unmarshal(stateBytes, &state); err != nil {
+				e.Fatalf("could not unmarshal state: %v", err)
+			}
+			states = append(states, state)
+			return true
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		return states
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			resp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+				Body: &vmapi.CardPost{
+					Name:    "Alien",
+					Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{TmdbId: tt.tmdbId}},
+				},
+			})
+			exam.Nil(e, env, err).Log(err).Must()
+			cardId := resp.(vmapi.PostCard201JSONResponse).Id
+
+			cfg := fake.Config()
+			if tt.noApiKey {
+				cfg.ApiKey = ""
+			}
+			handler := &catalog.TmdbRefreshHandler{Client: vmtmdb.New(cfg), ImageBaseUrl: "http://images.test"}
+			state, err := json.Marshal(catalog.TmdbRefreshState{CardId: cardId, TmdbId: tt.stateTmdbId})
+			exam.Nil(e, env, err).Log(err).Must()
+			result := handler.Handle(ctx, db, 0, catalog.TaskTypeTmdbRefresh, state)
+			exam.Equal(e, env, result.NewStatus, tt.wantStatus).Log(result).Log(tt.loc)
+			if tt.wantStatus == vmtask.StatusFailed {
+				exam.Equal(e, env, result.Retryable, false).Log(result).Log(tt.loc)
+			}
+
+			got, err := catalog.GetTmdbMovie(ctx, db, cardId)
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+
+			for i := range tt.wantDownloads {
+				tt.wantDownloads[i].CardId = cardId
+			}
+			exam.Equal(e, env, downloads(e), tt.wantDownloads).Log(tt.loc)
+		})
+	}
+
+	e.Run("deleted card", func(e exam.E) {
+		defer pg.Reset(e)
+		handler := &catalog.TmdbRefreshHandler{Client: vmtmdb.New(fake.Config())}
+		state, err := json.Marshal(catalog.TmdbRefreshState{CardId: 1, TmdbId: 348})
+		exam.Nil(e, env, err).Log(err).Must()
+		result := handler.Handle(ctx, db, 0, catalog.TaskTypeTmdbRefresh, state)
+		exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result)
+	})
+
+	e.Run("given release year is kept", func(e exam.E) {
+		defer pg.Reset(e)
+		resp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{
+				Name:    "Alien",
+				Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{TmdbId: Set(uint64(348)), ReleaseYear: Set(uint32(1980))}},
+			},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		cardId := resp.(vmapi.PostCard201JSONResponse).Id
+
+		handler := &catalog.TmdbRefreshHandler{Client: vmtmdb.New(fake.Config())}
+		state, err := json.Marshal(catalog.TmdbRefreshState{CardId: cardId, TmdbId: 348})
+		exam.Nil(e, env, err).Log(err).Must()
+		result := handler.Handle(ctx, db, 0, catalog.TaskTypeTmdbRefresh, state)
+		exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Must()
+
+		got, err := catalog.GetTmdbMovie(ctx, db, cardId)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, got.ReleaseYear, Set(uint32(1980)))
+	})
+
+	e.Run("release year from an earlier refresh is replaced", func(e exam.E) {
+		defer pg.Reset(e)
+		resp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{
+				Name:    "Alien",
+				Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{TmdbId: Set(uint64(348))}},
+			},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		cardId := resp.(vmapi.PostCard201JSONResponse).Id
+		handler := &catalog.TmdbRefreshHandler{Client: vmtmdb.New(fake.Config())}
+		refresh := func(e exam.E) *uint32 {
+			state, err := json.Marshal(catalog.TmdbRefreshState{CardId: cardId, TmdbId: 348})
+			exam.Nil(e, env, err).Log(err).Must()
+			result := handler.Handle(ctx, db, 0, catalog.TaskTypeTmdbRefresh, state)
+			exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Must()
+			got, err := catalog.GetTmdbMovie(ctx, db, cardId)
+			exam.Nil(e, env, err).Log(err).Must()
+			return got.ReleaseYear
+		}
+
+		// As if the card was refreshed before its tmdb_id was corrected.
+		const sql = "UPDATE catalog_movies SET release_year = 1986, release_year_from_tmdb = TRUE WHERE card_id = $1"
+		_, err = vmdb.Exec(ctx, db, vmdb.Positional(sql, cardId))
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, refresh(e), Set(uint32(1979)))
+
+		// Once the user sets the year, it is theirs to keep.
+		_, err = service.PatchCard(ctx, vmapi.PatchCardRequestObject{
+			Id:   cardId,
+			Body: &vmapi.PatchCardJSONRequestBody{{Movie: &vmapi.MoviePatch{ReleaseYear: Set(uint32(1980))}}},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, refresh(e), Set(uint32(1980)))
+	})
+}
+
+func TestTmdbRefresh_Enqueue(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	service := NewCatalogService(e, pg)
+
+	// refreshes returns the states of the TMDb refresh tasks, in the order
+	// they were created.
+	refreshes := func(e exam.E) []catalog.TmdbRefreshState {
+		const sql = "SELECT state FROM tasks WHERE task_type = $1 ORDER BY id"
+		var states []catalog.TmdbRefreshState
+		err := vmdb.Query(ctx, db, vmdb.Positional(sql, catalog.TaskTypeTmdbRefresh), func(stateBytes []byte) bool {
+			var state catalog.TmdbRefreshState
+			if err := json.Unmarshal(stateBytes, &state); err != nil {
+				e.Fatalf("could not unmarshal state: %v", err)
+			}
+			states = append(states, state)
+			return true
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		return states
+	}
+	post := func(e exam.E, name string, tmdbId *uint64) uint32 {
+		resp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{
+				Name:    name,
+				Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{TmdbId: tmdbId}},
+			},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		return resp.(vmapi.PostCard201JSONResponse).Id
+	}
+
+	e.Run("post with tmdb_id", func(e exam.E) {
+		defer pg.Reset(e)
+		id := post(e, "Alien", Set(uint64(348)))
+		exam.Equal(e, env, refreshes(e), []catalog.TmdbRefreshState{{CardId: id, TmdbId: 348}})
+	})
+
+	e.Run("post without tmdb_id", func(e exam.E) {
+		defer pg.Reset(e)
+		post(e, "Alien", nil)
+		exam.Equal(e, env, refreshes(e), []catalog.TmdbRefreshState(nil))
+	})
+
+	e.Run("patch", func(e exam.E) {
+		defer pg.Reset(e)
+		id := post(e, "Alien", nil)
+		patch := func(moviePatch vmapi.MoviePatch) {
+			_, err := service.PatchCard(ctx, vmapi.PatchCardRequestObject{
+				Id:   id,
+				Body: &vmapi.PatchCardJSONRequestBody{{Movie: &moviePatch}},
+			})
+			exam.Nil(e, env, err).Log(err).Must()
+		}
+		patch(vmapi.MoviePatch{ReleaseYear: Set(uint32(1979))})
+		exam.Equal(e, env, refreshes(e), []catalog.TmdbRefreshState(nil))
+		patch(vmapi.MoviePatch{TmdbId: Set(uint64(348))})
+		patch(vmapi.MoviePatch{TmdbId: Set(uint64(679))})
+		exam.Equal(e, env, refreshes(e), []catalog.TmdbRefreshState{
+			{CardId: id, TmdbId: 348},
+			{CardId: id, TmdbId: 679},
+		})
+	})
+
+	e.Run("patch with the same tmdb_id", func(e exam.E) {
+		defer pg.Reset(e)
+		id := post(e, "Alien", Set(uint64(348)))
+		_, err := service.PatchCard(ctx, vmapi.PatchCardRequestObject{
+			Id:   id,
+			Body: &vmapi.PatchCardJSONRequestBody{{Movie: &vmapi.MoviePatch{TmdbId: Set(uint64(348))}}},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, refreshes(e), []catalog.TmdbRefreshState{{CardId: id, TmdbId: 348}})
+	})
+}
@@ -0,0 +1,218 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// TaskTypeLibraryExport is the task type for exporting the library to the
+// export directory.
+const TaskTypeLibraryExport = "library_export"
+
+// exportLockId is the Postgres advisory lock that is held while the library
+// is exported, so that two exports never write the same folders.
+const exportLockId = 0x766d6578 // "vmex"
+
+// ExportHandler processes library export tasks.  It writes a folder for each
+// movie card that is linked to ingested media, with an NFO file that
+// describes the movie, and links to the media and artwork of the movie.  Only
+// folders that changed since the last export are rewritten.
+//
+// The export directory belongs to the exporter: folders in it that clash with
+// the folder of a movie are replaced.
+type ExportHandler struct {
+	Paths  config.Paths
+	Config config.Export
+}
+
+// exportRow is a row of the library_exports table.
+type exportRow struct {
+	CardId      uint32
+	DirName     string
+	Fingerprint string
+}
+
+// Handle implements vmtask.Handler.
+func (h *ExportHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, stateBytes []byte) vmtask.Result {
+	if h.Config.Dir == "" {
+		return vmtask.Failed("the export directory is not configured")
+	}
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional("SELECT pg_advisory_xact_lock($1)", exportLockId)); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to lock export: %v", err))
+	}
+	if err := os.MkdirAll(h.Config.Dir, 0755); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to create export directory: %v", err))
+	}
+
+	movies, err := loadMovies(ctx, db, h.Paths)
+	if err != nil {
+		return vmtask.Retry(err.Error())
+	}
+	entries, err := planEntries(h.Paths, movies)
+	if err != nil {
+		return vmtask.Retry(err.Error())
+	}
+	wanted := make(map[uint32]*entry, len(entries))
+	for _, e := range entries {
+		wanted[e.CardId] = e
+	}
+
+	const selectSql = `SELECT card_id, dir_name, fingerprint FROM library_exports`
+	previous := make(map[uint32]exportRow)
+	err = vmdb.Query(ctx, db, vmdb.Constant(selectSql), func(r exportRow) bool {
+		previous[r.CardId] = r
+		return true
+	})
+	if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to query library_exports: %v", err))
+	}
+
+	// Remove old folders first, so that a folder that is renamed can take
+	// the name that another folder had before.
+	removed := 0
+	for cardId, row := range previous {
+		e, ok := wanted[cardId]
+		if ok && e.DirName == row.DirName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(h.Config.Dir, row.DirName)); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to remove %q: %v", row.DirName, err))
+		}
+		if ok {
+			continue
+		}
+		const deleteSql = `DELETE FROM library_exports WHERE card_id = $1`
+		if _, err := vmdb.Exec(ctx, db, vmdb.Positional(deleteSql, cardId)); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to delete library export of card %d: %v", cardId, err))
+		}
+		removed++
+	}
+
+	written := 0
+	for _, e := range entries {
+		fingerprint, err := e.fingerprint(h.Config.LinkMode)
+		if err != nil {
+			return vmtask.Failed(err.Error())
+		}
+		if row, ok := previous[e.CardId]; ok && row.DirName == e.DirName && row.Fingerprint == fingerprint {
+			// Unchanged, unless someone removed the folder since.
+			if _, err := os.Stat(filepath.Join(h.Config.Dir, e.DirName)); err == nil {
+				continue
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return vmtask.Retry(fmt.Sprintf("failed to check %q: %v", e.DirName, err))
+			}
+		}
+		if err := h.write(e); errors.Is(err, syscall.EXDEV) {
+			// Retrying won't move the export directory to the right filesystem.
+			return vmtask.Failed(fmt.Sprintf("failed to export %q: %v", e.DirName, err))
+		} else if err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to export %q: %v", e.DirName, err))
+		}
+		const upsertSql = `
+			INSERT INTO library_exports (card_id, dir_name, fingerprint, exported_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (card_id) DO UPDATE
+			SET dir_name = EXCLUDED.dir_name,
+				fingerprint = EXCLUDED.fingerprint,
+				exported_at = EXCLUDED.exported_at
+		`
+		if _, err := vmdb.Exec(ctx, db, vmdb.Positional(upsertSql, e.CardId, e.DirName, fingerprint)); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to store library export of card %d: %v", e.CardId, err))
+		}
+		written++
+	}
+
+	slog.InfoContext(ctx, "Exported library", "dir", h.Config.Dir, "written", written, "removed", removed, "unchanged", len(entries)-written)
+	return vmtask.Completed()
+}
+
+// write writes the folder of e to a staging folder, and then moves it into
+// place, replacing whatever was there.
+func (h *ExportHandler) write(e *entry) error {
+	// Players skip hidden folders, so they never see a partial folder.
+	stagingPath := filepath.Join(h.Config.Dir, "."+e.DirName+".partial")
+	if err := os.RemoveAll(stagingPath); err != nil {
+		return fmt.Errorf("failed to clear staging folder: %w", err)
+	}
+	if err := os.Mkdir(stagingPath, 0755); err != nil {
+		return fmt.Errorf("failed to create staging folder: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(stagingPath, NfoName), e.Nfo, 0644); err != nil {
+		return fmt.Errorf("failed to write NFO: %w", err)
+	}
+	for _, l := range e.Links {
+		path := filepath.Join(stagingPath, l.Name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create folder for %q: %w", l.Name, err)
+		}
+		if err := h.link(l.Target, path); err != nil {
+			return fmt.Errorf("failed to link %q: %w", l.Name, err)
+		}
+	}
+
+	path := filepath.Join(h.Config.Dir, e.DirName)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove old folder: %w", err)
+	}
+	if err := os.Rename(stagingPath, path); err != nil {
+		return fmt.Errorf("failed to rename staging folder: %w", err)
+	}
+	return nil
+}
+
+// link makes path point at target.  Symbolic links are relative, so that the
+// library still works when the root and export directories are mounted
+// somewhere else together.  Directories can't be hard linked, so with
+// LinkModeHardlink they are recreated and the files in them are hard linked.
+func (h *ExportHandler) link(target, path string) error {
+	if h.Config.LinkMode != config.LinkModeHardlink {
+		rel, err := filepath.Rel(filepath.Dir(path), target)
+		if err != nil {
+			rel = target
+		}
+		return os.Symlink(rel, path)
+	}
+	return filepath.WalkDir(target, func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(target, src)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(path, rel)
+		if d.IsDir() {
+			return os.Mkdir(dst, 0755)
+		}
+		return os.Link(src, dst)
+	})
+}
+
+// CreateExportTask creates a task that exports the library.  If an export is
+// already waiting to run, that task is returned instead, since it will see the
+// same changes.
+func CreateExportTask(ctx context.Context, db vmdb.Runner) (int, error) {
+	const selectSql = `
+		SELECT id FROM tasks
+		WHERE task_type = $1 AND status = 'pending'
+		ORDER BY id
+		LIMIT 1
+	`
+	id, err := vmdb.QueryOne[int](ctx, db, vmdb.Positional(selectSql, TaskTypeLibraryExport))
+	if err == nil {
+		return id, nil
+	} else if !errors.Is(err, vmdb.ErrNotFound) {
+		return 0, fmt.Errorf("failed to query export tasks: %w", err)
+	}
+	return vmtask.Create(ctx, db, TaskTypeLibraryExport, []byte("{}"))
+}
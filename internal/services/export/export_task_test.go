@@ -0,0 +1,193 @@
+package export_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+	"github.com/krelinga/video-manager/internal/services/export"
+)
+
+func Set[T any](in T) *T {
+	return &in
+}
+
+func TestExportHandler(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	service := &catalog.CatalogService{Db: db}
+
+	// setup returns the paths of a new root directory, and a handler that
+	// exports to a new directory.
+	setup := func(e exam.E, linkMode string) (config.Paths, *export.ExportHandler) {
+		paths := config.Paths{RootDir: e.TempDir()}
+		if err := paths.Bootstrap(); err != nil {
+			e.Fatalf("could not bootstrap paths: %v", err)
+		}
+		return paths, &export.ExportHandler{
+			Paths:  paths,
+			Config: config.Export{Dir: e.TempDir(), LinkMode: linkMode},
+		}
+	}
+	postMovie := func(e exam.E, name string, releaseYear *uint32) uint32 {
+		resp, err := service.PostCard(ctx, vmapi.PostCardRequestObject{
+			Body: &vmapi.CardPost{
+				Name:    name,
+				Details: vmapi.CardPostDetails{Movie: &vmapi.Movie{ReleaseYear: releaseYear}},
+			},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		return resp.(vmapi.PostCard201JSONResponse).Id
+	}
+	// addDvd creates a DVD at relPath with a VIDEO_TS folder, and links it to
+	// the given card.
+	addDvd := func(e exam.E, paths config.Paths, cardId uint32, relPath string) {
+		ifo := filepath.Join(paths.Absolute(relPath), "VIDEO_TS", "VIDEO_TS.IFO")
+		if err := os.MkdirAll(filepath.Dir(ifo), 0755); err != nil {
+			e.Fatalf("could not create directory: %v", err)
+		}
+		if err := os.WriteFile(ifo, []byte("ifo"), 0644); err != nil {
+			e.Fatalf("could not write file: %v", err)
+		}
+		mediaId, err := vmdb.QueryOne[uint32](ctx, db, vmdb.Constant("INSERT INTO media (note) VALUES (NULL) RETURNING id"))
+		exam.Nil(e, env, err).Log(err).Must()
+		_, err = vmdb.Exec(ctx, db, vmdb.Positional("INSERT INTO media_dvds (media_id, path) VALUES ($1, $2)", mediaId, relPath))
+		exam.Nil(e, env, err).Log(err).Must()
+		_, err = vmdb.Exec(ctx, db, vmdb.Positional("INSERT INTO media_x_cards (media_id, card_id) VALUES ($1, $2)", mediaId, cardId))
+		exam.Nil(e, env, err).Log(err).Must()
+	}
+	run := func(e exam.E, handler *export.ExportHandler) {
+		result := handler.Handle(ctx, db, 0, export.TaskTypeLibraryExport, []byte("{}"))
+		exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Must()
+	}
+	exportedAt := func(e exam.E, cardId uint32) time.Time {
+		const sql = "SELECT exported_at FROM library_exports WHERE card_id = $1"
+		t, err := vmdb.QueryOne[time.Time](ctx, db, vmdb.Positional(sql, cardId))
+		exam.Nil(e, env, err).Log(err).Must()
+		return t
+	}
+	readFile := func(e exam.E, path string) string {
+		data, err := os.ReadFile(path)
+		exam.Nil(e, env, err).Log(err).Must()
+		return string(data)
+	}
+
+	e.Run("symlinks", func(e exam.E) {
+		defer pg.Reset(e)
+		paths, handler := setup(e, config.LinkModeSymlink)
+		cardId := postMovie(e, "Alien", Set(uint32(1979)))
+		addDvd(e, paths, cardId, "media/dvd/1")
+		run(e, handler)
+
+		dir := filepath.Join(handler.Config.Dir, "Alien (1979)")
+		exam.Equal(e, env, readFile(e, filepath.Join(dir, "VIDEO_TS", "VIDEO_TS.IFO")), "ifo")
+		info, err := os.Lstat(filepath.Join(dir, "VIDEO_TS"))
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, info.Mode()&os.ModeSymlink != 0, true)
+		exam.Equal(e, env, vmtest.FileExists(e, filepath.Join(dir, export.NfoName)), true)
+	})
+
+	e.Run("hard links", func(e exam.E) {
+		defer pg.Reset(e)
+		paths, handler := setup(e, config.LinkModeHardlink)
+		cardId := postMovie(e, "Alien", Set(uint32(1979)))
+		addDvd(e, paths, cardId, "media/dvd/1")
+		run(e, handler)
+
+		exported, err := os.Stat(filepath.Join(handler.Config.Dir, "Alien (1979)", "VIDEO_TS", "VIDEO_TS.IFO"))
+		exam.Nil(e, env, err).Log(err).Must()
+		original, err := os.Stat(paths.Absolute("media/dvd/1/VIDEO_TS/VIDEO_TS.IFO"))
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, os.SameFile(exported, original), true)
+	})
+
+	e.Run("media in inbox", func(e exam.E) {
+		defer pg.Reset(e)
+		paths, handler := setup(e, config.LinkModeSymlink)
+		cardId := postMovie(e, "Alien", Set(uint32(1979)))
+		addDvd(e, paths, cardId, "inbox/dvd/Alien")
+		run(e, handler)
+
+		entries, err := os.ReadDir(handler.Config.Dir)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, len(entries), 0)
+	})
+
+	e.Run("incremental", func(e exam.E) {
+		defer pg.Reset(e)
+		paths, handler := setup(e, config.LinkModeSymlink)
+		alien := postMovie(e, "Alien", Set(uint32(1979)))
+		addDvd(e, paths, alien, "media/dvd/1")
+		heat := postMovie(e, "Heat", Set(uint32(1995)))
+		addDvd(e, paths, heat, "media/dvd/2")
+		run(e, handler)
+		alienAt, heatAt := exportedAt(e, alien), exportedAt(e, heat)
+
+		// Nothing changed, so nothing is rewritten.
+		run(e, handler)
+		exam.Equal(e, env, exportedAt(e, alien), alienAt)
+		exam.Equal(e, env, exportedAt(e, heat), heatAt)
+
+		// A renamed card moves its folder.
+		_, err := vmdb.Exec(ctx, db, vmdb.Positional("UPDATE catalog_cards SET name = $1 WHERE id = $2", "Aliens", alien))
+		exam.Nil(e, env, err).Log(err).Must()
+		run(e, handler)
+		exam.Equal(e, env, vmtest.FileExists(e, filepath.Join(handler.Config.Dir, "Alien (1979)")), false)
+		exam.Equal(e, env, vmtest.FileExists(e, filepath.Join(handler.Config.Dir, "Aliens (1979)", export.NfoName)), true)
+		exam.Equal(e, env, exportedAt(e, heat), heatAt)
+
+		// A removed folder is written again.
+		if err := os.RemoveAll(filepath.Join(handler.Config.Dir, "Heat (1995)")); err != nil {
+			e.Fatalf("could not remove folder: %v", err)
+		}
+		run(e, handler)
+		exam.Equal(e, env, vmtest.FileExists(e, filepath.Join(handler.Config.Dir, "Heat (1995)", export.NfoName)), true)
+
+		// A deleted card loses its folder.
+		_, err = vmdb.Exec(ctx, db, vmdb.Positional("DELETE FROM catalog_cards WHERE id = $1", heat))
+		exam.Nil(e, env, err).Log(err).Must()
+		run(e, handler)
+		exam.Equal(e, env, vmtest.FileExists(e, filepath.Join(handler.Config.Dir, "Heat (1995)")), false)
+		const countSql = "SELECT COUNT(*) FROM library_exports"
+		count, err := vmdb.QueryOne[int](ctx, db, vmdb.Constant(countSql))
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, count, 1)
+	})
+
+	e.Run("not configured", func(e exam.E) {
+		handler := &export.ExportHandler{}
+		result := handler.Handle(ctx, db, 0, export.TaskTypeLibraryExport, []byte("{}"))
+		exam.Equal(e, env, result.NewStatus, vmtask.StatusFailed).Log(result)
+		exam.Equal(e, env, result.Retryable, false).Log(result)
+	})
+}
+
+func TestCreateExportTask(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	first, err := export.CreateExportTask(ctx, db)
+	exam.Nil(e, env, err).Log(err).Must()
+	// The first export has not started yet, so it is reused.
+	second, err := export.CreateExportTask(ctx, db)
+	exam.Nil(e, env, err).Log(err).Must()
+	exam.Equal(e, env, second, first)
+}
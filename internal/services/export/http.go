@@ -0,0 +1,42 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+)
+
+// StartExportResponse is the body of a response from the start export
+// endpoint.
+type StartExportResponse struct {
+	// TaskId is the ID of the export task, which can be followed with the
+	// task endpoints.
+	TaskId uint32 `json:"task_id"`
+}
+
+// RegisterRoutes adds the export endpoints to mux under baseUrl:
+//
+//	POST {baseUrl}/library/export  start an export of the library to the export directory
+func (s *ExportService) RegisterRoutes(mux *http.ServeMux, baseUrl string) {
+	mux.HandleFunc("POST "+baseUrl+"/library/export", s.handleStartExport)
+}
+
+func (s *ExportService) handleStartExport(w http.ResponseWriter, r *http.Request) {
+	if s.Config.Dir == "" {
+		vmerr.Middleware(w, r, vmerr.BadRequest(errors.New("the export directory is not configured")))
+		return
+	}
+	taskId, err := CreateExportTask(r.Context(), s.Db)
+	if err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(StartExportResponse{TaskId: uint32(taskId)}); err != nil {
+		slog.ErrorContext(r.Context(), "export: failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,72 @@
+package export_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/export"
+)
+
+// These requests are all rejected before the database is touched.
+func TestRoutes_BadRequests(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	mux := http.NewServeMux()
+	(&export.ExportService{}).RegisterRoutes(mux, "/api/v1")
+
+	tests := []struct {
+		name   string
+		loc    exam.Loc
+		method string
+		target string
+		want   int
+	}{
+		{
+			name:   "not configured",
+			loc:    exam.Here(),
+			method: http.MethodPost,
+			target: "/api/v1/library/export",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "wrong method",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/library/export",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			exam.Equal(e, env, rec.Code, tt.want).Log(tt.loc).Log(rec.Body.String())
+		})
+	}
+}
+
+func TestRoutes_StartExport(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	mux := http.NewServeMux()
+	service := &export.ExportService{
+		Db:     pg.DbRunner(e),
+		Config: config.Export{Dir: e.TempDir(), LinkMode: config.LinkModeSymlink},
+	}
+	service.RegisterRoutes(mux, "/api/v1")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/library/export", nil))
+	exam.Equal(e, env, rec.Code, http.StatusAccepted).Log(rec.Body.String()).Must()
+	var resp export.StartExportResponse
+	exam.Nil(e, env, json.Unmarshal(rec.Body.Bytes(), &resp)).Must()
+	exam.Equal(e, env, resp.TaskId != 0, true)
+}
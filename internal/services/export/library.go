@@ -0,0 +1,323 @@
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/services/artwork"
+	"github.com/krelinga/video-manager/internal/services/catalog"
+	"github.com/krelinga/video-manager/internal/services/media"
+)
+
+// exportMovie is a movie card to export, along with everything that goes in
+// its folder.
+type exportMovie struct {
+	CardId         uint32
+	Name           string
+	ReleaseYear    *uint32
+	TmdbId         *uint64
+	Overview       *string
+	RuntimeMinutes *uint32
+	Genres         []string
+	// Media lists the ingested media of the movie, by ID.
+	Media   []exportMedia
+	Artwork []*artwork.Artwork
+}
+
+type exportMedia struct {
+	Id   uint32
+	Kind media.Kind
+	// Path is relative to config.Paths.RootDir.
+	Path string
+}
+
+// loadMovies returns the movie cards that are linked to ingested media, by
+// card ID.  Media that are still in the inbox are left out, since they are
+// about to move.
+func loadMovies(ctx context.Context, db vmdb.Runner, paths config.Paths) ([]*exportMovie, error) {
+	const mediaSql = `
+		SELECT x.card_id, x.media_id, 'dvd' AS kind, d.path
+		FROM media_x_cards x JOIN media_dvds d ON d.media_id = x.media_id
+		UNION ALL
+		SELECT x.card_id, x.media_id, 'bluray' AS kind, b.path
+		FROM media_x_cards x JOIN media_blurays b ON b.media_id = x.media_id
+		UNION ALL
+		SELECT x.card_id, x.media_id, 'file' AS kind, f.path
+		FROM media_x_cards x JOIN media_files f ON f.media_id = x.media_id
+		ORDER BY card_id, media_id
+	`
+	type mediaRow struct {
+		CardId  uint32
+		MediaId uint32
+		Kind    media.Kind
+		Path    string
+	}
+	mediaByCard := make(map[uint32][]exportMedia)
+	err := vmdb.Query(ctx, db, vmdb.Constant(mediaSql), func(r mediaRow) bool {
+		if isIngested(paths, r.Kind, r.Path) {
+			mediaByCard[r.CardId] = append(mediaByCard[r.CardId], exportMedia{Id: r.MediaId, Kind: r.Kind, Path: r.Path})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	cardIds := make([]uint32, 0, len(mediaByCard))
+	for id := range mediaByCard {
+		cardIds = append(cardIds, id)
+	}
+
+	const movieSql = `
+		SELECT c.id, c.name, m.release_year, m.tmdb_id
+		FROM catalog_cards c
+		JOIN catalog_movies m ON m.card_id = c.id
+		WHERE c.id = ANY($1)
+		ORDER BY c.id
+	`
+	type movieRow struct {
+		Id          uint32
+		Name        string
+		ReleaseYear *uint32
+		TmdbId      *uint64
+	}
+	var movies []*exportMovie
+	err = vmdb.Query(ctx, db, vmdb.Positional(movieSql, cardIds), func(r movieRow) bool {
+		movies = append(movies, &exportMovie{
+			CardId:      r.Id,
+			Name:        r.Name,
+			ReleaseYear: r.ReleaseYear,
+			TmdbId:      r.TmdbId,
+			Media:       mediaByCard[r.Id],
+		})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query movies: %w", err)
+	}
+
+	for _, movie := range movies {
+		tmdb, err := catalog.GetTmdbMovie(ctx, db, movie.CardId)
+		if err != nil {
+			return nil, err
+		}
+		if tmdb != nil {
+			movie.Overview = tmdb.Overview
+			movie.RuntimeMinutes = tmdb.RuntimeMinutes
+			movie.Genres = tmdb.Genres
+		}
+		for _, kind := range artwork.Kinds {
+			a, err := artwork.GetArtwork(ctx, db, movie.CardId, kind)
+			if err != nil {
+				return nil, err
+			}
+			if a != nil {
+				movie.Artwork = append(movie.Artwork, a)
+			}
+		}
+	}
+	return movies, nil
+}
+
+// isIngested reports whether the media of the given kind at path has been
+// moved to its media directory.
+func isIngested(paths config.Paths, kind media.Kind, path string) bool {
+	var root string
+	switch kind {
+	case media.KindDvd:
+		root = paths.MediaDvd(config.PathKindRelative)
+	case media.KindBluray:
+		root = paths.MediaBluray(config.PathKindRelative)
+	case media.KindFile:
+		root = paths.MediaFile(config.PathKindRelative)
+	default:
+		return false
+	}
+	return strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// entry is the folder of one movie in the export directory.
+type entry struct {
+	CardId  uint32 `json:"card_id"`
+	DirName string `json:"dir_name"`
+	Nfo     []byte `json:"nfo"`
+	// Links are sorted by Name.
+	Links []link `json:"links"`
+}
+
+// link is a file or directory in the folder of a movie that points at a file
+// or directory outside of it.
+type link struct {
+	// Name is relative to the folder, and may include a subdirectory.
+	Name string `json:"name"`
+	// Target is an absolute path.
+	Target string `json:"target"`
+	// Version changes when the target is replaced in place, so that the
+	// folder is rewritten.  Hard links would otherwise keep the old file.
+	Version string `json:"version,omitempty"`
+}
+
+// fingerprint returns a hash of everything that is written for e with the
+// given link mode.
+func (e *entry) fingerprint(linkMode string) (string, error) {
+	data, err := json.Marshal(struct {
+		Entry    *entry `json:"entry"`
+		LinkMode string `json:"link_mode"`
+	}{e, linkMode})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal entry: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// planEntries returns the folders to export for movies.
+func planEntries(paths config.Paths, movies []*exportMovie) ([]*entry, error) {
+	dirNames := uniqueDirNames(movies)
+	entries := make([]*entry, 0, len(movies))
+	for _, movie := range movies {
+		e := &entry{
+			CardId:  movie.CardId,
+			DirName: dirNames[movie.CardId],
+		}
+		var err error
+		if e.Nfo, err = marshalNfo(movie); err != nil {
+			return nil, err
+		}
+		if e.Links, err = planLinks(paths, movie, e.DirName); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// planLinks returns the links in the folder of movie, which is called
+// dirName.  A single disc is linked into the folder itself, where players
+// look for its VIDEO_TS or BDMV folder.  Several discs each get a "Disc N"
+// subdirectory.  Video files are named after the folder, with a " - partN"
+// suffix if there are several.
+func planLinks(paths config.Paths, movie *exportMovie, dirName string) ([]link, error) {
+	var discs, files []exportMedia
+	for _, m := range movie.Media {
+		if m.Kind == media.KindFile {
+			files = append(files, m)
+		} else {
+			discs = append(discs, m)
+		}
+	}
+
+	var links []link
+	for i, disc := range discs {
+		prefix := ""
+		if len(discs) > 1 {
+			prefix = fmt.Sprintf("Disc %d/", i+1)
+		}
+		target := paths.Absolute(disc.Path)
+		children, err := os.ReadDir(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list media %d: %w", disc.Id, err)
+		}
+		for _, child := range children {
+			links = append(links, link{
+				Name:   prefix + child.Name(),
+				Target: filepath.Join(target, child.Name()),
+			})
+		}
+	}
+	for i, file := range files {
+		name := dirName
+		if len(files) > 1 {
+			name += fmt.Sprintf(" - part%d", i+1)
+		}
+		links = append(links, link{
+			Name:   name + filepath.Ext(file.Path),
+			Target: paths.Absolute(file.Path),
+		})
+	}
+	for _, a := range movie.Artwork {
+		links = append(links, link{
+			Name:    string(a.Kind) + imageExt(a.ContentType),
+			Target:  a.Path(paths, config.PathKindAbsolute, artwork.SizeOriginal),
+			Version: a.Sha256,
+		})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Name < links[j].Name
+	})
+	return links, nil
+}
+
+// imageExt returns the file extension for an image with the given content
+// type.
+func imageExt(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".jpg"
+	}
+}
+
+// uniqueDirNames returns the folder name of each of movies, by card ID.  The
+// folder is named "Name (Year)", like players expect.  If several movies
+// would get the same folder, all but the one with the lowest card ID also get
+// their card ID in the name.
+func uniqueDirNames(movies []*exportMovie) map[uint32]string {
+	names := make(map[uint32]string, len(movies))
+	// Folder names are compared case-insensitively, since the library may be
+	// shared with a system that ignores case.
+	taken := make(map[string]bool, len(movies))
+	byId := make([]*exportMovie, len(movies))
+	copy(byId, movies)
+	sort.Slice(byId, func(i, j int) bool {
+		return byId[i].CardId < byId[j].CardId
+	})
+	for _, movie := range byId {
+		name := dirName(movie)
+		if taken[strings.ToLower(name)] {
+			name = fmt.Sprintf("%s [%d]", name, movie.CardId)
+		}
+		taken[strings.ToLower(name)] = true
+		names[movie.CardId] = name
+	}
+	return names
+}
+
+// dirName returns the folder name of movie, without making it unique.
+func dirName(movie *exportMovie) string {
+	name := sanitizeName(movie.Name)
+	if name == "" {
+		name = fmt.Sprintf("Card %d", movie.CardId)
+	}
+	if movie.ReleaseYear != nil {
+		name = fmt.Sprintf("%s (%d)", name, *movie.ReleaseYear)
+	}
+	return name
+}
+
+// sanitizeName replaces the characters of name that are not allowed in file
+// names on common filesystems, including those of network shares.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < ' ', strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+	// Leading dots hide files, and trailing dots and spaces are dropped by
+	// Windows.
+	return strings.Trim(name, ". ")
+}
@@ -0,0 +1,142 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/services/artwork"
+	"github.com/krelinga/video-manager/internal/services/media"
+)
+
+func TestUniqueDirNames(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	movies := []*exportMovie{
+		{CardId: 3, Name: "alien", ReleaseYear: set(uint32(1979))},
+		{CardId: 1, Name: "Alien", ReleaseYear: set(uint32(1979))},
+		{CardId: 2, Name: "Alien"},
+		{CardId: 4, Name: "AC/DC: Live?"},
+		{CardId: 5, Name: "..."},
+		{CardId: 6, Name: " Se7en. "},
+	}
+	want := map[uint32]string{
+		1: "Alien (1979)",
+		2: "Alien",
+		3: "alien (1979) [3]",
+		4: "AC_DC_ Live_",
+		5: "Card 5",
+		6: "Se7en",
+	}
+	exam.Equal(e, env, uniqueDirNames(movies), want)
+}
+
+func TestIsIngested(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	paths := config.Paths{RootDir: "/root"}
+
+	tests := []struct {
+		name string
+		loc  exam.Loc
+		kind media.Kind
+		path string
+		want bool
+	}{
+		{name: "ingested DVD", loc: exam.Here(), kind: media.KindDvd, path: "media/dvd/1", want: true},
+		{name: "DVD in inbox", loc: exam.Here(), kind: media.KindDvd, path: "inbox/dvd/Alien", want: false},
+		{name: "ingested Blu-ray", loc: exam.Here(), kind: media.KindBluray, path: "media/bluray/2", want: true},
+		{name: "ingested video file", loc: exam.Here(), kind: media.KindFile, path: "media/file/3/alien.mkv", want: true},
+		{name: "video file in inbox", loc: exam.Here(), kind: media.KindFile, path: "inbox/file/alien.mkv", want: false},
+		{name: "wrong kind", loc: exam.Here(), kind: media.KindBluray, path: "media/dvd/1", want: false},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			exam.Equal(e, env, isIngested(paths, tt.kind, tt.path), tt.want).Log(tt.loc)
+		})
+	}
+}
+
+func TestPlanLinks(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	paths := config.Paths{RootDir: t.TempDir()}
+	for _, dir := range []string{"media/dvd/1/VIDEO_TS", "media/dvd/2/VIDEO_TS", "media/bluray/3/BDMV"} {
+		if err := os.MkdirAll(paths.Absolute(dir), 0755); err != nil {
+			e.Fatalf("could not create directory: %v", err)
+		}
+	}
+	abs := func(rel string) string {
+		return filepath.Join(paths.RootDir, rel)
+	}
+	poster := &artwork.Artwork{CardId: 7, Kind: artwork.KindPoster, ContentType: "image/png", Sha256: "abc123"}
+
+	tests := []struct {
+		name  string
+		loc   exam.Loc
+		movie *exportMovie
+		want  []link
+	}{
+		{
+			name: "one disc",
+			loc:  exam.Here(),
+			movie: &exportMovie{
+				Media: []exportMedia{{Id: 3, Kind: media.KindBluray, Path: "media/bluray/3"}},
+			},
+			want: []link{
+				{Name: "BDMV", Target: abs("media/bluray/3/BDMV")},
+			},
+		},
+		{
+			name: "several discs",
+			loc:  exam.Here(),
+			movie: &exportMovie{
+				Media: []exportMedia{
+					{Id: 1, Kind: media.KindDvd, Path: "media/dvd/1"},
+					{Id: 2, Kind: media.KindDvd, Path: "media/dvd/2"},
+				},
+			},
+			want: []link{
+				{Name: "Disc 1/VIDEO_TS", Target: abs("media/dvd/1/VIDEO_TS")},
+				{Name: "Disc 2/VIDEO_TS", Target: abs("media/dvd/2/VIDEO_TS")},
+			},
+		},
+		{
+			name: "one video file with artwork",
+			loc:  exam.Here(),
+			movie: &exportMovie{
+				Media:   []exportMedia{{Id: 4, Kind: media.KindFile, Path: "media/file/4/alien.mkv"}},
+				Artwork: []*artwork.Artwork{poster},
+			},
+			want: []link{
+				{Name: "Alien (1979).mkv", Target: abs("media/file/4/alien.mkv")},
+				{Name: "poster.png", Target: abs("artwork/7/poster/original"), Version: "abc123"},
+			},
+		},
+		{
+			name: "several video files",
+			loc:  exam.Here(),
+			movie: &exportMovie{
+				Media: []exportMedia{
+					{Id: 4, Kind: media.KindFile, Path: "media/file/4/part1.mkv"},
+					{Id: 5, Kind: media.KindFile, Path: "media/file/5/part2.mp4"},
+				},
+			},
+			want: []link{
+				{Name: "Alien (1979) - part1.mkv", Target: abs("media/file/4/part1.mkv")},
+				{Name: "Alien (1979) - part2.mp4", Target: abs("media/file/5/part2.mp4")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			got, err := planLinks(paths, tt.movie, "Alien (1979)")
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+}
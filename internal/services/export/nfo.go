@@ -0,0 +1,59 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+)
+
+// NfoName is the name of the file that describes a movie in its folder.
+const NfoName = "movie.nfo"
+
+// movieNfo is the subset of the Kodi movie NFO format that we fill in.
+// Jellyfin reads the same format.
+type movieNfo struct {
+	XMLName   xml.Name   `xml:"movie"`
+	Title     string     `xml:"title"`
+	Year      uint32     `xml:"year,omitempty"`
+	Plot      string     `xml:"plot,omitempty"`
+	Runtime   uint32     `xml:"runtime,omitempty"`
+	Genres    []string   `xml:"genre"`
+	UniqueIds []uniqueId `xml:"uniqueid"`
+}
+
+type uniqueId struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	Id      string `xml:",chardata"`
+}
+
+// marshalNfo returns the NFO of movie.
+func marshalNfo(movie *exportMovie) ([]byte, error) {
+	nfo := movieNfo{
+		Title:  movie.Name,
+		Genres: movie.Genres,
+	}
+	if movie.ReleaseYear != nil {
+		nfo.Year = *movie.ReleaseYear
+	}
+	if movie.Overview != nil {
+		nfo.Plot = *movie.Overview
+	}
+	if movie.RuntimeMinutes != nil {
+		nfo.Runtime = *movie.RuntimeMinutes
+	}
+	if movie.TmdbId != nil {
+		nfo.UniqueIds = append(nfo.UniqueIds, uniqueId{
+			Type:    "tmdb",
+			Default: true,
+			Id:      strconv.FormatUint(*movie.TmdbId, 10),
+		})
+	}
+	body, err := xml.MarshalIndent(nfo, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal NFO: %w", err)
+	}
+	out := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	out = append(out, body...)
+	return append(out, '\n'), nil
+}
@@ -0,0 +1,67 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+)
+
+func set[T any](in T) *T {
+	return &in
+}
+
+func TestMarshalNfo(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		name  string
+		loc   exam.Loc
+		movie *exportMovie
+		want  string
+	}{
+		{
+			name: "refreshed from TMDb",
+			loc:  exam.Here(),
+			movie: &exportMovie{
+				Name:           "Alien",
+				ReleaseYear:    set(uint32(1979)),
+				TmdbId:         set(uint64(348)),
+				Overview:       set("In space, no one can hear you scream."),
+				RuntimeMinutes: set(uint32(117)),
+				Genres:         []string{"Horror", "Science Fiction"},
+			},
+			want: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<movie>
+  <title>Alien</title>
+  <year>1979</year>
+  <plot>In space, no one can hear you scream.</plot>
+  <runtime>117</runtime>
+  <genre>Horror</genre>
+  <genre>Science Fiction</genre>
+  <uniqueid type="tmdb" default="true">348</uniqueid>
+</movie>
+`,
+		},
+		{
+			name: "name only",
+			loc:  exam.Here(),
+			movie: &exportMovie{
+				Name: "Tom & Jerry",
+			},
+			want: `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<movie>
+  <title>Tom &amp; Jerry</title>
+</movie>
+`,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			got, err := marshalNfo(tt.movie)
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, string(got), tt.want).Log(tt.loc)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package export
+
+import (
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+)
+
+// ExportService starts exports of the library for media players.  Its
+// endpoints are not part of vmapi, so it serves them itself; see
+// RegisterRoutes.
+type ExportService struct {
+	Db     vmdb.DbRunner
+	Config config.Export
+}
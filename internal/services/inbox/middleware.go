@@ -0,0 +1,147 @@
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+)
+
+// InboxDvd describes one directory in the DVD inbox.
+type InboxDvd struct {
+	// Path is relative to the root directory, as PostMedia expects it.
+	Path string `json:"path"`
+	// SizeBytes and FileCount count the regular files under Path.
+	SizeBytes int64 `json:"size_bytes"`
+	FileCount int   `json:"file_count"`
+	// ModifiedAt is the latest modification time of anything under Path.
+	ModifiedAt time.Time `json:"modified_at"`
+	// IsDvd reports whether Path looks like a DVD, in which case Format says
+	// how it was ripped.
+	IsDvd  bool          `json:"is_dvd"`
+	Format vmdisc.Format `json:"format,omitempty"`
+	// MediaId is set if media already references Path.
+	MediaId *uint32 `json:"media_id,omitempty"`
+}
+
+// StrictMiddleware adds the details of each DVD returned by ListInboxDVDs, as
+// dvds.  See package vmbody for why this isn't in the handler.
+func (s *InboxService) StrictMiddleware(f vmapi.StrictHandlerFunc, operationID string) vmapi.StrictHandlerFunc {
+	if operationID != "ListInboxDVDs" {
+		return f
+	}
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		response, err := f(ctx, w, r, request)
+		if err != nil {
+			return response, err
+		}
+		resp, ok := response.(vmapi.ListInboxDVDs200JSONResponse)
+		if !ok {
+			return response, nil
+		}
+		dvds, err := s.describeInboxDvds(ctx, resp.Paths)
+		if err != nil {
+			return nil, err
+		}
+		page := inboxPageWithDvds{
+			InboxPage: vmapi.InboxPage(resp),
+			Dvds:      dvds,
+		}
+		return listInboxDvdsWithDetailsResponse(page), nil
+	}
+}
+
+// describeInboxDvds returns the details of the inbox directories at the given
+// absolute paths.  Directories that have disappeared since they were listed
+// are left out.
+func (s *InboxService) describeInboxDvds(ctx context.Context, absPaths []string) ([]InboxDvd, error) {
+	dvds := make([]InboxDvd, 0, len(absPaths))
+	for _, absPath := range absPaths {
+		dvd, err := s.describeInboxDvd(absPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("could not describe inbox DVD %s: %w", absPath, err)
+		}
+		dvds = append(dvds, dvd)
+	}
+
+	relPaths := make([]string, len(dvds))
+	for i, dvd := range dvds {
+		relPaths[i] = dvd.Path
+	}
+	const sql = "SELECT path, media_id FROM media_dvds WHERE path = ANY($1)"
+	type row struct {
+		Path    string
+		MediaId uint32
+	}
+	mediaIds := make(map[string]uint32)
+	err := vmdb.Query(ctx, s.Db, vmdb.Positional(sql, relPaths), func(r row) bool {
+		mediaIds[r.Path] = r.MediaId
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not look up media for inbox DVDs: %w", err)
+	}
+	for i := range dvds {
+		if id, ok := mediaIds[dvds[i].Path]; ok {
+			dvds[i].MediaId = &id
+		}
+	}
+	return dvds, nil
+}
+
+func (s *InboxService) describeInboxDvd(absPath string) (InboxDvd, error) {
+	dvd := InboxDvd{
+		Path: s.Paths.InboxDvdName(config.PathKindRelative, filepath.Base(absPath)),
+	}
+	err := filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(dvd.ModifiedAt) {
+			dvd.ModifiedAt = info.ModTime()
+		}
+		if d.Type().IsRegular() {
+			dvd.SizeBytes += info.Size()
+			dvd.FileCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return InboxDvd{}, err
+	}
+	if format, err := vmdisc.DetectDvd(absPath); err == nil {
+		dvd.IsDvd = true
+		dvd.Format = format
+	}
+	return dvd, nil
+}
+
+// inboxPageWithDvds extends vmapi.InboxPage with the details of each DVD.
+type inboxPageWithDvds struct {
+	vmapi.InboxPage
+	Dvds []InboxDvd `json:"dvds"`
+}
+
+type listInboxDvdsWithDetailsResponse inboxPageWithDvds
+
+func (response listInboxDvdsWithDetailsResponse) VisitListInboxDVDsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(inboxPageWithDvds(response))
+}
@@ -0,0 +1,133 @@
+package inbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/inbox"
+)
+
+func TestStrictMiddleware(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	paths := config.Paths{
+		RootDir: e.TempDir(),
+	}
+	if err := paths.Bootstrap(); err != nil {
+		e.Fatalf("Failed to bootstrap paths: %v", err)
+	}
+	service := &inbox.InboxService{
+		Paths: paths,
+		Db:    db,
+	}
+
+	files := map[string]string{
+		"imported/VIDEO_TS/VIDEO_TS.IFO": "ifo",
+		"imported/VIDEO_TS/VTS_01_1.VOB": "vob-data",
+		"iso/movie.iso":                  "iso-data",
+		"photos/cat.jpg":                 "meow",
+	}
+	for name, content := range files {
+		path := paths.InboxDvdName(config.PathKindAbsolute, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			e.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			e.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	// Give everything the same modification time, except for one file.
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := filepath.WalkDir(paths.InboxDvd(config.PathKindAbsolute), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, modTime, modTime)
+	})
+	exam.Nil(e, env, err).Log(err).Must()
+	laterModTime := modTime.Add(time.Hour)
+	err = os.Chtimes(paths.InboxDvdName(config.PathKindAbsolute, "iso/movie.iso"), laterModTime, laterModTime)
+	exam.Nil(e, env, err).Log(err).Must()
+
+	mediaId, err := vmdb.QueryOne[uint32](ctx, db, vmdb.Constant("INSERT INTO media (note) VALUES (NULL) RETURNING id"))
+	exam.Nil(e, env, err).Log(err).Must()
+	_, err = vmdb.Exec(ctx, db, vmdb.Positional("INSERT INTO media_dvds (media_id, path) VALUES ($1, $2)", mediaId, "inbox/dvd/imported"))
+	exam.Nil(e, env, err).Log(err).Must()
+
+	listInboxDvds := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		return service.ListInboxDVDs(ctx, request.(vmapi.ListInboxDVDsRequestObject))
+	}
+	rec := httptest.NewRecorder()
+	resp, err := service.StrictMiddleware(listInboxDvds, "ListInboxDVDs")(ctx, rec, httptest.NewRequest(http.MethodGet, "/", nil), vmapi.ListInboxDVDsRequestObject{})
+	exam.Nil(e, env, err).Log(err).Must()
+	err = resp.(vmapi.ListInboxDVDsResponseObject).VisitListInboxDVDsResponse(rec)
+	exam.Nil(e, env, err).Log(err).Must()
+
+	var body struct {
+		Paths []string         `json:"paths"`
+		Dvds  []inbox.InboxDvd `json:"dvds"`
+	}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	exam.Nil(e, env, err).Log(err).Must()
+	exam.Equal(e, env, len(body.Paths), 3).Log(body.Paths)
+	for i := range body.Dvds {
+		body.Dvds[i].ModifiedAt = body.Dvds[i].ModifiedAt.UTC()
+	}
+	want := []inbox.InboxDvd{
+		{
+			Path:       "inbox/dvd/imported",
+			SizeBytes:  int64(len("ifo") + len("vob-data")),
+			FileCount:  2,
+			ModifiedAt: modTime,
+			IsDvd:      true,
+			Format:     vmdisc.FormatVideoTs,
+			MediaId:    &mediaId,
+		},
+		{
+			Path:       "inbox/dvd/iso",
+			SizeBytes:  int64(len("iso-data")),
+			FileCount:  1,
+			ModifiedAt: laterModTime,
+			IsDvd:      true,
+			Format:     vmdisc.FormatIso,
+		},
+		{
+			Path:       "inbox/dvd/photos",
+			SizeBytes:  int64(len("meow")),
+			FileCount:  1,
+			ModifiedAt: modTime,
+		},
+	}
+	exam.Equal(e, env, body.Dvds, want).Log(rec.Body.String())
+
+	e.Run("other operations are not affected", func(e exam.E) {
+		called := false
+		next := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+			called = true
+			return "unchanged", nil
+		}
+		resp, err := service.StrictMiddleware(next, "ListMedia")(ctx, httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), nil)
+		exam.Nil(e, env, err).Log(err)
+		exam.Equal(e, env, resp, any("unchanged"))
+		exam.Equal(e, env, called, true)
+	})
+}
@@ -8,12 +8,14 @@ import (
 
 	"github.com/krelinga/video-manager-api/go/vmapi"
 	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
 	"github.com/krelinga/video-manager/internal/lib/vmerr"
 	"github.com/krelinga/video-manager/internal/lib/vmpage"
 )
 
 type InboxService struct {
 	Paths config.Paths
+	Db    vmdb.DbRunner
 }
 
 func (s *InboxService) ListInboxDVDs(ctx context.Context, request vmapi.ListInboxDVDsRequestObject) (vmapi.ListInboxDVDsResponseObject, error) {
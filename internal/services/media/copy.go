@@ -0,0 +1,226 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// CopyFile is one entry of the media that is copied across filesystems.
+type CopyFile struct {
+	// Path is relative to the media directory, or empty if the media is a
+	// single video file.
+	Path  string `json:"path"`
+	Size  int64  `json:"size,omitempty"`
+	IsDir bool   `json:"is_dir,omitempty"`
+}
+
+// CopyProgress records how far a cross-filesystem copy of media has got.
+type CopyProgress struct {
+	Files []CopyFile `json:"files"`
+	// CopiedFiles is the number of entries of Files that have been copied.
+	CopiedFiles int `json:"copied_files"`
+	// CopiedOffset is the number of bytes copied of the entry after those.
+	CopiedOffset int64 `json:"copied_offset"`
+	// VerifiedFiles is the number of entries of Files that have been verified.
+	VerifiedFiles int `json:"verified_files"`
+	// VerifiedOffset is the number of bytes verified of the entry after those.
+	VerifiedOffset int64 `json:"verified_offset"`
+	CopiedBytes    int64 `json:"copied_bytes"`
+	VerifiedBytes  int64 `json:"verified_bytes"`
+	TotalBytes     int64 `json:"total_bytes"`
+}
+
+// isFile reports whether the media being copied is a single video file.
+func (p *CopyProgress) isFile() bool {
+	return len(p.Files) == 1 && p.Files[0].Path == ""
+}
+
+// listFiles returns the entries under dir in lexical order.  If dir is a
+// regular file, the only entry is dir itself, with an empty path.
+func listFiles(dir string) (*CopyProgress, error) {
+	progress := &CopyProgress{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir && d.IsDir() {
+			return nil
+		}
+		rel := ""
+		if path != dir {
+			rel, err = filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+		}
+		switch {
+		case d.IsDir():
+			progress.Files = append(progress.Files, CopyFile{Path: rel, IsDir: true})
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			progress.Files = append(progress.Files, CopyFile{Path: rel, Size: info.Size()})
+			progress.TotalBytes += info.Size()
+		default:
+			return fmt.Errorf("%q is not a regular file or directory", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// copyFileRange copies up to limit bytes of src into dst, starting at offset.
+// Anything in dst past offset is discarded first, since it may have been
+// written by an attempt that crashed before recording its progress.  The copied
+// bytes are synced to disk before returning.  onWrite is called with the
+// number of bytes copied so far after every write.
+func copyFileRange(ctx context.Context, src, dst string, offset, limit int64, onWrite func(int64)) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	if _, err := in.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	if err := out.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(&countingWriter{w: out, onWrite: onWrite}, io.LimitReader(ctxReader{ctx: ctx, r: in}, limit))
+	if err != nil {
+		return 0, err
+	}
+	if err := out.Sync(); err != nil {
+		return 0, err
+	}
+	return n, out.Close()
+}
+
+// compareBufferBytes is how much of each file compareFileRange reads at a time.
+const compareBufferBytes = 1 << 20
+
+// compareFileRange checks that src and dst both have the given size, and that
+// up to limit bytes of them match, starting at offset.  It returns the number of
+// bytes compared.
+func compareFileRange(ctx context.Context, src, dst string, size, offset, limit int64) (int64, error) {
+	var readers [2]io.Reader
+	for i, p := range []string{src, dst} {
+		f, err := os.Open(p)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		if info.Size() != size {
+			return 0, fmt.Errorf("%q is %d bytes, want %d", p, info.Size(), size)
+		}
+		readers[i] = io.NewSectionReader(f, offset, min(limit, size-offset))
+	}
+
+	srcBuf := make([]byte, compareBufferBytes)
+	dstBuf := make([]byte, compareBufferBytes)
+	var compared int64
+	for {
+		n, err := io.ReadFull(ctxReader{ctx: ctx, r: readers[0]}, srcBuf)
+		if errors.Is(err, io.EOF) {
+			return compared, nil
+		} else if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return compared, err
+		}
+		if _, err := io.ReadFull(ctxReader{ctx: ctx, r: readers[1]}, dstBuf[:n]); err != nil {
+			return compared, err
+		}
+		if !bytes.Equal(srcBuf[:n], dstBuf[:n]) {
+			return compared, fmt.Errorf("%q differs from %q after byte %d", dst, src, offset+compared)
+		}
+		compared += int64(n)
+	}
+}
+
+// countingWriter calls onWrite with the total number of bytes written.
+type countingWriter struct {
+	w       io.Writer
+	n       int64
+	onWrite func(int64)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.onWrite(c.n)
+	return n, err
+}
+
+// ctxReader stops reading once ctx is cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// progressInterval bounds how often a handler writes progress to the database.
+const progressInterval = time.Second
+
+// progressReporter reports the progress of one phase of a task, at most once
+// per progressInterval.
+type progressReporter struct {
+	ctx   context.Context
+	phase IngestionStep
+	total int64
+	last  time.Time
+}
+
+func newProgressReporter(ctx context.Context, phase IngestionStep, total int64) *progressReporter {
+	return &progressReporter{ctx: ctx, phase: phase, total: total}
+}
+
+// report records done, unless progress was reported recently and force is false.
+func (r *progressReporter) report(done int64, force bool) {
+	if !force && time.Since(r.last) < progressInterval {
+		return
+	}
+	r.last = time.Now()
+	progress := vmtask.Progress{
+		Phase: string(r.phase),
+		Done:  done,
+		Total: r.total,
+	}
+	if err := vmtask.ReportProgress(r.ctx, progress); err != nil {
+		// Progress is informational, so keep going.
+		slog.WarnContext(r.ctx, "Failed to report progress", "error", err)
+	}
+}
@@ -0,0 +1,179 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// The task types for cleaning up the files of each Kind of deleted media.
+const (
+	TaskTypeDvdDeletion    = "dvd_deletion"
+	TaskTypeBlurayDeletion = "bluray_deletion"
+	TaskTypeFileDeletion   = "file_deletion"
+)
+
+// DeletionState represents the state of a deletion task.
+type DeletionState struct {
+	MediaId uint32 `json:"media_id"`
+	// Path is the relative path of the media when it was deleted.
+	Path string `json:"path"`
+	// RestorePath is the relative path in the inbox to move the media back
+	// to.  If empty, the media is removed instead.
+	RestorePath string `json:"restore_path,omitempty"`
+}
+
+// DeletionHandler processes the deletion tasks of one Kind of media.
+// It removes the files of deleted media, or moves them back to the inbox.
+// Media that was never ingested is still in the inbox, and is left there.
+type DeletionHandler struct {
+	Kind  Kind
+	Paths config.Paths
+}
+
+// Handle implements vmtask.Handler.
+func (h *DeletionHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, stateBytes []byte) vmtask.Result {
+	var state DeletionState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to unmarshal state: %v", err))
+	}
+
+	// A copy across filesystems may have been interrupted part way through.
+	if err := os.RemoveAll(h.Kind.stagingPath(h.Paths, config.PathKindAbsolute, state.MediaId)); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to remove staging path: %v", err))
+	}
+
+	// If the media was deleted while its ingestion was interrupted, it may
+	// have been moved without the details table being updated.
+	relPath := ""
+	for _, candidate := range []string{state.Path, h.Kind.mediaPath(h.Paths, config.PathKindRelative, state.MediaId, state.Path)} {
+		ok, err := exists(h.Paths.Absolute(candidate))
+		if err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to check %s path: %v", h.Kind.label(), err))
+		}
+		if ok {
+			relPath = candidate
+			break
+		}
+	}
+	if relPath == "" {
+		// Already cleaned up, e.g. by an earlier attempt of this task.
+		return h.removeMediaDir(state)
+	}
+	mediaDir := h.Kind.mediaDir(h.Paths, config.PathKindRelative, state.MediaId)
+	if relPath != mediaDir && !isWithin(mediaDir, relPath) {
+		// The media was never ingested, so it is still the user's original in
+		// the inbox.  Leave it there.
+		return vmtask.Completed()
+	}
+
+	path := h.Paths.Absolute(relPath)
+	if state.RestorePath == "" {
+		if err := os.RemoveAll(path); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to remove %s path: %v", h.Kind.label(), err))
+		}
+		return h.removeMediaDir(state)
+	}
+
+	restorePath := h.Paths.Absolute(state.RestorePath)
+	if ok, err := exists(restorePath); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to check restore path: %v", err))
+	} else if ok {
+		// Retrying won't make the existing entry go away.
+		return vmtask.Failed(fmt.Sprintf("cannot restore %s to %q: path already exists", h.Kind.label(), state.RestorePath))
+	}
+	if err := os.Rename(path, restorePath); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to restore %s path: %v", h.Kind.label(), err))
+	}
+	return h.removeMediaDir(state)
+}
+
+// removeMediaDir removes the directory that held a video file once the file
+// itself is gone.  For other kinds the directory is the media itself, which is
+// already gone.
+func (h *DeletionHandler) removeMediaDir(state DeletionState) vmtask.Result {
+	if h.Kind != KindFile {
+		return vmtask.Completed()
+	}
+	if err := os.RemoveAll(h.Kind.mediaDir(h.Paths, config.PathKindAbsolute, state.MediaId)); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to remove media directory: %v", err))
+	}
+	return vmtask.Completed()
+}
+
+// CreateDeletionTask creates a new deletion task for media of the given kind.
+// This should be called within a transaction to ensure atomicity with media deletion.
+func CreateDeletionTask(ctx context.Context, db vmdb.Runner, kind Kind, state DeletionState) (int, error) {
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	return vmtask.Create(ctx, db, kind.DeletionTaskType(), stateBytes)
+}
+
+// planDeletion removes the ingestion tasks of the media with the given ID, and
+// returns the state of the task that cleans up its files.  It fails if the
+// media is being ingested right now, since its files are on the move.
+func planDeletion(ctx context.Context, tx vmdb.Runner, kind Kind, mediaId uint32, path string, opts DeleteMediaOptions) (*DeletionState, error) {
+	type ingestion struct {
+		Status vmtask.Status
+		State  []byte
+	}
+	// Lock the ingestion tasks so that no worker can claim them until we are done.
+	const selectSql = `
+		SELECT status, state FROM tasks
+		WHERE task_type = $1 AND (state->>'media_id')::integer = $2
+		ORDER BY id DESC
+		FOR UPDATE
+	`
+	var ingestions []ingestion
+	err := vmdb.Query(ctx, tx, vmdb.Positional(selectSql, kind.IngestionTaskType(), mediaId), func(i ingestion) bool {
+		ingestions = append(ingestions, i)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch %s ingestion tasks: %w", kind.label(), err)
+	}
+
+	var inboxPath string
+	for _, i := range ingestions {
+		if i.Status == vmtask.StatusRunning {
+			return nil, vmerr.BadRequest(fmt.Errorf("media with id %d is being ingested, try again later", mediaId))
+		}
+		var state IngestionState
+		if err := json.Unmarshal(i.State, &state); err == nil && inboxPath == "" {
+			inboxPath = state.InboxPath
+		}
+	}
+
+	const deleteSql = `DELETE FROM tasks WHERE task_type = $1 AND (state->>'media_id')::integer = $2`
+	if _, err := vmdb.Exec(ctx, tx, vmdb.Positional(deleteSql, kind.IngestionTaskType(), mediaId)); err != nil {
+		return nil, fmt.Errorf("could not delete %s ingestion tasks: %w", kind.label(), err)
+	}
+
+	state := &DeletionState{
+		MediaId: mediaId,
+		Path:    path,
+	}
+	if opts.RestoreToInbox {
+		if inboxPath == "" {
+			// Ingested before the inbox path was recorded, so pick a new name.
+			// Video files keep their extension, so they are still recognised.
+			name := fmt.Sprintf("media-%d", mediaId)
+			if kind == KindFile {
+				name += filepath.Ext(path)
+			}
+			inboxPath = kind.inboxName(config.Paths{}, config.PathKindRelative, name)
+		}
+		state.RestorePath = inboxPath
+	}
+	return state, nil
+}
@@ -0,0 +1,322 @@
+package media_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/media"
+)
+
+func TestDvdDeletionHandler(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	mkdir := func(e exam.E, paths config.Paths, rel string) {
+		if err := os.MkdirAll(paths.Absolute(rel), 0755); err != nil {
+			e.Fatalf("could not create directory: %v", err)
+		}
+	}
+
+	tests := []struct {
+		loc        exam.Loc
+		name       string
+		state      media.DeletionState
+		setup      func(e exam.E, paths config.Paths)
+		wantStatus vmtask.Status
+		check      func(e exam.E, paths config.Paths)
+	}{
+		{
+			loc:  exam.Here(),
+			name: "remove ingested DVD",
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "media/dvd/1",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				mkdir(e, paths, "media/dvd/1/VIDEO_TS")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/dvd/1")), false)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "restore ingested DVD to inbox",
+			state: media.DeletionState{
+				MediaId:     1,
+				Path:        "media/dvd/1",
+				RestorePath: "inbox/dvd/movie",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				mkdir(e, paths, "media/dvd/1/VIDEO_TS")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/dvd/1")), false)
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("inbox/dvd/movie/VIDEO_TS")), true)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "restore DVD that was never ingested",
+			state: media.DeletionState{
+				MediaId:     1,
+				Path:        "inbox/dvd/movie",
+				RestorePath: "inbox/dvd/movie",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				mkdir(e, paths, "inbox/dvd/movie")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("inbox/dvd/movie")), true)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "DVD moved by interrupted ingestion",
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "inbox/dvd/movie",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				mkdir(e, paths, "media/dvd/1")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/dvd/1")), false)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "interrupted copy",
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "inbox/dvd/movie",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				mkdir(e, paths, "inbox/dvd/movie")
+				mkdir(e, paths, "media/dvd/1.partial")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				// The original in the inbox is the user's, and is left alone.
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("inbox/dvd/movie")), true)
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/dvd/1.partial")), false)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "DVD that was never ingested",
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "inbox/dvd/movie",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				mkdir(e, paths, "inbox/dvd/movie/VIDEO_TS")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("inbox/dvd/movie/VIDEO_TS")), true)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "already removed",
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "media/dvd/1",
+			},
+			wantStatus: vmtask.StatusCompleted,
+		},
+		{
+			loc:  exam.Here(),
+			name: "restore destination exists",
+			state: media.DeletionState{
+				MediaId:     1,
+				Path:        "media/dvd/1",
+				RestorePath: "inbox/dvd/movie",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				mkdir(e, paths, "media/dvd/1")
+				mkdir(e, paths, "inbox/dvd/movie")
+			},
+			wantStatus: vmtask.StatusFailed,
+			check: func(e exam.E, paths config.Paths) {
+				// The DVD is left where it was.
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/dvd/1")), true)
+			},
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			if tt.setup != nil {
+				tt.setup(e, paths)
+			}
+			handler := &media.DeletionHandler{
+				Kind:  media.KindDvd,
+				Paths: paths,
+			}
+			stateBytes, err := json.Marshal(tt.state)
+			exam.Nil(e, env, err).Log(err).Must()
+
+			result := handler.Handle(ctx, nil, 1, media.TaskTypeDvdDeletion, stateBytes)
+			exam.Equal(e, env, result.NewStatus, tt.wantStatus).Log(result).Log(tt.loc)
+			if tt.check != nil {
+				tt.check(e, paths)
+			}
+		})
+	}
+}
+
+func TestDeletionHandler_OtherKinds(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	writeFile := func(e exam.E, paths config.Paths, rel string) {
+		path := paths.Absolute(rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			e.Fatalf("could not create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0644); err != nil {
+			e.Fatalf("could not write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		loc        exam.Loc
+		name       string
+		kind       media.Kind
+		state      media.DeletionState
+		setup      func(e exam.E, paths config.Paths)
+		wantStatus vmtask.Status
+		check      func(e exam.E, paths config.Paths)
+	}{
+		{
+			loc:  exam.Here(),
+			name: "remove ingested Blu-ray",
+			kind: media.KindBluray,
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "media/bluray/1",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				writeFile(e, paths, "media/bluray/1/BDMV/index.bdmv")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/bluray/1")), false)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "remove ingested video file",
+			kind: media.KindFile,
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "media/file/1/movie.mkv",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				writeFile(e, paths, "media/file/1/movie.mkv")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/file/1")), false)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "restore ingested video file to inbox",
+			kind: media.KindFile,
+			state: media.DeletionState{
+				MediaId:     1,
+				Path:        "media/file/1/movie.mkv",
+				RestorePath: "inbox/file/movie.mkv",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				writeFile(e, paths, "media/file/1/movie.mkv")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/file/1")), false)
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("inbox/file/movie.mkv")), true)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "video file moved by interrupted ingestion",
+			kind: media.KindFile,
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "inbox/file/movie.mkv",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				writeFile(e, paths, "media/file/1/movie.mkv")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/file/1")), false)
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "interrupted video file copy",
+			kind: media.KindFile,
+			state: media.DeletionState{
+				MediaId: 1,
+				Path:    "inbox/file/movie.mkv",
+			},
+			setup: func(e exam.E, paths config.Paths) {
+				writeFile(e, paths, "inbox/file/movie.mkv")
+				writeFile(e, paths, "media/file/1.partial")
+			},
+			wantStatus: vmtask.StatusCompleted,
+			check: func(e exam.E, paths config.Paths) {
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("media/file/1.partial")), false)
+				exam.Equal(e, env, vmtest.FileExists(e, paths.Absolute("inbox/file/movie.mkv")), true)
+			},
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			if tt.setup != nil {
+				tt.setup(e, paths)
+			}
+			handler := &media.DeletionHandler{
+				Kind:  tt.kind,
+				Paths: paths,
+			}
+			stateBytes, err := json.Marshal(tt.state)
+			exam.Nil(e, env, err).Log(err).Must()
+
+			result := handler.Handle(ctx, nil, 1, tt.kind.DeletionTaskType(), stateBytes)
+			exam.Equal(e, env, result.NewStatus, tt.wantStatus).Log(result).Log(tt.loc)
+			if tt.check != nil {
+				tt.check(e, paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// TaskTypeDvdScan is the task type for reading the titles of an ingested DVD.
+// These tasks are created as children of DVD ingestion tasks.
+const TaskTypeDvdScan = "dvd_scan"
+
+// DvdScanState represents the state of a DVD scan task.
+type DvdScanState struct {
+	MediaId uint32 `json:"media_id"`
+}
+
+// DvdScanHandler processes DVD scan tasks.  It reads the IFO files of an
+// ingested DVD, and stores its titles, audio streams and subtitles.
+type DvdScanHandler struct {
+	Paths config.Paths
+}
+
+// Handle implements vmtask.Handler.
+func (h *DvdScanHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, stateBytes []byte) vmtask.Result {
+	var state DvdScanState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to unmarshal state: %v", err))
+	}
+
+	const selectSql = `SELECT path FROM media_dvds WHERE media_id = $1`
+	relPath, err := vmdb.QueryOne[string](ctx, db, vmdb.Positional(selectSql, state.MediaId))
+	if errors.Is(err, vmdb.ErrNotFound) {
+		// The media was deleted, so there is nothing to scan.
+		return vmtask.Completed()
+	} else if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to query media_dvds: %v", err))
+	}
+
+	path := h.Paths.Absolute(relPath)
+	format, err := vmdisc.DetectDvd(path)
+	if err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to detect DVD format: %v", err))
+	}
+	if format != vmdisc.FormatVideoTs {
+		// The IFO files of a disc image are not readable without mounting it.
+		slog.InfoContext(ctx, "Skipping scan of DVD image", "media_id", state.MediaId, "path", relPath)
+		return vmtask.Completed()
+	}
+
+	info, err := vmdisc.ParseDvd(path)
+	if errors.Is(err, vmdisc.ErrInvalidIfo) {
+		// Retrying won't fix a malformed file.
+		return vmtask.Failed(fmt.Sprintf("failed to parse DVD: %v", err))
+	} else if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to read DVD: %v", err))
+	}
+
+	if err := storeDvdTitles(ctx, db, state.MediaId, info.Titles); err != nil {
+		return vmtask.Retry(err.Error())
+	}
+	return vmtask.Completed()
+}
+
+// storeDvdTitles replaces the stored titles of the given DVD with titles.
+func storeDvdTitles(ctx context.Context, db vmdb.Runner, mediaId uint32, titles []vmdisc.DvdTitle) error {
+	// Audio streams and subtitles are removed along with their titles.
+	const deleteSql = `DELETE FROM media_dvd_titles WHERE media_id = $1`
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(deleteSql, mediaId)); err != nil {
+		return fmt.Errorf("failed to delete DVD titles: %w", err)
+	}
+
+	const titleSql = `
+		INSERT INTO media_dvd_titles (media_id, title_number, title_set, duration_ms, chapters, angles)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	const audioSql = `
+		INSERT INTO media_dvd_title_audio (media_id, title_number, stream_index, language, codec, channels)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	const subtitleSql = `
+		INSERT INTO media_dvd_title_subtitles (media_id, title_number, stream_index, language)
+		VALUES ($1, $2, $3, $4)
+	`
+	for _, title := range titles {
+		_, err := vmdb.Exec(ctx, db, vmdb.Positional(titleSql, mediaId, title.Number, title.TitleSet, title.Duration.Milliseconds(), title.Chapters, title.Angles))
+		if err != nil {
+			return fmt.Errorf("failed to insert DVD title %d: %w", title.Number, err)
+		}
+		for i, audio := range title.Audio {
+			_, err := vmdb.Exec(ctx, db, vmdb.Positional(audioSql, mediaId, title.Number, i, audio.Language, audio.Codec, audio.Channels))
+			if err != nil {
+				return fmt.Errorf("failed to insert audio stream %d of DVD title %d: %w", i, title.Number, err)
+			}
+		}
+		for i, language := range title.SubtitleLanguages {
+			_, err := vmdb.Exec(ctx, db, vmdb.Positional(subtitleSql, mediaId, title.Number, i, language))
+			if err != nil {
+				return fmt.Errorf("failed to insert subtitle %d of DVD title %d: %w", i, title.Number, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetDvdTitles returns the stored titles of the given DVDs in title order, by
+// media ID.  DVDs that have not been scanned, or have no titles, are left out.
+func GetDvdTitles(ctx context.Context, db vmdb.Runner, mediaIds []uint32) (map[uint32][]vmdisc.DvdTitle, error) {
+	type titleRow struct {
+		MediaId    uint32
+		Number     int
+		TitleSet   int
+		DurationMs int64
+		Chapters   int
+		Angles     int
+	}
+	const titleSql = `
+		SELECT media_id, title_number, title_set, duration_ms, chapters, angles
+		FROM media_dvd_titles
+		WHERE media_id = ANY($1)
+		ORDER BY media_id, title_number
+	`
+	titles := make(map[uint32][]vmdisc.DvdTitle)
+	type titleKey struct {
+		MediaId uint32
+		Number  int
+	}
+	index := make(map[titleKey]int)
+	err := vmdb.Query(ctx, db, vmdb.Positional(titleSql, mediaIds), func(r titleRow) bool {
+		index[titleKey{r.MediaId, r.Number}] = len(titles[r.MediaId])
+		titles[r.MediaId] = append(titles[r.MediaId], vmdisc.DvdTitle{
+			Number:   r.Number,
+			TitleSet: r.TitleSet,
+			Duration: time.Duration(r.DurationMs) * time.Millisecond,
+			Chapters: r.Chapters,
+			Angles:   r.Angles,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DVD titles: %w", err)
+	}
+	title := func(mediaId uint32, number int) *vmdisc.DvdTitle {
+		return &titles[mediaId][index[titleKey{mediaId, number}]]
+	}
+
+	type audioRow struct {
+		MediaId  uint32
+		Number   int
+		Language string
+		Codec    string
+		Channels int
+	}
+	const audioSql = `
+		SELECT media_id, title_number, language, codec, channels
+		FROM media_dvd_title_audio
+		WHERE media_id = ANY($1)
+		ORDER BY media_id, title_number, stream_index
+	`
+	err = vmdb.Query(ctx, db, vmdb.Positional(audioSql, mediaIds), func(r audioRow) bool {
+		t := title(r.MediaId, r.Number)
+		t.Audio = append(t.Audio, vmdisc.AudioStream{Language: r.Language, Codec: r.Codec, Channels: r.Channels})
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DVD audio streams: %w", err)
+	}
+
+	type subtitleRow struct {
+		MediaId  uint32
+		Number   int
+		Language string
+	}
+	const subtitleSql = `
+		SELECT media_id, title_number, language
+		FROM media_dvd_title_subtitles
+		WHERE media_id = ANY($1)
+		ORDER BY media_id, title_number, stream_index
+	`
+	err = vmdb.Query(ctx, db, vmdb.Positional(subtitleSql, mediaIds), func(r subtitleRow) bool {
+		t := title(r.MediaId, r.Number)
+		t.SubtitleLanguages = append(t.SubtitleLanguages, r.Language)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DVD subtitles: %w", err)
+	}
+	return titles, nil
+}
+
+// createDvdScanTask creates a scan task for the given DVD as a child of the
+// ingestion task with the given ID.
+func createDvdScanTask(ctx context.Context, db vmdb.Runner, parentId int, mediaId uint32) (int, error) {
+	stateBytes, err := json.Marshal(DvdScanState{MediaId: mediaId})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return vmtask.CreateChild(ctx, db, parentId, TaskTypeDvdScan, stateBytes)
+}
@@ -0,0 +1,232 @@
+package media_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/media"
+)
+
+func TestDvdScanHandler(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+
+	titles := []vmdisc.DvdTitle{
+		{
+			Number:            1,
+			TitleSet:          1,
+			Duration:          time.Hour + 40*time.Millisecond,
+			Chapters:          20,
+			Angles:            1,
+			Audio:             []vmdisc.AudioStream{{Language: "en", Codec: "ac3", Channels: 6}, {Codec: "lpcm", Channels: 2}},
+			SubtitleLanguages: []string{"en", "fr"},
+		},
+		{
+			Number:   2,
+			TitleSet: 2,
+			Duration: 3 * time.Minute,
+			Chapters: 1,
+			Angles:   1,
+			Audio:    []vmdisc.AudioStream{{Language: "de", Codec: "mpeg2", Channels: 2}},
+		},
+	}
+
+	tests := []struct {
+		loc  exam.Loc
+		name string
+		// setup creates the DVD at the given path, if it should exist.
+		setup      func(e exam.E, path string)
+		wantStatus vmtask.Status
+		wantTitles []vmdisc.DvdTitle
+	}{
+		{
+			loc:  exam.Here(),
+			name: "VIDEO_TS folder",
+			setup: func(e exam.E, path string) {
+				vmtest.WriteDvd(e, path, titles)
+			},
+			wantStatus: vmtask.StatusCompleted,
+			wantTitles: titles,
+		},
+		{
+			loc:  exam.Here(),
+			name: "rescan replaces titles",
+			setup: func(e exam.E, path string) {
+				vmtest.WriteDvd(e, path, titles[:1])
+				_, err := vmdb.Exec(ctx, db, vmdb.Constant(`
+					INSERT INTO media_dvd_titles (media_id, title_number, title_set, duration_ms, chapters, angles)
+					VALUES (1, 1, 1, 1000, 1, 1), (1, 2, 1, 2000, 1, 1)
+				`))
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+			wantStatus: vmtask.StatusCompleted,
+			wantTitles: titles[:1],
+		},
+		{
+			loc:  exam.Here(),
+			name: "ISO image is skipped",
+			setup: func(e exam.E, path string) {
+				err := os.MkdirAll(path, 0755)
+				exam.Nil(e, env, err).Log(err).Must()
+				err = os.WriteFile(filepath.Join(path, "movie.iso"), []byte("iso"), 0644)
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+			wantStatus: vmtask.StatusCompleted,
+		},
+		{
+			loc:  exam.Here(),
+			name: "invalid IFO file",
+			setup: func(e exam.E, path string) {
+				err := os.MkdirAll(filepath.Join(path, "VIDEO_TS"), 0755)
+				exam.Nil(e, env, err).Log(err).Must()
+				err = os.WriteFile(filepath.Join(path, "VIDEO_TS", "VIDEO_TS.IFO"), []byte("ifo"), 0644)
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+			wantStatus: vmtask.StatusFailed,
+		},
+		{
+			loc:        exam.Here(),
+			name:       "missing DVD",
+			wantStatus: vmtask.StatusFailed,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			mediaId, err := vmdb.QueryOne[uint32](ctx, db, vmdb.Constant("INSERT INTO media (note) VALUES (NULL) RETURNING id"))
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, mediaId, uint32(1)).Must()
+			relPath := paths.MediaDvdId(config.PathKindRelative, mediaId)
+			_, err = vmdb.Exec(ctx, db, vmdb.Positional("INSERT INTO media_dvds (media_id, path) VALUES ($1, $2)", mediaId, relPath))
+			exam.Nil(e, env, err).Log(err).Must()
+			if tt.setup != nil {
+				tt.setup(e, paths.Absolute(relPath))
+			}
+
+			handler := &media.DvdScanHandler{Paths: paths}
+			state, err := json.Marshal(media.DvdScanState{MediaId: mediaId})
+			exam.Nil(e, env, err).Log(err).Must()
+			result := handler.Handle(ctx, db, 0, media.TaskTypeDvdScan, state)
+			exam.Equal(e, env, result.NewStatus, tt.wantStatus).Log(result).Log(tt.loc)
+
+			got, err := media.GetDvdTitles(ctx, db, []uint32{mediaId})
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, got[mediaId], tt.wantTitles).Log(tt.loc)
+		})
+	}
+
+	e.Run("deleted media", func(e exam.E) {
+		defer pg.Reset(e)
+		handler := &media.DvdScanHandler{Paths: config.Paths{RootDir: e.TempDir()}}
+		state, err := json.Marshal(media.DvdScanState{MediaId: 1})
+		exam.Nil(e, env, err).Log(err).Must()
+		result := handler.Handle(ctx, db, 0, media.TaskTypeDvdScan, state)
+		exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result)
+	})
+}
+
+func TestDvdIngestionHandler_Scan(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	mediaService := NewMediaService(e, pg)
+
+	tests := []struct {
+		loc  exam.Loc
+		name string
+		// setup changes the DVD in the inbox before it is ingested.
+		setup          func(e exam.E, path string)
+		wantScanStatus vmtask.Status
+		wantTitles     []vmdisc.DvdTitle
+	}{
+		{
+			loc:            exam.Here(),
+			name:           "scan succeeds",
+			wantScanStatus: vmtask.StatusCompleted,
+			wantTitles:     InboxDvdTitles,
+		},
+		{
+			loc:  exam.Here(),
+			name: "scan fails",
+			setup: func(e exam.E, path string) {
+				err := os.WriteFile(filepath.Join(path, "VIDEO_TS", "VIDEO_TS.IFO"), []byte("ifo"), 0644)
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+			wantScanStatus: vmtask.StatusFailed,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			mediaService.Paths = paths
+			inboxPath := NewInboxDvd(e, mediaService, "dvd")
+			if tt.setup != nil {
+				tt.setup(e, paths.Absolute(inboxPath))
+			}
+			postReq := vmapi.PostMediaRequestObject{
+				Body: &vmapi.MediaPost{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(inboxPath),
+					},
+				},
+			}
+			postResp, err := mediaService.PostMedia(ctx, postReq)
+			exam.Nil(e, env, err).Log(err).Must()
+			id := postResp.(vmapi.PostMedia201JSONResponse).Id
+
+			handler := &media.IngestionHandler{
+				Kind:  media.KindDvd,
+				Paths: paths,
+			}
+			// The DVD is ingested even if it can't be scanned.
+			result := runIngestion(e, db, handler, id, -1)
+			exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Log(tt.loc)
+
+			task, err := media.GetIngestionTask(ctx, db, media.KindDvd, id)
+			exam.Nil(e, env, err).Log(err).Must()
+			children, err := vmtask.GetChildTasks(ctx, db, task.Id)
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, len(children), 1).Log(tt.loc).Must()
+			exam.Equal(e, env, children[0].Status, tt.wantScanStatus).Log(tt.loc)
+			if tt.wantScanStatus == vmtask.StatusFailed {
+				exam.Match(e, env, children[0].Error, match.Not(match.Nil())).Log(tt.loc)
+			}
+
+			titles, err := media.GetDvdTitles(ctx, db, []uint32{id})
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, titles[id], tt.wantTitles).Log(tt.loc)
+		})
+	}
+}
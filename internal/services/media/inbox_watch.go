@@ -0,0 +1,87 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmwatch"
+)
+
+// NewInboxWatcher returns a watcher that creates media for DVDs as they are
+// added to the inbox, just as PostMedia would.
+func (ms *MediaService) NewInboxWatcher(cfg *config.InboxWatch) *vmwatch.Watcher {
+	return &vmwatch.Watcher{
+		Dir:          ms.Paths.InboxDvd(config.PathKindAbsolute),
+		QuietPeriod:  cfg.QuietPeriod,
+		PollInterval: cfg.PollInterval,
+		OnQuiet: func(ctx context.Context, name string) {
+			inboxPath := ms.Paths.InboxDvdName(config.PathKindRelative, name)
+			ctx = vmlog.With(ctx, slog.String("inbox_path", inboxPath))
+			mediaId, err := ms.createInboxDvd(ctx, inboxPath)
+			switch {
+			case err != nil:
+				slog.ErrorContext(ctx, "Failed to create media for inbox DVD", "error", err)
+			case mediaId != 0:
+				slog.InfoContext(ctx, "Created media for inbox DVD", "media_id", mediaId)
+			}
+		},
+	}
+}
+
+// createInboxDvd creates media for the DVD at inboxPath, and returns its ID.
+// It returns 0 if the DVD already has media, is still being ingested, or was
+// restored to the inbox by deleting its media.
+func (ms *MediaService) createInboxDvd(ctx context.Context, inboxPath string) (uint32, error) {
+	// A copy across filesystems leaves the DVD in the inbox until it is done,
+	// and an ingestion that failed leaves it there for someone to look at.
+	const ingestingQuery = `
+		SELECT COUNT(*) FROM tasks
+		WHERE task_type = $1 AND state->>'inbox_path' = $2 AND status <> $3
+	`
+	count, err := vmdb.QueryOne[int](ctx, ms.Db, vmdb.Positional(ingestingQuery, TaskTypeDvdIngestion, inboxPath, vmtask.StatusCompleted))
+	if err != nil {
+		return 0, fmt.Errorf("could not check for DVD ingestion tasks: %w", err)
+	}
+	if count > 0 {
+		return 0, nil
+	}
+
+	// Deleting media with restore_to_inbox moves the DVD back here, which must
+	// not bring the media straight back.
+	const restoredQuery = `
+		SELECT COUNT(*) FROM tasks
+		WHERE task_type = $1 AND state->>'restore_path' = $2 AND status <> $3
+	`
+	count, err = vmdb.QueryOne[int](ctx, ms.Db, vmdb.Positional(restoredQuery, TaskTypeDvdDeletion, inboxPath, vmtask.StatusFailed))
+	if err != nil {
+		return 0, fmt.Errorf("could not check for DVD deletion tasks: %w", err)
+	}
+	if count > 0 {
+		return 0, nil
+	}
+
+	request := vmapi.PostMediaRequestObject{
+		Body: &vmapi.MediaPost{
+			Details: vmapi.MediaPostDetails{
+				DvdInboxPath: &inboxPath,
+			},
+		},
+	}
+	response, err := ms.PostMedia(ctx, request)
+	var httpErr *vmerr.HttpError
+	if errors.As(err, &httpErr) && httpErr.Problem == vmerr.ProblemAlreadyExists {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return vmapi.Media(response.(vmapi.PostMedia201JSONResponse)).Id, nil
+}
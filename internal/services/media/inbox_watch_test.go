@@ -0,0 +1,93 @@
+package media_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/media"
+)
+
+func TestNewInboxWatcher(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	service := NewMediaService(e, pg)
+
+	cfg := &config.InboxWatch{
+		Enabled:      true,
+		QuietPeriod:  time.Minute,
+		PollInterval: time.Second,
+	}
+	w := service.NewInboxWatcher(cfg)
+	exam.Equal(e, env, w.Dir, service.Paths.InboxDvd(config.PathKindAbsolute))
+	exam.Equal(e, env, w.QuietPeriod, cfg.QuietPeriod)
+	exam.Equal(e, env, w.PollInterval, cfg.PollInterval)
+
+	listPaths := func(e exam.E) []string {
+		resp, err := service.ListMedia(ctx, vmapi.ListMediaRequestObject{})
+		exam.Nil(e, env, err).Log(err).Must()
+		var out []string
+		for _, m := range resp.(vmapi.ListMedia200JSONResponse).Media {
+			out = append(out, m.Details.Dvd.Path)
+		}
+		return out
+	}
+	setTask := func(e exam.E, status vmtask.Status, dvdPath string) {
+		const taskSql = "UPDATE tasks SET status = $1 WHERE task_type = $2"
+		_, err := vmdb.Exec(ctx, db, vmdb.Positional(taskSql, status, media.TaskTypeDvdIngestion))
+		exam.Nil(e, env, err).Log(err).Must()
+		const dvdSql = "UPDATE media_dvds SET path = $1"
+		_, err = vmdb.Exec(ctx, db, vmdb.Positional(dvdSql, dvdPath))
+		exam.Nil(e, env, err).Log(err).Must()
+	}
+
+	e.Run("creates media for new DVD", func(e exam.E) {
+		NewInboxDvd(e, service, "movie")
+		w.OnQuiet(ctx, "movie")
+		exam.Equal(e, env, listPaths(e), []string{"inbox/dvd/movie"})
+	})
+
+	e.Run("skips DVD that already has media", func(e exam.E) {
+		w.OnQuiet(ctx, "movie")
+		exam.Equal(e, env, listPaths(e), []string{"inbox/dvd/movie"})
+	})
+
+	e.Run("skips DVD that is still being ingested", func(e exam.E) {
+		// A copy across filesystems has not removed the inbox directory yet.
+		setTask(e, vmtask.StatusRunning, "media/dvd/1")
+		w.OnQuiet(ctx, "movie")
+		exam.Equal(e, env, listPaths(e), []string{"media/dvd/1"})
+	})
+
+	e.Run("creates media for reused name", func(e exam.E) {
+		setTask(e, vmtask.StatusCompleted, "media/dvd/1")
+		w.OnQuiet(ctx, "movie")
+		exam.Equal(e, env, listPaths(e), []string{"media/dvd/1", "inbox/dvd/movie"})
+	})
+
+	e.Run("skips DVD restored to the inbox", func(e exam.E) {
+		deleteCtx := media.WithDeleteMediaOptions(ctx, media.DeleteMediaOptions{RestoreToInbox: true})
+		_, err := service.DeleteMedia(deleteCtx, vmapi.DeleteMediaRequestObject{Id: 2})
+		exam.Nil(e, env, err).Log(err).Must()
+		w.OnQuiet(ctx, "movie")
+		exam.Equal(e, env, listPaths(e), []string{"media/dvd/1"})
+
+		// Once the restore has happened, the DVD stays in the inbox.
+		const taskSql = "UPDATE tasks SET status = $1 WHERE task_type = $2"
+		_, err = vmdb.Exec(ctx, db, vmdb.Positional(taskSql, vmtask.StatusCompleted, media.TaskTypeDvdDeletion))
+		exam.Nil(e, env, err).Log(err).Must()
+		w.OnQuiet(ctx, "movie")
+		exam.Equal(e, env, listPaths(e), []string{"media/dvd/1"})
+	})
+}
@@ -0,0 +1,459 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// The task types for ingesting each Kind of media.
+const (
+	TaskTypeDvdIngestion    = "dvd_ingestion"
+	TaskTypeBlurayIngestion = "bluray_ingestion"
+	TaskTypeFileIngestion   = "file_ingestion"
+)
+
+// DefaultCopyChunkBytes is the default for IngestionHandler.CopyChunkBytes.
+// Each chunk is copied or verified while the worker's transaction is open, so
+// it is kept small.
+const DefaultCopyChunkBytes = 64 << 20
+
+// IngestionStep is the step that an ingestion task will run next.
+type IngestionStep string
+
+const (
+	// IngestionStepMove moves the media from the inbox to its final location.
+	// The zero value of IngestionStep means the same thing.
+	IngestionStepMove IngestionStep = "move"
+	// IngestionStepCopy copies the media to a staging path next to its final
+	// location.  It is used instead of a move when the inbox and media
+	// directories are on different filesystems.
+	IngestionStepCopy IngestionStep = "copy"
+	// IngestionStepVerify checks the size and checksum of every copied file.
+	IngestionStepVerify IngestionStep = "verify"
+	// IngestionStepDeleteSource moves the staging path to the final location,
+	// and then removes the media from the inbox.
+	IngestionStepDeleteSource IngestionStep = "delete_source"
+	// IngestionStepUpdatePath records the final location in the details table.
+	IngestionStepUpdatePath IngestionStep = "update_path"
+	// IngestionStepScan waits for a child task that reads the titles of a DVD.
+	// Other kinds skip it.
+	IngestionStepScan IngestionStep = "scan"
+	// IngestionStepDone means that ingestion has finished.
+	IngestionStepDone IngestionStep = "done"
+)
+
+// IngestionState represents the state of an ingestion task.
+type IngestionState struct {
+	MediaId uint32 `json:"media_id"`
+	// InboxPath is the relative path the media was ingested from.  It is used
+	// to restore the media to the inbox if it is deleted.
+	InboxPath string `json:"inbox_path,omitempty"`
+	// Step is the next step to run.  Each step is committed before the next
+	// one starts, and each step can be re-run safely if the process crashes
+	// part way through it.
+	Step IngestionStep `json:"step,omitempty"`
+	// Copy is set once a cross-filesystem copy has started.
+	Copy *CopyProgress `json:"copy,omitempty"`
+	// ScanTaskId is the ID of the child task created by IngestionStepScan.
+	ScanTaskId int `json:"scan_task_id,omitempty"`
+}
+
+// IngestionHandler processes the ingestion tasks of one Kind of media.
+// It moves media from the inbox to their final location.
+type IngestionHandler struct {
+	Kind  Kind
+	Paths config.Paths
+	// CopyChunkBytes bounds how many bytes are copied or verified in each run
+	// of a task, so that progress is committed regularly and the transaction
+	// of the run stays short.  If zero, DefaultCopyChunkBytes is used.
+	CopyChunkBytes int64
+}
+
+// Handle implements vmtask.Handler.
+func (h *IngestionHandler) Handle(ctx context.Context, db vmdb.Runner, taskId int, taskType string, stateBytes []byte) vmtask.Result {
+	var state IngestionState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to unmarshal state: %v", err))
+	}
+
+	switch state.Step {
+	case "", IngestionStepMove:
+		return h.move(ctx, db, state)
+	case IngestionStepCopy:
+		return h.copy(ctx, db, state)
+	case IngestionStepVerify:
+		return h.verify(ctx, db, state)
+	case IngestionStepDeleteSource:
+		return h.deleteSource(ctx, db, state)
+	case IngestionStepUpdatePath:
+		return h.updatePath(ctx, db, state)
+	case IngestionStepScan:
+		return h.scan(ctx, db, taskId, state)
+	case IngestionStepDone:
+		return vmtask.Completed()
+	default:
+		return vmtask.Failed(fmt.Sprintf("unknown step %q", state.Step))
+	}
+}
+
+// move moves the media to its final location.  If an earlier attempt already
+// moved it but crashed before recording that, the move is skipped.
+func (h *IngestionHandler) move(ctx context.Context, db vmdb.Runner, state IngestionState) vmtask.Result {
+	// Get the current path from the details table
+	path, err := h.currentPath(ctx, db, state)
+	if err != nil {
+		return vmtask.Failed(err.Error())
+	}
+
+	newRelPath := h.Kind.mediaPath(h.Paths, config.PathKindRelative, state.MediaId, path)
+	if path != newRelPath {
+		newProgressReporter(ctx, IngestionStepMove, 0).report(0, true)
+		oldPath := h.Paths.Absolute(path)
+		newPath := h.Paths.Absolute(newRelPath)
+		oldExists, err := exists(oldPath)
+		if err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to check %s path: %v", h.Kind.label(), err))
+		}
+		newExists, err := exists(newPath)
+		if err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to check %s path: %v", h.Kind.label(), err))
+		}
+
+		switch {
+		case oldExists && newExists:
+			// We can't tell which one is the real media.
+			return vmtask.Failed(fmt.Sprintf("both %q and %q exist", path, newRelPath))
+		case oldExists:
+			if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+				return vmtask.Retry(fmt.Sprintf("failed to create media directory: %v", err))
+			}
+			if renameErr := os.Rename(oldPath, newPath); errors.Is(renameErr, syscall.EXDEV) {
+				slog.InfoContext(ctx, "Media is on a different filesystem, copying instead", "kind", h.Kind, "from", oldPath, "to", newPath)
+				state.Step = IngestionStepCopy
+				return nextStep(state, vmtask.Pending)
+			} else if renameErr != nil {
+				slog.ErrorContext(ctx, "Failed to rename media path", "kind", h.Kind, "from", oldPath, "to", newPath, "error", renameErr)
+				return vmtask.Retry(fmt.Sprintf("failed to rename %s path: %v", h.Kind.label(), renameErr))
+			}
+		case newExists:
+			slog.WarnContext(ctx, "Media was already moved by an earlier attempt", "kind", h.Kind, "from", oldPath, "to", newPath)
+		default:
+			// Retrying won't make a missing inbox entry appear.
+			return vmtask.Failed(fmt.Sprintf("%s path %q does not exist", h.Kind.label(), path))
+		}
+	}
+
+	state.Step = IngestionStepUpdatePath
+	return nextStep(state, vmtask.Pending)
+}
+
+// copy copies the next chunk of the media to the staging path.
+func (h *IngestionHandler) copy(ctx context.Context, db vmdb.Runner, state IngestionState) vmtask.Result {
+	path, err := h.sourcePath(ctx, db, state)
+	if err != nil {
+		return vmtask.Failed(err.Error())
+	}
+	stagingPath := h.Kind.stagingPath(h.Paths, config.PathKindAbsolute, state.MediaId)
+
+	if state.Copy == nil {
+		// Start from scratch, discarding anything left by an attempt that
+		// crashed before recording its progress.
+		if err := os.RemoveAll(stagingPath); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to clear staging path: %v", err))
+		}
+		state.Copy, err = listFiles(path)
+		if err != nil {
+			return vmtask.Failed(fmt.Sprintf("failed to list %s files: %v", h.Kind.label(), err))
+		}
+		// A single video file is copied straight to the staging path.
+		if !state.Copy.isFile() {
+			if err := os.Mkdir(stagingPath, 0755); err != nil {
+				return vmtask.Retry(fmt.Sprintf("failed to create staging path: %v", err))
+			}
+		}
+	}
+
+	progress := state.Copy
+	reporter := newProgressReporter(ctx, IngestionStepCopy, progress.TotalBytes)
+	reporter.report(progress.CopiedBytes, true)
+	budget := h.copyChunkBytes()
+	for progress.CopiedFiles < len(progress.Files) {
+		if budget <= 0 {
+			return nextStep(state, vmtask.Pending)
+		}
+		file := progress.Files[progress.CopiedFiles]
+		dst := filepath.Join(stagingPath, file.Path)
+		if file.IsDir {
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return vmtask.Retry(fmt.Sprintf("failed to create %q: %v", file.Path, err))
+			}
+			progress.CopiedFiles++
+			continue
+		}
+
+		remaining := file.Size - progress.CopiedOffset
+		copiedBytes := progress.CopiedBytes
+		onWrite := func(n int64) { reporter.report(copiedBytes+n, false) }
+		n, err := copyFileRange(ctx, filepath.Join(path, file.Path), dst, progress.CopiedOffset, min(remaining, budget), onWrite)
+		if err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to copy %q: %v", file.Path, err))
+		}
+		if n < min(remaining, budget) {
+			return vmtask.Failed(fmt.Sprintf("%q shrank while it was being copied", file.Path))
+		}
+		progress.CopiedOffset += n
+		progress.CopiedBytes += n
+		budget -= n
+		if progress.CopiedOffset == file.Size {
+			progress.CopiedFiles++
+			progress.CopiedOffset = 0
+		}
+	}
+
+	reporter.report(progress.CopiedBytes, true)
+	state.Step = IngestionStepVerify
+	return nextStep(state, vmtask.Pending)
+}
+
+// verify compares the size and contents of the next chunk of the copied files
+// with the originals.  If a file does not match, the copy is started again.
+func (h *IngestionHandler) verify(ctx context.Context, db vmdb.Runner, state IngestionState) vmtask.Result {
+	path, err := h.sourcePath(ctx, db, state)
+	if err != nil {
+		return vmtask.Failed(err.Error())
+	}
+	stagingPath := h.Kind.stagingPath(h.Paths, config.PathKindAbsolute, state.MediaId)
+	progress := state.Copy
+	if progress == nil {
+		return vmtask.Failed("nothing was copied")
+	}
+
+	reporter := newProgressReporter(ctx, IngestionStepVerify, progress.TotalBytes)
+	budget := h.copyChunkBytes()
+	for progress.VerifiedFiles < len(progress.Files) {
+		reporter.report(progress.VerifiedBytes, true)
+		if budget <= 0 {
+			return nextStep(state, vmtask.Pending)
+		}
+		file := progress.Files[progress.VerifiedFiles]
+		if file.IsDir {
+			progress.VerifiedFiles++
+			continue
+		}
+
+		remaining := file.Size - progress.VerifiedOffset
+		n, err := compareFileRange(ctx, filepath.Join(path, file.Path), filepath.Join(stagingPath, file.Path), file.Size, progress.VerifiedOffset, min(remaining, budget))
+		if err == nil && n < min(remaining, budget) {
+			err = fmt.Errorf("only %d of %d bytes could be read", n, min(remaining, budget))
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Copied file does not match, copying again", "kind", h.Kind, "file", file.Path, "error", err)
+			state.Step = IngestionStepCopy
+			state.Copy = nil
+			return nextStepWithError(state, vmtask.RetryWithState, fmt.Sprintf("failed to verify %q: %v", file.Path, err))
+		}
+		progress.VerifiedOffset += n
+		progress.VerifiedBytes += n
+		budget -= n
+		if progress.VerifiedOffset == file.Size {
+			progress.VerifiedFiles++
+			progress.VerifiedOffset = 0
+		}
+	}
+
+	state.Step = IngestionStepDeleteSource
+	return nextStep(state, vmtask.Pending)
+}
+
+// deleteSource moves the verified staging path to the final location, and then
+// removes the original from the inbox.
+func (h *IngestionHandler) deleteSource(ctx context.Context, db vmdb.Runner, state IngestionState) vmtask.Result {
+	path, err := h.sourcePath(ctx, db, state)
+	if err != nil {
+		return vmtask.Failed(err.Error())
+	}
+	stagingPath := h.Kind.stagingPath(h.Paths, config.PathKindAbsolute, state.MediaId)
+	newPath := h.Kind.mediaPath(h.Paths, config.PathKindAbsolute, state.MediaId, path)
+
+	stagingExists, err := exists(stagingPath)
+	if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to check staging path: %v", err))
+	}
+	// If the staging path is gone, an earlier attempt already moved it.
+	if stagingExists {
+		if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to create media directory: %v", err))
+		}
+		if err := os.Rename(stagingPath, newPath); err != nil {
+			return vmtask.Retry(fmt.Sprintf("failed to move staging path: %v", err))
+		}
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to remove %s from inbox: %v", h.Kind.label(), err))
+	}
+
+	state.Step = IngestionStepUpdatePath
+	return nextStep(state, vmtask.Pending)
+}
+
+// currentPath returns the relative path recorded in the details table.
+func (h *IngestionHandler) currentPath(ctx context.Context, db vmdb.Runner, state IngestionState) (string, error) {
+	selectSql := fmt.Sprintf(`SELECT path FROM %s WHERE media_id = $1`, h.Kind.table())
+	path, err := vmdb.QueryOne[string](ctx, db, vmdb.Positional(selectSql, state.MediaId))
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s: %w", h.Kind.table(), err)
+	}
+	return path, nil
+}
+
+// sourcePath returns the absolute path that the media is being copied from.
+func (h *IngestionHandler) sourcePath(ctx context.Context, db vmdb.Runner, state IngestionState) (string, error) {
+	path, err := h.currentPath(ctx, db, state)
+	if err != nil {
+		return "", err
+	}
+	return h.Paths.Absolute(path), nil
+}
+
+func (h *IngestionHandler) copyChunkBytes() int64 {
+	if h.CopyChunkBytes > 0 {
+		return h.CopyChunkBytes
+	}
+	return DefaultCopyChunkBytes
+}
+
+// updatePath records the final location of the media in the details table.
+func (h *IngestionHandler) updatePath(ctx context.Context, db vmdb.Runner, state IngestionState) vmtask.Result {
+	path, err := h.currentPath(ctx, db, state)
+	if err != nil {
+		return vmtask.Failed(err.Error())
+	}
+	relPath := h.Kind.mediaPath(h.Paths, config.PathKindRelative, state.MediaId, path)
+	if ok, err := exists(h.Paths.Absolute(relPath)); err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to check %s path: %v", h.Kind.label(), err))
+	} else if !ok {
+		return vmtask.Failed(fmt.Sprintf("%s path %q does not exist", h.Kind.label(), relPath))
+	}
+
+	updateSql := fmt.Sprintf(`UPDATE %s SET path = $2 WHERE media_id = $1`, h.Kind.table())
+	if _, err := vmdb.Exec(ctx, db, vmdb.Positional(updateSql, state.MediaId, relPath)); err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to update %s path: %v", h.Kind.table(), err))
+	}
+
+	if h.Kind == KindDvd {
+		state.Step = IngestionStepScan
+		return nextStep(state, vmtask.Pending)
+	}
+	state.Step = IngestionStepDone
+	return nextStep(state, vmtask.CompletedWithState)
+}
+
+// scan creates a child task that reads the titles of the DVD, and waits for it
+// to finish.  The DVD is already in place, so ingestion completes even if the
+// scan fails.
+func (h *IngestionHandler) scan(ctx context.Context, db vmdb.Runner, taskId int, state IngestionState) vmtask.Result {
+	if state.ScanTaskId != 0 {
+		child, err := vmtask.Get(ctx, db, state.ScanTaskId)
+		switch {
+		case errors.Is(err, vmdb.ErrNotFound):
+			// The child is gone, so start a new one.
+			state.ScanTaskId = 0
+		case err != nil:
+			return vmtask.Retry(fmt.Sprintf("failed to get scan task: %v", err))
+		case child.Status == vmtask.StatusCompleted:
+			state.Step = IngestionStepDone
+			return nextStep(state, vmtask.CompletedWithState)
+		case child.Status == vmtask.StatusFailed:
+			var errMsg string
+			if child.Error != nil {
+				errMsg = *child.Error
+			}
+			slog.WarnContext(ctx, "Failed to scan DVD", "media_id", state.MediaId, "task_id", child.Id, "error", errMsg)
+			state.Step = IngestionStepDone
+			return nextStep(state, vmtask.CompletedWithState)
+		default:
+			return nextStep(state, vmtask.Waiting)
+		}
+	}
+
+	scanTaskId, err := createDvdScanTask(ctx, db, taskId, state.MediaId)
+	if err != nil {
+		return vmtask.Retry(fmt.Sprintf("failed to create scan task: %v", err))
+	}
+	state.ScanTaskId = scanTaskId
+	return nextStep(state, vmtask.Waiting)
+}
+
+// nextStep marshals state and passes it to result.
+func nextStep(state IngestionState, result func([]byte) vmtask.Result) vmtask.Result {
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to marshal state: %v", err))
+	}
+	return result(stateBytes)
+}
+
+// nextStepWithError marshals state and passes it to result along with errMsg.
+func nextStepWithError(state IngestionState, result func([]byte, string) vmtask.Result, errMsg string) vmtask.Result {
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return vmtask.Failed(fmt.Sprintf("failed to marshal state: %v", err))
+	}
+	return result(stateBytes, errMsg)
+}
+
+// exists reports whether path exists.
+func exists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CreateIngestionTask creates a new ingestion task for the given media ID, which
+// moves the media of the given kind at inboxPath into the media directory.
+// This should be called within a transaction to ensure atomicity with media creation.
+func CreateIngestionTask(ctx context.Context, db vmdb.Runner, kind Kind, mediaId uint32, inboxPath string) (int, error) {
+	state := IngestionState{
+		MediaId:   mediaId,
+		InboxPath: inboxPath,
+	}
+	stateBytes, err := json.Marshal(state)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	return vmtask.Create(ctx, db, kind.IngestionTaskType(), stateBytes)
+}
+
+// GetIngestionTask retrieves the ingestion task of the given kind for a given media ID.
+// Returns nil if no task exists for the media ID.
+func GetIngestionTask(ctx context.Context, db vmdb.Runner, kind Kind, mediaId uint32) (*vmtask.Task, error) {
+	const sql = `
+		SELECT id, task_type, state, status, worker_id, lease_expires_at, error, parent_id, created_at, updated_at, attempts, run_after, progress
+		FROM tasks
+		WHERE task_type = $1 AND (state->>'media_id')::integer = $2
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	task, err := vmdb.QueryOne[vmtask.Task](ctx, db, vmdb.Positional(sql, kind.IngestionTaskType(), mediaId))
+	if err != nil {
+		if err == vmdb.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &task, nil
+}
@@ -0,0 +1,575 @@
+package media_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/media"
+)
+
+func TestDvdIngestionHandler(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	mediaService := NewMediaService(e, pg)
+
+	tests := []struct {
+		loc        exam.Loc
+		name       string
+		setup      func(e exam.E, paths config.Paths) []uint32
+		wantStatus vmtask.Status
+		wantError  match.Matcher
+		check      func(e exam.E, paths config.Paths, ids []uint32)
+	}{
+		{
+			loc:  exam.Here(),
+			name: "Move one directory",
+			setup: func(e exam.E, paths config.Paths) []uint32 {
+				dvdDirName := "dvd-1"
+				postReq := vmapi.PostMediaRequestObject{
+					Body: &vmapi.MediaPost{
+						Details: vmapi.MediaPostDetails{
+							DvdInboxPath: Set(NewInboxDvd(e, mediaService, dvdDirName)),
+						},
+					},
+				}
+				postResp, err := mediaService.PostMedia(ctx, postReq)
+				exam.Nil(e, env, err).Log(err).Must()
+				return []uint32{postResp.(vmapi.PostMedia201JSONResponse).Id}
+			},
+			wantStatus: vmtask.StatusCompleted,
+			wantError:  match.Nil(),
+			check: func(e exam.E, paths config.Paths, ids []uint32) {
+				id := ids[0]
+				// Check that the directory was moved
+				dvdDirName := "dvd-1"
+				exam.Equal(e, env, vmtest.FileExists(e, paths.InboxDvdName(config.PathKindAbsolute, dvdDirName)), false)
+				exam.Equal(e, env, vmtest.FileExists(e, paths.MediaDvdId(config.PathKindAbsolute, id)), true)
+
+				// Check that the media record was updated
+				getReq := vmapi.GetMediaRequestObject{
+					Id: id,
+				}
+				resp, err := mediaService.GetMedia(ctx, getReq)
+				exam.Nil(e, env, err).Log(err).Must()
+				exam.Equal(e, env, resp.(vmapi.GetMedia200JSONResponse).Details.Dvd.Path, paths.MediaDvdId(config.PathKindRelative, id))
+			},
+		},
+		{
+			loc:  exam.Here(),
+			name: "Handle error when input directory does not exist",
+			setup: func(e exam.E, paths config.Paths) []uint32 {
+				// Create a media record, then remove the directory before it is ingested
+				postReq := vmapi.PostMediaRequestObject{
+					Body: &vmapi.MediaPost{
+						Details: vmapi.MediaPostDetails{
+							DvdInboxPath: Set(NewInboxDvd(e, mediaService, "removed-dvd")),
+						},
+					},
+				}
+				postResp, err := mediaService.PostMedia(ctx, postReq)
+				exam.Nil(e, env, err).Log(err).Must()
+				if err := os.RemoveAll(paths.InboxDvdName(config.PathKindAbsolute, "removed-dvd")); err != nil {
+					e.Fatalf("Could not remove inbox dvd directory: %v", err)
+				}
+				return []uint32{postResp.(vmapi.PostMedia201JSONResponse).Id}
+			},
+			wantStatus: vmtask.StatusFailed,
+			wantError:  match.Not(match.Nil()),
+			check: func(e exam.E, paths config.Paths, ids []uint32) {
+				id := ids[0]
+				// Check that the media record was updated to error state
+				getReq := vmapi.GetMediaRequestObject{
+					Id: id,
+				}
+				resp, err := mediaService.GetMedia(ctx, getReq)
+				exam.Nil(e, env, err).Log(err).Must()
+				dvdResp := resp.(vmapi.GetMedia200JSONResponse)
+				exam.Equal(e, env, dvdResp.Details.Dvd.Ingestion.State, vmapi.DVDIngestionStateError).Log(deep.Format(env, dvdResp))
+				exam.Match(e, env, dvdResp.Details.Dvd.Ingestion.ErrorMessage, match.Not(match.Nil())).Log(deep.Format(env, dvdResp))
+			},
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			mediaService.Paths = paths
+			handler := &media.IngestionHandler{
+				Kind:  media.KindDvd,
+				Paths: paths,
+			}
+			var ids []uint32
+			if tt.setup != nil {
+				ids = tt.setup(e, paths)
+			}
+
+			result := runIngestion(e, db, handler, ids[0], -1)
+
+			exam.Equal(e, env, result.NewStatus, tt.wantStatus).Log(result)
+			if tt.wantError != nil {
+				var errPtr *string
+				if result.Error != "" {
+					errPtr = &result.Error
+				}
+				exam.Match(e, env, errPtr, tt.wantError).Log(result.Error)
+			}
+			if tt.check != nil {
+				tt.check(e, paths, ids)
+			}
+		})
+	}
+}
+
+// runIngestion runs the ingestion task of the given media until it stops
+// asking to be re-queued, and returns the last result.  If the task waits for
+// child tasks, they are run to completion before the task is resumed.  If
+// crashAt is not negative, the transaction of that run is rolled back instead
+// of committed, as if the process had crashed after the handler returned.
+func runIngestion(e exam.E, db vmdb.DbRunner, handler *media.IngestionHandler, mediaId uint32, crashAt int) vmtask.Result {
+	e.Helper()
+	ctx := context.Background()
+	env := deep.NewEnv()
+	for run := 0; run < 100; run++ {
+		// Get the task that was created
+		task, err := media.GetIngestionTask(ctx, db, handler.Kind, mediaId)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Match(e, env, task, match.Not(match.Nil())).Log("task should exist").Must()
+
+		// Execute the handler within a transaction
+		tx, err := db.Begin(ctx)
+		exam.Nil(e, env, err).Log(err).Must()
+		result := handler.Handle(ctx, tx, task.Id, task.TaskType, task.State)
+		if run == crashAt {
+			tx.Rollback(ctx)
+			continue
+		}
+		applyResult(e, tx, task.Id, result)
+		err = tx.Commit(ctx)
+		exam.Nil(e, env, err).Log(err).Must()
+
+		if result.NewStatus == vmtask.StatusWaiting {
+			runChildren(e, db, handler.Paths, task.Id)
+			continue
+		}
+		if result.NewStatus != vmtask.StatusPending {
+			return result
+		}
+	}
+	e.Fatalf("%s ingestion of media %d did not finish", handler.Kind, mediaId)
+	return vmtask.Result{}
+}
+
+// runChildren runs each unfinished child task of the given task once, and then
+// resumes the task, as the worker would once the children finish.
+func runChildren(e exam.E, db vmdb.DbRunner, paths config.Paths, parentId int) {
+	e.Helper()
+	ctx := context.Background()
+	env := deep.NewEnv()
+	children, err := vmtask.GetChildTasks(ctx, db, parentId)
+	exam.Nil(e, env, err).Log(err).Must()
+	for _, child := range children {
+		if child.Status != vmtask.StatusPending {
+			continue
+		}
+		exam.Equal(e, env, child.TaskType, media.TaskTypeDvdScan).Must()
+		handler := &media.DvdScanHandler{Paths: paths}
+		tx, err := db.Begin(ctx)
+		exam.Nil(e, env, err).Log(err).Must()
+		applyResult(e, tx, child.Id, handler.Handle(ctx, tx, child.Id, child.TaskType, child.State))
+		err = tx.Commit(ctx)
+		exam.Nil(e, env, err).Log(err).Must()
+	}
+	const sql = `UPDATE tasks SET status = 'pending' WHERE id = $1 AND status = 'waiting'`
+	_, err = vmdb.Exec(ctx, db, vmdb.Positional(sql, parentId))
+	exam.Nil(e, env, err).Log(err).Must()
+}
+
+// applyResult records result as the outcome of the task with the given ID.
+// Retryable failures are recorded as failures.
+func applyResult(e exam.E, tx vmdb.TxRunner, taskId int, result vmtask.Result) {
+	e.Helper()
+	ctx := context.Background()
+	env := deep.NewEnv()
+	var taskError *string
+	if result.NewStatus == vmtask.StatusFailed {
+		taskError = &result.Error
+	}
+	const sql = `
+		UPDATE tasks
+		SET status = $2, state = COALESCE($3::jsonb, state), error = $4, worker_id = NULL, lease_expires_at = NULL
+		WHERE id = $1
+	`
+	var newState *string
+	if result.NewState != nil {
+		s := string(result.NewState)
+		newState = &s
+	}
+	_, err := vmdb.Exec(ctx, tx, vmdb.Positional(sql, taskId, string(result.NewStatus), newState, taskError))
+	if err != nil {
+		tx.Rollback(ctx)
+	}
+	exam.Nil(e, env, err).Log(err).Must()
+}
+
+func TestDvdIngestionHandler_Crash(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	mediaService := NewMediaService(e, pg)
+
+	// setStep simulates a crash after the given step was recorded, but before
+	// the next step committed anything.
+	setStep := func(e exam.E, mediaId uint32, step media.IngestionStep) {
+		const sql = `
+			UPDATE tasks SET state = jsonb_set(state, '{step}', to_jsonb($2::text))
+			WHERE task_type = $1 AND (state->>'media_id')::integer = $3
+		`
+		_, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, media.TaskTypeDvdIngestion, string(step), mediaId))
+		exam.Nil(e, env, err).Log(err).Must()
+	}
+
+	tests := []struct {
+		loc  exam.Loc
+		name string
+		// crashAt is the run of the handler whose transaction is rolled back.
+		crashAt int
+		setup   func(e exam.E, paths config.Paths, mediaId uint32)
+	}{
+		{
+			loc:     exam.Here(),
+			name:    "no crash",
+			crashAt: -1,
+		},
+		{
+			loc:     exam.Here(),
+			name:    "crash after move",
+			crashAt: 0,
+		},
+		{
+			loc:     exam.Here(),
+			name:    "crash after updating path",
+			crashAt: 1,
+		},
+		{
+			loc:     exam.Here(),
+			name:    "crash during move",
+			crashAt: -1,
+			setup: func(e exam.E, paths config.Paths, mediaId uint32) {
+				// The directory was moved, but nothing was committed.
+				err := os.Rename(paths.InboxDvdName(config.PathKindAbsolute, "dvd"), paths.MediaDvdId(config.PathKindAbsolute, mediaId))
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+		},
+		{
+			loc:     exam.Here(),
+			name:    "crash before updating path",
+			crashAt: -1,
+			setup: func(e exam.E, paths config.Paths, mediaId uint32) {
+				err := os.Rename(paths.InboxDvdName(config.PathKindAbsolute, "dvd"), paths.MediaDvdId(config.PathKindAbsolute, mediaId))
+				exam.Nil(e, env, err).Log(err).Must()
+				setStep(e, mediaId, media.IngestionStepUpdatePath)
+			},
+		},
+		{
+			loc:     exam.Here(),
+			name:    "rerun after completion",
+			crashAt: -1,
+			setup: func(e exam.E, paths config.Paths, mediaId uint32) {
+				err := os.Rename(paths.InboxDvdName(config.PathKindAbsolute, "dvd"), paths.MediaDvdId(config.PathKindAbsolute, mediaId))
+				exam.Nil(e, env, err).Log(err).Must()
+				const sql = "UPDATE media_dvds SET path = $2 WHERE media_id = $1"
+				_, err = vmdb.Exec(ctx, db, vmdb.Positional(sql, mediaId, paths.MediaDvdId(config.PathKindRelative, mediaId)))
+				exam.Nil(e, env, err).Log(err).Must()
+			},
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			mediaService.Paths = paths
+			postReq := vmapi.PostMediaRequestObject{
+				Body: &vmapi.MediaPost{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(NewInboxDvd(e, mediaService, "dvd")),
+					},
+				},
+			}
+			postResp, err := mediaService.PostMedia(ctx, postReq)
+			exam.Nil(e, env, err).Log(err).Must()
+			id := postResp.(vmapi.PostMedia201JSONResponse).Id
+			if tt.setup != nil {
+				tt.setup(e, paths, id)
+			}
+
+			handler := &media.IngestionHandler{
+				Kind:  media.KindDvd,
+				Paths: paths,
+			}
+			result := runIngestion(e, db, handler, id, tt.crashAt)
+			exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Log(tt.loc)
+
+			exam.Equal(e, env, vmtest.FileExists(e, paths.InboxDvdName(config.PathKindAbsolute, "dvd")), false).Log(tt.loc)
+			exam.Equal(e, env, vmtest.FileExists(e, paths.MediaDvdId(config.PathKindAbsolute, id)), true).Log(tt.loc)
+			getResp, err := mediaService.GetMedia(ctx, vmapi.GetMediaRequestObject{Id: id})
+			exam.Nil(e, env, err).Log(err).Must()
+			dvd := getResp.(vmapi.GetMedia200JSONResponse).Details.Dvd
+			exam.Equal(e, env, dvd.Path, paths.MediaDvdId(config.PathKindRelative, id)).Log(tt.loc)
+			exam.Equal(e, env, dvd.Ingestion.State, vmapi.DVDIngestionStateDone).Log(tt.loc)
+		})
+	}
+}
+
+func TestDvdIngestionHandler_Copy(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	mediaService := NewMediaService(e, pg)
+
+	// The rename fallback can't be triggered without a second filesystem, so
+	// these tests start the task at the copy step instead.
+	files := map[string]string{
+		"VIDEO_TS/VIDEO_TS.IFO": "ifo",
+		"VIDEO_TS/VTS_01_1.VOB": strings.Repeat("0123456789", 1000),
+		"VIDEO_TS/VTS_01_2.VOB": strings.Repeat("abcdefghij", 500),
+	}
+
+	// With 4000 byte chunks, the task copies in runs 0-3, verifies in runs
+	// 4-7, deletes the source in run 8 and updates the path in run 9.
+	tests := []struct {
+		loc     exam.Loc
+		name    string
+		crashAt int
+	}{
+		{loc: exam.Here(), name: "no crash", crashAt: -1},
+		{loc: exam.Here(), name: "crash while copying first chunk", crashAt: 0},
+		{loc: exam.Here(), name: "crash while copying later chunk", crashAt: 2},
+		{loc: exam.Here(), name: "crash while finishing copy", crashAt: 3},
+		{loc: exam.Here(), name: "crash while verifying first chunk", crashAt: 4},
+		{loc: exam.Here(), name: "crash while verifying later chunk", crashAt: 6},
+		{loc: exam.Here(), name: "crash while finishing verify", crashAt: 7},
+		{loc: exam.Here(), name: "crash while deleting source", crashAt: 8},
+		{loc: exam.Here(), name: "crash while updating path", crashAt: 9},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			mediaService.Paths = paths
+			inbox := paths.InboxDvdName(config.PathKindAbsolute, "dvd")
+			for name, content := range files {
+				p := filepath.Join(inbox, name)
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					e.Fatalf("Could not create inbox dvd directory: %v", err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+					e.Fatalf("Could not write inbox dvd file: %v", err)
+				}
+			}
+			if err := os.MkdirAll(filepath.Join(inbox, "AUDIO_TS"), 0755); err != nil {
+				e.Fatalf("Could not create inbox dvd directory: %v", err)
+			}
+
+			postReq := vmapi.PostMediaRequestObject{
+				Body: &vmapi.MediaPost{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(paths.InboxDvdName(config.PathKindRelative, "dvd")),
+					},
+				},
+			}
+			postResp, err := mediaService.PostMedia(ctx, postReq)
+			exam.Nil(e, env, err).Log(err).Must()
+			id := postResp.(vmapi.PostMedia201JSONResponse).Id
+			const sql = `UPDATE tasks SET state = jsonb_set(state, '{step}', to_jsonb($1::text))`
+			_, err = vmdb.Exec(ctx, db, vmdb.Positional(sql, string(media.IngestionStepCopy)))
+			exam.Nil(e, env, err).Log(err).Must()
+
+			handler := &media.IngestionHandler{
+				Kind:           media.KindDvd,
+				Paths:          paths,
+				CopyChunkBytes: 4000,
+			}
+			result := runIngestion(e, db, handler, id, tt.crashAt)
+			exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Log(tt.loc)
+
+			exam.Equal(e, env, vmtest.FileExists(e, inbox), false).Log(tt.loc)
+			exam.Equal(e, env, vmtest.FileExists(e, paths.MediaDvdIdStaging(config.PathKindAbsolute, id)), false).Log(tt.loc)
+			dvdPath := paths.MediaDvdId(config.PathKindAbsolute, id)
+			for name, content := range files {
+				got, err := os.ReadFile(filepath.Join(dvdPath, name))
+				exam.Nil(e, env, err).Log(err).Log(tt.loc).Must()
+				exam.Equal(e, env, string(got), content).Log(name).Log(tt.loc)
+			}
+			exam.Equal(e, env, vmtest.FileExists(e, filepath.Join(dvdPath, "AUDIO_TS")), true).Log(tt.loc)
+
+			getResp, err := mediaService.GetMedia(ctx, vmapi.GetMediaRequestObject{Id: id})
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, getResp.(vmapi.GetMedia200JSONResponse).Details.Dvd.Path, paths.MediaDvdId(config.PathKindRelative, id)).Log(tt.loc)
+		})
+	}
+}
+
+func TestIngestionHandler_OtherKinds(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	mediaService := NewMediaService(e, pg)
+
+	tests := []struct {
+		loc  exam.Loc
+		name string
+		kind media.Kind
+		// copy starts the task at the copy step instead of the move step.
+		copy bool
+		// create creates the media in the inbox, and returns the details to
+		// post.
+		create func(e exam.E, paths config.Paths) media.MediaPostDetails
+		// wantPath is the relative path of the ingested media.
+		wantPath func(paths config.Paths, id uint32) string
+		// wantFiles are the files of the ingested media, relative to wantPath.
+		wantFiles map[string]string
+	}{
+		{
+			loc:  exam.Here(),
+			name: "Blu-ray",
+			kind: media.KindBluray,
+			create: func(e exam.E, paths config.Paths) media.MediaPostDetails {
+				return media.MediaPostDetails{BlurayInboxPath: Set(NewInboxBluray(e, mediaService, "movie"))}
+			},
+			wantPath: func(paths config.Paths, id uint32) string {
+				return paths.MediaBlurayId(config.PathKindRelative, id)
+			},
+			wantFiles: map[string]string{"BDMV/index.bdmv": "index.bdmv"},
+		},
+		{
+			loc:  exam.Here(),
+			name: "Blu-ray copy",
+			kind: media.KindBluray,
+			copy: true,
+			create: func(e exam.E, paths config.Paths) media.MediaPostDetails {
+				return media.MediaPostDetails{BlurayInboxPath: Set(NewInboxBluray(e, mediaService, "movie"))}
+			},
+			wantPath: func(paths config.Paths, id uint32) string {
+				return paths.MediaBlurayId(config.PathKindRelative, id)
+			},
+			wantFiles: map[string]string{"BDMV/index.bdmv": "index.bdmv"},
+		},
+		{
+			loc:  exam.Here(),
+			name: "video file",
+			kind: media.KindFile,
+			create: func(e exam.E, paths config.Paths) media.MediaPostDetails {
+				return media.MediaPostDetails{FileInboxPath: Set(NewInboxFile(e, mediaService, "movie.mkv"))}
+			},
+			wantPath: func(paths config.Paths, id uint32) string {
+				return filepath.Join(paths.MediaFileId(config.PathKindRelative, id), "movie.mkv")
+			},
+			wantFiles: map[string]string{"": "movie.mkv"},
+		},
+		{
+			loc:  exam.Here(),
+			name: "video file copy",
+			kind: media.KindFile,
+			copy: true,
+			create: func(e exam.E, paths config.Paths) media.MediaPostDetails {
+				return media.MediaPostDetails{FileInboxPath: Set(NewInboxFile(e, mediaService, "movie.mkv"))}
+			},
+			wantPath: func(paths config.Paths, id uint32) string {
+				return filepath.Join(paths.MediaFileId(config.PathKindRelative, id), "movie.mkv")
+			},
+			wantFiles: map[string]string{"": "movie.mkv"},
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			paths := config.Paths{
+				RootDir: e.TempDir(),
+			}
+			if err := paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			mediaService.Paths = paths
+			details := tt.create(e, paths)
+
+			postCtx := media.WithMediaPostDetails(ctx, details)
+			postResp, err := mediaService.PostMedia(postCtx, vmapi.PostMediaRequestObject{Body: &vmapi.MediaPost{}})
+			exam.Nil(e, env, err).Log(err).Log(tt.loc).Must()
+			id := postResp.(vmapi.PostMedia201JSONResponse).Id
+			if tt.copy {
+				const sql = `UPDATE tasks SET state = jsonb_set(state, '{step}', to_jsonb($1::text))`
+				_, err = vmdb.Exec(ctx, db, vmdb.Positional(sql, string(media.IngestionStepCopy)))
+				exam.Nil(e, env, err).Log(err).Must()
+			}
+
+			handler := &media.IngestionHandler{
+				Kind:  tt.kind,
+				Paths: paths,
+			}
+			result := runIngestion(e, db, handler, id, -1)
+			exam.Equal(e, env, result.NewStatus, vmtask.StatusCompleted).Log(result).Log(tt.loc)
+
+			wantPath := tt.wantPath(paths, id)
+			for name, content := range tt.wantFiles {
+				got, err := os.ReadFile(filepath.Join(paths.Absolute(wantPath), name))
+				exam.Nil(e, env, err).Log(err).Log(tt.loc).Must()
+				exam.Equal(e, env, string(got), content).Log(name).Log(tt.loc)
+			}
+			inboxEntries, err := os.ReadDir(paths.Absolute(filepath.Dir(wantInboxPath(details))))
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, len(inboxEntries), 0).Log(tt.loc)
+
+			const sql = `SELECT path FROM media_blurays WHERE media_id = $1 UNION ALL SELECT path FROM media_files WHERE media_id = $1`
+			gotPath, err := vmdb.QueryOne[string](ctx, db, vmdb.Positional(sql, id))
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, gotPath, wantPath).Log(tt.loc)
+		})
+	}
+}
+
+// wantInboxPath returns the inbox path that is set in details.
+func wantInboxPath(details media.MediaPostDetails) string {
+	if details.BlurayInboxPath != nil {
+		return *details.BlurayInboxPath
+	}
+	return *details.FileInboxPath
+}
@@ -0,0 +1,142 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+)
+
+// Kind says what the files of a media record are.  Each kind has its own
+// details table, inbox and media directories, and ingestion and deletion tasks.
+type Kind string
+
+const (
+	// KindDvd is a DVD, as a VIDEO_TS folder or an ISO image.
+	KindDvd Kind = "dvd"
+	// KindBluray is a Blu-ray, as a BDMV folder or an ISO image.
+	KindBluray Kind = "bluray"
+	// KindFile is a single video file, such as an MKV or MP4 file.
+	KindFile Kind = "file"
+)
+
+// Kinds lists every Kind, in the order they are checked.
+var Kinds = []Kind{KindDvd, KindBluray, KindFile}
+
+// IngestionTaskType returns the type of the tasks that ingest media of this kind.
+func (k Kind) IngestionTaskType() string {
+	return string(k) + "_ingestion"
+}
+
+// DeletionTaskType returns the type of the tasks that clean up the files of
+// deleted media of this kind.
+func (k Kind) DeletionTaskType() string {
+	return string(k) + "_deletion"
+}
+
+// table returns the name of the details table of this kind.
+func (k Kind) table() string {
+	return "media_" + string(k) + "s"
+}
+
+// label names this kind in messages.
+func (k Kind) label() string {
+	switch k {
+	case KindDvd:
+		return "DVD"
+	case KindBluray:
+		return "Blu-ray"
+	default:
+		return "video file"
+	}
+}
+
+// inboxPathField is the name of the PostMedia field that holds the inbox path
+// of this kind.
+func (k Kind) inboxPathField() string {
+	return string(k) + "_inbox_path"
+}
+
+// inbox returns the inbox directory of this kind.
+func (k Kind) inbox(p config.Paths, pk config.PathKind) string {
+	switch k {
+	case KindBluray:
+		return p.InboxBluray(pk)
+	case KindFile:
+		return p.InboxFile(pk)
+	default:
+		return p.InboxDvd(pk)
+	}
+}
+
+// inboxName returns the path of the entry with the given name in the inbox of
+// this kind.
+func (k Kind) inboxName(p config.Paths, pk config.PathKind, name string) string {
+	return filepath.Join(k.inbox(p, pk), name)
+}
+
+// mediaDir returns the directory that holds the files of the given media once
+// they are ingested.
+func (k Kind) mediaDir(p config.Paths, pk config.PathKind, mediaId uint32) string {
+	switch k {
+	case KindBluray:
+		return p.MediaBlurayId(pk, mediaId)
+	case KindFile:
+		return p.MediaFileId(pk, mediaId)
+	default:
+		return p.MediaDvdId(pk, mediaId)
+	}
+}
+
+// mediaPath returns the final location of the given media, whose inbox path
+// was inboxPath.  Video files keep their name inside mediaDir, while DVDs and
+// Blu-rays become mediaDir.
+func (k Kind) mediaPath(p config.Paths, pk config.PathKind, mediaId uint32, inboxPath string) string {
+	if k == KindFile {
+		return filepath.Join(k.mediaDir(p, pk, mediaId), filepath.Base(inboxPath))
+	}
+	return k.mediaDir(p, pk, mediaId)
+}
+
+// stagingPath returns the path that the given media is copied to before it is
+// moved to its final location.
+func (k Kind) stagingPath(p config.Paths, pk config.PathKind, mediaId uint32) string {
+	switch k {
+	case KindBluray:
+		return p.MediaBlurayIdStaging(pk, mediaId)
+	case KindFile:
+		return p.MediaFileIdStaging(pk, mediaId)
+	default:
+		return p.MediaDvdIdStaging(pk, mediaId)
+	}
+}
+
+// detect checks that the file or directory at path holds media of this kind.
+// If not, the error says why.
+func (k Kind) detect(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if k == KindFile {
+		if !info.Mode().IsRegular() {
+			return errors.New("not a regular file")
+		}
+		if !vmdisc.IsVideoFile(path) {
+			return fmt.Errorf("extension must be one of %v", vmdisc.VideoFileExts)
+		}
+		return nil
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory")
+	}
+	if k == KindBluray {
+		_, err = vmdisc.DetectBluray(path)
+	} else {
+		_, err = vmdisc.DetectDvd(path)
+	}
+	return err
+}
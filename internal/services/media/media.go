@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/krelinga/video-manager-api/go/vmapi"
 	"github.com/krelinga/video-manager/internal/lib/vmdb"
@@ -58,7 +59,7 @@ func (ms *MediaService) ListMedia(ctx context.Context, request vmapi.ListMediaRe
 			Note:       r.Note,
 		}
 		if r.IsDvd && r.Path != nil {
-			ingestion := taskStatusToDvdIngestion(r.TaskStatus, r.TaskError)
+			ingestion := taskStatusToIngestion(r.TaskStatus, r.TaskError)
 			media.Details = &vmapi.MediaDetails{
 				Dvd: &vmapi.DVD{
 					Path:      *r.Path,
@@ -95,9 +96,31 @@ func (ms *MediaService) PostMedia(ctx context.Context, request vmapi.PostMediaRe
 	}
 
 	// Validate that exactly one detail type is set
-	hasDvd := request.Body.Details.DvdInboxPath != nil
-	if !hasDvd {
-		return nil, vmerr.BadRequest(errors.New("exactly one of DvdInboxPath must be set"))
+	details := MediaPostDetailsFromContext(ctx)
+	inboxPaths := map[Kind]*string{
+		KindDvd:    request.Body.Details.DvdInboxPath,
+		KindBluray: details.BlurayInboxPath,
+		KindFile:   details.FileInboxPath,
+	}
+	var kind Kind
+	var fields []string
+	for _, k := range Kinds {
+		fields = append(fields, k.inboxPathField())
+		if inboxPaths[k] == nil {
+			continue
+		}
+		if kind != "" {
+			kind = ""
+			break
+		}
+		kind = k
+	}
+	if kind == "" {
+		return nil, vmerr.BadRequest(fmt.Errorf("exactly one of %s must be set", strings.Join(fields, ", ")))
+	}
+	inboxPath, err := ms.validateInboxPath(kind, *inboxPaths[kind])
+	if err != nil {
+		return nil, err
 	}
 
 	tx, err := ms.Db.Begin(ctx)
@@ -118,26 +141,21 @@ func (ms *MediaService) PostMedia(ctx context.Context, request vmapi.PostMediaRe
 	}
 
 	// Handle media details
-	if request.Body.Details.DvdInboxPath != nil {
-		dvdPath := *request.Body.Details.DvdInboxPath
-		if dvdPath == "" {
-			return nil, vmerr.BadRequest(errors.New("dvd_inbox_path must be non-empty"))
-		}
-
-		// Check if a DVD with this path already exists
-		const checkPathQuery = "SELECT COUNT(*) FROM media_dvds WHERE path = $1"
-		count, err := vmdb.QueryOne[int](ctx, tx, vmdb.Positional(checkPathQuery, dvdPath))
+	{
+		// Check if media with this path already exists
+		checkPathQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE path = $1", kind.table())
+		count, err := vmdb.QueryOne[int](ctx, tx, vmdb.Positional(checkPathQuery, inboxPath))
 		if err != nil {
-			return nil, fmt.Errorf("could not check for existing DVD path: %w", err)
+			return nil, fmt.Errorf("could not check for existing %s path: %w", kind.label(), err)
 		}
 		if count > 0 {
-			return nil, vmerr.AlreadyExists(errors.New("DVD with the given path already exists"))
+			return nil, vmerr.AlreadyExists(fmt.Errorf("%s with the given path already exists", kind.label()))
 		}
 
-		const insertDvdQuery = "INSERT INTO media_dvds (media_id, path) VALUES ($1, $2)"
-		_, err = vmdb.Exec(ctx, tx, vmdb.Positional(insertDvdQuery, mediaId, dvdPath))
+		insertQuery := fmt.Sprintf("INSERT INTO %s (media_id, path) VALUES ($1, $2)", kind.table())
+		_, err = vmdb.Exec(ctx, tx, vmdb.Positional(insertQuery, mediaId, inboxPath))
 		if err != nil {
-			return nil, fmt.Errorf("failed to insert DVD details: %w", err)
+			return nil, fmt.Errorf("failed to insert %s details: %w", kind.label(), err)
 		}
 	}
 
@@ -167,8 +185,8 @@ func (ms *MediaService) PostMedia(ctx context.Context, request vmapi.PostMediaRe
 		return nil, err
 	}
 
-	if _, err := CreateDvdIngestionTask(ctx, tx, mediaId); err != nil {
-		return nil, fmt.Errorf("could not create DVD ingestion task: %w", err)
+	if _, err := CreateIngestionTask(ctx, tx, kind, mediaId, inboxPath); err != nil {
+		return nil, fmt.Errorf("could not create %s ingestion task: %w", kind.label(), err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -184,14 +202,56 @@ func (ms *MediaService) DeleteMedia(ctx context.Context, request vmapi.DeleteMed
 		return nil, vmerr.BadRequest(errors.New("non-zero id is required"))
 	}
 
+	tx, err := ms.Db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Look up the details before deleting them, so that we know what to clean up on disk.
+	const detailsQuery = `
+		SELECT 'dvd', path FROM media_dvds WHERE media_id = $1
+		UNION ALL
+		SELECT 'bluray', path FROM media_blurays WHERE media_id = $1
+		UNION ALL
+		SELECT 'file', path FROM media_files WHERE media_id = $1
+	`
+	type detailsRow struct {
+		Kind Kind
+		Path string
+	}
+	var deletion *DeletionState
+	details, err := vmdb.QueryOne[detailsRow](ctx, tx, vmdb.Positional(detailsQuery, id))
+	switch {
+	case errors.Is(err, vmdb.ErrNotFound):
+		// No details, so there is nothing on disk to clean up.
+	case err != nil:
+		return nil, fmt.Errorf("could not fetch media details: %w", err)
+	default:
+		deletion, err = planDeletion(ctx, tx, details.Kind, id, details.Path, DeleteMediaOptionsFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	const query = "DELETE FROM media WHERE id = $1;"
-	rowsAffected, err := vmdb.Exec(ctx, ms.Db, vmdb.Positional(query, id))
+	rowsAffected, err := vmdb.Exec(ctx, tx, vmdb.Positional(query, id))
 	if err != nil {
 		return nil, fmt.Errorf("could not delete media: %w", err)
 	}
 	if rowsAffected == 0 {
 		return nil, vmerr.NotFound(fmt.Errorf("media with id %d not found", id))
 	}
+
+	if deletion != nil {
+		if _, err := CreateDeletionTask(ctx, tx, details.Kind, *deletion); err != nil {
+			return nil, fmt.Errorf("could not create %s deletion task: %w", details.Kind.label(), err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
 	return vmapi.DeleteMedia204Response{}, nil
 }
 
@@ -208,8 +268,9 @@ func getMediaCardIds(ctx context.Context, runner vmdb.Runner, mediaId uint32) ([
 	return cardIds, nil
 }
 
-// taskStatusToDvdIngestion converts a task status to DVDIngestion API type.
-func taskStatusToDvdIngestion(status *vmtask.Status, taskError *string) vmapi.DVDIngestion {
+// taskStatusToIngestion converts a task status to the DVDIngestion API type,
+// which is used for every kind of media.
+func taskStatusToIngestion(status *vmtask.Status, taskError *string) vmapi.DVDIngestion {
 	if status == nil {
 		// No task found - treat as pending (this shouldn't happen normally)
 		return vmapi.DVDIngestion{State: vmapi.DVDIngestionStatePending}
@@ -261,7 +322,7 @@ func getMedia(ctx context.Context, tx vmdb.TxRunner, id uint32) (vmapi.Media, er
 		Note:       r.Note,
 	}
 	if r.IsDvd && r.Path != nil {
-		ingestion := taskStatusToDvdIngestion(r.TaskStatus, r.TaskError)
+		ingestion := taskStatusToIngestion(r.TaskStatus, r.TaskError)
 		media.Details = &vmapi.MediaDetails{
 			Dvd: &vmapi.DVD{
 				Path:      *r.Path,
@@ -315,13 +376,17 @@ func (ms *MediaService) PatchMedia(ctx context.Context, request vmapi.PatchMedia
 		return nil, vmerr.BadRequest(errors.New("no patches provided"))
 	}
 
-	currentMedia, err := getMedia(ctx, tx, id)
-	if err != nil {
+	if _, err := getMedia(ctx, tx, id); err != nil {
 		return nil, err
 	}
 
-	for _, patch := range *request.Body {
+	details := MediaPatchDetailsFromContext(ctx)
+	for i, patch := range *request.Body {
 		var fieldsSet int
+		var extra MediaPatchDetails
+		if i < len(details) {
+			extra = details[i]
+		}
 
 		if patch.Note != nil {
 			fieldsSet++
@@ -418,40 +483,18 @@ func (ms *MediaService) PatchMedia(ctx context.Context, request vmapi.PatchMedia
 			}
 		}
 
-		if patch.Dvd != nil {
-			fieldsSet++
-			if currentMedia.Details == nil || currentMedia.Details.Dvd == nil {
-				return nil, vmerr.BadRequest(errors.New("cannot patch DVD fields on a non-DVD media"))
-			}
-			dvdPatch := patch.Dvd
-
-			if dvdPatch.Path == nil {
-				return nil, vmerr.BadRequest(errors.New("path must be set in DVD patch"))
+		pathPatches := map[Kind]*PathPatch{
+			KindDvd:    (*PathPatch)(patch.Dvd),
+			KindBluray: extra.Bluray,
+			KindFile:   extra.File,
+		}
+		for _, kind := range Kinds {
+			if pathPatches[kind] == nil {
+				continue
 			}
-
-			{
-				path := *dvdPatch.Path
-				if path == "" {
-					return nil, vmerr.BadRequest(errors.New("path cannot be empty"))
-				}
-				// Check if another DVD already has this path
-				const checkPathQuery = "SELECT COUNT(*) FROM media_dvds WHERE path = $1 AND media_id != $2"
-				count, err := vmdb.QueryOne[int](ctx, tx, vmdb.Positional(checkPathQuery, path, id))
-				if err != nil {
-					return nil, fmt.Errorf("could not check for existing DVD path: %w", err)
-				}
-				if count > 0 {
-					return nil, vmerr.AlreadyExists(errors.New("DVD with the given path already exists"))
-				}
-
-				const query = "UPDATE media_dvds SET path = $1 WHERE media_id = $2;"
-				rowsAffected, err := vmdb.Exec(ctx, tx, vmdb.Positional(query, path, id))
-				if err != nil {
-					return nil, fmt.Errorf("could not update path: %w", err)
-				}
-				if rowsAffected == 0 {
-					return nil, vmerr.NotFound(fmt.Errorf("DVD details for media id %d not found", id))
-				}
+			fieldsSet++
+			if err := patchPath(ctx, tx, kind, id, pathPatches[kind].Path); err != nil {
+				return nil, err
 			}
 		}
 
@@ -474,3 +517,42 @@ func (ms *MediaService) PatchMedia(ctx context.Context, request vmapi.PatchMedia
 
 	return vmapi.PatchMedia200JSONResponse(media), nil
 }
+
+// patchPath sets the path of the media with the given id, which must be of the
+// given kind.
+func patchPath(ctx context.Context, tx vmdb.Runner, kind Kind, id uint32, path *string) error {
+	checkKindQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE media_id = $1", kind.table())
+	count, err := vmdb.QueryOne[int](ctx, tx, vmdb.Positional(checkKindQuery, id))
+	if err != nil {
+		return fmt.Errorf("could not fetch %s details: %w", kind.label(), err)
+	}
+	if count == 0 {
+		return vmerr.BadRequest(fmt.Errorf("cannot patch %[1]s fields on a non-%[1]s media", kind.label()))
+	}
+
+	if path == nil {
+		return vmerr.BadRequest(fmt.Errorf("path must be set in %s patch", kind.label()))
+	}
+	if *path == "" {
+		return vmerr.BadRequest(errors.New("path cannot be empty"))
+	}
+	// Check if other media of this kind already has this path
+	checkPathQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE path = $1 AND media_id != $2", kind.table())
+	count, err = vmdb.QueryOne[int](ctx, tx, vmdb.Positional(checkPathQuery, *path, id))
+	if err != nil {
+		return fmt.Errorf("could not check for existing %s path: %w", kind.label(), err)
+	}
+	if count > 0 {
+		return vmerr.AlreadyExists(fmt.Errorf("%s with the given path already exists", kind.label()))
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET path = $1 WHERE media_id = $2;", kind.table())
+	rowsAffected, err := vmdb.Exec(ctx, tx, vmdb.Positional(query, *path, id))
+	if err != nil {
+		return fmt.Errorf("could not update path: %w", err)
+	}
+	if rowsAffected == 0 {
+		return vmerr.NotFound(fmt.Errorf("%s details for media id %d not found", kind.label(), id))
+	}
+	return nil
+}
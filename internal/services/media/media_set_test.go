@@ -533,7 +533,7 @@ func TestPatchMediaSet(t *testing.T) {
 		postReq := vmapi.PostMediaRequestObject{
 			Body: &vmapi.MediaPost{
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/path/to/media"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "media")),
 				},
 			},
 		}
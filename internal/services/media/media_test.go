@@ -2,12 +2,20 @@ package media_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/krelinga/go-libs/deep"
 	"github.com/krelinga/go-libs/exam"
 	"github.com/krelinga/go-libs/match"
 	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
 	"github.com/krelinga/video-manager/internal/lib/vmerr"
 	"github.com/krelinga/video-manager/internal/lib/vmtest"
 	"github.com/krelinga/video-manager/internal/services/catalog"
@@ -15,11 +23,63 @@ import (
 )
 
 func NewMediaService(e exam.E, pg *vmtest.Postgres) *media.MediaService {
+	paths := config.Paths{
+		RootDir: e.TempDir(),
+	}
+	if err := paths.Bootstrap(); err != nil {
+		e.Fatalf("failed to bootstrap paths: %v", err)
+	}
 	return &media.MediaService{
-		Db: pg.DbRunner(e),
+		Db:    pg.DbRunner(e),
+		Paths: paths,
 	}
 }
 
+// InboxDvdTitles are the titles of the DVDs created by NewInboxDvd.
+var InboxDvdTitles = []vmdisc.DvdTitle{
+	{
+		Number:            1,
+		TitleSet:          1,
+		Duration:          90 * time.Minute,
+		Chapters:          12,
+		Angles:            1,
+		Audio:             []vmdisc.AudioStream{{Language: "en", Codec: "ac3", Channels: 6}},
+		SubtitleLanguages: []string{"en"},
+	},
+}
+
+// NewInboxDvd creates a minimal DVD with the given name in the inbox of
+// service, and returns the path to post it with.
+func NewInboxDvd(e exam.E, service *media.MediaService, name string) string {
+	path := service.Paths.InboxDvdName(config.PathKindRelative, name)
+	vmtest.WriteDvd(e, service.Paths.Absolute(path), InboxDvdTitles)
+	return path
+}
+
+// NewInboxBluray creates a Blu-ray with the given name in the inbox of
+// service, and returns its relative path.
+func NewInboxBluray(e exam.E, service *media.MediaService, name string) string {
+	path := service.Paths.InboxBlurayName(config.PathKindRelative, name)
+	bdmv := filepath.Join(service.Paths.Absolute(path), "BDMV")
+	if err := os.MkdirAll(bdmv, 0755); err != nil {
+		e.Fatalf("could not create inbox blu-ray directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(bdmv, "index.bdmv"), []byte("index.bdmv"), 0644); err != nil {
+		e.Fatalf("could not write inbox blu-ray file: %v", err)
+	}
+	return path
+}
+
+// NewInboxFile creates a video file with the given name in the inbox of
+// service, and returns its relative path.  The file contains its name.
+func NewInboxFile(e exam.E, service *media.MediaService, name string) string {
+	path := service.Paths.InboxFileName(config.PathKindRelative, name)
+	if err := os.WriteFile(service.Paths.Absolute(path), []byte(name), 0644); err != nil {
+		e.Fatalf("could not write inbox video file: %v", err)
+	}
+	return path
+}
+
 func NewCatalogService(e exam.E, pg *vmtest.Postgres) *catalog.CatalogService {
 	return &catalog.CatalogService{
 		Db: pg.DbRunner(e),
@@ -58,7 +118,7 @@ func TestListMedia(t *testing.T) {
 		postReq := vmapi.PostMediaRequestObject{
 			Body: &vmapi.MediaPost{
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/path/to/dvd"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "to-dvd")),
 				},
 			},
 		}
@@ -82,7 +142,7 @@ func TestListMedia(t *testing.T) {
 									Fields: map[deep.Field]match.Matcher{
 										deep.NamedField("Dvd"): match.Pointer(match.Struct{
 											Fields: map[deep.Field]match.Matcher{
-												deep.NamedField("Path"): match.Equal("/path/to/dvd"),
+												deep.NamedField("Path"): match.Equal("inbox/dvd/to-dvd"),
 												deep.NamedField("Ingestion"): match.Equal(vmapi.DVDIngestion{
 													State: vmapi.DVDIngestionStatePending,
 												}),
@@ -118,7 +178,7 @@ func TestListMedia(t *testing.T) {
 			Body: &vmapi.MediaPost{
 				CardIds: []uint32{cardId},
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/path/to/dvd"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "to-dvd")),
 				},
 			},
 		}
@@ -152,12 +212,12 @@ func TestListMedia(t *testing.T) {
 	e.Run("continuation token", func(e exam.E) {
 		defer pg.Reset(e)
 		// Create multiple media entries
-		paths := []string{"/path/a", "/path/b", "/path/c"}
-		for _, path := range paths {
+		names := []string{"a", "b", "c"}
+		for _, name := range names {
 			postReq := vmapi.PostMediaRequestObject{
 				Body: &vmapi.MediaPost{
 					Details: vmapi.MediaPostDetails{
-						DvdInboxPath: Set(path),
+						DvdInboxPath: Set(NewInboxDvd(e, service, name)),
 					},
 				},
 			}
@@ -231,6 +291,25 @@ func TestPostMedia(t *testing.T) {
 		return cardResp.(vmapi.PostCard201JSONResponse).Id
 	}
 
+	// Helper to create an episode card, along with its series and season
+	createEpisodeCard := func(e exam.E) uint32 {
+		post := func(name string, details catalog.CardPostDetails) uint32 {
+			cardReq := vmapi.PostCardRequestObject{
+				Body: &vmapi.CardPost{Name: name},
+			}
+			cardResp, err := catalogService.PostCard(catalog.WithCardPostDetails(ctx, details), cardReq)
+			exam.Nil(e, env, err).Log(err).Must()
+			return cardResp.(vmapi.PostCard201JSONResponse).Id
+		}
+		seriesId := post("Test Series", catalog.CardPostDetails{Series: &catalog.Series{}})
+		seasonId := post("Test Season", catalog.CardPostDetails{
+			Season: &catalog.Season{SeriesId: seriesId, SeasonNumber: 1},
+		})
+		return post("Test Episode", catalog.CardPostDetails{
+			Episode: &catalog.Episode{SeasonId: seasonId, EpisodeNumber: 1},
+		})
+	}
+
 	tests := []struct {
 		loc      exam.Loc
 		name     string
@@ -272,6 +351,161 @@ func TestPostMedia(t *testing.T) {
 			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
 			wantResp: match.Nil(),
 		},
+		{
+			loc:  exam.Here(),
+			name: "absolute dvd path",
+			setup: func(e exam.E) *RequestBody {
+				path := NewInboxDvd(e, service, "absolute")
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(service.Paths.Absolute(path)),
+					},
+				}
+			},
+			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
+			wantResp: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path outside inbox",
+			setup: func(e exam.E) *RequestBody {
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set("media/dvd"),
+					},
+				}
+			},
+			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
+			wantResp: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path escapes inbox",
+			setup: func(e exam.E) *RequestBody {
+				NewInboxDvd(e, service, "escape")
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set("inbox/dvd/../../inbox/dvd/escape/../.."),
+					},
+				}
+			},
+			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
+			wantResp: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path symlinked outside inbox",
+			setup: func(e exam.E) *RequestBody {
+				target := e.TempDir()
+				if err := os.MkdirAll(filepath.Join(target, "VIDEO_TS"), 0755); err != nil {
+					e.Fatalf("could not create directory: %v", err)
+				}
+				link := service.Paths.InboxDvdName(config.PathKindRelative, "link")
+				if err := os.Symlink(target, service.Paths.Absolute(link)); err != nil {
+					e.Fatalf("could not create symlink: %v", err)
+				}
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(link),
+					},
+				}
+			},
+			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
+			wantResp: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path does not exist",
+			setup: func(e exam.E) *RequestBody {
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(service.Paths.InboxDvdName(config.PathKindRelative, "missing")),
+					},
+				}
+			},
+			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
+			wantResp: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path is not a dvd",
+			setup: func(e exam.E) *RequestBody {
+				path := service.Paths.InboxDvdName(config.PathKindRelative, "not-a-dvd")
+				if err := os.MkdirAll(filepath.Join(service.Paths.Absolute(path), "photos"), 0755); err != nil {
+					e.Fatalf("could not create directory: %v", err)
+				}
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(path),
+					},
+				}
+			},
+			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
+			wantResp: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path without VOB files",
+			setup: func(e exam.E) *RequestBody {
+				path := NewInboxDvd(e, service, "no-vobs")
+				if err := os.Remove(filepath.Join(service.Paths.Absolute(path), "VIDEO_TS", "VTS_01_1.VOB")); err != nil {
+					e.Fatalf("could not remove file: %v", err)
+				}
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(path),
+					},
+				}
+			},
+			wantErr:  vmtest.HttpError(vmerr.ProblemBadRequest),
+			wantResp: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path with iso image",
+			setup: func(e exam.E) *RequestBody {
+				path := service.Paths.InboxDvdName(config.PathKindRelative, "iso")
+				if err := os.MkdirAll(service.Paths.Absolute(path), 0755); err != nil {
+					e.Fatalf("could not create directory: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(service.Paths.Absolute(path), "movie.iso"), []byte("iso"), 0644); err != nil {
+					e.Fatalf("could not write file: %v", err)
+				}
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(path),
+					},
+				}
+			},
+			wantErr:  match.Nil(),
+			wantResp: match.Not(match.Nil()),
+		},
+		{
+			loc:  exam.Here(),
+			name: "dvd path is cleaned",
+			setup: func(e exam.E) *RequestBody {
+				path := NewInboxDvd(e, service, "unclean")
+				return &RequestBody{
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set("./" + path + "/"),
+					},
+				}
+			},
+			wantErr: match.Nil(),
+			wantResp: match.Interface(match.Struct{
+				Fields: map[deep.Field]match.Matcher{
+					deep.NamedField("Details"): match.Pointer(match.Struct{
+						Fields: map[deep.Field]match.Matcher{
+							deep.NamedField("Dvd"): match.Pointer(match.Struct{
+								Fields: map[deep.Field]match.Matcher{
+									deep.NamedField("Path"): match.Equal("inbox/dvd/unclean"),
+								},
+							}),
+						},
+					}),
+				},
+			}),
+		},
 		{
 			loc:  exam.Here(),
 			name: "duplicate dvd path",
@@ -280,7 +514,7 @@ func TestPostMedia(t *testing.T) {
 				postReq := Request{
 					Body: &RequestBody{
 						Details: vmapi.MediaPostDetails{
-							DvdInboxPath: Set("/path/to/dvd"),
+							DvdInboxPath: Set(NewInboxDvd(e, service, "to-dvd")),
 						},
 					},
 				}
@@ -289,7 +523,7 @@ func TestPostMedia(t *testing.T) {
 
 				return &RequestBody{
 					Details: vmapi.MediaPostDetails{
-						DvdInboxPath: Set("/path/to/dvd"),
+						DvdInboxPath: Set(NewInboxDvd(e, service, "to-dvd")),
 					},
 				}
 			},
@@ -302,7 +536,7 @@ func TestPostMedia(t *testing.T) {
 			setup: func(e exam.E) *RequestBody {
 				return &RequestBody{
 					Details: vmapi.MediaPostDetails{
-						DvdInboxPath: Set("/path/to/new/dvd"),
+						DvdInboxPath: Set(NewInboxDvd(e, service, "to-new-dvd")),
 					},
 				}
 			},
@@ -314,7 +548,7 @@ func TestPostMedia(t *testing.T) {
 						Fields: map[deep.Field]match.Matcher{
 							deep.NamedField("Dvd"): match.Pointer(match.Struct{
 								Fields: map[deep.Field]match.Matcher{
-									deep.NamedField("Path"): match.Equal("/path/to/new/dvd"),
+									deep.NamedField("Path"): match.Equal("inbox/dvd/to-new-dvd"),
 									deep.NamedField("Ingestion"): match.Equal(vmapi.DVDIngestion{
 										State: vmapi.DVDIngestionStatePending,
 									}),
@@ -339,7 +573,7 @@ func TestPostMedia(t *testing.T) {
 				return &RequestBody{
 					Note: &note,
 					Details: vmapi.MediaPostDetails{
-						DvdInboxPath: Set("/path/with/note"),
+						DvdInboxPath: Set(NewInboxDvd(e, service, "with-note")),
 					},
 				}
 			},
@@ -359,7 +593,31 @@ func TestPostMedia(t *testing.T) {
 				return &RequestBody{
 					CardIds: []uint32{cardId},
 					Details: vmapi.MediaPostDetails{
-						DvdInboxPath: Set("/path/with/card"),
+						DvdInboxPath: Set(NewInboxDvd(e, service, "with-card")),
+					},
+				}
+			},
+			wantErr: match.Nil(),
+			wantResp: match.Interface(match.Struct{
+				Fields: map[deep.Field]match.Matcher{
+					deep.NamedField("Id"): match.GreaterThan(uint32(0)),
+					deep.NamedField("CardIds"): match.Slice{
+						Matchers: []match.Matcher{
+							match.GreaterThan(uint32(0)),
+						},
+					},
+				},
+			}),
+		},
+		{
+			loc:  exam.Here(),
+			name: "successful media creation with episode card link",
+			setup: func(e exam.E) *RequestBody {
+				cardId := createEpisodeCard(e)
+				return &RequestBody{
+					CardIds: []uint32{cardId},
+					Details: vmapi.MediaPostDetails{
+						DvdInboxPath: Set(NewInboxDvd(e, service, "with-episode")),
 					},
 				}
 			},
@@ -382,7 +640,7 @@ func TestPostMedia(t *testing.T) {
 				return &RequestBody{
 					CardIds: []uint32{9999},
 					Details: vmapi.MediaPostDetails{
-						DvdInboxPath: Set("/path/invalid/card"),
+						DvdInboxPath: Set(NewInboxDvd(e, service, "invalid-card")),
 					},
 				}
 			},
@@ -409,6 +667,136 @@ func TestPostMedia(t *testing.T) {
 	}
 }
 
+func TestPostMedia_OtherKinds(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewMediaService(e, pg)
+	db := pg.DbRunner(e)
+
+	tests := []struct {
+		loc     exam.Loc
+		name    string
+		setup   func(exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails)
+		wantErr match.Matcher
+		// wantTable is the details table that should hold the new media.
+		wantTable string
+	}{
+		{
+			loc:  exam.Here(),
+			name: "Blu-ray",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				return vmapi.MediaPostDetails{}, media.MediaPostDetails{
+					BlurayInboxPath: Set(NewInboxBluray(e, service, "movie")),
+				}
+			},
+			wantErr:   match.Nil(),
+			wantTable: "media_blurays",
+		},
+		{
+			loc:  exam.Here(),
+			name: "video file",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				return vmapi.MediaPostDetails{}, media.MediaPostDetails{
+					FileInboxPath: Set(NewInboxFile(e, service, "movie.mkv")),
+				}
+			},
+			wantErr:   match.Nil(),
+			wantTable: "media_files",
+		},
+		{
+			loc:  exam.Here(),
+			name: "DVD and Blu-ray",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				dvd := vmapi.MediaPostDetails{DvdInboxPath: Set(NewInboxDvd(e, service, "movie"))}
+				return dvd, media.MediaPostDetails{BlurayInboxPath: Set(NewInboxBluray(e, service, "movie"))}
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:  exam.Here(),
+			name: "Blu-ray and video file",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				return vmapi.MediaPostDetails{}, media.MediaPostDetails{
+					BlurayInboxPath: Set(NewInboxBluray(e, service, "movie")),
+					FileInboxPath:   Set(NewInboxFile(e, service, "movie.mkv")),
+				}
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:  exam.Here(),
+			name: "DVD posted as Blu-ray",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				dvd := NewInboxDvd(e, service, "movie")
+				bluray := service.Paths.InboxBlurayName(config.PathKindRelative, "movie")
+				if err := os.Rename(service.Paths.Absolute(dvd), service.Paths.Absolute(bluray)); err != nil {
+					e.Fatalf("could not move DVD: %v", err)
+				}
+				return vmapi.MediaPostDetails{}, media.MediaPostDetails{BlurayInboxPath: Set(bluray)}
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:  exam.Here(),
+			name: "Blu-ray path in the DVD inbox",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				return vmapi.MediaPostDetails{}, media.MediaPostDetails{
+					BlurayInboxPath: Set(NewInboxDvd(e, service, "movie")),
+				}
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:  exam.Here(),
+			name: "file that is not a video",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				return vmapi.MediaPostDetails{}, media.MediaPostDetails{
+					FileInboxPath: Set(NewInboxFile(e, service, "movie.srt")),
+				}
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:  exam.Here(),
+			name: "directory posted as video file",
+			setup: func(e exam.E) (vmapi.MediaPostDetails, media.MediaPostDetails) {
+				path := service.Paths.InboxFileName(config.PathKindRelative, "movie.mkv")
+				if err := os.Mkdir(service.Paths.Absolute(path), 0755); err != nil {
+					e.Fatalf("could not create directory: %v", err)
+				}
+				return vmapi.MediaPostDetails{}, media.MediaPostDetails{FileInboxPath: Set(path)}
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			defer pg.Reset(e)
+			service.Paths = config.Paths{RootDir: e.TempDir()}
+			if err := service.Paths.Bootstrap(); err != nil {
+				e.Fatalf("failed to bootstrap paths: %v", err)
+			}
+			details, extra := tt.setup(e)
+			req := vmapi.PostMediaRequestObject{
+				Body: &vmapi.MediaPost{Details: details},
+			}
+			resp, err := service.PostMedia(media.WithMediaPostDetails(ctx, extra), req)
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			if tt.wantTable == "" {
+				return
+			}
+			id := resp.(vmapi.PostMedia201JSONResponse).Id
+			sql := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE media_id = $1", tt.wantTable)
+			count, err := vmdb.QueryOne[int](ctx, db, vmdb.Positional(sql, id))
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, count, 1).Log(tt.loc)
+		})
+	}
+}
+
 func TestDeleteMedia(t *testing.T) {
 	ctx := context.Background()
 	e := exam.New(t)
@@ -416,15 +804,50 @@ func TestDeleteMedia(t *testing.T) {
 	pg := vmtest.PostgresOnce(e)
 	defer pg.Reset(e)
 	service := NewMediaService(e, pg)
+	db := pg.DbRunner(e)
 
 	type Request = vmapi.DeleteMediaRequestObject
 
+	postDvd := func(e exam.E, name string) uint32 {
+		postReq := vmapi.PostMediaRequestObject{
+			Body: &vmapi.MediaPost{
+				Details: vmapi.MediaPostDetails{
+					DvdInboxPath: Set(NewInboxDvd(e, service, name)),
+				},
+			},
+		}
+		resp, err := service.PostMedia(ctx, postReq)
+		exam.Nil(e, env, err).Log(err).Must()
+		return resp.(vmapi.PostMedia201JSONResponse).Id
+	}
+	taskStates := func(e exam.E, taskType string) []string {
+		const sql = "SELECT state::text FROM tasks WHERE task_type = $1 ORDER BY id"
+		var states []string
+		err := vmdb.Query(ctx, db, vmdb.Positional(sql, taskType), func(state string) bool {
+			states = append(states, state)
+			return true
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		return states
+	}
+	deletionStates := func(e exam.E) []media.DeletionState {
+		var states []media.DeletionState
+		for _, raw := range taskStates(e, media.TaskTypeDvdDeletion) {
+			var state media.DeletionState
+			err := json.Unmarshal([]byte(raw), &state)
+			exam.Nil(e, env, err).Log(err).Must()
+			states = append(states, state)
+		}
+		return states
+	}
+
 	tests := []struct {
 		loc     exam.Loc
 		name    string
+		opts    media.DeleteMediaOptions
 		setup   func(exam.E) uint32
 		wantErr match.Matcher
-		check   func(exam.E)
+		check   func(exam.E, uint32)
 	}{
 		{
 			loc:  exam.Here(),
@@ -449,7 +872,7 @@ func TestDeleteMedia(t *testing.T) {
 				postReq := vmapi.PostMediaRequestObject{
 					Body: &vmapi.MediaPost{
 						Details: vmapi.MediaPostDetails{
-							DvdInboxPath: Set("/path/to/delete"),
+							DvdInboxPath: Set(NewInboxDvd(e, service, "to-delete")),
 						},
 					},
 				}
@@ -457,7 +880,7 @@ func TestDeleteMedia(t *testing.T) {
 				exam.Nil(e, env, err).Log(err).Must()
 				return resp.(vmapi.PostMedia201JSONResponse).Id
 			},
-			check: func(e exam.E) {
+			check: func(e exam.E, id uint32) {
 				listReq := vmapi.ListMediaRequestObject{}
 				listResp, err := service.ListMedia(ctx, listReq)
 				exam.Nil(e, env, err).Log(err).Must()
@@ -465,6 +888,61 @@ func TestDeleteMedia(t *testing.T) {
 			},
 			wantErr: match.Nil(),
 		},
+		{
+			loc:  exam.Here(),
+			name: "deletion schedules cleanup of the DVD",
+			setup: func(e exam.E) uint32 {
+				return postDvd(e, "delete-me")
+			},
+			check: func(e exam.E, id uint32) {
+				exam.Equal(e, env, len(taskStates(e, media.TaskTypeDvdIngestion)), 0)
+				exam.Equal(e, env, deletionStates(e), []media.DeletionState{
+					{MediaId: id, Path: "inbox/dvd/delete-me"},
+				})
+			},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "restore ingested DVD to inbox",
+			opts: media.DeleteMediaOptions{RestoreToInbox: true},
+			setup: func(e exam.E) uint32 {
+				id := postDvd(e, "restore-me")
+				// Pretend that ingestion has completed.
+				const sql = "UPDATE media_dvds SET path = $2 WHERE media_id = $1"
+				_, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, id, fmt.Sprintf("media/dvd/%d", id)))
+				exam.Nil(e, env, err).Log(err).Must()
+				_, err = vmdb.Exec(ctx, db, vmdb.Constant("UPDATE tasks SET status = 'completed'"))
+				exam.Nil(e, env, err).Log(err).Must()
+				return id
+			},
+			check: func(e exam.E, id uint32) {
+				exam.Equal(e, env, len(taskStates(e, media.TaskTypeDvdIngestion)), 0)
+				exam.Equal(e, env, deletionStates(e), []media.DeletionState{
+					{MediaId: id, Path: fmt.Sprintf("media/dvd/%d", id), RestorePath: "inbox/dvd/restore-me"},
+				})
+			},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:  exam.Here(),
+			name: "ingestion in progress",
+			setup: func(e exam.E) uint32 {
+				id := postDvd(e, "busy")
+				const sql = "UPDATE tasks SET status = 'running', worker_id = 'w', lease_expires_at = NOW() + INTERVAL '1 minute'"
+				_, err := vmdb.Exec(ctx, db, vmdb.Constant(sql))
+				exam.Nil(e, env, err).Log(err).Must()
+				return id
+			},
+			check: func(e exam.E, id uint32) {
+				// Nothing was deleted.
+				_, err := service.GetMedia(ctx, vmapi.GetMediaRequestObject{Id: id})
+				exam.Nil(e, env, err).Log(err)
+				exam.Equal(e, env, len(taskStates(e, media.TaskTypeDvdIngestion)), 1)
+				exam.Equal(e, env, len(deletionStates(e)), 0)
+			},
+			wantErr: vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
 	}
 
 	for _, tt := range tests {
@@ -475,10 +953,10 @@ func TestDeleteMedia(t *testing.T) {
 			req := Request{
 				Id: id,
 			}
-			_, err := service.DeleteMedia(ctx, req)
+			_, err := service.DeleteMedia(media.WithDeleteMediaOptions(ctx, tt.opts), req)
 			exam.Match(e, env, err, tt.wantErr).Log(err)
 			if tt.check != nil {
-				tt.check(e)
+				tt.check(e, id)
 			}
 		})
 	}
@@ -517,7 +995,7 @@ func TestGetMedia(t *testing.T) {
 		postReq := vmapi.PostMediaRequestObject{
 			Body: &vmapi.MediaPost{
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/path/to/get"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "to-get")),
 				},
 			},
 		}
@@ -538,7 +1016,7 @@ func TestGetMedia(t *testing.T) {
 					Fields: map[deep.Field]match.Matcher{
 						deep.NamedField("Dvd"): match.Pointer(match.Struct{
 							Fields: map[deep.Field]match.Matcher{
-								deep.NamedField("Path"): match.Equal("/path/to/get"),
+								deep.NamedField("Path"): match.Equal("inbox/dvd/to-get"),
 								deep.NamedField("Ingestion"): match.Equal(vmapi.DVDIngestion{
 									State: vmapi.DVDIngestionStatePending,
 								}),
@@ -569,7 +1047,7 @@ func TestGetMedia(t *testing.T) {
 			Body: &vmapi.MediaPost{
 				CardIds: []uint32{cardId},
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/path/with/cards"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "with-cards")),
 				},
 			},
 		}
@@ -612,7 +1090,7 @@ func TestPatchMedia(t *testing.T) {
 		postReq := vmapi.PostMediaRequestObject{
 			Body: &vmapi.MediaPost{
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/path/to/patch"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "to-patch")),
 				},
 			},
 		}
@@ -775,7 +1253,7 @@ func TestPatchMedia(t *testing.T) {
 			Body: &vmapi.MediaPost{
 				CardIds: []uint32{cardId},
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/path/with/card"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "with-card")),
 				},
 			},
 		}
@@ -852,7 +1330,7 @@ func TestPatchMedia(t *testing.T) {
 		postReq := vmapi.PostMediaRequestObject{
 			Body: &vmapi.MediaPost{
 				Details: vmapi.MediaPostDetails{
-					DvdInboxPath: Set("/existing/path"),
+					DvdInboxPath: Set(NewInboxDvd(e, service, "existing-path")),
 				},
 			},
 		}
@@ -861,7 +1339,7 @@ func TestPatchMedia(t *testing.T) {
 
 		// Create second media
 		id := createMedia(e)
-		newPath := "/existing/path"
+		newPath := "inbox/dvd/existing-path"
 		req := Request{
 			Id: id,
 			Body: &[]Patch{
@@ -0,0 +1,441 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmbody"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmdisc"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// QueryRestoreToInbox is the DeleteMedia query parameter that sets
+// DeleteMediaOptions.RestoreToInbox.
+const QueryRestoreToInbox = "restore_to_inbox"
+
+// DeleteMediaOptions controls what DeleteMedia does with the files of the
+// deleted media.
+type DeleteMediaOptions struct {
+	// RestoreToInbox moves a DVD back to the inbox instead of removing it.
+	RestoreToInbox bool
+}
+
+type deleteMediaOptionsKey struct{}
+
+// WithDeleteMediaOptions returns a copy of ctx that carries opts to DeleteMedia.
+func WithDeleteMediaOptions(ctx context.Context, opts DeleteMediaOptions) context.Context {
+	return context.WithValue(ctx, deleteMediaOptionsKey{}, opts)
+}
+
+// DeleteMediaOptionsFromContext returns the options attached to ctx by
+// WithDeleteMediaOptions, or the zero value if there are none.
+func DeleteMediaOptionsFromContext(ctx context.Context) DeleteMediaOptions {
+	opts, _ := ctx.Value(deleteMediaOptionsKey{}).(DeleteMediaOptions)
+	return opts
+}
+
+// MediaPostDetails holds the fields of the PostMedia details that
+// vmapi.MediaPostDetails can't express yet.  Like the others, at most one of
+// them may be set.
+type MediaPostDetails struct {
+	BlurayInboxPath *string `json:"bluray_inbox_path,omitempty"`
+	FileInboxPath   *string `json:"file_inbox_path,omitempty"`
+}
+
+type mediaPostDetailsKey struct{}
+
+// WithMediaPostDetails returns a copy of ctx that carries details to PostMedia.
+func WithMediaPostDetails(ctx context.Context, details MediaPostDetails) context.Context {
+	return context.WithValue(ctx, mediaPostDetailsKey{}, details)
+}
+
+// MediaPostDetailsFromContext returns the details attached to ctx by
+// WithMediaPostDetails, or the zero value if there are none.
+func MediaPostDetailsFromContext(ctx context.Context) MediaPostDetails {
+	details, _ := ctx.Value(mediaPostDetailsKey{}).(MediaPostDetails)
+	return details
+}
+
+// PathPatch changes the path of a Blu-ray or video file, like vmapi.DVDPatch
+// does for a DVD.
+type PathPatch struct {
+	Path *string `json:"path,omitempty"`
+}
+
+// MediaPatchDetails holds the fields of one PatchMedia patch that
+// vmapi.MediaPatch can't express yet.
+type MediaPatchDetails struct {
+	Bluray *PathPatch `json:"bluray,omitempty"`
+	File   *PathPatch `json:"file,omitempty"`
+}
+
+type mediaPatchDetailsKey struct{}
+
+// WithMediaPatchDetails returns a copy of ctx that carries details to
+// PatchMedia.  The entries of details match the patches in the request body by
+// index.
+func WithMediaPatchDetails(ctx context.Context, details []MediaPatchDetails) context.Context {
+	return context.WithValue(ctx, mediaPatchDetailsKey{}, details)
+}
+
+// MediaPatchDetailsFromContext returns the details attached to ctx by
+// WithMediaPatchDetails, or nil if there are none.
+func MediaPatchDetailsFromContext(ctx context.Context) []MediaPatchDetails {
+	details, _ := ctx.Value(mediaPatchDetailsKey{}).([]MediaPatchDetails)
+	return details
+}
+
+// StrictMiddleware adds these parts of the media API to the handlers:
+//   - the restore_to_inbox query parameter of DeleteMedia.
+//   - details.bluray_inbox_path and details.file_inbox_path in the body of
+//     PostMedia, which needs vmbody.Middleware.
+//   - bluray and file patches in the body of PatchMedia, which needs
+//     vmbody.Middleware.
+//   - details.bluray and details.file of the media returned by GetMedia,
+//     ListMedia, PostMedia and PatchMedia.
+//   - the ingestion progress of the media returned by GetMedia and ListMedia,
+//     as details.<kind>.ingestion.progress.
+//   - the scanned titles of the DVDs returned by GetMedia and ListMedia, as
+//     details.dvd.titles.
+func (ms *MediaService) StrictMiddleware(f vmapi.StrictHandlerFunc, operationID string) vmapi.StrictHandlerFunc {
+	switch operationID {
+	case "DeleteMedia":
+		return deleteOptionsMiddleware(f)
+	case "PostMedia":
+		return ms.detailsMiddleware(postDetailsMiddleware(f))
+	case "PatchMedia":
+		return ms.detailsMiddleware(patchDetailsMiddleware(f))
+	case "GetMedia", "ListMedia":
+		return ms.detailsMiddleware(f)
+	default:
+		return f
+	}
+}
+
+func deleteOptionsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		query := r.URL.Query()
+		if query.Has(QueryRestoreToInbox) {
+			restore, err := strconv.ParseBool(query.Get(QueryRestoreToInbox))
+			if err != nil {
+				return nil, vmerr.BadRequest(fmt.Errorf("invalid %s %q: %w", QueryRestoreToInbox, query.Get(QueryRestoreToInbox), err))
+			}
+			ctx = WithDeleteMediaOptions(ctx, DeleteMediaOptions{RestoreToInbox: restore})
+		}
+		return f(ctx, w, r, request)
+	}
+}
+
+func postDetailsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		if body, ok := vmbody.FromContext(ctx); ok {
+			var post struct {
+				Details MediaPostDetails `json:"details"`
+			}
+			if err := json.Unmarshal(body, &post); err != nil {
+				return nil, vmerr.BadRequest(fmt.Errorf("can't decode JSON body: %w", err))
+			}
+			ctx = WithMediaPostDetails(ctx, post.Details)
+		}
+		return f(ctx, w, r, request)
+	}
+}
+
+func patchDetailsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		if body, ok := vmbody.FromContext(ctx); ok {
+			var patches []MediaPatchDetails
+			if err := json.Unmarshal(body, &patches); err != nil {
+				return nil, vmerr.BadRequest(fmt.Errorf("can't decode JSON body: %w", err))
+			}
+			ctx = WithMediaPatchDetails(ctx, patches)
+		}
+		return f(ctx, w, r, request)
+	}
+}
+
+func (ms *MediaService) detailsMiddleware(f vmapi.StrictHandlerFunc) vmapi.StrictHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		response, err := f(ctx, w, r, request)
+		if err != nil {
+			return response, err
+		}
+		switch resp := response.(type) {
+		case vmapi.GetMedia200JSONResponse:
+			m, err := ms.extend(ctx, []vmapi.Media{vmapi.Media(resp)})
+			if err != nil || m == nil {
+				return response, err
+			}
+			return getExtendedMediaResponse(m[0]), nil
+		case vmapi.PostMedia201JSONResponse:
+			m, err := ms.extend(ctx, []vmapi.Media{vmapi.Media(resp)})
+			if err != nil || m == nil {
+				return response, err
+			}
+			return postExtendedMediaResponse(m[0]), nil
+		case vmapi.PatchMedia200JSONResponse:
+			m, err := ms.extend(ctx, []vmapi.Media{vmapi.Media(resp)})
+			if err != nil || m == nil {
+				return response, err
+			}
+			return patchExtendedMediaResponse(m[0]), nil
+		case vmapi.ListMedia200JSONResponse:
+			m, err := ms.extend(ctx, resp.Media)
+			if err != nil || m == nil {
+				return response, err
+			}
+			page := extendedMediaPage{
+				MediaPage: vmapi.MediaPage(resp),
+				Media:     m,
+			}
+			return listExtendedMediaResponse(page), nil
+		default:
+			return response, nil
+		}
+	}
+}
+
+// extend adds the details, ingestion progress and DVD titles that vmapi.Media
+// can't express to each of media.  It returns nil if there is nothing to add.
+func (ms *MediaService) extend(ctx context.Context, media []vmapi.Media) ([]extendedMedia, error) {
+	ids := make([]uint32, len(media))
+	for i, m := range media {
+		ids[i] = m.Id
+	}
+	details, err := getPathDetails(ctx, ms.Db, ids)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := getIngestionProgress(ctx, ms.Db, ids)
+	if err != nil {
+		return nil, err
+	}
+	titles, err := GetDvdTitles(ctx, ms.Db, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 && len(progress) == 0 && len(titles) == 0 {
+		return nil, nil
+	}
+	out := make([]extendedMedia, len(media))
+	for i, m := range media {
+		out[i] = extend(m, details[m.Id], progress, titles[m.Id])
+	}
+	return out, nil
+}
+
+// getPathDetails returns the Blu-ray and video file details of the given
+// media, by media ID.
+func getPathDetails(ctx context.Context, db vmdb.Runner, mediaIds []uint32) (map[uint32]kindDetails, error) {
+	const sql = `
+		SELECT d.media_id, d.kind, d.path, t.status, t.error
+		FROM (
+			SELECT media_id, 'bluray' AS kind, path FROM media_blurays WHERE media_id = ANY($1)
+			UNION ALL
+			SELECT media_id, 'file' AS kind, path FROM media_files WHERE media_id = ANY($1)
+		) d
+		LEFT JOIN tasks t ON t.task_type = d.kind || '_ingestion' AND (t.state->>'media_id')::integer = d.media_id
+	`
+	type row struct {
+		MediaId    uint32
+		Kind       Kind
+		Path       string
+		TaskStatus *vmtask.Status
+		TaskError  *string
+	}
+	details := make(map[uint32]kindDetails)
+	err := vmdb.Query(ctx, db, vmdb.Positional(sql, mediaIds), func(r row) bool {
+		details[r.MediaId] = kindDetails{
+			Kind: r.Kind,
+			Details: pathDetails{
+				Path:      r.Path,
+				Ingestion: ingestionWithProgress{DVDIngestion: taskStatusToIngestion(r.TaskStatus, r.TaskError)},
+			},
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch media details: %w", err)
+	}
+	return details, nil
+}
+
+// getIngestionProgress returns the progress of the unfinished ingestion tasks
+// of the given media, by media ID.
+func getIngestionProgress(ctx context.Context, db vmdb.Runner, mediaIds []uint32) (map[uint32]vmtask.Progress, error) {
+	const sql = `
+		SELECT (state->>'media_id')::integer, progress
+		FROM tasks
+		WHERE task_type = ANY($1)
+		  AND (state->>'media_id')::integer = ANY($2)
+		  AND status IN ('pending', 'running')
+		  AND progress IS NOT NULL
+	`
+	type row struct {
+		MediaId  uint32
+		Progress vmtask.Progress
+	}
+	taskTypes := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		taskTypes[i] = k.IngestionTaskType()
+	}
+	progress := make(map[uint32]vmtask.Progress)
+	err := vmdb.Query(ctx, db, vmdb.Positional(sql, taskTypes, mediaIds), func(r row) bool {
+		progress[r.MediaId] = r.Progress
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch ingestion progress: %w", err)
+	}
+	return progress, nil
+}
+
+// The types below extend the vmapi types with Blu-rays, video files and
+// ingestion progress.  Fields of the embedded types are shadowed by the fields
+// of the same name here.
+
+type ingestionWithProgress struct {
+	vmapi.DVDIngestion
+	Progress *vmtask.Progress `json:"progress,omitempty"`
+}
+
+type dvdWithProgress struct {
+	vmapi.DVD
+	Ingestion ingestionWithProgress `json:"ingestion"`
+	Titles    []dvdTitle            `json:"titles,omitempty"`
+}
+
+// dvdTitle is one title of a DVD, as read by the scan task.
+type dvdTitle struct {
+	Number            int              `json:"number"`
+	TitleSet          int              `json:"title_set"`
+	DurationMs        int64            `json:"duration_ms"`
+	Chapters          int              `json:"chapters"`
+	Angles            int              `json:"angles"`
+	Audio             []dvdAudioStream `json:"audio,omitempty"`
+	SubtitleLanguages []string         `json:"subtitle_languages,omitempty"`
+}
+
+type dvdAudioStream struct {
+	Language string `json:"language"`
+	Codec    string `json:"codec"`
+	Channels int    `json:"channels"`
+}
+
+func toDvdTitles(titles []vmdisc.DvdTitle) []dvdTitle {
+	var out []dvdTitle
+	for _, t := range titles {
+		title := dvdTitle{
+			Number:            t.Number,
+			TitleSet:          t.TitleSet,
+			DurationMs:        t.Duration.Milliseconds(),
+			Chapters:          t.Chapters,
+			Angles:            t.Angles,
+			SubtitleLanguages: t.SubtitleLanguages,
+		}
+		for _, a := range t.Audio {
+			title.Audio = append(title.Audio, dvdAudioStream{Language: a.Language, Codec: a.Codec, Channels: a.Channels})
+		}
+		out = append(out, title)
+	}
+	return out
+}
+
+// pathDetails describes a Blu-ray or a video file, in the same shape as
+// vmapi.DVD.
+type pathDetails struct {
+	Path      string                `json:"path"`
+	Ingestion ingestionWithProgress `json:"ingestion"`
+}
+
+// kindDetails is the pathDetails of media of the given kind.
+type kindDetails struct {
+	Kind    Kind
+	Details pathDetails
+}
+
+type extendedMediaDetails struct {
+	vmapi.MediaDetails
+	Dvd    *dvdWithProgress `json:"dvd,omitempty"`
+	Bluray *pathDetails     `json:"bluray,omitempty"`
+	File   *pathDetails     `json:"file,omitempty"`
+}
+
+type extendedMedia struct {
+	vmapi.Media
+	Details *extendedMediaDetails `json:"details,omitempty"`
+}
+
+type extendedMediaPage struct {
+	vmapi.MediaPage
+	Media []extendedMedia `json:"media"`
+}
+
+func extend(m vmapi.Media, details kindDetails, progress map[uint32]vmtask.Progress, titles []vmdisc.DvdTitle) extendedMedia {
+	out := extendedMedia{Media: m}
+	var ingestion *ingestionWithProgress
+	switch {
+	case m.Details != nil:
+		out.Details = &extendedMediaDetails{MediaDetails: *m.Details}
+		if m.Details.Dvd != nil {
+			out.Details.Dvd = &dvdWithProgress{
+				DVD:       *m.Details.Dvd,
+				Ingestion: ingestionWithProgress{DVDIngestion: m.Details.Dvd.Ingestion},
+				Titles:    toDvdTitles(titles),
+			}
+			ingestion = &out.Details.Dvd.Ingestion
+		}
+	case details.Kind == KindBluray:
+		out.Details = &extendedMediaDetails{Bluray: &details.Details}
+		ingestion = &out.Details.Bluray.Ingestion
+	case details.Kind == KindFile:
+		out.Details = &extendedMediaDetails{File: &details.Details}
+		ingestion = &out.Details.File.Ingestion
+	}
+	if p, ok := progress[m.Id]; ok && ingestion != nil {
+		ingestion.Progress = &p
+	}
+	return out
+}
+
+type getExtendedMediaResponse extendedMedia
+
+func (response getExtendedMediaResponse) VisitGetMediaResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(extendedMedia(response))
+}
+
+type postExtendedMediaResponse extendedMedia
+
+func (response postExtendedMediaResponse) VisitPostMediaResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(201)
+
+	return json.NewEncoder(w).Encode(extendedMedia(response))
+}
+
+type patchExtendedMediaResponse extendedMedia
+
+func (response patchExtendedMediaResponse) VisitPatchMediaResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(extendedMedia(response))
+}
+
+type listExtendedMediaResponse extendedMediaPage
+
+func (response listExtendedMediaResponse) VisitListMediaResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(extendedMediaPage(response))
+}
@@ -0,0 +1,411 @@
+package media_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmbody"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/media"
+)
+
+func TestStrictMiddleware_DeleteOptions(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc       exam.Loc
+		name      string
+		operation string
+		target    string
+		want      media.DeleteMediaOptions
+		wantErr   match.Matcher
+	}{
+		{
+			loc:       exam.Here(),
+			name:      "no options",
+			operation: "DeleteMedia",
+			target:    "/media/1",
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "restore to inbox",
+			operation: "DeleteMedia",
+			target:    "/media/1?restore_to_inbox=true",
+			want:      media.DeleteMediaOptions{RestoreToInbox: true},
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "malformed restore to inbox",
+			operation: "DeleteMedia",
+			target:    "/media/1?restore_to_inbox=maybe",
+			wantErr:   vmtest.HttpError(vmerr.ProblemBadRequest),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "other operations are not affected",
+			operation: "PatchMedia",
+			target:    "/media/1?restore_to_inbox=maybe",
+			wantErr:   match.Nil(),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			var got media.DeleteMediaOptions
+			next := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+				// Round-trip through DeleteMedia's view of the context.
+				got = media.DeleteMediaOptionsFromContext(ctx)
+				return nil, nil
+			}
+			f := (&media.MediaService{}).StrictMiddleware(next, tt.operation)
+			_, err := f(context.Background(), httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, tt.target, nil), nil)
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, got, tt.want).Log(tt.loc)
+		})
+	}
+}
+
+func TestStrictMiddleware_Details(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+
+	tests := []struct {
+		loc       exam.Loc
+		name      string
+		method    string
+		operation string
+		body      string
+		wantPost  media.MediaPostDetails
+		wantPatch []media.MediaPatchDetails
+		wantErr   match.Matcher
+	}{
+		{
+			loc:       exam.Here(),
+			name:      "post Blu-ray",
+			method:    http.MethodPost,
+			operation: "PostMedia",
+			body:      `{"name": "movie", "details": {"bluray_inbox_path": "inbox/bluray/movie"}}`,
+			wantPost:  media.MediaPostDetails{BlurayInboxPath: Set("inbox/bluray/movie")},
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "post video file",
+			method:    http.MethodPost,
+			operation: "PostMedia",
+			body:      `{"name": "movie", "details": {"file_inbox_path": "inbox/file/movie.mkv"}}`,
+			wantPost:  media.MediaPostDetails{FileInboxPath: Set("inbox/file/movie.mkv")},
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "post DVD",
+			method:    http.MethodPost,
+			operation: "PostMedia",
+			body:      `{"name": "movie", "details": {"dvd_inbox_path": "inbox/dvd/movie"}}`,
+			wantErr:   match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "patch",
+			method:    http.MethodPatch,
+			operation: "PatchMedia",
+			body:      `[{"note": "n"}, {"bluray": {"path": "a"}}, {"file": {"path": "b"}}]`,
+			wantPatch: []media.MediaPatchDetails{
+				{},
+				{Bluray: &media.PathPatch{Path: Set("a")}},
+				{File: &media.PathPatch{Path: Set("b")}},
+			},
+			wantErr: match.Nil(),
+		},
+		{
+			loc:       exam.Here(),
+			name:      "other operations are not affected",
+			method:    http.MethodPost,
+			operation: "PostMediaSet",
+			body:      `{"name": "set", "details": {"bluray_inbox_path": "inbox/bluray/movie"}}`,
+			wantErr:   match.Nil(),
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			var gotPost media.MediaPostDetails
+			var gotPatch []media.MediaPatchDetails
+			next := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+				gotPost = media.MediaPostDetailsFromContext(ctx)
+				gotPatch = media.MediaPatchDetailsFromContext(ctx)
+				return nil, nil
+			}
+			var err error
+			// Like the strict handler, decode the body before the middleware runs.
+			handler := vmbody.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body any
+				if decodeErr := json.NewDecoder(r.Body).Decode(&body); decodeErr != nil {
+					e.Fatalf("could not decode body: %v", decodeErr)
+				}
+				f := (&media.MediaService{}).StrictMiddleware(next, tt.operation)
+				_, err = f(r.Context(), w, r, nil)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/media", strings.NewReader(tt.body)))
+			exam.Match(e, env, err, tt.wantErr).Log(err).Log(tt.loc)
+			exam.Equal(e, env, gotPost, tt.wantPost).Log(tt.loc)
+			exam.Equal(e, env, gotPatch, tt.wantPatch).Log(tt.loc)
+		})
+	}
+}
+
+func TestStrictMiddleware_OtherKinds(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewMediaService(e, pg)
+
+	// serve runs the operation through the middleware with the given
+	// context, and returns the decoded JSON response body.
+	serve := func(e exam.E, ctx context.Context, operation string, request any) map[string]any {
+		next := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+			switch request := request.(type) {
+			case vmapi.PostMediaRequestObject:
+				return service.PostMedia(ctx, request)
+			case vmapi.PatchMediaRequestObject:
+				return service.PatchMedia(ctx, request)
+			case vmapi.GetMediaRequestObject:
+				return service.GetMedia(ctx, request)
+			default:
+				return service.ListMedia(ctx, request.(vmapi.ListMediaRequestObject))
+			}
+		}
+		rec := httptest.NewRecorder()
+		resp, err := service.StrictMiddleware(next, operation)(ctx, rec, httptest.NewRequest(http.MethodGet, "/", nil), request)
+		exam.Nil(e, env, err).Log(err).Must()
+		switch resp := resp.(type) {
+		case vmapi.PostMediaResponseObject:
+			err = resp.VisitPostMediaResponse(rec)
+		case vmapi.PatchMediaResponseObject:
+			err = resp.VisitPatchMediaResponse(rec)
+		case vmapi.GetMediaResponseObject:
+			err = resp.VisitGetMediaResponse(rec)
+		case vmapi.ListMediaResponseObject:
+			err = resp.VisitListMediaResponse(rec)
+		}
+		exam.Nil(e, env, err).Log(err).Must()
+		var body map[string]any
+		err = json.Unmarshal(rec.Body.Bytes(), &body)
+		exam.Nil(e, env, err).Log(err).Must()
+		return body
+	}
+	details := func(media any, kind media.Kind) map[string]any {
+		return media.(map[string]any)["details"].(map[string]any)[string(kind)].(map[string]any)
+	}
+	pending := map[string]any{"state": string(vmapi.DVDIngestionStatePending)}
+
+	blurayPath := NewInboxBluray(e, service, "movie")
+	postCtx := media.WithMediaPostDetails(ctx, media.MediaPostDetails{BlurayInboxPath: &blurayPath})
+	body := serve(e, postCtx, "PostMedia", vmapi.PostMediaRequestObject{Body: &vmapi.MediaPost{}})
+	blurayId := uint32(body["id"].(float64))
+	exam.Equal(e, env, details(body, media.KindBluray), map[string]any{"path": blurayPath, "ingestion": any(pending)})
+
+	filePath := NewInboxFile(e, service, "movie.mkv")
+	postCtx = media.WithMediaPostDetails(ctx, media.MediaPostDetails{FileInboxPath: &filePath})
+	body = serve(e, postCtx, "PostMedia", vmapi.PostMediaRequestObject{Body: &vmapi.MediaPost{}})
+	fileId := uint32(body["id"].(float64))
+	exam.Equal(e, env, details(body, media.KindFile), map[string]any{"path": filePath, "ingestion": any(pending)})
+
+	e.Run("get media", func(e exam.E) {
+		body := serve(e, ctx, "GetMedia", vmapi.GetMediaRequestObject{Id: fileId})
+		exam.Equal(e, env, details(body, media.KindFile)["path"], any(filePath))
+		_, ok := body["details"].(map[string]any)["dvd"]
+		exam.Equal(e, env, ok, false)
+	})
+
+	e.Run("list media", func(e exam.E) {
+		body := serve(e, ctx, "ListMedia", vmapi.ListMediaRequestObject{})
+		media := body["media"].([]any)
+		exam.Equal(e, env, len(media), 2).Must()
+		exam.Equal(e, env, details(media[0], "bluray")["path"], any(blurayPath))
+		exam.Equal(e, env, details(media[1], "file")["path"], any(filePath))
+	})
+
+	e.Run("patch Blu-ray path", func(e exam.E) {
+		patchCtx := media.WithMediaPatchDetails(ctx, []media.MediaPatchDetails{
+			{Bluray: &media.PathPatch{Path: Set("elsewhere")}},
+		})
+		request := vmapi.PatchMediaRequestObject{Id: blurayId, Body: &vmapi.PatchMediaJSONRequestBody{{}}}
+		body := serve(e, patchCtx, "PatchMedia", request)
+		exam.Equal(e, env, details(body, media.KindBluray)["path"], any("elsewhere"))
+	})
+
+	e.Run("patch Blu-ray path of video file", func(e exam.E) {
+		patchCtx := media.WithMediaPatchDetails(ctx, []media.MediaPatchDetails{
+			{Bluray: &media.PathPatch{Path: Set("elsewhere")}},
+		})
+		request := vmapi.PatchMediaRequestObject{Id: fileId, Body: &vmapi.PatchMediaJSONRequestBody{{}}}
+		_, err := service.PatchMedia(patchCtx, request)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest)).Log(err)
+	})
+}
+
+func TestStrictMiddleware_Progress(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	service := NewMediaService(e, pg)
+
+	postDvd := func(e exam.E, name string) uint32 {
+		postReq := vmapi.PostMediaRequestObject{
+			Body: &vmapi.MediaPost{
+				Details: vmapi.MediaPostDetails{
+					DvdInboxPath: Set(NewInboxDvd(e, service, name)),
+				},
+			},
+		}
+		resp, err := service.PostMedia(ctx, postReq)
+		exam.Nil(e, env, err).Log(err).Must()
+		return resp.(vmapi.PostMedia201JSONResponse).Id
+	}
+	// serve runs the operation through the middleware, and returns the
+	// decoded JSON response body.
+	serve := func(e exam.E, operation string, request any, f vmapi.StrictHandlerFunc) map[string]any {
+		rec := httptest.NewRecorder()
+		resp, err := service.StrictMiddleware(f, operation)(ctx, rec, httptest.NewRequest(http.MethodGet, "/", nil), request)
+		exam.Nil(e, env, err).Log(err).Must()
+		switch resp := resp.(type) {
+		case vmapi.GetMediaResponseObject:
+			err = resp.VisitGetMediaResponse(rec)
+		case vmapi.ListMediaResponseObject:
+			err = resp.VisitListMediaResponse(rec)
+		}
+		exam.Nil(e, env, err).Log(err).Must()
+		var body map[string]any
+		err = json.Unmarshal(rec.Body.Bytes(), &body)
+		exam.Nil(e, env, err).Log(err).Must()
+		return body
+	}
+	getMedia := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		return service.GetMedia(ctx, request.(vmapi.GetMediaRequestObject))
+	}
+	listMedia := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+		return service.ListMedia(ctx, request.(vmapi.ListMediaRequestObject))
+	}
+	ingestion := func(media any) map[string]any {
+		return media.(map[string]any)["details"].(map[string]any)["dvd"].(map[string]any)["ingestion"].(map[string]any)
+	}
+	wantProgress := map[string]any{"phase": "copy", "done": float64(10), "total": float64(100)}
+
+	busyId := postDvd(e, "busy")
+	idleId := postDvd(e, "idle")
+	const sql = `
+		UPDATE tasks
+		SET status = 'running', worker_id = 'w', lease_expires_at = NOW() + INTERVAL '1 minute',
+		    progress = '{"phase": "copy", "done": 10, "total": 100}'
+		WHERE (state->>'media_id')::integer = $1
+	`
+	_, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, busyId))
+	exam.Nil(e, env, err).Log(err).Must()
+
+	e.Run("get media with progress", func(e exam.E) {
+		body := serve(e, "GetMedia", vmapi.GetMediaRequestObject{Id: busyId}, getMedia)
+		exam.Equal(e, env, ingestion(body)["progress"], any(wantProgress))
+		exam.Equal(e, env, ingestion(body)["state"], any(string(vmapi.DVDIngestionStatePending)))
+		exam.Equal(e, env, body["id"], any(float64(busyId)))
+	})
+
+	e.Run("get media without progress", func(e exam.E) {
+		body := serve(e, "GetMedia", vmapi.GetMediaRequestObject{Id: idleId}, getMedia)
+		_, ok := ingestion(body)["progress"]
+		exam.Equal(e, env, ok, false)
+	})
+
+	e.Run("list media", func(e exam.E) {
+		body := serve(e, "ListMedia", vmapi.ListMediaRequestObject{}, listMedia)
+		media := body["media"].([]any)
+		exam.Equal(e, env, len(media), 2).Must()
+		exam.Equal(e, env, ingestion(media[0])["progress"], any(wantProgress))
+		_, ok := ingestion(media[1])["progress"]
+		exam.Equal(e, env, ok, false)
+	})
+}
+
+func TestStrictMiddleware_DvdTitles(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	db := pg.DbRunner(e)
+	service := NewMediaService(e, pg)
+
+	postDvd := func(e exam.E, name string) uint32 {
+		postReq := vmapi.PostMediaRequestObject{
+			Body: &vmapi.MediaPost{
+				Details: vmapi.MediaPostDetails{
+					DvdInboxPath: Set(NewInboxDvd(e, service, name)),
+				},
+			},
+		}
+		resp, err := service.PostMedia(ctx, postReq)
+		exam.Nil(e, env, err).Log(err).Must()
+		return resp.(vmapi.PostMedia201JSONResponse).Id
+	}
+	scannedId := postDvd(e, "scanned")
+	unscannedId := postDvd(e, "unscanned")
+	for _, sql := range []string{
+		`INSERT INTO media_dvd_titles (media_id, title_number, title_set, duration_ms, chapters, angles) VALUES ($1, 1, 1, 5400000, 12, 1)`,
+		`INSERT INTO media_dvd_title_audio (media_id, title_number, stream_index, language, codec, channels) VALUES ($1, 1, 0, 'en', 'ac3', 6)`,
+		`INSERT INTO media_dvd_title_subtitles (media_id, title_number, stream_index, language) VALUES ($1, 1, 0, 'fr')`,
+	} {
+		_, err := vmdb.Exec(ctx, db, vmdb.Positional(sql, scannedId))
+		exam.Nil(e, env, err).Log(err).Must()
+	}
+
+	// serve runs GetMedia through the middleware, and returns the DVD
+	// details of the decoded JSON response body.
+	serve := func(e exam.E, id uint32) map[string]any {
+		rec := httptest.NewRecorder()
+		getMedia := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request any) (any, error) {
+			return service.GetMedia(ctx, request.(vmapi.GetMediaRequestObject))
+		}
+		resp, err := service.StrictMiddleware(getMedia, "GetMedia")(ctx, rec, httptest.NewRequest(http.MethodGet, "/", nil), vmapi.GetMediaRequestObject{Id: id})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Nil(e, env, resp.(vmapi.GetMediaResponseObject).VisitGetMediaResponse(rec)).Must()
+		var body map[string]any
+		err = json.Unmarshal(rec.Body.Bytes(), &body)
+		exam.Nil(e, env, err).Log(err).Must()
+		return body["details"].(map[string]any)["dvd"].(map[string]any)
+	}
+
+	e.Run("scanned", func(e exam.E) {
+		want := []any{map[string]any{
+			"number":             float64(1),
+			"title_set":          float64(1),
+			"duration_ms":        float64(5400000),
+			"chapters":           float64(12),
+			"angles":             float64(1),
+			"audio":              []any{map[string]any{"language": "en", "codec": "ac3", "channels": float64(6)}},
+			"subtitle_languages": []any{"fr"},
+		}}
+		exam.Equal(e, env, serve(e, scannedId)["titles"], any(want))
+	})
+
+	e.Run("not scanned", func(e exam.E) {
+		_, ok := serve(e, unscannedId)["titles"]
+		exam.Equal(e, env, ok, false)
+	})
+}
@@ -1,9 +1,11 @@
 package media
 
 import (
+	"github.com/krelinga/video-manager/internal/lib/config"
 	"github.com/krelinga/video-manager/internal/lib/vmdb"
 )
 
 type MediaService struct {
-	Db vmdb.DbRunner
+	Db    vmdb.DbRunner
+	Paths config.Paths
 }
@@ -0,0 +1,59 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/krelinga/video-manager/internal/lib/config"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+)
+
+// validateInboxPath checks that path names media of the given kind in its
+// inbox, and returns it in canonical form.  Problems with path are reported as
+// BadRequest errors.
+func (ms *MediaService) validateInboxPath(kind Kind, path string) (string, error) {
+	field := kind.inboxPathField()
+	if path == "" {
+		return "", vmerr.BadRequest(fmt.Errorf("%s must be non-empty", field))
+	}
+	if filepath.IsAbs(path) {
+		return "", vmerr.BadRequest(fmt.Errorf("%s %q must be relative to the root directory", field, path))
+	}
+	cleanPath := filepath.Clean(path)
+	inbox := kind.inbox(ms.Paths, config.PathKindRelative)
+	if !isWithin(inbox, cleanPath) {
+		return "", vmerr.BadRequest(fmt.Errorf("%s %q is not inside %q", field, path, inbox))
+	}
+
+	// The path may still lead out of the inbox through a symlink.
+	realInbox, err := filepath.EvalSymlinks(kind.inbox(ms.Paths, config.PathKindAbsolute))
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s inbox: %w", kind.label(), err)
+	}
+	realPath, err := filepath.EvalSymlinks(ms.Paths.Absolute(cleanPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", vmerr.BadRequest(fmt.Errorf("%s %q does not exist", field, path))
+	} else if err != nil {
+		return "", fmt.Errorf("could not resolve %s %q: %w", field, path, err)
+	}
+	if !isWithin(realInbox, realPath) {
+		return "", vmerr.BadRequest(fmt.Errorf("%s %q resolves to a path outside %q", field, path, inbox))
+	}
+
+	if err := kind.detect(realPath); err != nil {
+		return "", vmerr.BadRequest(fmt.Errorf("%s %q is not a %s: %w", field, path, kind.label(), err))
+	}
+	return cleanPath, nil
+}
+
+// isWithin reports whether path is strictly inside dir.  Both must be clean.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,64 @@
+package task
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// Task is the JSON representation of a vmtask.Task.
+type Task struct {
+	Id             uint32           `json:"id"`
+	TaskType       string           `json:"task_type"`
+	Status         vmtask.Status    `json:"status"`
+	State          json.RawMessage  `json:"state"`
+	Error          *string          `json:"error,omitempty"`
+	ParentId       *uint32          `json:"parent_id,omitempty"`
+	WorkerId       *string          `json:"worker_id,omitempty"`
+	LeaseExpiresAt *time.Time       `json:"lease_expires_at,omitempty"`
+	Attempts       uint32           `json:"attempts"`
+	RunAfter       time.Time        `json:"run_after"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	Progress       *vmtask.Progress `json:"progress,omitempty"`
+	// Children is only populated by GetTask, and holds the whole subtree.
+	Children []Task `json:"children,omitempty"`
+}
+
+// TaskPage is a single page of results from ListTasks.
+type TaskPage struct {
+	Tasks         []Task  `json:"tasks"`
+	NextPageToken *string `json:"next_page_token,omitempty"`
+}
+
+// ListTasksParams filters and pages the results of ListTasks.
+type ListTasksParams struct {
+	TaskType  *string
+	Status    *vmtask.Status
+	ParentId  *uint32
+	PageSize  *uint32
+	PageToken *string
+}
+
+func toApiTask(t *vmtask.Task) Task {
+	out := Task{
+		Id:             uint32(t.Id),
+		TaskType:       t.TaskType,
+		Status:         t.Status,
+		State:          json.RawMessage(t.State),
+		Error:          t.Error,
+		WorkerId:       t.WorkerId,
+		LeaseExpiresAt: t.LeaseExpiresAt,
+		Attempts:       uint32(t.Attempts),
+		RunAfter:       t.RunAfter,
+		CreatedAt:      t.CreatedAt,
+		UpdatedAt:      t.UpdatedAt,
+		Progress:       t.Progress,
+	}
+	if t.ParentId != nil {
+		parentId := uint32(*t.ParentId)
+		out.ParentId = &parentId
+	}
+	return out
+}
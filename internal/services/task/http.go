@@ -0,0 +1,106 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+// RegisterRoutes adds the task endpoints to mux under baseUrl:
+//
+//	GET  {baseUrl}/tasks              list tasks, filtered by task_type, status and parent_id
+//	GET  {baseUrl}/tasks/{id}         get a task and its child tree
+//	POST {baseUrl}/tasks/{id}/cancel  cancel a task and its descendants
+//	POST {baseUrl}/tasks/{id}/requeue requeue a failed task
+func (ts *TaskService) RegisterRoutes(mux *http.ServeMux, baseUrl string) {
+	mux.HandleFunc("GET "+baseUrl+"/tasks", ts.handleListTasks)
+	mux.HandleFunc("GET "+baseUrl+"/tasks/{id}", ts.handleGetTask)
+	mux.HandleFunc("POST "+baseUrl+"/tasks/{id}/cancel", ts.handleCancelTask)
+	mux.HandleFunc("POST "+baseUrl+"/tasks/{id}/requeue", ts.handleRequeueTask)
+}
+
+func (ts *TaskService) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var params ListTasksParams
+	if query.Has("task_type") {
+		taskType := query.Get("task_type")
+		params.TaskType = &taskType
+	}
+	if query.Has("status") {
+		status := vmtask.Status(query.Get("status"))
+		params.Status = &status
+	}
+	var err error
+	if params.ParentId, err = parseUint32(query, "parent_id"); err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	}
+	if params.PageSize, err = parseUint32(query, "page_size"); err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	}
+	if query.Has("page_token") {
+		pageToken := query.Get("page_token")
+		params.PageToken = &pageToken
+	}
+
+	page, err := ts.ListTasks(r.Context(), params)
+	if err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	}
+	writeJson(r.Context(), w, page)
+}
+
+func (ts *TaskService) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	ts.handleById(w, r, ts.GetTask)
+}
+
+func (ts *TaskService) handleCancelTask(w http.ResponseWriter, r *http.Request) {
+	ts.handleById(w, r, ts.CancelTask)
+}
+
+func (ts *TaskService) handleRequeueTask(w http.ResponseWriter, r *http.Request) {
+	ts.handleById(w, r, ts.RequeueTask)
+}
+
+func (ts *TaskService) handleById(w http.ResponseWriter, r *http.Request, op func(ctx context.Context, id uint32) (*Task, error)) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		vmerr.Middleware(w, r, vmerr.BadRequest(fmt.Errorf("invalid task id %q: %w", r.PathValue("id"), err)))
+		return
+	}
+	t, err := op(r.Context(), uint32(id))
+	if err != nil {
+		vmerr.Middleware(w, r, err)
+		return
+	}
+	writeJson(r.Context(), w, t)
+}
+
+func parseUint32(query url.Values, name string) (*uint32, error) {
+	if !query.Has(name) {
+		return nil, nil
+	}
+	v, err := strconv.ParseUint(query.Get(name), 10, 32)
+	if err != nil {
+		return nil, vmerr.BadRequest(fmt.Errorf("invalid %s %q: %w", name, query.Get(name), err))
+	}
+	out := uint32(v)
+	return &out, nil
+}
+
+func writeJson(ctx context.Context, w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.ErrorContext(ctx, "task: failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package task_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/video-manager/internal/services/task"
+)
+
+// These requests are all rejected before the database is touched.
+func TestRoutes_BadRequests(t *testing.T) {
+	e := exam.New(t)
+	env := deep.NewEnv()
+	mux := http.NewServeMux()
+	(&task.TaskService{}).RegisterRoutes(mux, "/api/v1")
+
+	tests := []struct {
+		name   string
+		loc    exam.Loc
+		method string
+		target string
+		want   int
+	}{
+		{
+			name:   "non-numeric id",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/tasks/abc",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "zero id",
+			loc:    exam.Here(),
+			method: http.MethodPost,
+			target: "/api/v1/tasks/0/cancel",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "bad parent_id",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/tasks?parent_id=x",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "bad page_size",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/tasks?page_size=-1",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "unknown status",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/tasks?status=bogus",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "wrong method",
+			loc:    exam.Here(),
+			method: http.MethodGet,
+			target: "/api/v1/tasks/1/requeue",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		e.Run(tt.name, func(e exam.E) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			exam.Equal(e, env, rec.Code, tt.want).Log(tt.loc).Log(rec.Body.String())
+		})
+	}
+}
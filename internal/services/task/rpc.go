@@ -0,0 +1,11 @@
+package task
+
+import (
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+)
+
+// TaskService exposes the vmtask queue for inspection and administration.
+// Its endpoints are not part of vmapi, so it serves them itself; see RegisterRoutes.
+type TaskService struct {
+	Db vmdb.DbRunner
+}
@@ -0,0 +1,186 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmpage"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+)
+
+func validStatus(status vmtask.Status) bool {
+	switch status {
+	case vmtask.StatusPending, vmtask.StatusRunning, vmtask.StatusWaiting, vmtask.StatusCompleted, vmtask.StatusFailed:
+		return true
+	}
+	return false
+}
+
+func (ts *TaskService) ListTasks(ctx context.Context, params ListTasksParams) (*TaskPage, error) {
+	const sql = `
+		SELECT id, task_type, state, status, worker_id, lease_expires_at, error, parent_id, created_at, updated_at, attempts, run_after, progress
+		FROM tasks
+		WHERE id > @lastSeenId
+		  AND (@taskType::text IS NULL OR task_type = @taskType::text)
+		  AND (@status::text IS NULL OR status = @status::task_status)
+		  AND (@parentId::integer IS NULL OR parent_id = @parentId::integer)
+		ORDER BY id ASC
+		LIMIT @limit;
+	`
+	var status *string
+	if params.Status != nil {
+		if !validStatus(*params.Status) {
+			return nil, vmerr.BadRequest(fmt.Errorf("unknown task status %q", *params.Status))
+		}
+		status = (*string)(params.Status)
+	}
+
+	query := &vmpage.ListQuery{
+		Sql:       sql,
+		Want:      params.PageSize,
+		Default:   50,
+		Max:       100,
+		PageToken: params.PageToken,
+		Params: map[string]any{
+			"taskType": params.TaskType,
+			"status":   status,
+			"parentId": params.ParentId,
+		},
+	}
+	page := &TaskPage{
+		Tasks: []Task{},
+	}
+	nextPageToken, err := vmpage.ListPtr(ctx, ts.Db, query, func(t *vmtask.Task) uint32 {
+		page.Tasks = append(page.Tasks, toApiTask(t))
+		return uint32(t.Id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.NextPageToken = nextPageToken
+	return page, nil
+}
+
+func (ts *TaskService) GetTask(ctx context.Context, id uint32) (*Task, error) {
+	if id == 0 {
+		return nil, vmerr.BadRequest(errors.New("non-zero id is required"))
+	}
+
+	tx, err := ts.Db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := getTask(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := getChildren(ctx, tx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// CancelTask cancels a task that has not finished yet, along with all of its
+// descendants.  If the task has a waiting parent, the parent is resumed so it
+// can react to the cancellation.
+func (ts *TaskService) CancelTask(ctx context.Context, id uint32) (*Task, error) {
+	if id == 0 {
+		return nil, vmerr.BadRequest(errors.New("non-zero id is required"))
+	}
+
+	tx, err := ts.Db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := getTask(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status == vmtask.StatusCompleted || t.Status == vmtask.StatusFailed {
+		return nil, vmerr.BadRequest(fmt.Errorf("task with id %d already %s", id, t.Status))
+	}
+
+	if err := vmtask.Cancel(ctx, tx, int(id)); err != nil {
+		return nil, fmt.Errorf("could not cancel task: %w", err)
+	}
+	if t.ParentId != nil {
+		if _, err := vmtask.Resume(ctx, tx, int(*t.ParentId)); err != nil {
+			return nil, fmt.Errorf("could not resume parent task: %w", err)
+		}
+	}
+
+	if t, err = getTask(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return t, nil
+}
+
+// RequeueTask moves a failed task back to pending with a fresh retry budget.
+func (ts *TaskService) RequeueTask(ctx context.Context, id uint32) (*Task, error) {
+	if id == 0 {
+		return nil, vmerr.BadRequest(errors.New("non-zero id is required"))
+	}
+
+	tx, err := ts.Db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	t, err := getTask(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != vmtask.StatusFailed {
+		return nil, vmerr.BadRequest(fmt.Errorf("task with id %d is %s, only failed tasks can be requeued", id, t.Status))
+	}
+
+	if _, err := vmtask.Requeue(ctx, tx, int(id)); err != nil {
+		return nil, fmt.Errorf("could not requeue task: %w", err)
+	}
+
+	if t, err = getTask(ctx, tx, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return t, nil
+}
+
+func getTask(ctx context.Context, runner vmdb.Runner, id uint32) (*Task, error) {
+	t, err := vmtask.Get(ctx, runner, int(id))
+	if errors.Is(err, vmdb.ErrNotFound) {
+		return nil, vmerr.NotFound(fmt.Errorf("task with id %d not found", id))
+	} else if err != nil {
+		return nil, fmt.Errorf("could not fetch task: %w", err)
+	}
+	out := toApiTask(t)
+	return &out, nil
+}
+
+// getChildren fills in the Children of parent, recursively.
+func getChildren(ctx context.Context, runner vmdb.Runner, parent *Task) error {
+	children, err := vmtask.GetChildTasks(ctx, runner, int(parent.Id))
+	if err != nil {
+		return fmt.Errorf("could not fetch child tasks: %w", err)
+	}
+	for i := range children {
+		child := toApiTask(&children[i])
+		if err := getChildren(ctx, runner, &child); err != nil {
+			return err
+		}
+		parent.Children = append(parent.Children, child)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package task_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/services/task"
+)
+
+func set[T any](in T) *T {
+	return &in
+}
+
+func NewTaskService(e exam.E, pg *vmtest.Postgres) *task.TaskService {
+	return &task.TaskService{
+		Db: pg.DbRunner(e),
+	}
+}
+
+func createTask(e exam.E, db vmdb.Runner, taskType string) uint32 {
+	e.Helper()
+	id, err := vmtask.Create(context.Background(), db, taskType, nil)
+	exam.Nil(e, deep.NewEnv(), err).Log(err).Must()
+	return uint32(id)
+}
+
+func createChild(e exam.E, db vmdb.Runner, parentId uint32, taskType string) uint32 {
+	e.Helper()
+	id, err := vmtask.CreateChild(context.Background(), db, int(parentId), taskType, nil)
+	exam.Nil(e, deep.NewEnv(), err).Log(err).Must()
+	return uint32(id)
+}
+
+func setStatus(e exam.E, db vmdb.Runner, id uint32, status vmtask.Status) {
+	e.Helper()
+	const sql = `UPDATE tasks SET status = $2 WHERE id = $1`
+	_, err := vmdb.Exec(context.Background(), db, vmdb.Positional(sql, id, string(status)))
+	exam.Nil(e, deep.NewEnv(), err).Log(err).Must()
+}
+
+func ids(tasks []task.Task) []uint32 {
+	out := []uint32{}
+	for _, t := range tasks {
+		out = append(out, t.Id)
+	}
+	return out
+}
+
+func TestListTasks(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewTaskService(e, pg)
+	db := pg.DbRunner(e)
+
+	e.Run("empty list", func(e exam.E) {
+		defer pg.Reset(e)
+		page, err := service.ListTasks(ctx, task.ListTasksParams{})
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, page, &task.TaskPage{Tasks: []task.Task{}}).Log(page)
+	})
+
+	e.Run("filters", func(e exam.E) {
+		defer pg.Reset(e)
+		a := createTask(e, db, "type-a")
+		b := createTask(e, db, "type-b")
+		child := createChild(e, db, a, "type-b")
+		setStatus(e, db, b, vmtask.StatusFailed)
+
+		tests := []struct {
+			name   string
+			loc    exam.Loc
+			params task.ListTasksParams
+			want   []uint32
+		}{
+			{
+				name: "no filter",
+				loc:  exam.Here(),
+				want: []uint32{a, b, child},
+			},
+			{
+				name:   "task type",
+				loc:    exam.Here(),
+				params: task.ListTasksParams{TaskType: set("type-b")},
+				want:   []uint32{b, child},
+			},
+			{
+				name:   "status",
+				loc:    exam.Here(),
+				params: task.ListTasksParams{Status: set(vmtask.StatusFailed)},
+				want:   []uint32{b},
+			},
+			{
+				name:   "default status",
+				loc:    exam.Here(),
+				params: task.ListTasksParams{Status: set(vmtask.StatusPending)},
+				want:   []uint32{a, child},
+			},
+			{
+				name:   "parent",
+				loc:    exam.Here(),
+				params: task.ListTasksParams{ParentId: set(a)},
+				want:   []uint32{child},
+			},
+			{
+				name: "combined",
+				loc:  exam.Here(),
+				params: task.ListTasksParams{
+					TaskType: set("type-b"),
+					Status:   set(vmtask.StatusPending),
+				},
+				want: []uint32{child},
+			},
+		}
+		for _, tt := range tests {
+			e.Run(tt.name, func(e exam.E) {
+				page, err := service.ListTasks(ctx, tt.params)
+				exam.Nil(e, env, err).Log(err).Log(tt.loc).Must()
+				exam.Equal(e, env, ids(page.Tasks), tt.want).Log(tt.loc)
+			})
+		}
+	})
+
+	e.Run("pagination", func(e exam.E) {
+		defer pg.Reset(e)
+		var want []uint32
+		for range 3 {
+			want = append(want, createTask(e, db, "type-a"))
+		}
+
+		var got []uint32
+		var token *string
+		for {
+			page, err := service.ListTasks(ctx, task.ListTasksParams{
+				PageSize:  set(uint32(2)),
+				PageToken: token,
+			})
+			exam.Nil(e, env, err).Log(err).Must()
+			got = append(got, ids(page.Tasks)...)
+			if token = page.NextPageToken; token == nil {
+				break
+			}
+		}
+		exam.Equal(e, env, got, want)
+	})
+
+	e.Run("unknown status", func(e exam.E) {
+		_, err := service.ListTasks(ctx, task.ListTasksParams{Status: set(vmtask.Status("bogus"))})
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest))
+	})
+
+	e.Run("bad page token", func(e exam.E) {
+		_, err := service.ListTasks(ctx, task.ListTasksParams{PageToken: set("not a token")})
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest))
+	})
+}
+
+func TestGetTask(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewTaskService(e, pg)
+	db := pg.DbRunner(e)
+
+	e.Run("includes child tree", func(e exam.E) {
+		defer pg.Reset(e)
+		root := createTask(e, db, "root")
+		child := createChild(e, db, root, "child")
+		grandchild := createChild(e, db, child, "grandchild")
+
+		got, err := service.GetTask(ctx, root)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Match(e, env, got, match.Pointer(match.Struct{
+			Fields: map[deep.Field]match.Matcher{
+				deep.NamedField("Id"):       match.Equal(root),
+				deep.NamedField("TaskType"): match.Equal("root"),
+				deep.NamedField("Status"):   match.Equal(vmtask.StatusPending),
+				deep.NamedField("Children"): match.Len(match.Equal(1)),
+			},
+		})).Log(got).Must()
+		exam.Equal(e, env, got.Children[0].Id, child)
+		exam.Equal(e, env, got.Children[0].ParentId, &root)
+		exam.Equal(e, env, ids(got.Children[0].Children), []uint32{grandchild})
+	})
+
+	e.Run("not found", func(e exam.E) {
+		_, err := service.GetTask(ctx, 9999)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemNotFound))
+	})
+
+	e.Run("zero id", func(e exam.E) {
+		_, err := service.GetTask(ctx, 0)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest))
+	})
+}
+
+func TestCancelTask(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewTaskService(e, pg)
+	db := pg.DbRunner(e)
+
+	e.Run("cancels descendants and resumes parent", func(e exam.E) {
+		defer pg.Reset(e)
+		parent := createTask(e, db, "parent")
+		setStatus(e, db, parent, vmtask.StatusWaiting)
+		child := createChild(e, db, parent, "child")
+		grandchild := createChild(e, db, child, "grandchild")
+
+		got, err := service.CancelTask(ctx, child)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, got.Status, vmtask.StatusFailed)
+		exam.Equal(e, env, got.Error, set("cancelled"))
+
+		want := map[uint32]vmtask.Status{
+			parent:     vmtask.StatusPending,
+			grandchild: vmtask.StatusFailed,
+		}
+		for id, status := range want {
+			got, err := service.GetTask(ctx, id)
+			exam.Nil(e, env, err).Log(err).Must()
+			exam.Equal(e, env, got.Status, status).Log(id)
+		}
+	})
+
+	e.Run("already finished", func(e exam.E) {
+		defer pg.Reset(e)
+		id := createTask(e, db, "type-a")
+		setStatus(e, db, id, vmtask.StatusCompleted)
+		_, err := service.CancelTask(ctx, id)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest))
+	})
+
+	e.Run("not found", func(e exam.E) {
+		_, err := service.CancelTask(ctx, 9999)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemNotFound))
+	})
+}
+
+func TestRequeueTask(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	pg := vmtest.PostgresOnce(e)
+	defer pg.Reset(e)
+	service := NewTaskService(e, pg)
+	db := pg.DbRunner(e)
+
+	e.Run("requeues failed task", func(e exam.E) {
+		defer pg.Reset(e)
+		id := createTask(e, db, "type-a")
+		_, err := service.CancelTask(ctx, id)
+		exam.Nil(e, env, err).Log(err).Must()
+
+		got, err := service.RequeueTask(ctx, id)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Equal(e, env, got.Status, vmtask.StatusPending)
+		exam.Match(e, env, got.Error, match.Nil())
+		exam.Equal(e, env, got.Attempts, uint32(0))
+	})
+
+	e.Run("not failed", func(e exam.E) {
+		defer pg.Reset(e)
+		id := createTask(e, db, "type-a")
+		_, err := service.RequeueTask(ctx, id)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest))
+	})
+
+	e.Run("not found", func(e exam.E) {
+		_, err := service.RequeueTask(ctx, 9999)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemNotFound))
+	})
+}
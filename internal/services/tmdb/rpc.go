@@ -2,12 +2,77 @@ package tmdb
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmpage"
+	"github.com/krelinga/video-manager/internal/lib/vmtmdb"
 )
 
-type TMDbService struct{}
+type TMDbService struct {
+	Client *vmtmdb.Client
+}
 
 func (s *TMDbService) SearchTmdbMovies(ctx context.Context, request vmapi.SearchTmdbMoviesRequestObject) (vmapi.SearchTmdbMoviesResponseObject, error) {
-	return nil, nil // TODO: implement
+	query := request.Params.Query
+	if query == "" {
+		return nil, vmerr.BadRequest(errors.New("query must be non-empty"))
+	}
+
+	limit := &vmpage.Limit{
+		Want:    request.Params.PageSize,
+		Default: vmtmdb.PageSize,
+		Max:     100,
+	}
+	fetch := func(offset, limit uint32) ([]vmapi.TmdbMovie, uint32, error) {
+		// TMDb pages are fixed-size, so we may need to skip into the first page
+		// and read several pages to fill the requested limit.
+		tmdbPage := int(offset/vmtmdb.PageSize) + 1
+		skip := int(offset % vmtmdb.PageSize)
+		var movies []vmapi.TmdbMovie
+		var total uint32
+		for uint32(len(movies)) < limit {
+			page, err := s.Client.SearchMovies(ctx, query, tmdbPage)
+			if err != nil {
+				return nil, 0, vmerr.InternalError(fmt.Errorf("could not search TMDb: %w", err))
+			}
+			total = uint32(page.TotalResults)
+			if skip < len(page.Results) {
+				for _, m := range page.Results[skip:] {
+					movies = append(movies, toApiMovie(m))
+				}
+			}
+			if tmdbPage >= page.TotalPages {
+				break
+			}
+			tmdbPage++
+			skip = 0
+		}
+		return movies, total, nil
+	}
+	movies, nextPageToken, err := vmpage.ListFromOffset(limit, request.Params.PageToken, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := vmapi.SearchTmdbMovies200JSONResponse{
+		Movies:        movies,
+		NextPageToken: nextPageToken,
+	}
+	return resp, nil
+}
+
+func toApiMovie(m vmtmdb.Movie) vmapi.TmdbMovie {
+	movie := vmapi.TmdbMovie{
+		TmdbId:      m.Id,
+		Title:       m.Title,
+		ReleaseYear: m.ReleaseYear(),
+		PosterUrl:   vmtmdb.PosterUrl(m.PosterPath),
+	}
+	if m.Overview != "" {
+		movie.Description = &m.Overview
+	}
+	return movie
 }
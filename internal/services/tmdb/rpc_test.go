@@ -0,0 +1,140 @@
+package tmdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/krelinga/go-libs/deep"
+	"github.com/krelinga/go-libs/exam"
+	"github.com/krelinga/go-libs/match"
+	"github.com/krelinga/video-manager-api/go/vmapi"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmtest"
+	"github.com/krelinga/video-manager/internal/lib/vmtmdb"
+	"github.com/krelinga/video-manager/internal/services/tmdb"
+)
+
+func set[T any](in T) *T {
+	return &in
+}
+
+func TestSearchTmdbMovies(t *testing.T) {
+	ctx := context.Background()
+	e := exam.New(t)
+	env := deep.NewEnv()
+	fake := vmtest.NewTmdb(e)
+	service := &tmdb.TMDbService{
+		Client: vmtmdb.New(fake.Config()),
+	}
+
+	type Request = vmapi.SearchTmdbMoviesRequestObject
+	type Params = vmapi.SearchTmdbMoviesParams
+
+	e.Run("maps results", func(e exam.E) {
+		req := Request{
+			Params: Params{
+				Query:    "alien",
+				PageSize: set(uint32(1)),
+			},
+		}
+		resp, err := service.SearchTmdbMovies(ctx, req)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Match(e, env, resp, match.Interface(match.Struct{
+			Fields: map[deep.Field]match.Matcher{
+				deep.NamedField("Movies"): match.Equal([]vmapi.TmdbMovie{
+					{
+						TmdbId:      348,
+						Title:       "Alien",
+						Description: set("During its return to the earth, commercial spaceship Nostromo intercepts a distress signal from a distant planet."),
+						ReleaseYear: set(uint32(1979)),
+						PosterUrl:   set("https://image.tmdb.org/t/p/w500/vfrQk5IPloGg1v9Rzbh2Eg3VGyM.jpg"),
+					},
+				}),
+				deep.NamedField("NextPageToken"): match.Pointer(match.Len(match.GreaterThan(0))),
+			},
+		})).Log(resp)
+	})
+
+	e.Run("missing poster and overview", func(e exam.E) {
+		// "The Alien Factor" is the 11th result and has no poster.
+		first, err := service.SearchTmdbMovies(ctx, Request{
+			Params: Params{Query: "alien", PageSize: set(uint32(10))},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		second, err := service.SearchTmdbMovies(ctx, Request{
+			Params: Params{
+				Query:     "alien",
+				PageSize:  set(uint32(1)),
+				PageToken: first.(vmapi.SearchTmdbMovies200JSONResponse).NextPageToken,
+			},
+		})
+		exam.Nil(e, env, err).Log(err).Must()
+		movies := second.(vmapi.SearchTmdbMovies200JSONResponse).Movies
+		exam.Equal(e, env, len(movies), 1).Must()
+		exam.Equal(e, env, movies[0].Title, "The Alien Factor")
+		exam.Match(e, env, movies[0].PosterUrl, match.Nil())
+	})
+
+	e.Run("pages across TMDb pages", func(e exam.E) {
+		var titles []string
+		var token *string
+		for {
+			req := Request{
+				Params: Params{
+					Query:     "alien",
+					PageSize:  set(uint32(7)),
+					PageToken: token,
+				},
+			}
+			resp, err := service.SearchTmdbMovies(ctx, req)
+			exam.Nil(e, env, err).Log(err).Must()
+			page := resp.(vmapi.SearchTmdbMovies200JSONResponse)
+			exam.Match(e, env, len(page.Movies), match.LessThanOrEqual(7)).Must()
+			for _, m := range page.Movies {
+				titles = append(titles, m.Title)
+			}
+			token = page.NextPageToken
+			if token == nil {
+				break
+			}
+		}
+		exam.Equal(e, env, len(titles), 23)
+		exam.Equal(e, env, titles[0], "Alien")
+		exam.Equal(e, env, titles[22], "Alien Opponent")
+	})
+
+	e.Run("no results", func(e exam.E) {
+		req := Request{
+			Params: Params{Query: "zzzz no such movie"},
+		}
+		resp, err := service.SearchTmdbMovies(ctx, req)
+		exam.Nil(e, env, err).Log(err).Must()
+		exam.Match(e, env, resp, match.Interface(match.Struct{
+			Fields: map[deep.Field]match.Matcher{
+				deep.NamedField("Movies"):        match.Len(match.Equal(0)),
+				deep.NamedField("NextPageToken"): match.Nil(),
+			},
+		})).Log(resp)
+	})
+
+	e.Run("empty query", func(e exam.E) {
+		_, err := service.SearchTmdbMovies(ctx, Request{})
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest))
+	})
+
+	e.Run("bad page token", func(e exam.E) {
+		req := Request{
+			Params: Params{Query: "alien", PageToken: set("not a token")},
+		}
+		_, err := service.SearchTmdbMovies(ctx, req)
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemBadRequest))
+	})
+
+	e.Run("TMDb error", func(e exam.E) {
+		cfg := fake.Config()
+		cfg.ApiKey = "wrong"
+		broken := &tmdb.TMDbService{Client: vmtmdb.New(cfg)}
+		_, err := broken.SearchTmdbMovies(ctx, Request{Params: Params{Query: "alien"}})
+		exam.Match(e, env, err, vmtest.HttpError(vmerr.ProblemInternalError))
+	})
+}
@@ -3,16 +3,29 @@ package main
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/krelinga/video-manager-api/go/vmapi"
 	"github.com/krelinga/video-manager/internal/lib/config"
 	"github.com/krelinga/video-manager/internal/lib/migrate"
+	"github.com/krelinga/video-manager/internal/lib/vmbody"
 	"github.com/krelinga/video-manager/internal/lib/vmdb"
+	"github.com/krelinga/video-manager/internal/lib/vmerr"
+	"github.com/krelinga/video-manager/internal/lib/vmlog"
+	"github.com/krelinga/video-manager/internal/lib/vmmetrics"
+	"github.com/krelinga/video-manager/internal/lib/vmready"
 	"github.com/krelinga/video-manager/internal/lib/vmtask"
+	"github.com/krelinga/video-manager/internal/lib/vmtmdb"
+	"github.com/krelinga/video-manager/internal/services/artwork"
 	"github.com/krelinga/video-manager/internal/services/catalog"
+	"github.com/krelinga/video-manager/internal/services/export"
 	"github.com/krelinga/video-manager/internal/services/inbox"
 	"github.com/krelinga/video-manager/internal/services/media"
+	"github.com/krelinga/video-manager/internal/services/task"
 	"github.com/krelinga/video-manager/internal/services/tmdb"
 
 	"golang.org/x/net/http2"
@@ -29,61 +42,185 @@ func main() {
 	// Initialize configuration
 	config := config.New()
 
+	logger, err := vmlog.New(os.Stderr, config.LogFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
+		return
+	}
+	slog.SetDefault(logger)
+
 	// Make sure that all necessary directories exist.
 	if err := config.Paths.Bootstrap(); err != nil {
-		fmt.Printf("Failed to bootstrap paths: %v\n", err)
+		slog.Error("Failed to bootstrap paths", "error", err)
 		return
 	}
 
 	// Create database connection pool.
-	postgresUrl := config.Postgres.URL()
-	fmt.Printf("Connecting to Postgres at %s\n", postgresUrl)
-	db, err := vmdb.New(postgresUrl)
+	slog.Info("Connecting to Postgres", "host", config.Postgres.Host, "port", config.Postgres.Port, "dbname", config.Postgres.DBName)
+	db, err := vmdb.New(config.Postgres.URL())
 	if err != nil {
-		fmt.Printf("Unable to connect to database: %v\n", err)
+		slog.Error("Unable to connect to database", "error", err)
 		return
 	}
 	defer db.Close()
 
 	// Handle any necessary DB migrations.
 	if err := migrate.Up(config.Postgres); err != nil {
-		fmt.Printf("Database migration error: %v\n", err)
+		slog.Error("Database migration error", "error", err)
 		return
 	}
 
 	// Register task handlers.
 	registry := &vmtask.Registry{}
-	registry.MustRegister(media.TaskTypeDvdIngestion, &media.DvdIngestionHandler{
+	for _, kind := range media.Kinds {
+		registry.MustRegister(kind.IngestionTaskType(), &media.IngestionHandler{
+			Kind:  kind,
+			Paths: config.Paths,
+		})
+		registry.MustRegister(kind.DeletionTaskType(), &media.DeletionHandler{
+			Kind:  kind,
+			Paths: config.Paths,
+		})
+	}
+	registry.MustRegister(media.TaskTypeDvdScan, &media.DvdScanHandler{
 		Paths: config.Paths,
 	})
+	registry.MustRegister(catalog.TaskTypeTmdbRefresh, &catalog.TmdbRefreshHandler{
+		Client: vmtmdb.New(config.Tmdb),
+	})
+	registry.MustRegister(artwork.TaskTypeArtworkDownload, &artwork.DownloadHandler{
+		Paths: config.Paths,
+	})
+	registry.MustRegister(export.TaskTypeLibraryExport, &export.ExportHandler{
+		Paths:  config.Paths,
+		Config: *config.Export,
+	})
 
 	// Start task handlers.
-	if err := registry.StartHandlers(context.Background(), *config.Postgres, db, config.WorkerGoroutines); err != nil {
-		fmt.Printf("Failed to start handlers: %v\n", err)
+	handlersCtx, cancelHandlers := context.WithCancel(context.Background())
+	defer cancelHandlers()
+	if err := registry.StartHandlers(handlersCtx, *config.Postgres, db, config.WorkerGoroutines); err != nil {
+		slog.Error("Failed to start handlers", "error", err)
 		return
 	}
 
 	service := &CombinedService{
+		ArtworkService: &artwork.ArtworkService{
+			Db:    db,
+			Paths: config.Paths,
+		},
 		CatalogService: &catalog.CatalogService{
 			Db: db,
 		},
+		ExportService: &export.ExportService{
+			Db:     db,
+			Config: *config.Export,
+		},
 		InboxService: &inbox.InboxService{
 			Paths: config.Paths,
+			Db:    db,
 		},
 		MediaService: &media.MediaService{
+			Db:    db,
+			Paths: config.Paths,
+		},
+		TMDbService: &tmdb.TMDbService{
+			Client: vmtmdb.New(config.Tmdb),
+		},
+		TaskService: &task.TaskService{
 			Db: db,
 		},
-		TMDbService: &tmdb.TMDbService{},
 	}
-	handler := vmapi.NewStrictHandler(service, nil)
-	vmapi.HandlerFromMuxWithBaseURL(handler, mux, "/api/v1")
+	if config.InboxWatch.Enabled {
+		watcher := service.MediaService.NewInboxWatcher(config.InboxWatch)
+		slog.Info("Watching DVD inbox", "dir", watcher.Dir, "quiet_period", watcher.QuietPeriod)
+		go watcher.Run(handlersCtx)
+	}
+
+	middlewares := []vmapi.StrictMiddlewareFunc{
+		vmmetrics.StrictMiddleware,
+		service.CatalogService.StrictMiddleware,
+		service.InboxService.StrictMiddleware,
+		service.MediaService.StrictMiddleware,
+	}
+	handler := vmapi.NewStrictHandlerWithOptions(service, middlewares, vmapi.StrictHTTPServerOptions{
+		RequestErrorHandlerFunc:  vmerr.RequestMiddleware,
+		ResponseErrorHandlerFunc: vmerr.Middleware,
+	})
+	vmapi.HandlerWithOptions(handler, vmapi.StdHTTPServerOptions{
+		BaseURL:          "/api/v1",
+		BaseRouter:       mux,
+		Middlewares:      []vmapi.MiddlewareFunc{vmbody.Middleware},
+		ErrorHandlerFunc: vmerr.RequestMiddleware,
+	})
+	// The task, card search, artwork and export endpoints are not part of
+	// vmapi, so they are routed separately.
+	service.TaskService.RegisterRoutes(mux, "/api/v1")
+	service.CatalogService.RegisterRoutes(mux, "/api/v1")
+	service.ArtworkService.RegisterRoutes(mux, "/api/v1")
+	service.ExportService.RegisterRoutes(mux, "/api/v1")
+
+	// Unlike /health, /ready only succeeds once everything we depend on is usable.
+	readyChecks := map[string]vmready.Check{
+		"database":      vmready.Database(db),
+		"migrations":    vmready.MigrationVersion(db),
+		"task_handlers": vmready.TaskHandlers(registry),
+	}
+	for _, dir := range config.Paths.Dirs() {
+		readyChecks["dir:"+dir] = vmready.Writable(config.Paths.Absolute(dir))
+	}
+	mux.Handle("/ready", &vmready.Handler{Checks: readyChecks})
+
+	vmmetrics.Registry.MustRegister(
+		vmmetrics.NewPoolCollector(db),
+		vmtask.NewCountCollector(db),
+	)
+	mux.Handle("/metrics", vmmetrics.Handler())
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf("0.0.0.0:%d", config.HttpPort),
-		Handler: h2c.NewHandler(mux, &http2.Server{}),
+		Handler: h2c.NewHandler(vmlog.Middleware(mux), &http2.Server{}),
 	}
-	fmt.Printf("Starting server on port %d\n", config.HttpPort)
-	if err := server.ListenAndServe(); err != nil {
-		fmt.Printf("Server error: %v\n", err)
+
+	// Stop gracefully on SIGTERM (e.g. a container restart) or SIGINT.
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", config.HttpPort)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		slog.Error("Server error", "error", err)
+	case <-signalCtx.Done():
+		slog.Info("Shutting down", "timeout", config.ShutdownTimeout)
+	}
+	// A second signal kills the process immediately.
+	stopSignals()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancelShutdown()
+
+	// Stop accepting new requests, and let in-flight ones finish.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown error", "error", err)
+	}
+
+	// Stop the task handlers.  Tasks that were claimed but never started are
+	// released so that other workers can pick them up right away.
+	cancelHandlers()
+	handlersDone := make(chan struct{})
+	go func() {
+		registry.Wait()
+		close(handlersDone)
+	}()
+	select {
+	case <-handlersDone:
+		slog.Info("Shutdown complete")
+	case <-shutdownCtx.Done():
+		slog.Warn("Timed out waiting for task handlers to stop")
 	}
 }